@@ -0,0 +1,103 @@
+// Package agent lets applications in the main container of a task control task protection through
+// an ecstp-sidecar running in the same task, falling back to calling ECS directly when no sidecar
+// is present.
+//
+// The sidecar is discovered via the ECSTP_AGENT_ADDR environment variable, which should be set to
+// the same address on both containers in the task definition, e.g. "127.0.0.1:9477".
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+const (
+	// EnvAddr is the environment variable holding the sidecar's address, as host:port or URL.
+	EnvAddr = "ECSTP_AGENT_ADDR"
+	// EnvToken is the environment variable holding the bearer token for the sidecar, if it
+	// requires one.
+	EnvToken = "ECSTP_AGENT_TOKEN"
+)
+
+// discoveryTimeout bounds the probe of the sidecar's status endpoint.
+const discoveryTimeout = 2 * time.Second
+
+// ErrNoSidecar is returned by Discover when no sidecar is configured or reachable and there is no
+// fallback client.
+var ErrNoSidecar = errors.New("no task protection sidecar found")
+
+// Protector controls the protection of the current task.
+type Protector interface {
+	// Protect enables protection of the task until Unprotect is called.
+	Protect(ctx context.Context) error
+	// Unprotect releases the protection enabled by Protect.
+	Unprotect(ctx context.Context) error
+	// State returns the current protection state of the task.
+	State(ctx context.Context) (ecstp.State, error)
+}
+
+// Discover returns a Protector using the sidecar at ECSTP_AGENT_ADDR if it is set and reachable.
+//
+// Otherwise, a Protector calling ECS directly through fallback is returned, or ErrNoSidecar if
+// fallback is nil.
+func Discover(ctx context.Context, fallback *ecstp.Client) (Protector, error) {
+	if addr, ok := os.LookupEnv(EnvAddr); ok && addr != "" {
+		client := NewClient(addr)
+		client.Token = os.Getenv(EnvToken)
+
+		probeCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+		_, err := client.State(probeCtx)
+		cancel()
+		if err == nil || fallback == nil {
+			return client, err
+		}
+	}
+
+	if fallback == nil {
+		return nil, ErrNoSidecar
+	}
+
+	return Direct(ecstp.NewManager(fallback, nil)), nil
+}
+
+// Direct returns a Protector that updates protection through m, without a sidecar.
+func Direct(m *ecstp.Manager) Protector {
+	return &directProtector{manager: m}
+}
+
+type directProtector struct {
+	manager *ecstp.Manager
+}
+
+func (p *directProtector) Protect(ctx context.Context) error {
+	_, err := p.manager.Protect(ctx, nil)
+	return err
+}
+
+func (p *directProtector) Unprotect(ctx context.Context) error {
+	_, err := p.manager.Unprotect(ctx)
+	return err
+}
+
+func (p *directProtector) State(ctx context.Context) (ecstp.State, error) {
+	return p.manager.State(), nil
+}
+
+// baseURL normalizes addr to a URL without a trailing slash.
+func baseURL(addr string) string {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+
+	return strings.TrimRight(addr, "/")
+}
+
+// defaultHTTPClient is used by Clients without an HTTPClient. Requests are bounded by their
+// context, but a timeout guards against a wedged sidecar.
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
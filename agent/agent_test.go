@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/sidecar"
+)
+
+type testECSClient struct {
+	fail bool
+}
+
+func (c *testECSClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if c.fail {
+		return &ecs.UpdateTaskProtectionOutput{
+			Failures: []types.Failure{{Arn: aws.String(params.Tasks[0]), Reason: aws.String("MISSING")}},
+		}, nil
+	}
+
+	return &ecs.UpdateTaskProtectionOutput{
+		ProtectedTasks: []types.ProtectedTask{{
+			TaskArn:           aws.String(params.Tasks[0]),
+			ProtectionEnabled: params.ProtectionEnabled,
+		}},
+	}, nil
+}
+
+var testMetadata = &ecstp.MetadataBody{
+	Cluster: "test_cluster",
+	TaskARN: "test_arn",
+}
+
+// startSidecar serves a sidecar API for a test task until the test ends.
+func startSidecar(t *testing.T, ecsClient ecstp.ECSClient) (*ecstp.Manager, *httptest.Server) {
+	t.Helper()
+
+	m := ecstp.NewManager(ecstp.NewClient(ecsClient), testMetadata)
+	ts := httptest.NewServer(sidecar.NewServer(m))
+	t.Cleanup(ts.Close)
+
+	return m, ts
+}
+
+func TestDiscover(t *testing.T) {
+	_, sidecarServer := startSidecar(t, &testECSClient{})
+	unreachable := httptest.NewServer(nil)
+	unreachable.Close()
+
+	tests := []struct {
+		name       string
+		addr       string
+		fallback   *ecstp.Client
+		wantClient bool
+		wantDirect bool
+		wantErr    bool
+	}{
+		{
+			name:       "should use the sidecar when it is reachable",
+			addr:       sidecarServer.URL,
+			fallback:   ecstp.NewClient(&testECSClient{}),
+			wantClient: true,
+		},
+		{
+			name:       "should accept host:port addresses",
+			addr:       sidecarServer.Listener.Addr().String(),
+			wantClient: true,
+		},
+		{
+			name:       "should fall back when the sidecar is unreachable",
+			addr:       unreachable.URL,
+			fallback:   ecstp.NewClient(&testECSClient{}),
+			wantDirect: true,
+		},
+		{
+			name:       "should fall back when no sidecar is configured",
+			fallback:   ecstp.NewClient(&testECSClient{}),
+			wantDirect: true,
+		},
+		{
+			name:    "should fail without a sidecar or fallback",
+			wantErr: true,
+		},
+		{
+			name:    "should fail when the sidecar is unreachable without a fallback",
+			addr:    unreachable.URL,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvAddr, tt.addr)
+
+			got, err := Discover(context.Background(), tt.fallback)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			_, isClient := got.(*Client)
+			_, isDirect := got.(*directProtector)
+			assert.Equal(t, tt.wantClient, isClient)
+			assert.Equal(t, tt.wantDirect, isDirect)
+		})
+	}
+}
+
+func TestDirect(t *testing.T) {
+	p := Direct(ecstp.NewManager(ecstp.NewClient(&testECSClient{}), testMetadata))
+
+	require.NoError(t, p.Protect(context.Background()))
+	state, err := p.State(context.Background())
+	require.NoError(t, err)
+	assert.True(t, state.Protected)
+
+	require.NoError(t, p.Unprotect(context.Background()))
+	state, err = p.State(context.Background())
+	require.NoError(t, err)
+	assert.False(t, state.Protected)
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "127.0.0.1:9477", want: "http://127.0.0.1:9477"},
+		{addr: "http://localhost:9477/", want: "http://localhost:9477"},
+		{addr: "https://sidecar", want: "https://sidecar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.want, baseURL(tt.addr))
+		})
+	}
+}
+
+// waitForState waits until p reports the given protection state.
+func waitForState(t *testing.T, p Protector, protected bool) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		state, err := p.State(context.Background())
+		return err == nil && state.Protected == protected
+	}, time.Second, 10*time.Millisecond)
+}
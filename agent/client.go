@@ -0,0 +1,379 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/sidecar"
+)
+
+// Client is a Protector backed by an ecstp-sidecar.
+//
+// Protect acquires a lease on the sidecar and heartbeats it in the background until Unprotect is
+// called, so the task is unprotected by the sidecar if the application dies.
+//
+// Since the lease is heartbeated for as long as the application runs, a missing Unprotect keeps
+// the task protected indefinitely. With LeakThreshold set, a lease held for longer than the
+// threshold without a call to Touch is logged as a possible leak, along with the stack of the
+// Protect call if CaptureStack is set.
+type Client struct {
+	// BaseURL is the sidecar's URL, e.g. "http://127.0.0.1:9477".
+	BaseURL string
+	// Token, if set, is sent as a bearer token.
+	Token string
+	// Name identifies the lease held by this client. Defaults to the hostname.
+	Name string
+	// Labels identify the work holding the lease, e.g. a job ID, and are listed with the lease by
+	// the sidecar.
+	Labels map[string]string
+	// LeaseTTL is the time the sidecar holds the lease without a heartbeat. Defaults to
+	// sidecar.DefaultLeaseTTL.
+	LeaseTTL   time.Duration
+	HTTPClient *http.Client
+	// LeakThreshold is the time a lease may be held without a call to Touch before it is logged
+	// as a possible leak. Zero disables leak detection.
+	LeakThreshold time.Duration
+	// CaptureStack records the stack of each Protect call acquiring a lease, to be logged with
+	// leak warnings. Capturing the stack is relatively expensive.
+	CaptureStack bool
+	Logger       *slog.Logger
+
+	mu sync.Mutex
+	// stopBeats stops the heartbeat of the held lease, which then sends the lease's ID on leaseID.
+	stopBeats context.CancelFunc
+	leaseID   chan string
+
+	// leaseMu guards the held lease and the fields used for leak detection, which the heartbeat
+	// updates while mu is held by Unprotect.
+	leaseMu    sync.Mutex
+	lease      *sidecar.Lease
+	acquiredAt time.Time
+	touchedAt  time.Time
+	stack      []byte
+	leakLogged bool
+}
+
+// SidecarError is returned when the sidecar responds with an unexpected status.
+type SidecarError struct {
+	StatusCode int
+	// Detail is the error reported by the sidecar, if it failed to update protection.
+	Detail *ecstp.ErrorDetail
+	Body   string
+}
+
+func (e *SidecarError) Error() string {
+	if e.Detail != nil {
+		return fmt.Sprintf("sidecar returned %d: %v", e.StatusCode, e.Detail)
+	}
+
+	return fmt.Sprintf("sidecar returned %d: %s", e.StatusCode, e.Body)
+}
+
+// NewClient returns a Client for the sidecar at addr, given as host:port or URL.
+func NewClient(addr string) *Client {
+	return &Client{BaseURL: baseURL(addr)}
+}
+
+// Protect acquires a lease on the sidecar, protecting the task. It does nothing if a lease is
+// already held.
+func (c *Client) Protect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.leaseID != nil {
+		return nil
+	}
+
+	req := c.leaseRequest(ctx)
+	var lease sidecar.Lease
+	err := c.do(ctx, http.MethodPost, "/leases", req, http.StatusCreated, &lease)
+	if err != nil {
+		return err
+	}
+
+	c.leaseMu.Lock()
+	c.lease = &lease
+	c.acquiredAt, c.touchedAt, c.stack, c.leakLogged = time.Now(), time.Now(), nil, false
+	if c.CaptureStack {
+		c.stack = debug.Stack()
+	}
+	c.leaseMu.Unlock()
+
+	beatCtx, cancel := context.WithCancel(context.Background())
+	c.stopBeats = cancel
+	c.leaseID = make(chan string, 1)
+	go c.heartbeat(beatCtx, lease.ID, req, c.leaseID)
+
+	return nil
+}
+
+// Unprotect releases the lease acquired by Protect. The sidecar unprotects the task once no other
+// leases are held.
+func (c *Client) Unprotect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.leaseID == nil {
+		return nil
+	}
+
+	c.stopBeats()
+	id := <-c.leaseID
+	c.leaseID = nil
+	c.leaseMu.Lock()
+	c.lease = nil
+	c.leaseMu.Unlock()
+
+	err := c.do(ctx, http.MethodDelete, "/leases/"+id, nil, http.StatusNoContent, nil)
+	if sidecarErr, ok := err.(*SidecarError); ok && sidecarErr.StatusCode == http.StatusNotFound {
+		// the lease already expired
+		return nil
+	}
+
+	return err
+}
+
+// Lease returns the lease held by the Client, if any.
+func (c *Client) Lease() (sidecar.Lease, bool) {
+	c.leaseMu.Lock()
+	defer c.leaseMu.Unlock()
+
+	if c.lease == nil {
+		return sidecar.Lease{}, false
+	}
+
+	return *c.lease, true
+}
+
+// Do runs fn while holding a lease, acquiring one if none is held and releasing it once fn
+// returns. fn runs with the pprof labels of LeaseLabels, so CPU profiles of long-running protected
+// work can be attributed to it.
+//
+// A Client holds a single lease, so concurrent calls share it and it's released as soon as the
+// call that acquired it returns. Workers running concurrently should use a Client each.
+func (c *Client) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, held := c.Lease(); !held {
+		if err := c.Protect(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			// release the lease even if ctx was canceled
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ecstp.FinalUnprotectTimeout)
+			defer cancel()
+			if unprotectErr := c.Unprotect(releaseCtx); err == nil {
+				err = unprotectErr
+			}
+		}()
+	}
+
+	lease, _ := c.Lease()
+	pprof.Do(ctx, LeaseLabels(lease), func(ctx context.Context) {
+		err = fn(ctx)
+	})
+
+	return err
+}
+
+// LeaseLabels returns the pprof labels identifying work done under lease: ecstp_lease_id,
+// ecstp_lease_name if the lease is named, and the lease's Labels.
+func LeaseLabels(lease sidecar.Lease) pprof.LabelSet {
+	args := []string{"ecstp_lease_id", lease.ID}
+	if lease.Name != "" {
+		args = append(args, "ecstp_lease_name", lease.Name)
+	}
+	for k, v := range lease.Labels {
+		args = append(args, k, v)
+	}
+
+	return pprof.Labels(args...)
+}
+
+// Touch records that the holder of the lease still intends to hold it, deferring leak detection
+// by another LeakThreshold.
+func (c *Client) Touch() {
+	c.leaseMu.Lock()
+	defer c.leaseMu.Unlock()
+
+	c.touchedAt, c.leakLogged = time.Now(), false
+}
+
+// checkLeak logs a warning if the lease has been held for longer than LeakThreshold since it was
+// acquired or touched, once until it is touched again.
+func (c *Client) checkLeak(id string) {
+	c.leaseMu.Lock()
+	defer c.leaseMu.Unlock()
+
+	since := time.Since(c.touchedAt)
+	if c.leakLogged || since < c.LeakThreshold {
+		return
+	}
+	c.leakLogged = true
+
+	attrs := []any{
+		slog.String("lease_id", id),
+		slog.String("name", c.name()),
+		slog.Duration("held", time.Since(c.acquiredAt)),
+		slog.Duration("since_touch", since),
+	}
+	if c.stack != nil {
+		attrs = append(attrs, slog.String("stack", string(c.stack)))
+	}
+	c.logger().Warn("lease held beyond leak threshold, missing Unprotect?", attrs...)
+}
+
+// State returns the protection state reported by the sidecar.
+func (c *Client) State(ctx context.Context) (ecstp.State, error) {
+	var state ecstp.State
+	err := c.do(ctx, http.MethodGet, "/status", nil, http.StatusOK, &state)
+
+	return state, err
+}
+
+// heartbeat extends the lease every third of its TTL until ctx is done, then sends the ID of the
+// held lease on leaseID. A lease lost by the sidecar is re-acquired with req, since the
+// application still expects to be protected.
+func (c *Client) heartbeat(ctx context.Context, id string, req sidecar.LeaseRequest, leaseID chan<- string) {
+	defer func() { leaseID <- id }()
+
+	ticker := time.NewTicker(c.leaseTTL() / 3)
+	defer ticker.Stop()
+
+	var leakCheck <-chan time.Time
+	if c.LeakThreshold > 0 {
+		leakTicker := time.NewTicker(c.LeakThreshold / 4)
+		defer leakTicker.Stop()
+		leakCheck = leakTicker.C
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-leakCheck:
+			c.checkLeak(id)
+			continue
+		case <-ctx.Done():
+			return
+		}
+
+		err := c.do(ctx, http.MethodPut, "/leases/"+id, nil, http.StatusOK, nil)
+		if sidecarErr, ok := err.(*SidecarError); ok && sidecarErr.StatusCode == http.StatusNotFound {
+			var lease sidecar.Lease
+			if err := c.do(ctx, http.MethodPost, "/leases", req, http.StatusCreated, &lease); err == nil {
+				id = lease.ID
+				c.leaseMu.Lock()
+				c.lease = &lease
+				c.leaseMu.Unlock()
+			}
+		}
+	}
+}
+
+// do sends a request to the sidecar and decodes the response into out if it has status want.
+func (c *Client) do(ctx context.Context, method, path string, in any, want int, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != want {
+		sidecarErr := &SidecarError{StatusCode: res.StatusCode, Body: string(bytes.TrimSpace(b))}
+		var detail ecstp.ErrorDetail
+		if json.Unmarshal(b, &detail) == nil && detail.Operation != "" {
+			sidecarErr.Detail = &detail
+		}
+		return sidecarErr
+	}
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+// leaseRequest returns the request acquiring a lease, labeled with the Client's Labels and the
+// correlation labels of ctx, which take precedence.
+func (c *Client) leaseRequest(ctx context.Context) sidecar.LeaseRequest {
+	labels := maps.Clone(c.Labels)
+	if ctxLabels := ecstp.LabelsFromContext(ctx); len(ctxLabels) > 0 {
+		if labels == nil {
+			labels = make(map[string]string, len(ctxLabels))
+		}
+		maps.Copy(labels, ctxLabels)
+	}
+
+	return sidecar.LeaseRequest{
+		Name:       c.name(),
+		Labels:     labels,
+		TTLSeconds: int(c.leaseTTL() / time.Second),
+	}
+}
+
+func (c *Client) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	name, _ := os.Hostname()
+
+	return name
+}
+
+func (c *Client) leaseTTL() time.Duration {
+	if c.LeaseTTL <= 0 {
+		return sidecar.DefaultLeaseTTL
+	}
+
+	return c.LeaseTTL
+}
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+
+	return c.Logger
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return defaultHTTPClient
+	}
+
+	return c.HTTPClient
+}
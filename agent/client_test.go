@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+func TestClient_ProtectUnprotect(t *testing.T) {
+	_, ts := startSidecar(t, &testECSClient{})
+	c := NewClient(ts.URL)
+
+	require.NoError(t, c.Protect(context.Background()))
+	// a second Protect reuses the held lease
+	require.NoError(t, c.Protect(context.Background()))
+	waitForState(t, c, true)
+
+	require.NoError(t, c.Unprotect(context.Background()))
+	waitForState(t, c, false)
+
+	// Unprotect without a lease is a no-op
+	assert.NoError(t, c.Unprotect(context.Background()))
+}
+
+func TestClient_Heartbeat(t *testing.T) {
+	_, ts := startSidecar(t, &testECSClient{})
+	c := NewClient(ts.URL)
+	c.LeaseTTL = 1 * time.Second
+
+	require.NoError(t, c.Protect(context.Background()))
+
+	// the lease outlives its TTL while heartbeated
+	time.Sleep(1500 * time.Millisecond)
+	state, err := c.State(context.Background())
+	require.NoError(t, err)
+	assert.True(t, state.Protected)
+
+	require.NoError(t, c.Unprotect(context.Background()))
+	waitForState(t, c, false)
+}
+
+func TestClient_LeakDetection(t *testing.T) {
+	_, ts := startSidecar(t, &testECSClient{})
+	logs := &syncBuffer{}
+	c := NewClient(ts.URL)
+	c.LeakThreshold = 100 * time.Millisecond
+	c.CaptureStack = true
+	c.Logger = slog.New(slog.NewTextHandler(logs, nil))
+
+	require.NoError(t, c.Protect(context.Background()))
+	defer c.Unprotect(context.Background())
+
+	// touching the lease defers leak detection
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		c.Touch()
+	}
+	assert.Empty(t, logs.String())
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "lease held beyond leak threshold")
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Contains(t, logs.String(), "lease held beyond leak threshold")
+	assert.Contains(t, logs.String(), "TestClient_LeakDetection", "the stack of Protect should be logged")
+	assert.Equal(t, 1, strings.Count(logs.String(), "leak threshold"), "leaks should be logged once")
+}
+
+func TestClient_Do(t *testing.T) {
+	_, ts := startSidecar(t, &testECSClient{})
+	c := NewClient(ts.URL)
+	c.Name = "worker-1"
+	c.Labels = map[string]string{"job": "42"}
+
+	ctx := ecstp.ContextWithLabels(context.Background(), map[string]string{"tenant": "acme"})
+	err := c.Do(ctx, func(ctx context.Context) error {
+		lease, held := c.Lease()
+		require.True(t, held)
+		waitForState(t, c, true)
+
+		id, _ := pprof.Label(ctx, "ecstp_lease_id")
+		assert.Equal(t, lease.ID, id)
+		name, _ := pprof.Label(ctx, "ecstp_lease_name")
+		assert.Equal(t, "worker-1", name)
+		job, _ := pprof.Label(ctx, "job")
+		assert.Equal(t, "42", job)
+		tenant, _ := pprof.Label(ctx, "tenant")
+		assert.Equal(t, "acme", tenant, "correlation labels of the context should be added to the lease")
+
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+
+	_, held := c.Lease()
+	assert.False(t, held, "the lease should be released once fn returns")
+	waitForState(t, c, false)
+}
+
+func TestClient_Errors(t *testing.T) {
+	_, ts := startSidecar(t, &testECSClient{fail: true})
+	c := NewClient(ts.URL)
+
+	err := c.Protect(context.Background())
+
+	var sidecarErr *SidecarError
+	require.True(t, errors.As(err, &sidecarErr))
+	require.NotNil(t, sidecarErr.Detail)
+	assert.Equal(t, "UpdateTaskProtection", sidecarErr.Detail.Operation)
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
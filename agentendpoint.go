@@ -0,0 +1,274 @@
+package ecstp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go"
+)
+
+// EnvAgentURI is the environment variable ECS sets to the URI of the ECS agent endpoint of a task.
+const EnvAgentURI = "ECS_AGENT_URI"
+
+// agentStatePath is the path of the task protection endpoint of the ECS agent.
+const agentStatePath = "/task-protection/v1/state"
+
+// maxAgentResponseSize caps the response of the ECS agent read by an AgentClient.
+const maxAgentResponseSize = 1 << 20
+
+// ErrAgentEndpointUnavailable is wrapped by the errors of an AgentClient that can't serve a call,
+// e.g. because ECS_AGENT_URI isn't set, the agent can't be reached or doesn't provide the task
+// protection endpoint, or the call is for another task.
+var ErrAgentEndpointUnavailable = errors.New("ECS agent task protection endpoint unavailable")
+
+// AgentClient is an ECSClient updating the protection of the task it runs in via the task
+// protection endpoint of the ECS agent, $ECS_AGENT_URI/task-protection/v1/state, instead of the ECS
+// API, so the task role doesn't need ECS API permissions. It also implements TaskProtectionGetter.
+//
+// The endpoint only serves the task it's exposed to, which the AgentClient learns from its first
+// response, reading the protection before its first update if needed. Calls for other tasks fail
+// with an error wrapping ErrAgentEndpointUnavailable. Errors reported by the agent are returned as
+// a smithy.APIError, and the ecs.Options of a call, such as its credentials, don't apply. It's safe
+// for concurrent use.
+type AgentClient struct {
+	// Endpoint is the URI of the ECS agent endpoint. Defaults to ECS_AGENT_URI.
+	Endpoint string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	taskARN string
+}
+
+// agentStateRequest is the body of an update sent to the task protection endpoint.
+type agentStateRequest struct {
+	ProtectionEnabled bool   `json:"ProtectionEnabled"`
+	ExpiresInMinutes  *int32 `json:"ExpiresInMinutes,omitempty"`
+}
+
+// agentStateResponse is the body returned by the task protection endpoint, which holds exactly one
+// of Protection, Failure and Error.
+type agentStateResponse struct {
+	Protection *struct {
+		TaskArn           string     `json:"TaskArn"`
+		ProtectionEnabled bool       `json:"ProtectionEnabled"`
+		ExpirationDate    *time.Time `json:"ExpirationDate"`
+	} `json:"protection"`
+	Failure *struct {
+		Arn    string `json:"Arn"`
+		Reason string `json:"Reason"`
+		Detail string `json:"Detail"`
+	} `json:"failure"`
+	Error *struct {
+		Arn     string `json:"Arn"`
+		Code    string `json:"Code"`
+		Message string `json:"Message"`
+	} `json:"error"`
+}
+
+// UpdateTaskProtection implements ECSClient.
+func (a *AgentClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, _ ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if err := a.checkTasks(ctx, params.Tasks); err != nil {
+		return nil, err
+	}
+
+	state, err := a.do(ctx, http.MethodPut, &agentStateRequest{
+		ProtectionEnabled: params.ProtectionEnabled,
+		ExpiresInMinutes:  params.ExpiresInMinutes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tasks, failures := state.results()
+
+	return &ecs.UpdateTaskProtectionOutput{ProtectedTasks: tasks, Failures: failures}, nil
+}
+
+// GetTaskProtection implements TaskProtectionGetter.
+func (a *AgentClient) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, _ ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	state, err := a.do(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkTasks(ctx, params.Tasks); err != nil {
+		return nil, err
+	}
+	tasks, failures := state.results()
+
+	return &ecs.GetTaskProtectionOutput{ProtectedTasks: tasks, Failures: failures}, nil
+}
+
+// checkTasks returns an error wrapping ErrAgentEndpointUnavailable unless tasks only holds the task
+// of the agent, reading the protection to learn it if no call has yet.
+func (a *AgentClient) checkTasks(ctx context.Context, tasks []string) error {
+	a.mu.Lock()
+	taskARN := a.taskARN
+	a.mu.Unlock()
+
+	if taskARN == "" {
+		if _, err := a.do(ctx, http.MethodGet, nil); err != nil {
+			return err
+		}
+		a.mu.Lock()
+		taskARN = a.taskARN
+		a.mu.Unlock()
+	}
+
+	if len(tasks) != 1 || !sameTask(tasks[0], taskARN) {
+		return fmt.Errorf("%w: tasks %v aren't the task of the agent, %s", ErrAgentEndpointUnavailable, tasks, taskARN)
+	}
+
+	return nil
+}
+
+// do calls the task protection endpoint with body, if not nil, and decodes its response.
+func (a *AgentClient) do(ctx context.Context, method string, body *agentStateRequest) (*agentStateResponse, error) {
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		if endpoint = os.Getenv(EnvAgentURI); endpoint == "" {
+			return nil, fmt.Errorf("%w: %s is not set", ErrAgentEndpointUnavailable, EnvAgentURI)
+		}
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+agentStatePath, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAgentEndpointUnavailable, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := a.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		// the call was abandoned rather than the endpoint being unavailable
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %w", ErrAgentEndpointUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxAgentResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var state agentStateResponse
+	if err := json.Unmarshal(b, &state); err != nil || (state.Protection == nil && state.Failure == nil && state.Error == nil) {
+		// e.g. a 404 from an agent predating the endpoint
+		return nil, fmt.Errorf("%w: unexpected response with status %s", ErrAgentEndpointUnavailable, res.Status)
+	}
+	if state.Error != nil {
+		return nil, &smithy.GenericAPIError{Code: state.Error.Code, Message: state.Error.Message}
+	}
+
+	a.mu.Lock()
+	if state.Protection != nil {
+		a.taskARN = state.Protection.TaskArn
+	} else {
+		a.taskARN = state.Failure.Arn
+	}
+	a.mu.Unlock()
+
+	return &state, nil
+}
+
+// results returns the task protection or failure reported by s in the form of the ECS API.
+func (s *agentStateResponse) results() ([]types.ProtectedTask, []types.Failure) {
+	if s.Failure != nil {
+		return nil, []types.Failure{{
+			Arn:    aws.String(s.Failure.Arn),
+			Reason: aws.String(s.Failure.Reason),
+			Detail: aws.String(s.Failure.Detail),
+		}}
+	}
+
+	return []types.ProtectedTask{{
+		TaskArn:           aws.String(s.Protection.TaskArn),
+		ProtectionEnabled: s.Protection.ProtectionEnabled,
+		ExpirationDate:    s.Protection.ExpirationDate,
+	}}, nil
+}
+
+// agentFallback updates and reads protection via the agent endpoint of a Client created with
+// WithAgentEndpoint, falling back to its ECS client while the endpoint is unavailable.
+type agentFallback struct {
+	c *Client
+}
+
+// UpdateTaskProtection implements ECSClient.
+func (f agentFallback) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	output, err := f.c.agent.UpdateTaskProtection(ctx, params)
+	if !f.fallback(ctx, err) {
+		return output, err
+	}
+
+	return f.c.ECSClient.UpdateTaskProtection(ctx, params, optFns...)
+}
+
+// GetTaskProtection implements TaskProtectionGetter.
+func (f agentFallback) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	output, err := f.c.agent.GetTaskProtection(ctx, params)
+	if !f.fallback(ctx, err) {
+		return output, err
+	}
+	getter, ok := f.c.ECSClient.(TaskProtectionGetter)
+	if !ok {
+		return nil, err
+	}
+
+	return getter.GetTaskProtection(ctx, params, optFns...)
+}
+
+// fallback reports whether a call failing with err should be retried with the ECS client.
+func (f agentFallback) fallback(ctx context.Context, err error) bool {
+	if !errors.Is(err, ErrAgentEndpointUnavailable) || f.c.ECSClient == nil {
+		return false
+	}
+	f.c.log().DebugContext(ctx, "ECS agent endpoint unavailable, falling back to the ECS API", slog.Any("error", err))
+
+	return true
+}
+
+// protectionClient returns the client updating and reading protection: the agent endpoint falling
+// back to the ECS client if the Client was created with WithAgentEndpoint, and the ECS client
+// otherwise or if credentials, which the agent can't use, are set for the call.
+func (c *Client) protectionClient(credentials aws.CredentialsProvider) ECSClient {
+	if c.agent == nil || credentials != nil {
+		return c.ECSClient
+	}
+
+	return agentFallback{c: c}
+}
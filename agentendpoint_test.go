@@ -0,0 +1,259 @@
+package ecstp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const agentTestTaskARN = "arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/0123456789abcdef"
+
+// testAgent emulates the task protection endpoint of the ECS agent.
+type testAgent struct {
+	// status and body, if set, are returned instead of the protection.
+	status int
+	body   string
+
+	mu        sync.Mutex
+	protected bool
+	expiresAt time.Time
+	puts      int
+}
+
+func (a *testAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.status != 0 {
+		w.WriteHeader(a.status)
+		w.Write([]byte(a.body))
+		return
+	}
+	if r.URL.Path != agentStatePath {
+		http.NotFound(w, r)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if r.Method == http.MethodPut {
+		var req agentStateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		a.puts++
+		a.protected = req.ProtectionEnabled
+		a.expiresAt = time.Time{}
+		if req.ProtectionEnabled && req.ExpiresInMinutes != nil {
+			a.expiresAt = time.Date(2024, 1, 1, 0, int(*req.ExpiresInMinutes), 0, 0, time.UTC)
+		}
+	}
+
+	protection := map[string]any{"TaskArn": agentTestTaskARN, "ProtectionEnabled": a.protected}
+	if !a.expiresAt.IsZero() {
+		protection["ExpirationDate"] = a.expiresAt
+	}
+	json.NewEncoder(w).Encode(map[string]any{"protection": protection})
+}
+
+// updates returns the number of updates received.
+func (a *testAgent) updates() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.puts
+}
+
+func TestAgentClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		agent         *testAgent
+		tasks         []string
+		wantProtected bool
+		wantFailure   string
+		wantCode      string
+		wantErr       error
+	}{
+		{
+			name:          "should update the protection of the task of the agent",
+			agent:         &testAgent{},
+			tasks:         []string{agentTestTaskARN},
+			wantProtected: true,
+		},
+		{
+			name:          "should accept the task ID",
+			agent:         &testAgent{},
+			tasks:         []string{"0123456789abcdef"},
+			wantProtected: true,
+		},
+		{
+			name:    "should refuse other tasks",
+			agent:   &testAgent{},
+			tasks:   []string{remoteTestARNPrefix + "other"},
+			wantErr: ErrAgentEndpointUnavailable,
+		},
+		{
+			name: "should report failures",
+			agent: &testAgent{
+				status: http.StatusOK,
+				body:   `{"failure": {"Arn": "` + agentTestTaskARN + `", "Reason": "TASK_NOT_VALID", "Detail": "not running"}}`,
+			},
+			tasks:       []string{agentTestTaskARN},
+			wantFailure: "TASK_NOT_VALID",
+		},
+		{
+			name: "should return the errors of the agent as API errors",
+			agent: &testAgent{
+				status: http.StatusBadRequest,
+				body:   `{"error": {"Arn": "` + agentTestTaskARN + `", "Code": "AccessDeniedException", "Message": "denied"}}`,
+			},
+			tasks:    []string{agentTestTaskARN},
+			wantCode: "AccessDeniedException",
+		},
+		{
+			name:    "should be unavailable on agents without the endpoint",
+			agent:   &testAgent{status: http.StatusNotFound, body: "404 page not found"},
+			tasks:   []string{agentTestTaskARN},
+			wantErr: ErrAgentEndpointUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.agent)
+			defer server.Close()
+			a := &AgentClient{Endpoint: server.URL}
+
+			output, err := a.UpdateTaskProtection(context.Background(), &ecs.UpdateTaskProtectionInput{
+				Cluster:           aws.String("test_cluster"),
+				Tasks:             tt.tasks,
+				ProtectionEnabled: true,
+				ExpiresInMinutes:  aws.Int32(60),
+			})
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Zero(t, tt.agent.updates())
+			case tt.wantCode != "":
+				var apiErr smithy.APIError
+				if assert.ErrorAs(t, err, &apiErr) {
+					assert.Equal(t, tt.wantCode, apiErr.ErrorCode())
+				}
+				assert.NotErrorIs(t, err, ErrAgentEndpointUnavailable)
+			case tt.wantFailure != "":
+				if assert.NoError(t, err) && assert.Len(t, output.Failures, 1) {
+					assert.Equal(t, tt.wantFailure, *output.Failures[0].Reason)
+				}
+			default:
+				if assert.NoError(t, err) && assert.Len(t, output.ProtectedTasks, 1) {
+					task := output.ProtectedTasks[0]
+					assert.Equal(t, agentTestTaskARN, *task.TaskArn)
+					assert.Equal(t, tt.wantProtected, task.ProtectionEnabled)
+					assert.Equal(t, time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC), task.ExpirationDate.UTC())
+				}
+			}
+		})
+	}
+}
+
+func TestAgentClient_Unset(t *testing.T) {
+	t.Setenv(EnvAgentURI, "")
+	a := &AgentClient{}
+
+	_, err := a.GetTaskProtection(context.Background(), &ecs.GetTaskProtectionInput{Tasks: []string{agentTestTaskARN}})
+	assert.ErrorIs(t, err, ErrAgentEndpointUnavailable)
+}
+
+func TestClient_WithAgentEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		agent       *testAgent
+		taskARN     string
+		credentials aws.CredentialsProvider
+		wantAgent   bool
+		wantErr     bool
+	}{
+		{
+			name:      "should protect via the agent endpoint",
+			agent:     &testAgent{},
+			taskARN:   agentTestTaskARN,
+			wantAgent: true,
+		},
+		{
+			name:    "should fall back to the ECS API on agents without the endpoint",
+			agent:   &testAgent{status: http.StatusNotFound},
+			taskARN: agentTestTaskARN,
+		},
+		{
+			name:    "should fall back to the ECS API for other tasks",
+			agent:   &testAgent{},
+			taskARN: remoteTestARNPrefix + "other",
+		},
+		{
+			name:        "should use the ECS API for calls with their own credentials",
+			agent:       &testAgent{},
+			taskARN:     agentTestTaskARN,
+			credentials: aws.AnonymousCredentials{},
+		},
+		{
+			name: "should not fall back on errors of the agent",
+			agent: &testAgent{
+				status: http.StatusInternalServerError,
+				body:   `{"error": {"Code": "ServerException", "Message": "internal error"}}`,
+			},
+			taskARN: agentTestTaskARN,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.agent)
+			defer server.Close()
+			t.Setenv(EnvAgentURI, server.URL)
+
+			ecsClient := &CountingTestClient{}
+			c := NewClient(ecsClient, WithAgentEndpoint())
+			_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+				Metadata:    &MetadataBody{Cluster: "test_cluster", TaskARN: tt.taskARN},
+				Protect:     true,
+				Credentials: tt.credentials,
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Zero(t, ecsClient.protects.Load())
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantAgent, tt.agent.updates() == 1)
+				assert.Equal(t, !tt.wantAgent, ecsClient.protects.Load() == 1)
+			}
+		})
+	}
+}
+
+func TestClient_WithAgentEndpoint_GetTaskProtection(t *testing.T) {
+	server := httptest.NewServer(&testAgent{protected: true})
+	defer server.Close()
+	t.Setenv(EnvAgentURI, server.URL)
+
+	// the ECS client doesn't implement TaskProtectionGetter
+	c := NewClient(&SuccessfulTestClient{}, WithAgentEndpoint())
+	got, err := c.GetTaskProtection(context.Background(), &GetTaskProtectionInput{
+		Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: agentTestTaskARN},
+	})
+	if assert.NoError(t, err) {
+		assert.True(t, got.Protected)
+	}
+
+	_, err = c.GetTaskProtection(context.Background(), &GetTaskProtectionInput{
+		Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "other"},
+	})
+	assert.ErrorIs(t, err, ErrAgentEndpointUnavailable)
+}
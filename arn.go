@@ -0,0 +1,92 @@
+package ecstp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// ErrInvalidTaskARN is returned by ParseTaskARN for strings that aren't ECS task ARNs.
+var ErrInvalidTaskARN = errors.New("invalid task ARN")
+
+// TaskARN is a parsed ECS task ARN, in either the current format including the cluster name
+// (arn:aws:ecs:region:account:task/cluster/id) or the old one without it
+// (arn:aws:ecs:region:account:task/id).
+//
+// Its components can be used for logging, metrics labels or to construct clients for the task's
+// region.
+type TaskARN struct {
+	Partition string
+	Region    string
+	AccountID string
+	// ClusterName is empty for ARNs in the old format.
+	ClusterName string
+	TaskID      string
+}
+
+// ParseTaskARN parses s as an ECS task ARN, returning an error wrapping ErrInvalidTaskARN if it
+// isn't one.
+func ParseTaskARN(s string) (TaskARN, error) {
+	parsed, err := arn.Parse(s)
+	if err != nil {
+		return TaskARN{}, fmt.Errorf("%w %q: %v", ErrInvalidTaskARN, s, err)
+	}
+	if parsed.Service != "ecs" {
+		return TaskARN{}, fmt.Errorf("%w %q: not an ECS ARN", ErrInvalidTaskARN, s)
+	}
+
+	a := TaskARN{
+		Partition: parsed.Partition,
+		Region:    parsed.Region,
+		AccountID: parsed.AccountID,
+	}
+	parts := strings.Split(parsed.Resource, "/")
+	switch {
+	case parts[0] != "task":
+		return TaskARN{}, fmt.Errorf("%w %q: not a task", ErrInvalidTaskARN, s)
+	case len(parts) == 2 && parts[1] != "":
+		a.TaskID = parts[1]
+	case len(parts) == 3 && parts[1] != "" && parts[2] != "":
+		a.ClusterName, a.TaskID = parts[1], parts[2]
+	default:
+		return TaskARN{}, fmt.Errorf("%w %q: malformed resource %q", ErrInvalidTaskARN, s, parsed.Resource)
+	}
+
+	return a, nil
+}
+
+// String returns the ARN.
+func (a TaskARN) String() string {
+	resource := "task/" + a.TaskID
+	if a.ClusterName != "" {
+		resource = "task/" + a.ClusterName + "/" + a.TaskID
+	}
+
+	return a.arn(resource)
+}
+
+// ClusterARN returns the ARN of the cluster named clusterName in the task's account and region.
+// If clusterName is empty, the task's ClusterName is used, and an empty string returned if the
+// ARN doesn't include one.
+func (a TaskARN) ClusterARN(clusterName string) string {
+	if clusterName == "" {
+		clusterName = a.ClusterName
+	}
+	if clusterName == "" {
+		return ""
+	}
+
+	return a.arn("cluster/" + clusterName)
+}
+
+func (a TaskARN) arn(resource string) string {
+	return arn.ARN{
+		Partition: a.Partition,
+		Service:   "ecs",
+		Region:    a.Region,
+		AccountID: a.AccountID,
+		Resource:  resource,
+	}.String()
+}
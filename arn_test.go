@@ -0,0 +1,82 @@
+package ecstp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTaskARN(t *testing.T) {
+	tests := []struct {
+		name    string
+		arn     string
+		want    TaskARN
+		wantErr bool
+	}{
+		{
+			name: "should parse a task ARN",
+			arn:  "arn:aws:ecs:eu-west-2:123456789012:task/prod/0123456789abcdef",
+			want: TaskARN{
+				Partition:   "aws",
+				Region:      "eu-west-2",
+				AccountID:   "123456789012",
+				ClusterName: "prod",
+				TaskID:      "0123456789abcdef",
+			},
+		},
+		{
+			name: "should parse a task ARN in the old format",
+			arn:  "arn:aws-us-gov:ecs:us-gov-west-1:123456789012:task/0123456789abcdef",
+			want: TaskARN{
+				Partition: "aws-us-gov",
+				Region:    "us-gov-west-1",
+				AccountID: "123456789012",
+				TaskID:    "0123456789abcdef",
+			},
+		},
+		{
+			name:    "should reject task IDs",
+			arn:     "0123456789abcdef",
+			wantErr: true,
+		},
+		{
+			name:    "should reject ARNs of other services",
+			arn:     "arn:aws:sqs:eu-west-2:123456789012:task/prod/0123456789abcdef",
+			wantErr: true,
+		},
+		{
+			name:    "should reject ARNs of other resources",
+			arn:     "arn:aws:ecs:eu-west-2:123456789012:cluster/prod",
+			wantErr: true,
+		},
+		{
+			name:    "should reject malformed resources",
+			arn:     "arn:aws:ecs:eu-west-2:123456789012:task/prod/",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTaskARN(tt.arn)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTaskARN)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+				assert.Equal(t, tt.arn, got.String())
+			}
+		})
+	}
+}
+
+func TestTaskARN_ClusterARN(t *testing.T) {
+	a := TaskARN{Partition: "aws", Region: "eu-west-2", AccountID: "123456789012", ClusterName: "prod", TaskID: "abc"}
+
+	assert.Equal(t, "arn:aws:ecs:eu-west-2:123456789012:cluster/prod", a.ClusterARN(""))
+	assert.Equal(t, "arn:aws:ecs:eu-west-2:123456789012:cluster/other", a.ClusterARN("other"))
+
+	a.ClusterName = ""
+	assert.Equal(t, "", a.ClusterARN(""))
+}
@@ -0,0 +1,285 @@
+package ecstp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// AuditRecord describes a single protection transition attempted by a Client.
+//
+// Records only contain the task identifiers and sanitized error details, never credentials or the
+// full task metadata document.
+type AuditRecord struct {
+	Time             time.Time    `json:"time"`
+	Cluster          string       `json:"cluster"`
+	TaskARN          string       `json:"taskArn"`
+	Protect          bool         `json:"protect"`
+	ExpiresInMinutes *int32       `json:"expiresInMinutes,omitempty"`
+	ExpiresAt        *time.Time   `json:"expiresAt,omitempty"`
+	Reason           string       `json:"reason,omitempty"`
+	DryRun           bool         `json:"dryRun,omitempty"`
+	Failure          string       `json:"failure,omitempty"`
+	Error            *ErrorDetail `json:"error,omitempty"`
+	// Labels are the correlation labels of the update, see ContextWithLabels.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Auditor receives an AuditRecord for every protection update attempted by a Client.
+//
+// Audit is called synchronously after the update, so implementations should not block.
+type Auditor interface {
+	Audit(ctx context.Context, record AuditRecord)
+}
+
+// AuditorFunc is an adapter to allow the use of ordinary functions as Auditors.
+type AuditorFunc func(ctx context.Context, record AuditRecord)
+
+// Audit calls f(ctx, record).
+func (f AuditorFunc) Audit(ctx context.Context, record AuditRecord) {
+	f(ctx, record)
+}
+
+func (c *Client) audit(
+	ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput,
+	output *ecs.UpdateTaskProtectionOutput, err error,
+) {
+	if c.auditor == nil {
+		return
+	}
+
+	record := AuditRecord{
+		Time:             time.Now().UTC(),
+		Cluster:          metadata.Cluster,
+		TaskARN:          metadata.TaskARN,
+		Protect:          input.Protect,
+		ExpiresInMinutes: input.ExpiresInMinutes,
+		Reason:           input.Reason,
+		DryRun:           c.isDryRun(ctx),
+		Labels:           input.Labels,
+	}
+	if err != nil {
+		record.Error = NewErrorDetail(OperationUpdateTaskProtection, metadata.TaskARN, err)
+	}
+	if output != nil {
+		for _, task := range output.ProtectedTasks {
+			if aws.ToString(task.TaskArn) == metadata.TaskARN {
+				record.ExpiresAt = task.ExpirationDate
+			}
+		}
+		for _, failure := range output.Failures {
+			if aws.ToString(failure.Arn) == metadata.TaskARN {
+				record.Failure = aws.ToString(failure.Reason)
+			}
+		}
+	}
+
+	c.auditor.Audit(ctx, record)
+}
+
+// AuditBatchWriter writes a batch of audit records to durable storage.
+type AuditBatchWriter interface {
+	WriteBatch(ctx context.Context, records []AuditRecord) error
+}
+
+const (
+	// DefaultAuditBatchSize is the default maximum number of records of an AuditExporter batch.
+	DefaultAuditBatchSize = 100
+	// DefaultAuditFlushInterval is the default time between flushes of an AuditExporter.
+	DefaultAuditFlushInterval = 10 * time.Second
+)
+
+// AuditExporter is an Auditor that buffers records and hands them to an AuditBatchWriter in
+// batches, either when MaxBatchSize records are buffered or every FlushInterval.
+//
+// Start must be called before records are exported and Close flushes any remaining records.
+type AuditExporter struct {
+	Writer AuditBatchWriter
+	// MaxBatchSize defaults to DefaultAuditBatchSize.
+	MaxBatchSize int
+	// FlushInterval defaults to DefaultAuditFlushInterval.
+	FlushInterval time.Duration
+	// OnError is called when a batch can't be written. The batch is dropped, while the records
+	// buffered after it are kept for the next flush.
+	OnError func(err error)
+
+	mu      sync.Mutex
+	records []AuditRecord
+	full    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewAuditExporter returns an AuditExporter writing to w with the default batch size and flush
+// interval.
+func NewAuditExporter(w AuditBatchWriter) *AuditExporter {
+	return &AuditExporter{
+		Writer:        w,
+		MaxBatchSize:  DefaultAuditBatchSize,
+		FlushInterval: DefaultAuditFlushInterval,
+	}
+}
+
+// Start starts the background goroutine flushing batches.
+func (e *AuditExporter) Start() {
+	e.full = make(chan struct{}, 1)
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+
+	go func() {
+		defer close(e.done)
+
+		ticker := time.NewTicker(e.flushInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-e.full:
+			case <-e.stop:
+				return
+			}
+			e.flush(context.Background())
+		}
+	}()
+}
+
+// Audit buffers record for export.
+func (e *AuditExporter) Audit(_ context.Context, record AuditRecord) {
+	e.mu.Lock()
+	e.records = append(e.records, record)
+	full := len(e.records) >= e.maxBatchSize()
+	e.mu.Unlock()
+
+	if full && e.full != nil {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close stops the background goroutine and writes any buffered records.
+func (e *AuditExporter) Close(ctx context.Context) error {
+	if e.stop != nil {
+		close(e.stop)
+		<-e.done
+	}
+
+	return e.flush(ctx)
+}
+
+func (e *AuditExporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	records := e.records
+	e.records = nil
+	e.mu.Unlock()
+
+	for len(records) > 0 {
+		n := min(len(records), e.maxBatchSize())
+		err := e.Writer.WriteBatch(ctx, records[:n])
+		records = records[n:]
+		if err != nil {
+			// the records after the failed batch are written by the next flush, before those
+			// buffered since
+			e.mu.Lock()
+			e.records = append(records, e.records...)
+			e.mu.Unlock()
+			if e.OnError != nil {
+				e.OnError(err)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *AuditExporter) maxBatchSize() int {
+	if e.MaxBatchSize <= 0 {
+		return DefaultAuditBatchSize
+	}
+
+	return e.MaxBatchSize
+}
+
+func (e *AuditExporter) flushInterval() time.Duration {
+	if e.FlushInterval <= 0 {
+		return DefaultAuditFlushInterval
+	}
+
+	return e.FlushInterval
+}
+
+// LogEvent is a single CloudWatch Logs event.
+type LogEvent struct {
+	Timestamp time.Time
+	Message   string
+}
+
+// LogEventsPutter puts events to a CloudWatch Logs stream. It is typically implemented by a thin
+// wrapper around the PutLogEvents call of a CloudWatch Logs client.
+type LogEventsPutter interface {
+	PutLogEvents(ctx context.Context, logGroup, logStream string, events []LogEvent) error
+}
+
+// CloudWatchLogsAuditWriter is an AuditBatchWriter that writes each record as a JSON log event to a
+// CloudWatch Logs stream.
+type CloudWatchLogsAuditWriter struct {
+	Client    LogEventsPutter
+	LogGroup  string
+	LogStream string
+}
+
+// WriteBatch implements AuditBatchWriter.
+func (w *CloudWatchLogsAuditWriter) WriteBatch(ctx context.Context, records []AuditRecord) error {
+	events := make([]LogEvent, len(records))
+	for i, record := range records {
+		b, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		events[i] = LogEvent{
+			Timestamp: record.Time,
+			Message:   string(b),
+		}
+	}
+
+	return w.Client.PutLogEvents(ctx, w.LogGroup, w.LogStream, events)
+}
+
+// ObjectPutter writes an object to S3. It is typically implemented by a thin wrapper around the
+// PutObject call of an S3 client.
+type ObjectPutter interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte) error
+}
+
+// S3AuditWriter is an AuditBatchWriter that writes each batch as a JSON lines object under Prefix
+// in Bucket.
+type S3AuditWriter struct {
+	Client ObjectPutter
+	Bucket string
+	Prefix string
+}
+
+// WriteBatch implements AuditBatchWriter.
+func (w *S3AuditWriter) WriteBatch(ctx context.Context, records []AuditRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	key := fmt.Sprintf("%s%s/%d.jsonl", w.Prefix, now.Format("2006/01/02"), now.UnixNano())
+
+	return w.Client.PutObject(ctx, w.Bucket, key, buf.Bytes())
+}
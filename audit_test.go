@@ -0,0 +1,250 @@
+package ecstp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingAuditor struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (a *recordingAuditor) Audit(_ context.Context, record AuditRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, record)
+}
+
+type recordingBatchWriter struct {
+	mu      sync.Mutex
+	batches [][]AuditRecord
+	err     error
+}
+
+func (w *recordingBatchWriter) WriteBatch(_ context.Context, records []AuditRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, append([]AuditRecord(nil), records...))
+	return w.err
+}
+
+func (w *recordingBatchWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.batches)
+}
+
+func TestClient_UpdateTaskProtection_Audit(t *testing.T) {
+	tests := []struct {
+		name        string
+		ecsClient   ECSClient
+		wantFailure string
+	}{
+		{
+			name:      "should audit a successful update",
+			ecsClient: &SuccessfulTestClient{},
+		},
+		{
+			name:        "should audit a failed update",
+			ecsClient:   &FailureTestClient{},
+			wantFailure: "failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditor := &recordingAuditor{}
+			c := NewClient(tt.ecsClient, WithAuditor(auditor))
+
+			_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+				Metadata:         &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:          true,
+				ExpiresInMinutes: aws.Int32(30),
+				Reason:           "processing job",
+			})
+			if assert.NoError(t, err) && assert.Len(t, auditor.records, 1) {
+				record := auditor.records[0]
+				assert.Equal(t, "test_cluster", record.Cluster)
+				assert.Equal(t, "test_arn", record.TaskARN)
+				assert.True(t, record.Protect)
+				assert.Equal(t, aws.Int32(30), record.ExpiresInMinutes)
+				assert.Equal(t, "processing job", record.Reason)
+				assert.Equal(t, tt.wantFailure, record.Failure)
+			}
+		})
+	}
+}
+
+func TestAuditExporter(t *testing.T) {
+	tests := []struct {
+		name        string
+		records     int
+		batchSize   int
+		wantBatches int
+	}{
+		{
+			name:        "should write a single partial batch on close",
+			records:     3,
+			batchSize:   10,
+			wantBatches: 1,
+		},
+		{
+			name:        "should split records into batches of the maximum size",
+			records:     25,
+			batchSize:   10,
+			wantBatches: 3,
+		},
+		{
+			name:        "should not write empty batches",
+			records:     0,
+			batchSize:   10,
+			wantBatches: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &recordingBatchWriter{}
+			e := NewAuditExporter(w)
+			e.MaxBatchSize = tt.batchSize
+			e.FlushInterval = time.Hour
+			e.Start()
+
+			for i := 0; i < tt.records; i++ {
+				e.Audit(context.Background(), AuditRecord{TaskARN: "test_arn"})
+			}
+
+			if assert.NoError(t, e.Close(context.Background())) {
+				total := 0
+				for _, batch := range w.batches {
+					assert.LessOrEqual(t, len(batch), tt.batchSize)
+					total += len(batch)
+				}
+				assert.Equal(t, tt.records, total)
+				assert.GreaterOrEqual(t, len(w.batches), tt.wantBatches)
+			}
+		})
+	}
+}
+
+func TestAuditExporter_FlushInterval(t *testing.T) {
+	w := &recordingBatchWriter{}
+	e := NewAuditExporter(w)
+	e.FlushInterval = 10 * time.Millisecond
+	e.Start()
+	defer e.Close(context.Background())
+
+	e.Audit(context.Background(), AuditRecord{TaskARN: "test_arn"})
+
+	assert.Eventually(t, func() bool { return w.count() == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestAuditExporter_OnError(t *testing.T) {
+	w := &recordingBatchWriter{err: errors.New("write failed")}
+	e := NewAuditExporter(w)
+
+	var gotErr error
+	e.OnError = func(err error) { gotErr = err }
+	e.Audit(context.Background(), AuditRecord{TaskARN: "test_arn"})
+
+	assert.EqualError(t, e.Close(context.Background()), "write failed")
+	assert.EqualError(t, gotErr, "write failed")
+}
+
+func TestAuditExporter_OnError_KeepsUnsent(t *testing.T) {
+	w := &recordingBatchWriter{err: errors.New("write failed")}
+	e := NewAuditExporter(w)
+	e.MaxBatchSize = 2
+	for i := 0; i < 5; i++ {
+		e.Audit(context.Background(), AuditRecord{TaskARN: fmt.Sprint(i)})
+	}
+
+	assert.Error(t, e.Close(context.Background()))
+	w.err = nil
+	require.NoError(t, e.Close(context.Background()))
+
+	require.Len(t, w.batches, 3)
+	assert.Equal(t, []AuditRecord{{TaskARN: "0"}, {TaskARN: "1"}}, w.batches[0], "the failed batch should be dropped")
+	assert.Equal(t, []AuditRecord{{TaskARN: "2"}, {TaskARN: "3"}}, w.batches[1], "records after the failed batch should be kept")
+	assert.Equal(t, []AuditRecord{{TaskARN: "4"}}, w.batches[2])
+}
+
+func TestAuditExporter_ZeroValue(t *testing.T) {
+	w := &recordingBatchWriter{}
+	e := &AuditExporter{Writer: w}
+	e.Start()
+
+	for i := 0; i < DefaultAuditBatchSize+1; i++ {
+		e.Audit(context.Background(), AuditRecord{TaskARN: "test_arn"})
+	}
+
+	require.NoError(t, e.Close(context.Background()))
+	total := 0
+	for _, batch := range w.batches {
+		assert.LessOrEqual(t, len(batch), DefaultAuditBatchSize)
+		total += len(batch)
+	}
+	assert.Equal(t, DefaultAuditBatchSize+1, total)
+}
+
+type fakeLogEventsPutter struct {
+	group, stream string
+	events        []LogEvent
+}
+
+func (p *fakeLogEventsPutter) PutLogEvents(_ context.Context, group, stream string, events []LogEvent) error {
+	p.group, p.stream, p.events = group, stream, events
+	return nil
+}
+
+type fakeObjectPutter struct {
+	bucket, key string
+	body        []byte
+}
+
+func (p *fakeObjectPutter) PutObject(_ context.Context, bucket, key string, body []byte) error {
+	p.bucket, p.key, p.body = bucket, key, body
+	return nil
+}
+
+func TestCloudWatchLogsAuditWriter_WriteBatch(t *testing.T) {
+	now := time.Now().UTC()
+	p := &fakeLogEventsPutter{}
+	w := &CloudWatchLogsAuditWriter{Client: p, LogGroup: "group", LogStream: "stream"}
+
+	err := w.WriteBatch(context.Background(), []AuditRecord{
+		{Time: now, TaskARN: "a"},
+		{Time: now, TaskARN: "b"},
+	})
+	if assert.NoError(t, err) && assert.Len(t, p.events, 2) {
+		assert.Equal(t, "group", p.group)
+		assert.Equal(t, "stream", p.stream)
+		assert.Equal(t, now, p.events[0].Timestamp)
+
+		var got AuditRecord
+		assert.NoError(t, json.Unmarshal([]byte(p.events[1].Message), &got))
+		assert.Equal(t, "b", got.TaskARN)
+	}
+}
+
+func TestS3AuditWriter_WriteBatch(t *testing.T) {
+	p := &fakeObjectPutter{}
+	w := &S3AuditWriter{Client: p, Bucket: "bucket", Prefix: "audit/"}
+
+	err := w.WriteBatch(context.Background(), []AuditRecord{{TaskARN: "a"}, {TaskARN: "b"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "bucket", p.bucket)
+		assert.True(t, strings.HasPrefix(p.key, "audit/"))
+		assert.True(t, strings.HasSuffix(p.key, ".jsonl"))
+		assert.Len(t, strings.Split(strings.TrimSpace(string(p.body)), "\n"), 2)
+	}
+}
@@ -0,0 +1,99 @@
+package ecstp
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// maxUpdateTasks is the number of tasks UpdateTaskProtection accepts per call.
+const maxUpdateTasks = 10
+
+// pendingUpdate is a task whose update has been prepared but not sent yet.
+type pendingUpdate struct {
+	index    int
+	metadata *MetadataBody
+	input    *UpdateTaskProtectionInput
+}
+
+// updateTasks updates the protection of tasks as UpdateTaskProtection does for each of them given
+// input, whose Metadata is ignored, but sends the updates of tasks of the same cluster together, up
+// to 10 per call. The output of the call of each task is returned along with its error.
+func (c *Client) updateTasks(
+	ctx context.Context, tasks []MetadataBody, input *UpdateTaskProtectionInput,
+) ([]*ecs.UpdateTaskProtectionOutput, []error) {
+	outputs := make([]*ecs.UpdateTaskProtectionOutput, len(tasks))
+	errs := make([]error, len(tasks))
+
+	input, err := resolveExpiresIn(input)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return outputs, errs
+	}
+
+	// tasks are sent together if their prepared updates are the same, e.g. unless a blackout
+	// started while they were prepared
+	type batchKey struct {
+		cluster          string
+		expiresInMinutes int32
+	}
+	var batches [][]pendingUpdate
+	open := map[batchKey]int{}
+	for i := range tasks {
+		metadata := normalizeMetadata(&tasks[i])
+		prepared, err := c.prepareUpdate(ctx, metadata, input)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if c.isDryRun(ctx) {
+			outputs[i] = c.dryRunUpdate(ctx, metadata, prepared)
+			c.audit(ctx, metadata, prepared, outputs[i], nil)
+			continue
+		}
+
+		key := batchKey{cluster: metadata.Cluster, expiresInMinutes: -1}
+		if prepared.ExpiresInMinutes != nil {
+			key.expiresInMinutes = *prepared.ExpiresInMinutes
+		}
+		batch, ok := open[key]
+		if !ok {
+			batch = len(batches)
+			batches = append(batches, nil)
+			open[key] = batch
+		}
+		batches[batch] = append(batches[batch], pendingUpdate{index: i, metadata: metadata, input: prepared})
+		if len(batches[batch]) == maxUpdateTasks {
+			delete(open, key)
+		}
+	}
+
+	for _, batch := range batches {
+		c.sendUpdates(ctx, batch, outputs, errs)
+	}
+
+	return outputs, errs
+}
+
+// sendUpdates makes the UpdateTaskProtection call of batch, recording the output and error of each
+// of its tasks in outputs and errs.
+func (c *Client) sendUpdates(ctx context.Context, batch []pendingUpdate, outputs []*ecs.UpdateTaskProtectionOutput, errs []error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	arns := make([]string, len(batch))
+	for i, update := range batch {
+		arns[i] = update.metadata.TaskARN
+	}
+
+	output, err := c.sendUpdate(ctx, batch[0].metadata.Cluster, arns, batch[0].input)
+	if err == nil {
+		err = NewUpdateResult(output).Verify(arns...)
+	}
+	for _, update := range batch {
+		outputs[update.index] = output
+		errs[update.index] = c.finishUpdate(ctx, update.metadata, update.input, output, err)
+	}
+}
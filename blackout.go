@@ -0,0 +1,169 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// ErrDeploymentBlackout is returned when protection is requested during a blackout and the Client
+// was configured to pause protection. It wraps ErrProtectionNotAllowed and ErrDeploymentBlocking.
+var ErrDeploymentBlackout = fmt.Errorf("%w: %w", ErrProtectionNotAllowed, ErrDeploymentBlocking)
+
+// BlackoutSource reports whether a blackout is in effect, e.g. because a deployment is rolling out
+// and protected tasks would hold it up.
+type BlackoutSource interface {
+	Blackout(ctx context.Context, metadata *MetadataBody) (bool, error)
+}
+
+// BlackoutFunc is an adapter to allow the use of ordinary functions as BlackoutSources.
+type BlackoutFunc func(ctx context.Context, metadata *MetadataBody) (bool, error)
+
+// Blackout calls f(ctx, metadata).
+func (f BlackoutFunc) Blackout(ctx context.Context, metadata *MetadataBody) (bool, error) {
+	return f(ctx, metadata)
+}
+
+// blackout is the blackout policy configured with WithBlackout.
+type blackout struct {
+	source           BlackoutSource
+	expiresInMinutes int32
+}
+
+// applyBlackout consults the blackout source before enabling protection, returning the input to
+// use instead: either input with its expiry capped, or an error wrapping ErrDeploymentBlackout if
+// protection is paused. If the source fails, protection is enabled as requested, unless strict
+// checks were requested with ContextWithOverrides.
+func (c *Client) applyBlackout(ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput) (*UpdateTaskProtectionInput, error) {
+	active, err := c.blackout.source.Blackout(ctx, metadata)
+	if err != nil && isStrict(ctx) {
+		return nil, fmt.Errorf("%w: unable to check for a deployment blackout of task %s: %w",
+			ErrProtectionNotAllowed, metadata.TaskARN, err)
+	}
+	if err != nil {
+		c.log().WarnContext(ctx, "unable to check for a deployment blackout, protecting as requested",
+			slog.String("task_arn", metadata.TaskARN),
+			slog.Any("error", err),
+		)
+		return input, nil
+	}
+	if !active {
+		return input, nil
+	}
+
+	minutes := c.blackout.expiresInMinutes
+	if minutes <= 0 {
+		return nil, fmt.Errorf("%w: task %s", ErrDeploymentBlackout, metadata.TaskARN)
+	}
+
+	requested := int32(DefaultExpiresInMinutes)
+	if input.ExpiresInMinutes != nil {
+		requested = *input.ExpiresInMinutes
+	}
+	if requested <= minutes {
+		return input, nil
+	}
+
+	c.log().InfoContext(ctx, "deployment blackout in effect, shortening protection",
+		slog.String("task_arn", metadata.TaskARN),
+		slog.Int("expires_in_minutes", int(minutes)),
+		labelsAttr(input.Labels),
+	)
+	capped := *input
+	capped.ExpiresInMinutes = &minutes
+
+	return &capped, nil
+}
+
+// ServiceDescriber is implemented by ECS clients that support the DescribeServices API.
+type ServiceDescriber interface {
+	DescribeServices(
+		ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options),
+	) (*ecs.DescribeServicesOutput, error)
+}
+
+// ServiceDeploymentBlackout is a BlackoutSource reporting a blackout while the task's service has
+// a deployment in progress.
+type ServiceDeploymentBlackout struct {
+	Client ServiceDescriber
+	// Service is the name of the service. Defaults to the service that started the task, which
+	// requires Client to implement TaskDescriber.
+	Service string
+
+	mu      sync.Mutex
+	service string
+}
+
+// Blackout implements BlackoutSource.
+func (s *ServiceDeploymentBlackout) Blackout(ctx context.Context, metadata *MetadataBody) (bool, error) {
+	service, err := s.resolveService(ctx, metadata)
+	if err != nil {
+		return false, err
+	}
+
+	out, err := s.Client.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(metadata.Cluster),
+		Services: []string{service},
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(out.Services) == 0 {
+		return false, fmt.Errorf("service %s not found", service)
+	}
+
+	return deploying(out.Services[0].Deployments), nil
+}
+
+// deploying reports whether deployments describe a deployment in progress.
+func deploying(deployments []types.Deployment) bool {
+	if len(deployments) > 1 {
+		return true
+	}
+	for _, deployment := range deployments {
+		if deployment.RolloutState == types.DeploymentRolloutStateInProgress {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *ServiceDeploymentBlackout) resolveService(ctx context.Context, metadata *MetadataBody) (string, error) {
+	if s.Service != "" {
+		return s.Service, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.service != "" {
+		return s.service, nil
+	}
+
+	describer, ok := s.Client.(TaskDescriber)
+	if !ok {
+		return "", errors.New("ECS client does not support DescribeTasks")
+	}
+	out, err := describer.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+		Cluster: aws.String(metadata.Cluster),
+		Tasks:   []string{metadata.TaskARN},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, task := range out.Tasks {
+		if name, ok := strings.CutPrefix(aws.ToString(task.Group), "service:"); ok {
+			s.service = name
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("task %s was not started by a service", metadata.TaskARN)
+}
@@ -0,0 +1,194 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// ExpiryTestClient records the protection period of the last UpdateTaskProtection call.
+type ExpiryTestClient struct {
+	SuccessfulTestClient
+	calls            int
+	expiresInMinutes *int32
+}
+
+func (c *ExpiryTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.calls++
+	c.expiresInMinutes = params.ExpiresInMinutes
+
+	return c.SuccessfulTestClient.UpdateTaskProtection(ctx, params, optFns...)
+}
+
+func TestClient_UpdateTaskProtection_Blackout(t *testing.T) {
+	active := BlackoutFunc(func(context.Context, *MetadataBody) (bool, error) { return true, nil })
+	inactive := BlackoutFunc(func(context.Context, *MetadataBody) (bool, error) { return false, nil })
+	failing := BlackoutFunc(func(context.Context, *MetadataBody) (bool, error) { return false, errors.New("throttled") })
+
+	tests := []struct {
+		name             string
+		source           BlackoutSource
+		blackoutMinutes  int32
+		expiresInMinutes *int32
+		wantMinutes      *int32
+		wantErr          error
+	}{
+		{
+			name:             "should protect as requested outside blackouts",
+			source:           inactive,
+			blackoutMinutes:  5,
+			expiresInMinutes: aws.Int32(60),
+			wantMinutes:      aws.Int32(60),
+		},
+		{
+			name:             "should shorten protection during blackouts",
+			source:           active,
+			blackoutMinutes:  5,
+			expiresInMinutes: aws.Int32(60),
+			wantMinutes:      aws.Int32(5),
+		},
+		{
+			name:            "should shorten the default protection period",
+			source:          active,
+			blackoutMinutes: 5,
+			wantMinutes:     aws.Int32(5),
+		},
+		{
+			name:             "should keep shorter protection periods",
+			source:           active,
+			blackoutMinutes:  5,
+			expiresInMinutes: aws.Int32(2),
+			wantMinutes:      aws.Int32(2),
+		},
+		{
+			name:    "should pause protection during blackouts",
+			source:  active,
+			wantErr: ErrDeploymentBlackout,
+		},
+		{
+			name:             "should protect as requested if the source fails",
+			source:           failing,
+			expiresInMinutes: aws.Int32(60),
+			wantMinutes:      aws.Int32(60),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &ExpiryTestClient{}
+			c := NewClient(ecsClient, WithBlackout(tt.source, tt.blackoutMinutes))
+
+			_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+				Metadata:         &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:          true,
+				ExpiresInMinutes: tt.expiresInMinutes,
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.ErrorIs(t, err, ErrProtectionNotAllowed)
+				assert.Zero(t, ecsClient.calls)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMinutes, ecsClient.expiresInMinutes)
+		})
+	}
+}
+
+func TestClient_UpdateTaskProtection_Blackout_Unprotect(t *testing.T) {
+	active := BlackoutFunc(func(context.Context, *MetadataBody) (bool, error) { return true, nil })
+	ecsClient := &ExpiryTestClient{}
+	c := NewClient(ecsClient, WithBlackout(active, 0))
+
+	_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+		Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+	})
+	assert.NoError(t, err, "disabling protection should not be affected by blackouts")
+	assert.Equal(t, 1, ecsClient.calls)
+}
+
+type ServiceTestClient struct {
+	group       string
+	deployments []types.Deployment
+	err         error
+}
+
+func (c *ServiceTestClient) DescribeServices(
+	ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options),
+) (*ecs.DescribeServicesOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return &ecs.DescribeServicesOutput{
+		Services: []types.Service{{ServiceName: aws.String(params.Services[0]), Deployments: c.deployments}},
+	}, nil
+}
+
+func (c *ServiceTestClient) DescribeTasks(
+	ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options),
+) (*ecs.DescribeTasksOutput, error) {
+	return &ecs.DescribeTasksOutput{
+		Tasks: []types.Task{{TaskArn: aws.String(params.Tasks[0]), Group: aws.String(c.group)}},
+	}, nil
+}
+
+func TestServiceDeploymentBlackout_Blackout(t *testing.T) {
+	completed := types.Deployment{Status: aws.String("PRIMARY"), RolloutState: types.DeploymentRolloutStateCompleted}
+	inProgress := types.Deployment{Status: aws.String("PRIMARY"), RolloutState: types.DeploymentRolloutStateInProgress}
+	active := types.Deployment{Status: aws.String("ACTIVE"), RolloutState: types.DeploymentRolloutStateCompleted}
+
+	tests := []struct {
+		name    string
+		client  *ServiceTestClient
+		service string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "should not report a blackout once the deployment completed",
+			client: &ServiceTestClient{group: "service:web", deployments: []types.Deployment{completed}},
+		},
+		{
+			name:   "should report a blackout while a rollout is in progress",
+			client: &ServiceTestClient{group: "service:web", deployments: []types.Deployment{inProgress}},
+			want:   true,
+		},
+		{
+			name:    "should report a blackout while deployments overlap",
+			client:  &ServiceTestClient{deployments: []types.Deployment{completed, active}},
+			service: "web",
+			want:    true,
+		},
+		{
+			name:    "should return an error for standalone tasks",
+			client:  &ServiceTestClient{group: "family:worker"},
+			wantErr: true,
+		},
+		{
+			name:    "should return DescribeServices errors",
+			client:  &ServiceTestClient{err: errors.New("access denied")},
+			service: "web",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ServiceDeploymentBlackout{Client: tt.client, Service: tt.service}
+
+			got, err := s.Blackout(context.Background(), &MetadataBody{Cluster: "test", TaskARN: "test_arn"})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
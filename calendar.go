@@ -0,0 +1,206 @@
+package ecstp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrOutsideWindow is returned when protection is requested outside the windows allowed by the
+// Client's Calendar. It wraps ErrProtectionNotAllowed.
+var ErrOutsideWindow = fmt.Errorf("%w: outside protection window", ErrProtectionNotAllowed)
+
+// Window is a recurring window of the day, e.g. 22:00 to 06:00 on weekdays.
+type Window struct {
+	// Days are the days the window starts on. Empty means every day.
+	Days []time.Weekday
+	// Start and End are offsets from midnight. The window wraps past midnight if End isn't after
+	// Start.
+	Start time.Duration
+	End   time.Duration
+}
+
+// Period is an explicit period of time.
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Calendar defines when protection may be held. Protection is allowed during any of Windows (or at
+// any time if there are none), except during Blackouts.
+type Calendar struct {
+	// Location is the time zone of Windows. Defaults to UTC.
+	Location  *time.Location
+	Windows   []Window
+	Blackouts []Period
+}
+
+// maxWindowChain is the number of adjoining windows and blackout gaps followed to find the end of
+// an allowed period.
+const maxWindowChain = 16
+
+// Allowed reports whether protection may be held at t and, if so, until when. A zero time means
+// protection is allowed indefinitely.
+func (c *Calendar) Allowed(t time.Time) (bool, time.Time) {
+	allowed, until := c.allowedAt(t)
+	if !allowed {
+		return false, time.Time{}
+	}
+
+	// an allowed period may span adjoining windows, e.g. Mon-Fri 18:00-00:00 and 00:00-08:00
+	for i := 0; i < maxWindowChain && !until.IsZero(); i++ {
+		next, nextUntil := c.allowedAt(until)
+		if !next || !nextUntil.After(until) {
+			break
+		}
+		until = nextUntil
+	}
+
+	return true, until
+}
+
+// allowedAt is like Allowed, but only considers the window containing t.
+func (c *Calendar) allowedAt(t time.Time) (bool, time.Time) {
+	for _, blackout := range c.Blackouts {
+		if !t.Before(blackout.Start) && t.Before(blackout.End) {
+			return false, time.Time{}
+		}
+	}
+
+	var until time.Time
+	if len(c.Windows) > 0 {
+		var ok bool
+		if until, ok = c.windowEnd(t); !ok {
+			return false, time.Time{}
+		}
+	}
+
+	for _, blackout := range c.Blackouts {
+		if blackout.Start.After(t) && (until.IsZero() || blackout.Start.Before(until)) {
+			until = blackout.Start
+		}
+	}
+
+	return true, until
+}
+
+// windowEnd returns the latest end of the windows containing t.
+func (c *Calendar) windowEnd(t time.Time) (time.Time, bool) {
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+
+	var end time.Time
+	found := false
+	// a window that started the previous day may wrap past midnight
+	for _, offset := range []int{-1, 0} {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, loc)
+		for _, w := range c.Windows {
+			if !w.on(day.Weekday()) {
+				continue
+			}
+			start, stop := day.Add(w.Start), day.Add(w.End)
+			if !stop.After(start) {
+				stop = stop.Add(24 * time.Hour)
+			}
+			if !t.Before(start) && t.Before(stop) && stop.After(end) {
+				end, found = stop, true
+			}
+		}
+	}
+
+	return end, found
+}
+
+func (w Window) on(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWindow parses a window of the form "[days] HH:MM-HH:MM", where days is a comma-separated
+// list of days or day ranges, e.g. "Mon-Fri 22:00-06:00" or "Sat,Sun 00:00-24:00". Without days,
+// the window applies every day.
+func ParseWindow(s string) (Window, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Window{}, fmt.Errorf("invalid window %q", s)
+	}
+
+	var w Window
+	if len(fields) == 2 {
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+		}
+		w.Days = days
+	}
+
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", s)
+	}
+	var err error
+	if w.Start, err = parseTimeOfDay(start); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.End, err = parseTimeOfDay(end); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+
+	return w, nil
+}
+
+func parseDays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, ok := weekdays[strings.ToLower(first)]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdays[strings.ToLower(last)]; !ok {
+				return nil, fmt.Errorf("unknown day %q", last)
+			}
+		}
+		for day := from; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == to {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hours, &minutes); err != nil ||
+		hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
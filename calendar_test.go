@@ -0,0 +1,180 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendar_Allowed(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Wednesday
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	weeknights := Window{
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start: 22 * time.Hour,
+		End:   6 * time.Hour,
+	}
+	blackout := Period{Start: day.Add(23 * time.Hour), End: day.Add(24 * time.Hour)}
+
+	tests := []struct {
+		name      string
+		calendar  Calendar
+		at        time.Time
+		want      bool
+		wantUntil time.Time
+	}{
+		{
+			name: "should allow protection indefinitely without windows",
+			at:   day,
+			want: true,
+		},
+		{
+			name:      "should allow protection until the window ends",
+			calendar:  Calendar{Windows: []Window{weeknights}},
+			at:        day.Add(22*time.Hour + 30*time.Minute),
+			want:      true,
+			wantUntil: day.Add(30 * time.Hour),
+		},
+		{
+			name:      "should allow protection in a window that started the previous day",
+			calendar:  Calendar{Windows: []Window{weeknights}},
+			at:        day.Add(2 * time.Hour),
+			want:      true,
+			wantUntil: day.Add(6 * time.Hour),
+		},
+		{
+			name:     "should refuse protection outside windows",
+			calendar: Calendar{Windows: []Window{weeknights}},
+			at:       day.Add(12 * time.Hour),
+		},
+		{
+			name:     "should only start windows on their days",
+			calendar: Calendar{Windows: []Window{weeknights}},
+			// Saturday 23:00
+			at: day.Add(3*24*time.Hour + 23*time.Hour),
+		},
+		{
+			name:      "should end the allowed period at the next blackout",
+			calendar:  Calendar{Windows: []Window{weeknights}, Blackouts: []Period{blackout}},
+			at:        day.Add(22 * time.Hour),
+			want:      true,
+			wantUntil: blackout.Start,
+		},
+		{
+			name:     "should refuse protection during blackouts",
+			calendar: Calendar{Blackouts: []Period{blackout}},
+			at:       blackout.Start,
+		},
+		{
+			name: "should follow adjoining windows",
+			calendar: Calendar{Windows: []Window{
+				{Start: 8 * time.Hour, End: 12 * time.Hour},
+				{Start: 12 * time.Hour, End: 18 * time.Hour},
+			}},
+			at:        day.Add(9 * time.Hour),
+			want:      true,
+			wantUntil: day.Add(18 * time.Hour),
+		},
+		{
+			name:      "should evaluate windows in the calendar's time zone",
+			calendar:  Calendar{Location: newYork, Windows: []Window{{Start: 9 * time.Hour, End: 17 * time.Hour}}},
+			at:        time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC),
+			want:      true,
+			wantUntil: time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, until := tt.calendar.Allowed(tt.at)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, tt.wantUntil.Equal(until), "until = %v, want %v", until, tt.wantUntil)
+		})
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Window
+		wantErr bool
+	}{
+		{
+			name: "should parse a daily window",
+			s:    "09:00-17:30",
+			want: Window{Start: 9 * time.Hour, End: 17*time.Hour + 30*time.Minute},
+		},
+		{
+			name: "should parse day ranges and lists",
+			s:    "Fri-Mon,wed 22:00-24:00",
+			want: Window{
+				Days:  []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday, time.Wednesday},
+				Start: 22 * time.Hour,
+				End:   24 * time.Hour,
+			},
+		},
+		{
+			name:    "should reject unknown days",
+			s:       "Someday 09:00-17:00",
+			wantErr: true,
+		},
+		{
+			name:    "should reject invalid times",
+			s:       "09:00-25:00",
+			wantErr: true,
+		},
+		{
+			name:    "should reject missing ranges",
+			s:       "09:00",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWindow(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestManager_Calendar(t *testing.T) {
+	now := time.Now()
+
+	t.Run("should refuse protection outside windows", func(t *testing.T) {
+		cal := &Calendar{Blackouts: []Period{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}}}
+		m := NewManager(NewClient(&UnreachableTestClient{t: t}, WithCalendar(cal)), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+		events, cancel := m.Subscribe()
+		defer cancel()
+
+		_, err := m.Protect(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrOutsideWindow)
+		assert.ErrorIs(t, err, ErrProtectionNotAllowed)
+		assert.Equal(t, EventProtectionRefused, (<-events).Type)
+	})
+
+	t.Run("should release protection when the window ends", func(t *testing.T) {
+		cal := &Calendar{Blackouts: []Period{{Start: now.Add(50 * time.Millisecond), End: now.Add(time.Hour)}}}
+		m := NewManager(NewClient(&SuccessfulTestClient{}, WithCalendar(cal)), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+		events, cancel := m.Subscribe()
+		defer cancel()
+
+		_, err := m.Protect(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, EventProtected, (<-events).Type)
+
+		assert.Equal(t, EventWindowClosed, (<-events).Type)
+		assert.Equal(t, EventUnprotected, (<-events).Type)
+		assert.False(t, m.State().Protected)
+	})
+}
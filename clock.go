@@ -0,0 +1,38 @@
+package ecstp
+
+import (
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// clockSkewThreshold is the clock skew below which timestamps aren't adjusted, as the Date header
+// of responses only has a resolution of a second and is delayed by network latency.
+const clockSkewThreshold = 2 * time.Second
+
+// ClockSkew returns how far the clock of AWS is ahead of the local clock, as observed from the Date
+// header of the response described by metadata, e.g. the ResultMetadata of an ECS output. It
+// returns false if the response carried no Date header, e.g. for outputs of a dry run.
+func ClockSkew(metadata middleware.Metadata) (time.Duration, bool) {
+	serverTime, ok := awsmiddleware.GetServerTime(metadata)
+	if !ok {
+		return 0, false
+	}
+	responseAt, ok := awsmiddleware.GetResponseAt(metadata)
+	if !ok {
+		return 0, false
+	}
+
+	return serverTime.Sub(responseAt), true
+}
+
+// LocalTime converts t, read from the clock of AWS, to the local clock given the skew between them
+// returned by ClockSkew. Skews of less than a couple of seconds are ignored.
+func LocalTime(t time.Time, skew time.Duration) time.Time {
+	if skew > -clockSkewThreshold && skew < clockSkewThreshold {
+		return t
+	}
+
+	return t.Add(-skew)
+}
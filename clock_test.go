@@ -0,0 +1,95 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalTime(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		skew time.Duration
+		want time.Time
+	}{
+		{
+			name: "should ignore small skews",
+			skew: -time.Second,
+			want: at,
+		},
+		{
+			name: "should adjust for a clock behind AWS",
+			skew: 5 * time.Minute,
+			want: at.Add(-5 * time.Minute),
+		},
+		{
+			name: "should adjust for a clock ahead of AWS",
+			skew: -5 * time.Minute,
+			want: at.Add(5 * time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, LocalTime(at, tt.skew))
+		})
+	}
+}
+
+func TestManager_ClockSkew(t *testing.T) {
+	tests := []struct {
+		name     string
+		skew     time.Duration
+		wantSkew time.Duration
+	}{
+		{
+			name: "should track expiry on a synchronized clock",
+		},
+		{
+			name:     "should track expiry on a clock behind AWS",
+			skew:     10 * time.Minute,
+			wantSkew: 10 * time.Minute,
+		},
+		{
+			name:     "should track expiry on a clock ahead of AWS",
+			skew:     -10 * time.Minute,
+			wantSkew: -10 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// AWS's clock is skewed from the local clock, so are the expiry and Date header
+				serverNow := time.Now().Add(tt.skew)
+				w.Header().Set("Date", serverNow.UTC().Format(http.TimeFormat))
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				fmt.Fprintf(w, `{"protectedTasks":[{"taskArn":"test_arn","protectionEnabled":true,"expirationDate":%d}],"failures":[]}`,
+					serverNow.Add(60*time.Minute).Unix())
+			}))
+			defer server.Close()
+
+			ecsClient := ecs.New(ecs.Options{
+				Region:       "us-east-1",
+				BaseEndpoint: aws.String(server.URL),
+				Credentials:  credentials.NewStaticCredentialsProvider("default", "secret", ""),
+			})
+			m := NewManager(NewClient(ecsClient), &MetadataBody{Cluster: "test", TaskARN: "test_arn"})
+
+			state, err := m.Protect(context.Background(), aws.Int32(60))
+			require.NoError(t, err)
+			require.NotNil(t, state.ExpiresAt)
+			assert.InDelta(t, tt.wantSkew.Seconds(), state.ClockSkew.Seconds(), 2)
+			assert.WithinDuration(t, time.Now().Add(60*time.Minute), *state.ExpiresAt, 3*time.Second)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package ecstp
+
+import "github.com/aws/aws-sdk-go-v2/aws/arn"
+
+// normalizeMetadata returns metadata with its Cluster set to the full ARN of the cluster, derived
+// from the Task ARN when the metadata omits the cluster or only names it. Some platform versions
+// and configurations report a short cluster name or none at all.
+//
+// metadata is returned unchanged if its Cluster is already an ARN or the cluster can't be derived,
+// e.g. from a Task ARN in the old format without the cluster name.
+func normalizeMetadata(metadata *MetadataBody) *MetadataBody {
+	if arn.IsARN(metadata.Cluster) {
+		return metadata
+	}
+	taskARN, err := ParseTaskARN(metadata.TaskARN)
+	if err != nil {
+		return metadata
+	}
+	clusterARN := taskARN.ClusterARN(metadata.Cluster)
+	if clusterARN == "" {
+		return metadata
+	}
+
+	normalized := *metadata
+	normalized.Cluster = clusterARN
+
+	return &normalized
+}
@@ -0,0 +1,66 @@
+package ecstp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeMetadata(t *testing.T) {
+	const (
+		taskARN    = "arn:aws:ecs:eu-west-2:123456789012:task/prod/0123456789abcdef"
+		clusterARN = "arn:aws:ecs:eu-west-2:123456789012:cluster/prod"
+	)
+
+	tests := []struct {
+		name     string
+		metadata MetadataBody
+		want     string
+	}{
+		{
+			name:     "should keep a cluster ARN",
+			metadata: MetadataBody{Cluster: clusterARN, TaskARN: taskARN},
+			want:     clusterARN,
+		},
+		{
+			name:     "should expand a short cluster name",
+			metadata: MetadataBody{Cluster: "prod", TaskARN: taskARN},
+			want:     clusterARN,
+		},
+		{
+			name:     "should derive a missing cluster from the task ARN",
+			metadata: MetadataBody{TaskARN: taskARN},
+			want:     clusterARN,
+		},
+		{
+			name:     "should keep the partition of the task ARN",
+			metadata: MetadataBody{TaskARN: "arn:aws-cn:ecs:cn-north-1:123456789012:task/prod/0123456789abcdef"},
+			want:     "arn:aws-cn:ecs:cn-north-1:123456789012:cluster/prod",
+		},
+		{
+			name:     "should expand a short name with an old format task ARN",
+			metadata: MetadataBody{Cluster: "default", TaskARN: "arn:aws:ecs:eu-west-2:123456789012:task/0123456789abcdef"},
+			want:     "arn:aws:ecs:eu-west-2:123456789012:cluster/default",
+		},
+		{
+			name:     "should leave a missing cluster with an old format task ARN",
+			metadata: MetadataBody{TaskARN: "arn:aws:ecs:eu-west-2:123456789012:task/0123456789abcdef"},
+			want:     "",
+		},
+		{
+			name:     "should leave the cluster of an invalid task ARN",
+			metadata: MetadataBody{Cluster: "prod", TaskARN: "test_arn"},
+			want:     "prod",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := tt.metadata
+			got := normalizeMetadata(&metadata)
+
+			assert.Equal(t, tt.want, got.Cluster)
+			assert.Equal(t, tt.metadata.TaskARN, got.TaskARN)
+			assert.Equal(t, tt.metadata, metadata, "the metadata should not be modified")
+		})
+	}
+}
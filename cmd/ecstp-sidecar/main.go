@@ -0,0 +1,213 @@
+// Command ecstp-sidecar exposes task protection to other processes in an ECS task.
+//
+// Usage:
+//
+//	ecstp-sidecar [-listen addr] [-token-file path] [-drain-timeout 20s]
+//	ecstp-sidecar -stdio
+//
+// By default an HTTP API is served on -listen, a TCP address or the path of a Unix socket prefixed
+// with "unix:", e.g. on a volume shared with the other containers. See sidecar.Server for the
+// endpoints. On SIGTERM, new leases are rejected and held leases are given -drain-timeout to be
+// released before the task is unprotected, see sidecar.Server.Shutdown. With -stdio, JSON-RPC 2.0
+// requests are read from stdin and responses written to stdout, one JSON object per line, so a
+// parent process can drive protection via pipes.
+//
+// The client is created with ecstp.NewDefaultClient and updates protection via the ECS agent where
+// it can, see ecstp.WithAgentEndpoint.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/agent"
+	"github.com/Thumbscrew/ecs-task-protection/ecstpddb"
+	"github.com/Thumbscrew/ecs-task-protection/reconcile"
+	"github.com/Thumbscrew/ecs-task-protection/sidecar"
+)
+
+func main() {
+	stdio := flag.Bool("stdio", false, "serve JSON-RPC over stdin/stdout instead of HTTP")
+	listen := flag.String("listen", defaultListenAddr(), "address to serve the HTTP API on, or unix:/path/to/socket (defaults to $"+agent.EnvAddr+")")
+	drainTimeout := flag.Duration("drain-timeout", 20*time.Second, "time to wait for leases to be released on SIGTERM before the final unprotect")
+	eventsQueueURL := flag.String("events-queue-url", "", "SQS queue receiving ECS task state change events for this task, to reconcile the protection state")
+	registryTable := flag.String("registry-table", "", "DynamoDB table to record the protection state and lease names in")
+	tokenFile := flag.String("token-file", "", "file of bearer tokens accepted by the HTTP API, one per line; reloaded on SIGHUP")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// stdout is reserved for the protocol in stdio mode
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	client, err := ecstp.NewDefaultClient(ctx, ecstp.WithLogger(logger), ecstp.WithAgentEndpoint())
+	if err != nil {
+		logger.Error("unable to create ECS client", slog.Any("error", err))
+		os.Exit(1)
+	}
+	manager := ecstp.NewManager(client, nil)
+
+	var cfg aws.Config
+	if *eventsQueueURL != "" || *registryTable != "" {
+		if cfg, err = awsConfig(ctx, client); err != nil {
+			logger.Error("unable to load AWS config", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	if *eventsQueueURL != "" {
+		listener := &reconcile.Listener{Client: sqs.NewFromConfig(cfg), QueueURL: *eventsQueueURL, Manager: manager, Logger: logger}
+		go listener.Run(ctx)
+	}
+
+	var registry *ecstpddb.Registry
+	if *registryTable != "" {
+		registry = &ecstpddb.Registry{Client: dynamodb.NewFromConfig(cfg), TableName: *registryTable, Manager: manager, Logger: logger}
+	}
+
+	if *stdio {
+		if registry != nil {
+			go registry.Run(ctx)
+		}
+		err = sidecar.ServeJSONRPC(ctx, manager, os.Stdin, os.Stdout)
+	} else {
+		err = serveHTTP(ctx, logger, manager, registry, *listen, *tokenFile, *drainTimeout)
+	}
+	// a stdio session ends with the context, whereas serveHTTP reports a failed shutdown
+	if err != nil && (ctx.Err() == nil || !*stdio) {
+		logger.Error("sidecar failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// awsConfig loads the default AWS configuration for the SQS and DynamoDB clients, in the region of
+// client, which NewDefaultClient resolves from the task metadata if the configuration doesn't set
+// one.
+func awsConfig(ctx context.Context, client *ecstp.Client) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	if ecsClient, ok := client.ECSClient.(*ecs.Client); ok {
+		opts = append(opts, config.WithRegion(ecsClient.Options().Region))
+	}
+
+	return config.LoadDefaultConfig(ctx, opts...)
+}
+
+// defaultListenAddr returns the address advertised to the main container via ECSTP_AGENT_ADDR, so
+// both containers can share the same environment.
+func defaultListenAddr() string {
+	addr := os.Getenv(agent.EnvAddr)
+	if addr == "" {
+		return "127.0.0.1:9477"
+	}
+	if u, err := url.Parse(addr); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return addr
+}
+
+func serveHTTP(
+	ctx context.Context, logger *slog.Logger, manager *ecstp.Manager, registry *ecstpddb.Registry,
+	addr, tokenFile string, drainTimeout time.Duration,
+) error {
+	server := sidecar.NewServer(manager)
+	server.Logger = logger
+
+	if registry != nil {
+		registry.Labels = server.LeaseNames
+		go registry.Run(ctx)
+	}
+
+	if tokenFile != "" {
+		auth, err := sidecar.LoadTokenAuth(tokenFile)
+		if err != nil {
+			return err
+		}
+		server.Auth = auth
+		go reloadOnHangup(ctx, logger, auth)
+	}
+
+	listener, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		// keep serving while draining so clients can release their leases
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout+5*time.Second)
+		defer cancel()
+		report := server.Shutdown(shutdownCtx, drainTimeout)
+		httpServer.Shutdown(shutdownCtx)
+		if report.Error != nil {
+			shutdownErr <- report.Error
+		}
+		close(shutdownErr)
+	}()
+
+	logger.Info("serving sidecar API", slog.String("addr", addr))
+	if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return <-shutdownErr
+}
+
+// listen listens on addr, a TCP address or the path of a Unix socket prefixed with "unix:".
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	// remove the socket left behind by a previous run of the container
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return net.Listen("unix", path)
+}
+
+// reloadOnHangup reloads the auth tokens whenever the process receives SIGHUP.
+func reloadOnHangup(ctx context.Context, logger *slog.Logger, auth *sidecar.TokenAuth) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-hup:
+			if err := auth.Reload(); err != nil {
+				logger.Error("unable to reload auth tokens", slog.Any("error", err))
+			} else {
+				logger.Info("reloaded auth tokens")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/controller"
+)
+
+func runController(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("controller", flag.ContinueOnError)
+	listen := fs.String("listen", "", "address to accept protection requests on over HTTP")
+	queueURL := fs.String("queue-url", "", "SQS queue to consume protection requests from")
+	rate := fs.Float64("rate", controller.DefaultCallsPerSecond, "maximum UpdateTaskProtection calls per second")
+	window := fs.Duration("batch-window", controller.DefaultBatchWindow, "time to wait for requests to batch together")
+	if err := fs.Parse(args); err != nil {
+		return &exitError{code: 2}
+	}
+	if *listen == "" && *queueURL == "" {
+		fs.Usage()
+		return &exitError{code: 2}
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	c := controller.New(ecs.NewFromConfig(cfg))
+	c.CallsPerSecond = *rate
+	c.BatchWindow = *window
+	c.Logger = logger
+	c.Auditor = auditLogger(logger)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var runners []func() error
+	runners = append(runners, func() error { return c.Run(ctx) })
+	if *queueURL != "" {
+		source := &controller.SQSSource{Client: sqs.NewFromConfig(cfg), QueueURL: *queueURL, Logger: logger}
+		runners = append(runners, func() error { return source.Run(ctx, c) })
+	}
+	if *listen != "" {
+		runners = append(runners, func() error { return serveController(ctx, logger, c, *listen) })
+	}
+
+	// the first runner to stop stops the others
+	errs := make(chan error, len(runners))
+	for _, run := range runners {
+		go func(run func() error) { errs <- run() }(run)
+	}
+	var firstErr error
+	for range runners {
+		if err := <-errs; err != nil && firstErr == nil && ctx.Err() == nil {
+			firstErr = err
+		}
+		cancel()
+	}
+
+	return firstErr
+}
+
+// auditLogger returns an Auditor writing audit records to logger.
+func auditLogger(logger *slog.Logger) ecstp.Auditor {
+	return ecstp.AuditorFunc(func(ctx context.Context, record ecstp.AuditRecord) {
+		logger.InfoContext(ctx, "audit", slog.Any("record", record))
+	})
+}
+
+func serveController(ctx context.Context, logger *slog.Logger, c *controller.Controller, addr string) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           c.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("serving controller API", slog.String("addr", addr))
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
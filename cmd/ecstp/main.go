@@ -0,0 +1,107 @@
+// Command ecstp manages ECS task scale-in protection from inside an ECS task.
+//
+// Usage:
+//
+//	ecstp <command> [flags]
+//
+// Commands:
+//
+//	protect     enable protection of the task
+//	unprotect   disable protection of the task
+//	status      show whether the task is protected, and until when
+//	renew       keep the task protected until stopped
+//	preflight   check the IAM permissions required for task protection
+//	controller  apply protection requests for tasks across a fleet
+//	remote      protect, unprotect, inspect or watch tasks from outside them
+//	plan        show the changes a batch protect or unprotect would make
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{name: "protect", summary: "enable protection of the task", run: runProtect},
+	{name: "unprotect", summary: "disable protection of the task", run: runUnprotect},
+	{name: "status", summary: "show whether the task is protected, and until when", run: runStatus},
+	{name: "renew", summary: "keep the task protected until stopped", run: runRenew},
+	{name: "preflight", summary: "check the IAM permissions required for task protection", run: runPreflight},
+	{name: "controller", summary: "apply protection requests for tasks across a fleet", run: runController},
+	{name: "remote", summary: "protect, unprotect, inspect or watch tasks from outside them", run: runRemote},
+	{name: "plan", summary: "show the changes a batch protect or unprotect would make", run: runPlan},
+}
+
+// exitError is returned by commands that have already reported their failure and only need to set
+// the exit code.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		if err := cmd.run(ctx, args[1:]); err != nil {
+			if exitErr, ok := err.(*exitError); ok {
+				return exitErr.code
+			}
+			fmt.Fprintf(os.Stderr, "ecstp %s: %v\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "ecstp: unknown command %q\n\n", args[0])
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: ecstp <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s%s\n", cmd.name, cmd.summary)
+	}
+}
+
+// metadataFromFlags returns the metadata to use for a command, or nil if the current task should
+// be resolved via the metadata endpoint.
+func metadataFromFlags(cluster, taskARN string) *ecstp.MetadataBody {
+	if cluster == "" && taskARN == "" {
+		return nil
+	}
+
+	return &ecstp.MetadataBody{
+		Cluster: cluster,
+		TaskARN: taskARN,
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{
+			name:     "should print usage without a command",
+			wantCode: 2,
+		},
+		{
+			name:     "should reject unknown commands",
+			args:     []string{"shield"},
+			wantCode: 2,
+		},
+		{
+			name:     "should reject unknown protect flags",
+			args:     []string{"protect", "-for", "10"},
+			wantCode: 2,
+		},
+		{
+			name:     "should reject invalid protect flag values",
+			args:     []string{"protect", "-expires", "soon"},
+			wantCode: 2,
+		},
+		{
+			name:     "should reject unknown unprotect flags",
+			args:     []string{"unprotect", "-expires", "10"},
+			wantCode: 2,
+		},
+		{
+			name:     "should reject unknown status flags",
+			args:     []string{"status", "-yaml"},
+			wantCode: 2,
+		},
+		{
+			name:     "should reject a non-positive renewal interval",
+			args:     []string{"renew", "-interval", "0s"},
+			wantCode: 2,
+		},
+		{
+			name:     "should reject a protection period not longer than the renewal interval",
+			args:     []string{"renew", "-interval", "10m", "-expires", "10"},
+			wantCode: 2,
+		},
+		{
+			name:     "should reject unknown preflight flags",
+			args:     []string{"preflight", "-role", "test"},
+			wantCode: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, run(tt.args))
+		})
+	}
+}
+
+func TestParseProtectFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    protectFlags
+		wantErr bool
+	}{
+		{
+			name: "should default to the current task and ECS's protection period",
+		},
+		{
+			name: "should parse the task and protection period",
+			args: []string{"-cluster", "test_cluster", "-task", "test_task", "-expires", "60"},
+			want: protectFlags{
+				metadata:         &ecstp.MetadataBody{Cluster: "test_cluster", TaskARN: "test_task"},
+				expiresInMinutes: aws.Int32(60),
+			},
+		},
+		{
+			name:    "should fail on an invalid protection period",
+			args:    []string{"-expires", "1h"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProtectFlags(tt.args)
+
+			if tt.wantErr {
+				assert.Equal(t, &exitError{code: 2}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseRenewFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    renewFlags
+		wantErr bool
+	}{
+		{
+			name: "should default to a protection period of 3 intervals",
+			args: []string{"-interval", "5m"},
+			want: renewFlags{interval: 5 * time.Minute, expiry: 15 * time.Minute},
+		},
+		{
+			name: "should parse the task, protection period and keep",
+			args: []string{"-task", "test_task", "-expires", "30", "-keep"},
+			want: renewFlags{
+				metadata: &ecstp.MetadataBody{TaskARN: "test_task"},
+				interval: 10 * time.Minute,
+				expiry:   30 * time.Minute,
+				keep:     true,
+			},
+		},
+		{
+			name:    "should fail on a negative interval",
+			args:    []string{"-interval", "-1m"},
+			wantErr: true,
+		},
+		{
+			name:    "should fail on a protection period equal to the interval",
+			args:    []string{"-interval", "10m", "-expires", "10"},
+			wantErr: true,
+		},
+		{
+			name:    "should fail on a protection period shorter than the interval",
+			args:    []string{"-interval", "1h", "-expires", "30"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRenewFlags(tt.args)
+
+			if tt.wantErr {
+				assert.Equal(t, &exitError{code: 2}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTaskFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    *ecstp.MetadataBody
+		wantErr bool
+	}{
+		{
+			name: "should select the current task by default",
+		},
+		{
+			name: "should select a task of the current cluster",
+			args: []string{"-task", "test_task"},
+			want: &ecstp.MetadataBody{TaskARN: "test_task"},
+		},
+		{
+			name: "should select a task of another cluster",
+			args: []string{"-cluster", "test_cluster", "-task", "test_task"},
+			want: &ecstp.MetadataBody{Cluster: "test_cluster", TaskARN: "test_task"},
+		},
+		{
+			name:    "should fail on a missing flag value",
+			args:    []string{"-cluster"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("preflight", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			metadata := taskFlags(fs)
+
+			err := fs.Parse(tt.args)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, metadata())
+		})
+	}
+}
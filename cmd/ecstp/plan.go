@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+const planUsage = `Usage: ecstp plan [flags] protect|unprotect
+
+Shows the changes a batch protect or unprotect would make, without making them unless -apply is
+set. Tasks are selected with -service or -task, as with remote. The planned change of every task is
+written to stdout as a line of JSON, and a summary to stderr.
+
+Flags:
+`
+
+func runPlan(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), planUsage)
+		fs.PrintDefaults()
+	}
+	cluster := fs.String("cluster", "", "cluster of the tasks (required)")
+	service := fs.String("service", "", "select the running tasks of this service")
+	tasks := fs.String("task", "", "comma-separated task IDs or ARNs to select")
+	expiresIn := fs.Int("expires-in", 0, "protection period in minutes for protect (defaults to ECS's default)")
+	apply := fs.Bool("apply", false, "apply the planned changes")
+	if err := fs.Parse(args); err != nil {
+		return &exitError{code: 2}
+	}
+	if fs.NArg() != 1 || (fs.Arg(0) != "protect" && fs.Arg(0) != "unprotect") ||
+		*cluster == "" || (*service == "" && *tasks == "") {
+		fs.Usage()
+		return &exitError{code: 2}
+	}
+
+	client, err := ecstp.NewDefaultClient(ctx)
+	if err != nil {
+		return err
+	}
+	remote := ecstp.NewRemote(client)
+
+	selector := ecstp.TaskSelector{Cluster: *cluster, Service: *service}
+	if *tasks != "" {
+		selector.Tasks = strings.Split(*tasks, ",")
+	}
+	targets, err := remote.Resolve(ctx, selector)
+	if err != nil {
+		return err
+	}
+
+	var minutes *int32
+	if *expiresIn != 0 {
+		minutes = aws.Int32(int32(*expiresIn))
+	}
+	plan, err := remote.Plan(ctx, targets, fs.Arg(0) == "protect", minutes)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, entry := range plan.Entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Plan: %d to change, %d compliant, %d invalid.\n",
+		len(plan.Changes()), len(plan.Compliant()), len(plan.Invalid()))
+	if !*apply {
+		return nil
+	}
+
+	states, err := remote.Apply(ctx, plan)
+	if printErr := printStates(states); printErr != nil {
+		return printErr
+	}
+
+	return err
+}
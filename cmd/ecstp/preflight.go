@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+func runPreflight(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("preflight", flag.ContinueOnError)
+	metadata := taskFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return &exitError{code: 2}
+	}
+
+	client, err := ecstp.NewDefaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	report, err := client.Preflight(ctx, metadata())
+	if err != nil {
+		return err
+	}
+	if err := report.Print(os.Stdout); err != nil {
+		return err
+	}
+	if !report.Passed() {
+		return &exitError{code: 1}
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// taskFlags registers the flags selecting the task of a command, which defaults to the current
+// task as resolved via the metadata endpoint.
+func taskFlags(fs *flag.FlagSet) func() *ecstp.MetadataBody {
+	cluster := fs.String("cluster", "", "cluster of the task (defaults to the current task's cluster)")
+	taskARN := fs.String("task", "", "task ARN (defaults to the current task)")
+
+	return func() *ecstp.MetadataBody {
+		return metadataFromFlags(*cluster, *taskARN)
+	}
+}
+
+// protectFlags are the flags of the protect command.
+type protectFlags struct {
+	metadata         *ecstp.MetadataBody
+	expiresInMinutes *int32
+}
+
+func parseProtectFlags(args []string) (protectFlags, error) {
+	fs := flag.NewFlagSet("protect", flag.ContinueOnError)
+	metadata := taskFlags(fs)
+	expires := fs.Int("expires", 0, "protection period in minutes (defaults to ECS's default of 120)")
+	if err := fs.Parse(args); err != nil {
+		return protectFlags{}, &exitError{code: 2}
+	}
+
+	flags := protectFlags{metadata: metadata()}
+	if *expires > 0 {
+		flags.expiresInMinutes = aws.Int32(int32(*expires))
+	}
+
+	return flags, nil
+}
+
+func runProtect(ctx context.Context, args []string) error {
+	flags, err := parseProtectFlags(args)
+	if err != nil {
+		return err
+	}
+
+	client, err := ecstp.NewDefaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	state, err := ecstp.NewManager(client, flags.metadata).Protect(ctx, flags.expiresInMinutes)
+	if err != nil {
+		return err
+	}
+
+	return printStates([]ecstp.State{state})
+}
+
+func runUnprotect(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("unprotect", flag.ContinueOnError)
+	metadata := taskFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return &exitError{code: 2}
+	}
+
+	client, err := ecstp.NewDefaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	state, err := ecstp.NewManager(client, metadata()).FinalUnprotect(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printStates([]ecstp.State{state})
+}
+
+func runStatus(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	metadata := taskFlags(fs)
+	asJSON := fs.Bool("json", false, "write the status as a line of JSON")
+	if err := fs.Parse(args); err != nil {
+		return &exitError{code: 2}
+	}
+
+	client, err := ecstp.NewDefaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	protection, err := client.GetTaskProtection(ctx, &ecstp.GetTaskProtectionInput{Metadata: metadata()})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(ecstp.State{
+			Protected: protection.Protected,
+			ExpiresAt: protection.ExpiresAt,
+			Cluster:   protection.Cluster,
+			TaskARN:   protection.TaskARN,
+			UpdatedAt: time.Now().UTC(),
+		})
+	}
+
+	fmt.Printf("task:      %s\n", protection.TaskARN)
+	if !protection.Protected || protection.ExpiresAt == nil {
+		fmt.Printf("protected: %t\n", protection.Protected)
+		return nil
+	}
+	fmt.Printf("protected: true, until %s (%s left)\n",
+		protection.ExpiresAt.Local().Format(time.RFC3339), protection.Remaining().Round(time.Second))
+
+	return nil
+}
+
+// renewFlags are the flags of the renew command.
+type renewFlags struct {
+	metadata *ecstp.MetadataBody
+	interval time.Duration
+	expiry   time.Duration
+	keep     bool
+}
+
+func parseRenewFlags(args []string) (renewFlags, error) {
+	fs := flag.NewFlagSet("renew", flag.ContinueOnError)
+	metadata := taskFlags(fs)
+	interval := fs.Duration("interval", 10*time.Minute, "time between renewals")
+	expires := fs.Int("expires", 0, "protection period in minutes set by each renewal (defaults to 3 intervals)")
+	keep := fs.Bool("keep", false, "leave protection enabled when stopped")
+	if err := fs.Parse(args); err != nil {
+		return renewFlags{}, &exitError{code: 2}
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(fs.Output(), "-interval must be positive")
+		fs.Usage()
+		return renewFlags{}, &exitError{code: 2}
+	}
+
+	flags := renewFlags{metadata: metadata(), interval: *interval, expiry: 3 * *interval, keep: *keep}
+	if *expires > 0 {
+		flags.expiry = time.Duration(*expires) * time.Minute
+	}
+	// protection would lapse between renewals otherwise
+	if flags.expiry <= flags.interval {
+		fmt.Fprintln(fs.Output(), "-expires must be longer than -interval")
+		fs.Usage()
+		return renewFlags{}, &exitError{code: 2}
+	}
+
+	return flags, nil
+}
+
+func runRenew(ctx context.Context, args []string) error {
+	flags, err := parseRenewFlags(args)
+	if err != nil {
+		return err
+	}
+
+	client, err := ecstp.NewDefaultClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	manager := ecstp.NewManager(client, flags.metadata)
+	renewer := &ecstp.Renewer{
+		Manager:  manager,
+		Strategy: ecstp.FixedInterval{Interval: flags.interval, Expiry: flags.expiry},
+	}
+
+	// renews until interrupted, e.g. by SIGTERM when the container is stopped
+	err = renewer.Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		return err
+	}
+	if flags.keep {
+		return nil
+	}
+	_, err = manager.ShutdownUnprotect(ctx)
+
+	return err
+}
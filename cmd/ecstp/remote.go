@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+const remoteUsage = `Usage: ecstp remote [flags] protect|unprotect|status|watch|override|restore|export|import
+
+Operates the protection of tasks from outside them, e.g. with local credentials or over ECS Exec.
+Tasks are selected with -service or -task. The state of every task is written to stdout as a line
+of JSON.
+
+override protects the selected tasks (or unprotects them with -unprotect) after saving their
+protection to the -save file, which must not exist, and restore restores the protection saved in
+it. export writes the protection of the selected tasks, or of every task of the cluster, to stdout
+as JSON, and import re-applies an export read from stdin.
+
+Flags:
+`
+
+func runRemote(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("remote", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), remoteUsage)
+		fs.PrintDefaults()
+	}
+	cluster := fs.String("cluster", "", "cluster of the tasks (required)")
+	service := fs.String("service", "", "select the running tasks of this service")
+	tasks := fs.String("task", "", "comma-separated task IDs or ARNs to select")
+	expiresIn := fs.Int("expires-in", 0, "protection period in minutes for protect (defaults to ECS's default)")
+	interval := fs.Duration("interval", 10*time.Second, "polling interval for watch")
+	save := fs.String("save", "", "file saving the protection overridden by override, read by restore")
+	unprotect := fs.Bool("unprotect", false, "unprotect the tasks with override")
+	if err := fs.Parse(args); err != nil {
+		return &exitError{code: 2}
+	}
+	var valid bool
+	switch fs.Arg(0) {
+	case "restore":
+		valid = *save != ""
+	case "import":
+		valid = true
+	case "export":
+		valid = *cluster != ""
+	case "override":
+		valid = *save != "" && *cluster != "" && (*service != "" || *tasks != "")
+	default:
+		valid = *cluster != "" && (*service != "" || *tasks != "")
+	}
+	if fs.NArg() != 1 || !valid {
+		fs.Usage()
+		return &exitError{code: 2}
+	}
+
+	client, err := ecstp.NewDefaultClient(ctx)
+	if err != nil {
+		return err
+	}
+	remote := ecstp.NewRemote(client)
+
+	selector := ecstp.TaskSelector{Cluster: *cluster, Service: *service}
+	if *tasks != "" {
+		selector.Tasks = strings.Split(*tasks, ",")
+	}
+	switch fs.Arg(0) {
+	case "restore":
+		return restoreOverride(ctx, remote, *save)
+	case "import":
+		return importProtection(ctx, remote)
+	case "export":
+		export, err := remote.Export(ctx, selector)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(export)
+	}
+
+	targets, err := remote.Resolve(ctx, selector)
+	if err != nil {
+		return err
+	}
+
+	var states []ecstp.State
+	switch fs.Arg(0) {
+	case "protect":
+		var minutes *int32
+		if *expiresIn > 0 {
+			minutes = aws.Int32(int32(*expiresIn))
+		}
+		states, err = remote.Protect(ctx, targets, minutes)
+	case "unprotect":
+		states, err = remote.Unprotect(ctx, targets)
+	case "status":
+		states, err = remote.Status(ctx, targets)
+	case "override":
+		var minutes *int32
+		if *expiresIn > 0 {
+			minutes = aws.Int32(int32(*expiresIn))
+		}
+		// create the file first, so that an override is never applied without being saved
+		f, createErr := os.OpenFile(*save, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if createErr != nil {
+			return createErr
+		}
+		var override ecstp.FleetOverride
+		override, states, err = remote.ApplyOverride(ctx, targets, !*unprotect, minutes)
+		if saveErr := saveOverride(f, override); saveErr != nil {
+			return errors.Join(saveErr, err)
+		}
+	case "watch":
+		err = remote.Watch(ctx, targets, *interval, printStates)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	default:
+		fs.Usage()
+		return &exitError{code: 2}
+	}
+
+	if printErr := printStates(states); printErr != nil {
+		return printErr
+	}
+
+	return err
+}
+
+// saveOverride writes override to f as JSON and closes it.
+func saveOverride(f *os.File, override ecstp.FleetOverride) error {
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+
+	return errors.Join(enc.Encode(override), f.Close())
+}
+
+// restoreOverride restores the protection saved to path by override.
+func restoreOverride(ctx context.Context, remote *ecstp.Remote, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var override ecstp.FleetOverride
+	if err := json.Unmarshal(data, &override); err != nil {
+		return fmt.Errorf("invalid override in %s: %w", path, err)
+	}
+
+	states, err := remote.RestoreOverride(ctx, override)
+	if printErr := printStates(states); printErr != nil {
+		return printErr
+	}
+
+	return err
+}
+
+// importProtection re-applies the protection exported by export, read from stdin.
+func importProtection(ctx context.Context, remote *ecstp.Remote) error {
+	var export ecstp.ProtectionExport
+	if err := json.NewDecoder(os.Stdin).Decode(&export); err != nil {
+		return fmt.Errorf("invalid export: %w", err)
+	}
+
+	states, err := remote.Import(ctx, export)
+	if printErr := printStates(states); printErr != nil {
+		return printErr
+	}
+
+	return err
+}
+
+// printStates writes states to stdout as JSON lines.
+func printStates(states []ecstp.State) error {
+	enc := json.NewEncoder(os.Stdout)
+	for _, state := range states {
+		if err := enc.Encode(state); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
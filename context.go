@@ -0,0 +1,86 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrProtectionLost is the cause of a context returned by Manager.ShutdownContext being canceled
+// because the task is no longer protected.
+var ErrProtectionLost = errors.New("task protection lost")
+
+// ShutdownContext returns a context derived from parent that is canceled once the task is no
+// longer protected: when protection is disabled or expires, ECS reports the task stopping, or an
+// update through the Manager fails. The cause, as returned by context.Cause, wraps
+// ErrProtectionLost and describes what happened.
+//
+// Long-running work can select on the context to checkpoint or abort the moment it may be
+// interrupted by scale-in. If the task isn't protected when ShutdownContext is called, the context
+// is canceled immediately. The CancelFunc releases the resources of the context and should be
+// called once the work is done.
+func (m *Manager) ShutdownContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	events, unsubscribe := m.Subscribe()
+
+	if cause := protectionLost(m.State(), time.Now()); cause != nil {
+		unsubscribe()
+		cancel(cause)
+		return ctx, func() { cancel(context.Canceled) }
+	}
+
+	go func() {
+		defer unsubscribe()
+
+		expiry := time.NewTimer(time.Until(expiresAt(m.State())))
+		defer expiry.Stop()
+		for {
+			var event Event
+			select {
+			case event = <-events:
+			case <-expiry.C:
+			case <-ctx.Done():
+				return
+			}
+
+			state := m.State()
+			if event.Type == EventUpdateFailed {
+				cancel(fmt.Errorf("%w: %v", ErrProtectionLost, event.State.LastError))
+				return
+			}
+			if cause := protectionLost(state, time.Now()); cause != nil {
+				cancel(cause)
+				return
+			}
+			expiry.Reset(time.Until(expiresAt(state)))
+		}
+	}()
+
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// protectionLost returns the cause of a shutdown context's cancelation if state doesn't describe
+// protection in effect at now, or nil if it does.
+func protectionLost(state State, now time.Time) error {
+	switch {
+	case state.Stopping:
+		return fmt.Errorf("%w: task stopping: %s", ErrProtectionLost, state.StopReason)
+	case !state.Protected:
+		return fmt.Errorf("%w: task not protected", ErrProtectionLost)
+	case !protectedAt(state, now):
+		return fmt.Errorf("%w: protection expired at %s", ErrProtectionLost, state.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// expiresAt returns when the protection described by state expires, or a time far in the future if
+// it doesn't.
+func expiresAt(state State) time.Time {
+	if state.ExpiresAt == nil {
+		return time.Now().Add(24 * time.Hour)
+	}
+
+	return *state.ExpiresAt
+}
@@ -0,0 +1,128 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ExpiringTestClient reports protection expiring after expiry, or fails once fail is set.
+type ExpiringTestClient struct {
+	SuccessfulTestClient
+	expiry time.Duration
+	fail   atomic.Bool
+}
+
+func (c *ExpiringTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if c.fail.Load() {
+		return nil, errors.New("throttled")
+	}
+
+	output, err := c.SuccessfulTestClient.UpdateTaskProtection(ctx, params, optFns...)
+	if c.expiry > 0 && params.ProtectionEnabled {
+		for i := range output.ProtectedTasks {
+			output.ProtectedTasks[i].ExpirationDate = aws.Time(time.Now().Add(c.expiry))
+		}
+	}
+
+	return output, err
+}
+
+func TestManager_ShutdownContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiry    time.Duration
+		lose      func(m *Manager, c *ExpiringTestClient)
+		wantCause string
+	}{
+		{
+			name: "should cancel once protection is disabled",
+			lose: func(m *Manager, _ *ExpiringTestClient) {
+				m.Unprotect(context.Background())
+			},
+			wantCause: "task not protected",
+		},
+		{
+			name:      "should cancel once protection expires",
+			expiry:    50 * time.Millisecond,
+			lose:      func(*Manager, *ExpiringTestClient) {},
+			wantCause: "protection expired",
+		},
+		{
+			name: "should cancel once the task is stopping",
+			lose: func(m *Manager, _ *ExpiringTestClient) {
+				m.MarkStopping("Scaling activity initiated")
+			},
+			wantCause: "task stopping: Scaling activity initiated",
+		},
+		{
+			name: "should cancel once an update fails",
+			lose: func(m *Manager, c *ExpiringTestClient) {
+				c.fail.Store(true)
+				m.Protect(context.Background(), nil)
+			},
+			wantCause: "throttled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &ExpiringTestClient{expiry: tt.expiry}
+			m := NewManager(NewClient(ecsClient), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+			_, err := m.Protect(context.Background(), nil)
+			require.NoError(t, err)
+
+			ctx, cancel := m.ShutdownContext(context.Background())
+			defer cancel()
+			assert.NoError(t, ctx.Err())
+
+			tt.lose(m, ecsClient)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+				t.Fatal("context wasn't canceled")
+			}
+			cause := context.Cause(ctx)
+			assert.ErrorIs(t, cause, ErrProtectionLost)
+			assert.ErrorContains(t, cause, tt.wantCause)
+		})
+	}
+}
+
+func TestManager_ShutdownContext_Unprotected(t *testing.T) {
+	m := NewManager(NewClient(&UnreachableTestClient{t: t}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+	ctx, cancel := m.ShutdownContext(context.Background())
+	defer cancel()
+
+	assert.Error(t, ctx.Err())
+	assert.ErrorIs(t, context.Cause(ctx), ErrProtectionLost)
+}
+
+func TestManager_ShutdownContext_Renewed(t *testing.T) {
+	m := NewManager(NewClient(&ExpiringTestClient{expiry: 50 * time.Millisecond}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := m.ShutdownContext(context.Background())
+	defer cancel()
+
+	// renewing protection keeps the context alive past the original expiry
+	for i := 0; i < 4; i++ {
+		time.Sleep(25 * time.Millisecond)
+		_, err := m.Protect(context.Background(), nil)
+		require.NoError(t, err)
+	}
+	assert.NoError(t, ctx.Err())
+
+	cancel()
+	assert.ErrorIs(t, context.Cause(ctx), context.Canceled)
+}
@@ -0,0 +1,328 @@
+// Package controller provides a central service that applies task protection requests for tasks
+// across a fleet, rather than from inside each task.
+//
+// Requests are received from an HTTP endpoint or an SQS queue (optionally fed by EventBridge),
+// grouped into batched UpdateTaskProtection calls, rate limited, and audited per task.
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+const (
+	// MaxBatchSize is the maximum number of tasks ECS accepts in a single UpdateTaskProtection call.
+	MaxBatchSize = 10
+	// DefaultBatchWindow is the default time a request waits for others to batch with.
+	DefaultBatchWindow = 100 * time.Millisecond
+	// DefaultCallsPerSecond is the default rate of UpdateTaskProtection calls.
+	DefaultCallsPerSecond = 5
+)
+
+// ErrInvalidRequest is returned for requests that can never succeed, e.g. with a missing task ARN.
+var ErrInvalidRequest = errors.New("invalid protection request")
+
+// Request is a request to change the protection of a single task.
+type Request struct {
+	Cluster          string `json:"cluster"`
+	TaskARN          string `json:"taskArn"`
+	Protect          bool   `json:"protect"`
+	ExpiresInMinutes *int32 `json:"expiresInMinutes,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+}
+
+// Validate reports whether r is a request ECS could accept.
+func (r Request) Validate() error {
+	switch {
+	case r.Cluster == "":
+		return fmt.Errorf("%w: cluster is required", ErrInvalidRequest)
+	case r.TaskARN == "":
+		return fmt.Errorf("%w: taskArn is required", ErrInvalidRequest)
+	case r.ExpiresInMinutes != nil && (*r.ExpiresInMinutes < 1 || *r.ExpiresInMinutes > ecstp.MaxExpiresInMinutes):
+		return fmt.Errorf("%w: expiresInMinutes must be between 1 and %d", ErrInvalidRequest, ecstp.MaxExpiresInMinutes)
+	case strings.HasPrefix(r.TaskARN, "arn:"):
+		// tasks may also be identified by ID
+		if _, err := ecstp.ParseTaskARN(r.TaskARN); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+
+	return nil
+}
+
+// Result is the outcome of a Request.
+type Result struct {
+	Request
+	ExpiresAt *time.Time         `json:"expiresAt,omitempty"`
+	Error     *ecstp.ErrorDetail `json:"error,omitempty"`
+	err       error
+}
+
+// Err returns the error applying the request, or nil if it succeeded.
+func (r Result) Err() error {
+	return r.err
+}
+
+// Controller applies protection requests for arbitrary tasks.
+//
+// Requests submitted within BatchWindow of each other for the same cluster, protection state and
+// expiry are sent in a single UpdateTaskProtection call. Run must be called to process requests.
+type Controller struct {
+	// BatchWindow is the time the first request of a batch waits for others. Defaults to
+	// DefaultBatchWindow.
+	BatchWindow time.Duration
+	// CallsPerSecond limits the rate of UpdateTaskProtection calls. Defaults to
+	// DefaultCallsPerSecond.
+	CallsPerSecond float64
+	// Auditor, if set, receives a record for every request applied.
+	Auditor ecstp.Auditor
+	Logger  *slog.Logger
+
+	ecs      ecstp.ECSClient
+	requests chan *pending
+}
+
+type pending struct {
+	req    Request
+	result chan Result
+}
+
+// batchKey groups requests that can share an UpdateTaskProtection call.
+type batchKey struct {
+	cluster string
+	protect bool
+	expires int32
+}
+
+// New returns a Controller that updates task protection using ecsClient.
+func New(ecsClient ecstp.ECSClient) *Controller {
+	return &Controller{
+		BatchWindow:    DefaultBatchWindow,
+		CallsPerSecond: DefaultCallsPerSecond,
+		ecs:            ecsClient,
+		requests:       make(chan *pending),
+	}
+}
+
+// Submit queues req and waits until it has been applied or ctx is done.
+//
+// The returned error wraps ErrInvalidRequest, an *ecstp.ProtectionFailureError if ECS reported a
+// failure for the task or left it out of the response, an *ecstp.TaskARNMismatchError
+// if ECS reported the outcome of tasks outside the batch, or the error from ECS.
+func (c *Controller) Submit(ctx context.Context, req Request) (Result, error) {
+	if err := req.Validate(); err != nil {
+		return failed(req, err), err
+	}
+
+	p := &pending{req: req, result: make(chan Result, 1)}
+	select {
+	case c.requests <- p:
+	case <-ctx.Done():
+		return failed(req, ctx.Err()), ctx.Err()
+	}
+
+	select {
+	case res := <-p.result:
+		return res, res.err
+	case <-ctx.Done():
+		return failed(req, ctx.Err()), ctx.Err()
+	}
+}
+
+// Run processes submitted requests until ctx is done.
+func (c *Controller) Run(ctx context.Context) error {
+	var (
+		wg       sync.WaitGroup
+		batches  = make(chan []*pending)
+		interval = time.Duration(float64(time.Second) / c.callsPerSecond())
+	)
+	defer wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var next time.Time
+		for batch := range batches {
+			if wait := time.Until(next); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+				}
+			}
+			next = time.Now().Add(interval)
+			c.apply(ctx, batch)
+		}
+	}()
+	defer close(batches)
+
+	for {
+		var first *pending
+		select {
+		case first = <-c.requests:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		groups := map[batchKey][]*pending{}
+		var order []batchKey
+		add := func(p *pending) {
+			key := keyOf(p.req)
+			if _, ok := groups[key]; !ok {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], p)
+		}
+		add(first)
+
+		window := time.NewTimer(c.batchWindow())
+	collect:
+		for {
+			select {
+			case p := <-c.requests:
+				add(p)
+			case <-window.C:
+				break collect
+			case <-ctx.Done():
+				window.Stop()
+				break collect
+			}
+		}
+
+		for _, key := range order {
+			for group := groups[key]; len(group) > 0; {
+				n := min(len(group), MaxBatchSize)
+				batches <- group[:n]
+				group = group[n:]
+			}
+		}
+	}
+}
+
+// apply sends a single UpdateTaskProtection call for batch and delivers the per-task results.
+func (c *Controller) apply(ctx context.Context, batch []*pending) {
+	first := batch[0].req
+	var expiresInMinutes *int32
+	if first.Protect {
+		expiresInMinutes = first.ExpiresInMinutes
+	}
+	tasks := make([]string, 0, len(batch))
+	for _, p := range batch {
+		tasks = append(tasks, p.req.TaskARN)
+	}
+
+	output, err := c.ecs.UpdateTaskProtection(ctx, &ecs.UpdateTaskProtectionInput{
+		Cluster:           aws.String(first.Cluster),
+		Tasks:             tasks,
+		ProtectionEnabled: first.Protect,
+		ExpiresInMinutes:  expiresInMinutes,
+	})
+	if err == nil {
+		// unexpected tasks in the response mean none of its results can be trusted
+		err = ecstp.NewUpdateResult(output).Verify(tasks...)
+	}
+	if err != nil {
+		c.logger().ErrorContext(ctx, "task protection update failed",
+			slog.String("cluster", first.Cluster),
+			slog.Int("tasks", len(tasks)),
+			slog.Any("error", ecstp.NewErrorDetail(ecstp.OperationUpdateTaskProtection, "", err)),
+		)
+	}
+
+	updates := ecstp.NewUpdateResult(output)
+	for _, p := range batch {
+		res := result(p.req, updates, err)
+		c.audit(ctx, res)
+		p.result <- res
+	}
+}
+
+// result returns the outcome of req within a batch call that returned the per-task results updates
+// and err.
+func result(req Request, updates ecstp.Result, err error) Result {
+	if err == nil {
+		err = updates.Failure(req.TaskARN)
+	}
+	if err != nil {
+		return failed(req, err)
+	}
+
+	// requests may identify their task by ID
+	task, _ := updates.Task(req.TaskARN)
+
+	return Result{Request: req, ExpiresAt: task.ExpiresAt}
+}
+
+// failed returns the Result of req failing with err.
+func failed(req Request, err error) Result {
+	return Result{
+		Request: req,
+		Error:   ecstp.NewErrorDetail(ecstp.OperationUpdateTaskProtection, req.TaskARN, err),
+		err:     err,
+	}
+}
+
+func (c *Controller) audit(ctx context.Context, res Result) {
+	if c.Auditor == nil {
+		return
+	}
+
+	record := ecstp.AuditRecord{
+		Time:             time.Now().UTC(),
+		Cluster:          res.Cluster,
+		TaskARN:          res.TaskARN,
+		Protect:          res.Protect,
+		ExpiresInMinutes: res.ExpiresInMinutes,
+		ExpiresAt:        res.ExpiresAt,
+		Reason:           res.Reason,
+		Error:            res.Error,
+	}
+	var failure *ecstp.ProtectionFailureError
+	if errors.As(res.err, &failure) {
+		record.Failure = failure.Reason
+	}
+
+	c.Auditor.Audit(ctx, record)
+}
+
+func keyOf(req Request) batchKey {
+	key := batchKey{cluster: req.Cluster, protect: req.Protect}
+	if req.Protect && req.ExpiresInMinutes != nil {
+		key.expires = *req.ExpiresInMinutes
+	}
+
+	return key
+}
+
+func (c *Controller) batchWindow() time.Duration {
+	if c.BatchWindow <= 0 {
+		return DefaultBatchWindow
+	}
+
+	return c.BatchWindow
+}
+
+func (c *Controller) callsPerSecond() float64 {
+	if c.CallsPerSecond <= 0 {
+		return DefaultCallsPerSecond
+	}
+
+	return c.CallsPerSecond
+}
+
+func (c *Controller) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+
+	return c.Logger
+}
@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// testECSClient records UpdateTaskProtection calls, failing any task listed in failTasks and
+// reporting any task listed in extraTasks alongside those requested.
+type testECSClient struct {
+	err        error
+	failTasks  map[string]bool
+	extraTasks []string
+	// arnPrefix, if set, is prepended to the task IDs of the response, which reports an expiry
+	arnPrefix string
+
+	mu    sync.Mutex
+	calls []*ecs.UpdateTaskProtectionInput
+}
+
+func (c *testECSClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, params)
+	c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	output := &ecs.UpdateTaskProtectionOutput{}
+	for _, task := range append(params.Tasks, c.extraTasks...) {
+		if c.failTasks[task] {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(task), Reason: aws.String("TASK_NOT_VALID")})
+			continue
+		}
+		protected := types.ProtectedTask{
+			TaskArn:           aws.String(task),
+			ProtectionEnabled: params.ProtectionEnabled,
+		}
+		if c.arnPrefix != "" {
+			protected.TaskArn = aws.String(c.arnPrefix + task)
+			protected.ExpirationDate = aws.Time(testExpiresAt)
+		}
+		output.ProtectedTasks = append(output.ProtectedTasks, protected)
+	}
+
+	return output, nil
+}
+
+func (c *testECSClient) Calls() []*ecs.UpdateTaskProtectionInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*ecs.UpdateTaskProtectionInput(nil), c.calls...)
+}
+
+// startController runs a Controller using client until the test ends.
+func startController(t *testing.T, client ecstp.ECSClient, configure ...func(*Controller)) *Controller {
+	t.Helper()
+
+	c := New(client)
+	c.BatchWindow = 20 * time.Millisecond
+	c.CallsPerSecond = 1000
+	for _, f := range configure {
+		f(c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return c
+}
+
+func TestController_Submit(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *testECSClient
+		req     Request
+		wantErr func(error) bool
+	}{
+		{
+			name:   "should apply a valid request",
+			client: &testECSClient{},
+			req:    Request{Cluster: "cluster", TaskARN: "task", Protect: true},
+		},
+		{
+			name:   "should reject a request without a task",
+			client: &testECSClient{},
+			req:    Request{Cluster: "cluster", Protect: true},
+			wantErr: func(err error) bool {
+				return errors.Is(err, ErrInvalidRequest)
+			},
+		},
+		{
+			name:   "should reject a malformed task ARN",
+			client: &testECSClient{},
+			req:    Request{Cluster: "cluster", TaskARN: "arn:aws:ecs:eu-west-2:123456789012:cluster/cluster", Protect: true},
+			wantErr: func(err error) bool {
+				return errors.Is(err, ErrInvalidRequest)
+			},
+		},
+		{
+			name:   "should reject an out of range expiry",
+			client: &testECSClient{},
+			req:    Request{Cluster: "cluster", TaskARN: "task", Protect: true, ExpiresInMinutes: aws.Int32(ecstp.MaxExpiresInMinutes + 1)},
+			wantErr: func(err error) bool {
+				return errors.Is(err, ErrInvalidRequest)
+			},
+		},
+		{
+			name:   "should return task failures",
+			client: &testECSClient{failTasks: map[string]bool{"task": true}},
+			req:    Request{Cluster: "cluster", TaskARN: "task", Protect: true},
+			wantErr: func(err error) bool {
+				var failure *ecstp.ProtectionFailureError
+				return errors.As(err, &failure) && failure.Reason == "TASK_NOT_VALID"
+			},
+		},
+		{
+			name:   "should reject responses for unrequested tasks",
+			client: &testECSClient{extraTasks: []string{"other"}},
+			req:    Request{Cluster: "cluster", TaskARN: "task", Protect: true},
+			wantErr: func(err error) bool {
+				var mismatch *ecstp.TaskARNMismatchError
+				return errors.As(err, &mismatch) && mismatch.Unexpected[0] == "other"
+			},
+		},
+		{
+			name:   "should return ECS errors",
+			client: &testECSClient{err: errors.New("boom")},
+			req:    Request{Cluster: "cluster", TaskARN: "task"},
+			wantErr: func(err error) bool {
+				return err != nil && err.Error() == "boom"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records []ecstp.AuditRecord
+			c := startController(t, tt.client, func(c *Controller) {
+				c.Auditor = ecstp.AuditorFunc(func(ctx context.Context, record ecstp.AuditRecord) {
+					records = append(records, record)
+				})
+			})
+
+			res, err := c.Submit(context.Background(), tt.req)
+			if tt.wantErr != nil {
+				assert.True(t, tt.wantErr(err), "unexpected error: %v", err)
+				assert.NotNil(t, res.Error)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Nil(t, res.Error)
+			require.Len(t, records, 1)
+			assert.Equal(t, tt.req.TaskARN, records[0].TaskARN)
+		})
+	}
+}
+
+var testExpiresAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestController_Submit_TaskID(t *testing.T) {
+	var records []ecstp.AuditRecord
+	c := startController(t, &testECSClient{arnPrefix: "arn:aws:ecs:eu-west-2:123456789012:task/cluster/"}, func(c *Controller) {
+		c.Auditor = ecstp.AuditorFunc(func(ctx context.Context, record ecstp.AuditRecord) {
+			records = append(records, record)
+		})
+	})
+
+	res, err := c.Submit(context.Background(), Request{Cluster: "cluster", TaskARN: "task", Protect: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, &testExpiresAt, res.ExpiresAt, "the result of a task identified by ID should be matched by its ARN")
+	require.Len(t, records, 1)
+	assert.Equal(t, &testExpiresAt, records[0].ExpiresAt)
+}
+
+func TestController_Batching(t *testing.T) {
+	client := &testECSClient{}
+	c := startController(t, client, func(c *Controller) {
+		c.BatchWindow = 100 * time.Millisecond
+	})
+
+	reqs := []Request{
+		{Cluster: "a", TaskARN: "1", Protect: true},
+		{Cluster: "a", TaskARN: "2", Protect: true},
+		{Cluster: "a", TaskARN: "3", Protect: true, ExpiresInMinutes: aws.Int32(10)},
+		{Cluster: "a", TaskARN: "4", Protect: false},
+		{Cluster: "b", TaskARN: "5", Protect: true},
+	}
+	for i := 0; i < 12; i++ {
+		reqs = append(reqs, Request{Cluster: "c", TaskARN: string(rune('a' + i)), Protect: true})
+	}
+
+	var wg sync.WaitGroup
+	for _, req := range reqs {
+		wg.Add(1)
+		go func(req Request) {
+			defer wg.Done()
+			_, err := c.Submit(context.Background(), req)
+			assert.NoError(t, err)
+		}(req)
+	}
+	wg.Wait()
+
+	sizes := map[string][]int{}
+	for _, call := range client.Calls() {
+		assert.LessOrEqual(t, len(call.Tasks), MaxBatchSize)
+		sizes[aws.ToString(call.Cluster)] = append(sizes[aws.ToString(call.Cluster)], len(call.Tasks))
+	}
+	assert.ElementsMatch(t, []int{2, 1, 1}, sizes["a"])
+	assert.ElementsMatch(t, []int{1}, sizes["b"])
+	assert.ElementsMatch(t, []int{10, 2}, sizes["c"])
+}
+
+func TestController_RateLimit(t *testing.T) {
+	client := &testECSClient{}
+	c := startController(t, client, func(c *Controller) {
+		c.BatchWindow = time.Millisecond
+		c.CallsPerSecond = 20
+	})
+
+	start := time.Now()
+	for _, cluster := range []string{"a", "b", "c"} {
+		_, err := c.Submit(context.Background(), Request{Cluster: cluster, TaskARN: "task", Protect: true})
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, client.Calls(), 3)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxRequestBody is the maximum size of a request body accepted by Handler.
+const maxRequestBody = 1 << 20
+
+// Handler returns an http.Handler accepting POSTed protection requests.
+//
+// The body is either a single Request or a JSON array of Requests. The response is a JSON array of
+// Results in the same order, with status 200 if every request succeeded, 400 if any request was
+// invalid and 502 otherwise.
+func (c *Controller) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reqs, err := decodeRequests(body)
+		if err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results := make([]Result, len(reqs))
+		var wg sync.WaitGroup
+		for i, req := range reqs {
+			wg.Add(1)
+			go func(i int, req Request) {
+				defer wg.Done()
+				results[i], _ = c.Submit(r.Context(), req)
+			}(i, req)
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		for _, res := range results {
+			switch {
+			case errors.Is(res.err, ErrInvalidRequest):
+				status = http.StatusBadRequest
+			case res.err != nil && status == http.StatusOK:
+				status = http.StatusBadGateway
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(results)
+	})
+}
+
+// decodeRequests decodes a single Request or an array of Requests.
+func decodeRequests(body []byte) ([]Request, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var reqs []Request
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			return nil, err
+		}
+		return reqs, nil
+	}
+
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	return []Request{req}, nil
+}
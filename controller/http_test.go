@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_Handler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		wantStatus  int
+		wantResults int
+	}{
+		{
+			name:        "should apply a single request",
+			method:      http.MethodPost,
+			body:        `{"cluster":"cluster","taskArn":"task","protect":true}`,
+			wantStatus:  http.StatusOK,
+			wantResults: 1,
+		},
+		{
+			name:        "should apply an array of requests",
+			method:      http.MethodPost,
+			body:        `[{"cluster":"cluster","taskArn":"a","protect":true},{"cluster":"cluster","taskArn":"b","protect":true}]`,
+			wantStatus:  http.StatusOK,
+			wantResults: 2,
+		},
+		{
+			name:        "should report task failures",
+			method:      http.MethodPost,
+			body:        `{"cluster":"cluster","taskArn":"failing","protect":true}`,
+			wantStatus:  http.StatusBadGateway,
+			wantResults: 1,
+		},
+		{
+			name:        "should report invalid requests",
+			method:      http.MethodPost,
+			body:        `[{"cluster":"cluster","taskArn":"a","protect":true},{"cluster":"cluster"}]`,
+			wantStatus:  http.StatusBadRequest,
+			wantResults: 2,
+		},
+		{
+			name:       "should reject malformed bodies",
+			method:     http.MethodPost,
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject other methods",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := startController(t, &testECSClient{failTasks: map[string]bool{"failing": true}})
+
+			rec := httptest.NewRecorder()
+			c.Handler().ServeHTTP(rec, httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantResults > 0 {
+				var got []Result
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+				assert.Len(t, got, tt.wantResults)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// SQSClient is the subset of the SQS client used by SQSSource.
+type SQSClient interface {
+	ReceiveMessage(
+		ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options),
+	) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(
+		ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options),
+	) (*sqs.DeleteMessageOutput, error)
+}
+
+// SQSSource feeds protection requests from an SQS queue into a Controller.
+//
+// Each message body is a Request, or an EventBridge event whose detail is a Request, so the queue
+// can be the target of an EventBridge rule. Messages are deleted once applied, or if they can never
+// succeed; other failures are left on the queue to be retried after the visibility timeout.
+type SQSSource struct {
+	Client   SQSClient
+	QueueURL string
+	Logger   *slog.Logger
+}
+
+// eventBridgeEvent is the envelope of an event delivered to SQS by EventBridge.
+type eventBridgeEvent struct {
+	Detail json.RawMessage `json:"detail"`
+}
+
+// Run receives messages and submits them to c until ctx is done.
+func (s *SQSSource) Run(ctx context.Context, c *Controller) error {
+	for {
+		output, err := s.Client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(s.QueueURL),
+			MaxNumberOfMessages: MaxBatchSize,
+			WaitTimeSeconds:     20,
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			s.logger().ErrorContext(ctx, "failed to receive messages", slog.String("error", err.Error()))
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, msg := range output.Messages {
+			wg.Add(1)
+			go func(msg types.Message) {
+				defer wg.Done()
+				s.handle(ctx, c, msg)
+			}(msg)
+		}
+		wg.Wait()
+	}
+}
+
+func (s *SQSSource) handle(ctx context.Context, c *Controller, msg types.Message) {
+	req, err := decodeMessage(aws.ToString(msg.Body))
+	if err == nil {
+		_, err = c.Submit(ctx, req)
+	}
+	if err != nil && !errors.Is(err, ErrInvalidRequest) {
+		s.logger().WarnContext(ctx, "protection request failed, leaving message for retry",
+			slog.String("message_id", aws.ToString(msg.MessageId)),
+			slog.Any("error", ecstp.NewErrorDetail(ecstp.OperationUpdateTaskProtection, req.TaskARN, err)),
+		)
+		return
+	}
+	if err != nil {
+		s.logger().WarnContext(ctx, "discarding invalid protection request",
+			slog.String("message_id", aws.ToString(msg.MessageId)),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	if _, err := s.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(s.QueueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	}); err != nil {
+		s.logger().ErrorContext(ctx, "failed to delete message",
+			slog.String("message_id", aws.ToString(msg.MessageId)),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// decodeMessage decodes a Request from a message body, unwrapping an EventBridge envelope.
+func decodeMessage(body string) (Request, error) {
+	var event eventBridgeEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return Request{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	data := []byte(body)
+	if len(event.Detail) > 0 {
+		data = event.Detail
+	}
+
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return Request{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	return req, nil
+}
+
+func (s *SQSSource) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+
+	return s.Logger
+}
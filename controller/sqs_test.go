@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSQSClient delivers messages once and records deleted receipt handles.
+type testSQSClient struct {
+	mu       sync.Mutex
+	messages []types.Message
+	deleted  []string
+}
+
+func (c *testSQSClient) ReceiveMessage(
+	ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options),
+) (*sqs.ReceiveMessageOutput, error) {
+	c.mu.Lock()
+	messages := c.messages
+	c.messages = nil
+	c.mu.Unlock()
+
+	if len(messages) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (c *testSQSClient) DeleteMessage(
+	ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options),
+) (*sqs.DeleteMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, aws.ToString(params.ReceiptHandle))
+
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (c *testSQSClient) Deleted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.deleted...)
+}
+
+func TestSQSSource_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		ecs         *testECSClient
+		wantDeleted bool
+		wantCalls   int
+	}{
+		{
+			name:        "should apply and delete a request",
+			body:        `{"cluster":"cluster","taskArn":"task","protect":true}`,
+			ecs:         &testECSClient{},
+			wantDeleted: true,
+			wantCalls:   1,
+		},
+		{
+			name: "should unwrap EventBridge events",
+			body: `{"version":"0","detail-type":"Task Protection Request","source":"custom",` +
+				`"detail":{"cluster":"cluster","taskArn":"task","protect":false}}`,
+			ecs:         &testECSClient{},
+			wantDeleted: true,
+			wantCalls:   1,
+		},
+		{
+			name:        "should leave failed requests for retry",
+			body:        `{"cluster":"cluster","taskArn":"task","protect":true}`,
+			ecs:         &testECSClient{failTasks: map[string]bool{"task": true}},
+			wantDeleted: false,
+			wantCalls:   1,
+		},
+		{
+			name:        "should discard invalid requests",
+			body:        `not json`,
+			ecs:         &testECSClient{},
+			wantDeleted: true,
+			wantCalls:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := startController(t, tt.ecs)
+			client := &testSQSClient{messages: []types.Message{
+				{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt"), Body: aws.String(tt.body)},
+			}}
+			source := &SQSSource{Client: client, QueueURL: "queue"}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			done := make(chan error)
+			go func() { done <- source.Run(ctx, c) }()
+
+			// the second receive blocks until ctx is done, so wait for the first message to settle
+			assert.Eventually(t, func() bool {
+				return len(tt.ecs.Calls()) == tt.wantCalls && (len(client.Deleted()) > 0) == tt.wantDeleted
+			}, 400*time.Millisecond, 5*time.Millisecond)
+			cancel()
+			assert.ErrorIs(t, <-done, context.Canceled)
+		})
+	}
+}
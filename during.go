@@ -0,0 +1,87 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+)
+
+// ProtectDuringInput defines the parameters of ProtectDuring.
+//
+// If Metadata is nil, the Cluster and Task ARN are retrieved via GetTaskArn. A nil
+// *ProtectDuringInput is treated like an empty one.
+type ProtectDuringInput struct {
+	Metadata *MetadataBody
+}
+
+// ProtectDuring enables protection of the task, runs fn and disables protection once fn returns,
+// like a Job wrapped with Wrap:
+//
+//	err := client.ProtectDuring(ctx, &ecstp.ProtectDuringInput{}, func(ctx context.Context) error {
+//		return process(ctx, msg)
+//	})
+//
+// Protection is renewed as decided by the Renewal of the Client's Profile, or the Adaptive
+// strategy, while fn runs. Concurrent calls for the same task share one protection, disabled
+// when the last returns, without the Profile's Debounce. Protection is disabled even if fn fails,
+// panics or ctx is canceled, through a context detached from ctx and bounded by
+// FinalUnprotectTimeout. A panic in fn is recovered and returned as a *JobPanicError.
+//
+// If protection can't be enabled, fn isn't run. The returned error joins the errors of enabling
+// protection, fn and disabling protection.
+func (c *Client) ProtectDuring(ctx context.Context, input *ProtectDuringInput, fn func(ctx context.Context) error) error {
+	var metadata *MetadataBody
+	if input != nil {
+		metadata = input.Metadata
+	}
+	m, done := c.duringManager(metadata)
+	defer done()
+
+	if err := m.hold(ctx); err != nil {
+		return err
+	}
+
+	err := runJob(ctx, JobFunc(fn))
+
+	return errors.Join(err, m.releaseHold(ctx, true))
+}
+
+// duringManager is a Manager shared by concurrent ProtectDuring calls.
+type duringManager struct {
+	*Manager
+	// calls is the number of ProtectDuring calls using the Manager.
+	calls int
+}
+
+// duringManager returns the Manager shared by the ProtectDuring calls for the task described by
+// metadata, or for the current task if nil, and a function to call once done with it. The Manager
+// is discarded once the last call is done with it, after disabling protection, so a later call
+// starts afresh.
+func (c *Client) duringManager(metadata *MetadataBody) (*Manager, func()) {
+	var key string
+	if metadata != nil {
+		key = normalizeMetadata(metadata).TaskARN
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.duringManagers == nil {
+		c.duringManagers = map[string]*duringManager{}
+	}
+	m, ok := c.duringManagers[key]
+	if !ok {
+		m = &duringManager{Manager: NewManager(c, metadata)}
+		c.duringManagers[key] = m
+	}
+	m.calls++
+
+	return m.Manager, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		m.calls--
+		if m.calls == 0 {
+			delete(c.duringManagers, key)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ProtectDuring(t *testing.T) {
+	task := remoteTestARNPrefix + "a"
+
+	tests := []struct {
+		name      string
+		fn        func(ctx context.Context, cancel context.CancelFunc) error
+		wantErr   error
+		wantPanic any
+	}{
+		{
+			name: "should run the function while protected",
+			fn:   func(ctx context.Context, cancel context.CancelFunc) error { return nil },
+		},
+		{
+			name:    "should return the error of the function",
+			fn:      func(ctx context.Context, cancel context.CancelFunc) error { return errTestJob },
+			wantErr: errTestJob,
+		},
+		{
+			name:      "should recover a panic in the function",
+			fn:        func(ctx context.Context, cancel context.CancelFunc) error { panic("boom") },
+			wantPanic: "boom",
+		},
+		{
+			name: "should disable protection once the context is canceled",
+			fn: func(ctx context.Context, cancel context.CancelFunc) error {
+				cancel()
+				return ctx.Err()
+			},
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &ExpiryTrackingTestClient{expiresAt: map[string]time.Time{}}
+			c := NewClient(ecsClient)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			err := c.ProtectDuring(ctx, &ProtectDuringInput{Metadata: &MetadataBody{TaskARN: task}},
+				func(ctx context.Context) error {
+					assert.Positive(t, ecsClient.Remaining(task), "function should run while protected")
+					return tt.fn(ctx, cancel)
+				})
+
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantPanic != nil:
+				var panicErr *JobPanicError
+				if assert.ErrorAs(t, err, &panicErr) {
+					assert.Equal(t, tt.wantPanic, panicErr.Value)
+				}
+			default:
+				assert.NoError(t, err)
+			}
+			assert.Zero(t, ecsClient.Remaining(task), "protection should be disabled once the function returns")
+		})
+	}
+}
+
+func TestClient_ProtectDuring_Overlapping(t *testing.T) {
+	task := remoteTestARNPrefix + "a"
+	ecsClient := &ExpiryTrackingTestClient{expiresAt: map[string]time.Time{}}
+	c := NewClient(ecsClient)
+	input := &ProtectDuringInput{Metadata: &MetadataBody{TaskARN: task}}
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- c.ProtectDuring(context.Background(), input, func(ctx context.Context) error {
+			close(started)
+			<-finish
+			return nil
+		})
+	}()
+	<-started
+
+	require.NoError(t, c.ProtectDuring(context.Background(), input, func(ctx context.Context) error { return nil }))
+	assert.Positive(t, ecsClient.Remaining(task), "protection should be held by the running function")
+
+	close(finish)
+	require.NoError(t, <-done)
+	assert.Zero(t, ecsClient.Remaining(task))
+	assert.Empty(t, c.duringManagers, "the shared Manager should be discarded once the last call returns")
+}
+
+func TestClient_ProtectDuring_NilInput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`)
+	}))
+	defer server.Close()
+
+	ecsClient := &CountingTestClient{}
+	c := NewClient(ecsClient, WithMetadataEndpoint(server.URL))
+
+	run := false
+	err := c.ProtectDuring(context.Background(), nil, func(ctx context.Context) error {
+		run = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, run)
+	assert.Equal(t, int32(1), ecsClient.protects.Load())
+	assert.Equal(t, int32(1), ecsClient.unprotects.Load())
+}
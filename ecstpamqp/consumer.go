@@ -0,0 +1,129 @@
+// Package ecstpamqp keeps an ECS task protected while it processes RabbitMQ deliveries consumed
+// with github.com/rabbitmq/amqp091-go, so a scale-in doesn't interrupt long message processing and
+// force the messages to be redelivered.
+package ecstpamqp
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// Delivery is the part of an AMQP delivery used by a Consumer. It's implemented by amqp091.Delivery.
+type Delivery interface {
+	Ack(multiple bool) error
+	Nack(multiple, requeue bool) error
+}
+
+// message settles a Delivery for Manager.HandleMessage, requeueing it when it fails unless discard
+// is set.
+type message struct {
+	Delivery
+	discard bool
+}
+
+func (m message) Ack() error {
+	return m.Delivery.Ack(false)
+}
+
+func (m message) Nack() error {
+	return m.Delivery.Nack(false, !m.discard)
+}
+
+func (m message) Requeue() error {
+	return m.Delivery.Nack(false, true)
+}
+
+// Consumer processes AMQP deliveries with Handler, holding a protection lease for each delivery
+// until it's acknowledged:
+//
+//	deliveries, err := ch.Consume(queue, "", false, false, false, false, nil)
+//	consumer := &ecstpamqp.Consumer[amqp091.Delivery]{Manager: manager, Handler: handle, Concurrency: prefetch}
+//	err = consumer.Consume(ctx, deliveries)
+//
+// Each delivery is processed with Manager.HandleMessage, so the task is protected from when the
+// first delivery is received until the last one being processed has been acked, or nacked if
+// Handler fails. The broker requeues the unacknowledged deliveries of a worker whose connection
+// or channel closes, e.g. after a crash.
+//
+// The channel must consume without auto-ack. A Consumer is safe for concurrent use.
+type Consumer[D Delivery] struct {
+	Manager *ecstp.Manager
+	Handler func(ctx context.Context, delivery D) error
+	// Concurrency is the number of deliveries processed at once by Consume, typically the prefetch
+	// count of the channel. Defaults to 1.
+	Concurrency int
+	// DiscardFailed makes failed deliveries be nacked without requeueing, e.g. to dead-letter
+	// them. By default they're requeued.
+	DiscardFailed bool
+	Logger        *slog.Logger
+
+	inFlight atomic.Int64
+}
+
+// Consume processes deliveries until the channel is closed or ctx is done, returning ctx.Err() in
+// the latter case. Deliveries being processed are acknowledged before Consume returns. Errors
+// processing deliveries are logged.
+func (c *Consumer[D]) Consume(ctx context.Context, deliveries <-chan D) error {
+	concurrency := max(c.Concurrency, 1)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		var delivery D
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				return nil
+			}
+			delivery = d
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := c.Handle(ctx, delivery); err != nil {
+				c.logger().ErrorContext(ctx, "unable to process delivery", slog.Any("error", err))
+			}
+		}()
+	}
+}
+
+// Handle processes delivery with Manager.HandleMessage, acking it if Handler succeeds and nacking
+// it otherwise. If protection can't be enabled, delivery is requeued without being processed, so
+// it's redelivered to a worker that can protect itself.
+func (c *Consumer[D]) Handle(ctx context.Context, delivery D) error {
+	msg := message{Delivery: delivery, discard: c.DiscardFailed}
+	return c.Manager.HandleMessage(ctx, msg, func(ctx context.Context) error {
+		c.inFlight.Add(1)
+		defer c.inFlight.Add(-1)
+
+		return c.Handler(ctx, delivery)
+	})
+}
+
+// InFlight returns the number of deliveries being processed.
+func (c *Consumer[D]) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+func (c *Consumer[D]) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+
+	return c.Logger
+}
@@ -0,0 +1,161 @@
+package ecstpamqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+// testDelivery records its acknowledgement, noting whether the task was protected at the time.
+type testDelivery struct {
+	id          int
+	protectedAt func() bool
+
+	mu  sync.Mutex
+	ack string
+}
+
+func (d *testDelivery) Ack(multiple bool) error {
+	return d.record("ack")
+}
+
+func (d *testDelivery) Nack(multiple, requeue bool) error {
+	return d.record(fmt.Sprintf("nack requeue=%t", requeue))
+}
+
+func (d *testDelivery) record(ack string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.protectedAt != nil && !d.protectedAt() {
+		ack += " (unprotected)"
+	}
+	d.ack = ack
+
+	return nil
+}
+
+func (d *testDelivery) Acked() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.ack
+}
+
+func newTestConsumer(ecsClient *ecstptest.ECSClient, handler func(ctx context.Context, d *testDelivery) error) *Consumer[*testDelivery] {
+	return &Consumer[*testDelivery]{Manager: ecstptest.NewManager(ecsClient), Handler: handler}
+}
+
+func TestConsumer_Handle(t *testing.T) {
+	tests := []struct {
+		name          string
+		ecsErr        error
+		discardFailed bool
+		handleErr     error
+		wantAck       string
+		wantErr       bool
+	}{
+		{
+			name:    "should ack processed deliveries while protected",
+			wantAck: "ack",
+		},
+		{
+			name:      "should requeue failed deliveries while protected",
+			handleErr: errors.New("boom"),
+			wantAck:   "nack requeue=true",
+			wantErr:   true,
+		},
+		{
+			name:          "should discard failed deliveries",
+			discardFailed: true,
+			handleErr:     errors.New("boom"),
+			wantAck:       "nack requeue=false",
+			wantErr:       true,
+		},
+		{
+			name:    "should requeue deliveries without processing them if protection fails",
+			ecsErr:  errors.New("throttled"),
+			wantAck: "nack requeue=true (unprotected)",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed := false
+			ecsClient := &ecstptest.ECSClient{}
+			ecsClient.SetErr(tt.ecsErr)
+			c := newTestConsumer(ecsClient, func(ctx context.Context, d *testDelivery) error {
+				processed = true
+				return tt.handleErr
+			})
+			c.DiscardFailed = tt.discardFailed
+			d := &testDelivery{protectedAt: func() bool { return c.Manager.State().Protected }}
+
+			err := c.Handle(context.Background(), d)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAck, d.Acked())
+			assert.Equal(t, tt.ecsErr == nil, processed)
+			assert.False(t, c.Manager.State().Protected, "protection should be released once acknowledged")
+		})
+	}
+}
+
+func TestConsumer_Consume(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	var running, maxRunning atomic.Int32
+	c := newTestConsumer(ecsClient, func(ctx context.Context, d *testDelivery) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRunning.Load()
+			if n <= max || maxRunning.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	c.Concurrency = 3
+
+	deliveries := make(chan *testDelivery)
+	var sent []*testDelivery
+	go func() {
+		for i := 0; i < 9; i++ {
+			d := &testDelivery{id: i}
+			sent = append(sent, d)
+			deliveries <- d
+		}
+		close(deliveries)
+	}()
+
+	require.NoError(t, c.Consume(context.Background(), deliveries))
+	for _, d := range sent {
+		assert.Equal(t, "ack", d.Acked(), "delivery %d", d.id)
+	}
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+	assert.Greater(t, maxRunning.Load(), int32(1), "deliveries should be processed concurrently")
+	assert.False(t, c.Manager.State().Protected)
+	assert.Equal(t, 0, c.InFlight())
+}
+
+func TestConsumer_Consume_Canceled(t *testing.T) {
+	c := newTestConsumer(&ecstptest.ECSClient{}, func(ctx context.Context, d *testDelivery) error {
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, c.Consume(ctx, make(chan *testDelivery)), context.Canceled)
+}
@@ -0,0 +1,129 @@
+// Package ecstpcron runs scheduled jobs inside a protection lease, so a scale-in doesn't interrupt
+// a job started by a cron library such as github.com/robfig/cron/v3 or github.com/go-co-op/gocron.
+package ecstpcron
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// DefaultMaxDuration is the default maximum duration of a job.
+const DefaultMaxDuration = 30 * time.Minute
+
+// Job is a scheduled job. It's implemented by cron.Job of github.com/robfig/cron/v3.
+type Job interface {
+	Run()
+}
+
+// JobFunc is a Job calling itself.
+type JobFunc func()
+
+// Run calls f.
+func (f JobFunc) Run() {
+	f()
+}
+
+// Wrapper runs jobs while holding a protection lease for each:
+//
+//	c := cron.New(cron.WithChain(func(job cron.Job) cron.Job {
+//		return wrapper.Wrap("", 0, job)
+//	}))
+//	s.NewJob(gocron.DurationJob(time.Hour), gocron.NewTask(wrapper.Func("report", 10*time.Minute, report)))
+//
+// Each job holds protection of the task with Manager.Acquire, so protection is renewed according
+// to the Profile of the Manager's Client while jobs run, shared with the Manager's other holders,
+// and disabled once the last of them is released. A job's hold is released once it returns or its
+// maximum duration has passed, whichever comes first, so a job that hangs doesn't keep the task
+// protected. It's safe for concurrent use, e.g. with overlapping jobs.
+type Wrapper struct {
+	Manager *ecstp.Manager
+	// MaxDuration is the maximum duration of jobs that don't set their own. Defaults to
+	// DefaultMaxDuration.
+	MaxDuration time.Duration
+	Logger      *slog.Logger
+
+	running atomic.Int64
+}
+
+// Run calls fn while holding a protection lease, with a context canceled after maxDuration, or the
+// Wrapper's MaxDuration if it's zero. If name is set, it's added to the correlation labels of ctx
+// as "job". If protection can't be enabled, fn isn't called.
+//
+// The returned error joins the errors of fn and of releasing its hold.
+func (w *Wrapper) Run(ctx context.Context, name string, maxDuration time.Duration, fn func(ctx context.Context) error) error {
+	if maxDuration <= 0 {
+		maxDuration = w.maxDuration()
+	}
+	if name != "" {
+		ctx = ecstp.ContextWithLabels(ctx, map[string]string{"job": name})
+	}
+
+	hold, err := w.Manager.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	w.running.Add(1)
+	defer w.running.Add(-1)
+
+	// a job overrunning its deadline, e.g. one ignoring its context, no longer holds protection
+	overrun := time.AfterFunc(maxDuration, func() {
+		if err := hold.Release(context.WithoutCancel(ctx)); err != nil {
+			w.logger().ErrorContext(ctx, "unable to release the protection of an overrunning job", slog.Any("error", err))
+		}
+	})
+	jobCtx, cancel := context.WithTimeout(ctx, maxDuration)
+	err = fn(jobCtx)
+	cancel()
+	overrun.Stop()
+
+	return errors.Join(err, hold.Release(ctx))
+}
+
+// Func returns a function running fn with Run, e.g. to pass to gocron.NewTask or cron.FuncJob.
+// Errors are logged.
+func (w *Wrapper) Func(name string, maxDuration time.Duration, fn func(ctx context.Context) error) func() {
+	return func() {
+		ctx := context.Background()
+		if err := w.Run(ctx, name, maxDuration, fn); err != nil {
+			w.logger().ErrorContext(ctx, "scheduled job failed",
+				slog.String("job", name),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// Wrap returns a Job running job with Run, e.g. from a cron.JobWrapper. Since job isn't passed a
+// context, it isn't interrupted once maxDuration has passed, but its hold is released.
+func (w *Wrapper) Wrap(name string, maxDuration time.Duration, job Job) Job {
+	return JobFunc(w.Func(name, maxDuration, func(ctx context.Context) error {
+		job.Run()
+		return nil
+	}))
+}
+
+// Running returns the number of running jobs.
+func (w *Wrapper) Running() int {
+	return int(w.running.Load())
+}
+
+func (w *Wrapper) maxDuration() time.Duration {
+	if w.MaxDuration <= 0 {
+		return DefaultMaxDuration
+	}
+
+	return w.MaxDuration
+}
+
+func (w *Wrapper) logger() *slog.Logger {
+	if w.Logger == nil {
+		return slog.Default()
+	}
+
+	return w.Logger
+}
@@ -0,0 +1,174 @@
+package ecstpcron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+// protects returns the expiry of the UpdateTaskProtection calls made through c enabling protection.
+func protects(c *ecstptest.ECSClient) []int32 {
+	var minutes []int32
+	for _, call := range c.Calls() {
+		if call.Operation == ecstptest.OperationUpdateTaskProtection && call.Protect {
+			minutes = append(minutes, aws.ToInt32(call.ExpiresInMinutes))
+		}
+	}
+
+	return minutes
+}
+
+func newTestWrapper(ecsClient *ecstptest.ECSClient, opts ...ecstp.Option) *Wrapper {
+	return &Wrapper{Manager: ecstptest.NewManager(ecsClient, opts...)}
+}
+
+func TestWrapper_Run(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []ecstp.Option
+		ecsErr       error
+		jobErr       error
+		wantProtects []int32
+		wantRun      bool
+		wantErr      bool
+	}{
+		{
+			name:         "should protect the task for the default renewal expiry",
+			wantProtects: []int32{30},
+			wantRun:      true,
+		},
+		{
+			name:         "should protect the task as set by the profile",
+			opts:         []ecstp.Option{ecstp.WithProfile(ecstp.Profile{Renewal: ecstp.FixedInterval{Expiry: time.Hour}})},
+			wantProtects: []int32{60},
+			wantRun:      true,
+		},
+		{
+			name:         "should return the error of the job",
+			jobErr:       errors.New("boom"),
+			wantProtects: []int32{30},
+			wantRun:      true,
+			wantErr:      true,
+		},
+		{
+			name:         "should not run the job if protection fails",
+			ecsErr:       errors.New("throttled"),
+			wantProtects: []int32{30},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &ecstptest.ECSClient{}
+			ecsClient.SetErr(tt.ecsErr)
+			w := newTestWrapper(ecsClient, tt.opts...)
+			run := false
+			err := w.Run(context.Background(), "test_job", 0, func(ctx context.Context) error {
+				run = true
+				assert.True(t, w.Manager.State().Protected, "job should run while protected")
+				assert.Equal(t, map[string]string{"job": "test_job"}, ecstp.LabelsFromContext(ctx))
+				return tt.jobErr
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantRun, run)
+			assert.Equal(t, tt.wantProtects, protects(ecsClient))
+			assert.False(t, w.Manager.State().Protected)
+			assert.Equal(t, 0, w.Running())
+		})
+	}
+}
+
+func TestWrapper_Run_MaxDuration(t *testing.T) {
+	w := newTestWrapper(&ecstptest.ECSClient{})
+
+	err := w.Run(context.Background(), "", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, w.Manager.State().Protected)
+}
+
+func TestWrapper_Run_Overlapping(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	w := newTestWrapper(ecsClient)
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- w.Run(context.Background(), "long", time.Hour, func(ctx context.Context) error {
+			close(started)
+			<-finish
+			return nil
+		})
+	}()
+	<-started
+
+	// jobs started while another runs share its protection
+	require.NoError(t, w.Run(context.Background(), "short", time.Minute, func(ctx context.Context) error { return nil }))
+	assert.True(t, w.Manager.State().Protected, "protection should be held by the running job")
+	require.NoError(t, w.Run(context.Background(), "longer", 2*time.Hour, func(ctx context.Context) error { return nil }))
+	assert.True(t, w.Manager.State().Protected)
+
+	close(finish)
+	require.NoError(t, <-done)
+	assert.Equal(t, []int32{30}, protects(ecsClient))
+	assert.False(t, w.Manager.State().Protected)
+}
+
+func TestWrapper_Run_Overrun(t *testing.T) {
+	w := newTestWrapper(&ecstptest.ECSClient{})
+
+	err := w.Run(context.Background(), "", 10*time.Millisecond, func(ctx context.Context) error {
+		// a job ignoring its context
+		assert.Eventually(t, func() bool { return !w.Manager.State().Protected }, time.Second, time.Millisecond,
+			"protection should be released once the job overruns its max duration")
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, w.Running())
+}
+
+func TestWrapper_Run_SharedManager(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	w := newTestWrapper(ecsClient)
+	hold, err := w.Manager.Acquire(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, w.Run(context.Background(), "", 0, func(ctx context.Context) error { return nil }))
+	assert.True(t, w.Manager.State().Protected, "protection should be kept for the Manager's other holders")
+
+	require.NoError(t, hold.Release(context.Background()))
+	assert.False(t, w.Manager.State().Protected)
+	assert.Equal(t, []int32{30}, protects(ecsClient))
+}
+
+func TestWrapper_Wrap(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	w := newTestWrapper(ecsClient)
+	w.MaxDuration = 5 * time.Minute
+
+	run := false
+	w.Wrap("", 0, JobFunc(func() {
+		run = true
+		assert.True(t, w.Manager.State().Protected)
+	})).Run()
+
+	assert.True(t, run)
+	assert.Equal(t, []int32{30}, protects(ecsClient))
+	assert.False(t, w.Manager.State().Protected)
+}
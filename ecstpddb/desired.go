@@ -0,0 +1,116 @@
+package ecstpddb
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/Thumbscrew/ecs-task-protection/fleet"
+)
+
+// Attribute names of the items declaring the desired protection of tasks read by DesiredSource,
+// along with AttrTaskARN and AttrCluster.
+const (
+	// AttrProtect declares protection as an RFC 3339 timestamp protecting the task until then, or
+	// a boolean protecting it indefinitely or not at all.
+	AttrProtect          = "protect"
+	AttrExpiresInMinutes = "expiresInMinutes"
+	AttrReason           = "reason"
+)
+
+// ScanClient is the subset of the DynamoDB client used by DesiredSource.
+type ScanClient interface {
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// DesiredSource is a fleet.Source reading the desired protection of tasks from a DynamoDB table,
+// with an item per task:
+//
+//	{"taskArn": "arn:aws:ecs:...", "cluster": "prod", "protect": "2024-06-01T18:00:00Z", "reason": "migration"}
+//
+// The table is read with a full scan, so it should only hold declarations. Invalid items are logged
+// and ignored.
+type DesiredSource struct {
+	Client    ScanClient
+	TableName string
+	Logger    *slog.Logger
+}
+
+// Desired returns the desired protection declared by every item of the table.
+func (s *DesiredSource) Desired(ctx context.Context) (map[fleet.Key]fleet.Desired, error) {
+	desired := map[fleet.Key]fleet.Desired{}
+	input := &dynamodb.ScanInput{TableName: aws.String(s.TableName)}
+	for {
+		output, err := s.Client.Scan(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan %s: %w", s.TableName, err)
+		}
+		for _, item := range output.Items {
+			key, d, err := parseDesiredItem(item)
+			if err != nil {
+				s.logger().WarnContext(ctx, "ignoring invalid protection declaration",
+					slog.String("table", s.TableName),
+					slog.Any("error", err),
+				)
+				continue
+			}
+			desired[key] = d
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return desired, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// parseDesiredItem returns the task and desired protection declared by item.
+func parseDesiredItem(item map[string]types.AttributeValue) (fleet.Key, fleet.Desired, error) {
+	key := fleet.Key{Cluster: stringAttr(item, AttrCluster), TaskARN: stringAttr(item, AttrTaskARN)}
+	if key.Cluster == "" || key.TaskARN == "" {
+		return key, fleet.Desired{}, fmt.Errorf("%s and %s are required", AttrCluster, AttrTaskARN)
+	}
+
+	var d fleet.Desired
+	switch v := item[AttrProtect].(type) {
+	case *types.AttributeValueMemberBOOL:
+		d.Protect = v.Value
+	case *types.AttributeValueMemberS:
+		var err error
+		if d, err = fleet.ParseDesired(v.Value); err != nil {
+			return key, d, fmt.Errorf("task %s: %w", key.TaskARN, err)
+		}
+	default:
+		return key, d, fmt.Errorf("task %s: %s is required", key.TaskARN, AttrProtect)
+	}
+	if v, ok := item[AttrExpiresInMinutes].(*types.AttributeValueMemberN); ok {
+		minutes, err := strconv.ParseInt(v.Value, 10, 32)
+		if err != nil {
+			return key, d, fmt.Errorf("task %s: invalid %s: %w", key.TaskARN, AttrExpiresInMinutes, err)
+		}
+		d.ExpiresInMinutes = int32(minutes)
+	}
+	d.Reason = stringAttr(item, AttrReason)
+
+	return key, d, nil
+}
+
+func (s *DesiredSource) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+
+	return s.Logger
+}
+
+func stringAttr(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+
+	return ""
+}
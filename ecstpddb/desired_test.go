@@ -0,0 +1,91 @@
+package ecstpddb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Thumbscrew/ecs-task-protection/fleet"
+)
+
+// testScanClient returns a page of items per Scan call.
+type testScanClient struct {
+	err   error
+	pages [][]map[string]types.AttributeValue
+}
+
+func (c *testScanClient) Scan(
+	ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options),
+) (*dynamodb.ScanOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	page := 0
+	if params.ExclusiveStartKey != nil {
+		page = int(params.ExclusiveStartKey["page"].(*types.AttributeValueMemberN).Value[0] - '0')
+	}
+
+	output := &dynamodb.ScanOutput{Items: c.pages[page]}
+	if page+1 < len(c.pages) {
+		output.LastEvaluatedKey = map[string]types.AttributeValue{
+			"page": &types.AttributeValueMemberN{Value: string(rune('0' + page + 1))},
+		}
+	}
+
+	return output, nil
+}
+
+func desiredItem(taskARN string, protect types.AttributeValue, extra ...string) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		AttrTaskARN: &types.AttributeValueMemberS{Value: taskARN},
+		AttrCluster: &types.AttributeValueMemberS{Value: "prod"},
+	}
+	if protect != nil {
+		item[AttrProtect] = protect
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		item[extra[i]] = &types.AttributeValueMemberN{Value: extra[i+1]}
+	}
+
+	return item
+}
+
+func TestDesiredSource_Desired(t *testing.T) {
+	until := time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC)
+	client := &testScanClient{pages: [][]map[string]types.AttributeValue{
+		{
+			desiredItem("task-1", &types.AttributeValueMemberBOOL{Value: true}, AttrExpiresInMinutes, "30"),
+			desiredItem("task-2", &types.AttributeValueMemberS{Value: until.Format(time.RFC3339)}),
+		},
+		{
+			desiredItem("task-3", &types.AttributeValueMemberS{Value: "false"}),
+			desiredItem("task-4", &types.AttributeValueMemberS{Value: "soon"}),
+			desiredItem("task-5", nil),
+		},
+	}}
+	source := &DesiredSource{Client: client, TableName: "protection-declarations"}
+
+	desired, err := source.Desired(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, map[fleet.Key]fleet.Desired{
+		{Cluster: "prod", TaskARN: "task-1"}: {Protect: true, ExpiresInMinutes: 30},
+		{Cluster: "prod", TaskARN: "task-2"}: {Protect: true, Until: aws.Time(until)},
+		{Cluster: "prod", TaskARN: "task-3"}: {Protect: false},
+	}, desired, "invalid items should be ignored")
+}
+
+func TestDesiredSource_Desired_Error(t *testing.T) {
+	source := &DesiredSource{Client: &testScanClient{err: errors.New("throttled")}, TableName: "protection-declarations"}
+
+	_, err := source.Desired(context.Background())
+
+	assert.ErrorContains(t, err, "throttled")
+}
@@ -0,0 +1,177 @@
+// Package ecstpddb records the protection state of tasks in a DynamoDB table, giving operators a
+// queryable live inventory of which tasks across the organization are protected and why.
+package ecstpddb
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+const (
+	// DefaultRetention is the default time a record is kept after protection ends or expires.
+	DefaultRetention = time.Hour
+	// DefaultRefreshInterval is the default interval between rewrites of the current state.
+	DefaultRefreshInterval = time.Minute
+)
+
+// Item attribute names. The table's partition key must be the string attribute AttrTaskARN, and
+// TTL should be enabled on AttrTTL so records of stopped tasks are removed.
+const (
+	AttrTaskARN   = "taskArn"
+	AttrCluster   = "cluster"
+	AttrProtected = "protected"
+	AttrExpiresAt = "expiresAt"
+	AttrLabels    = "labels"
+	AttrStopping  = "stopping"
+	AttrLastError = "lastError"
+	AttrUpdatedAt = "updatedAt"
+	AttrTTL       = "ttl"
+)
+
+// DynamoDBClient is the subset of the DynamoDB client used by Registry.
+type DynamoDBClient interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// Registry writes a Manager's protection state, along with labels describing why the task is
+// protected (e.g. the names of held leases), to a DynamoDB table:
+//
+//	registry := &ecstpddb.Registry{
+//		Client:    dynamodb.NewFromConfig(cfg),
+//		TableName: "task-protection",
+//		Manager:   manager,
+//	}
+//	go registry.Run(ctx)
+//
+// A record expires Retention after the task's protection does, or after it was last written if the
+// task is unprotected, so records of tasks that stopped without unprotecting are eventually
+// removed by DynamoDB TTL.
+type Registry struct {
+	Client    DynamoDBClient
+	TableName string
+	Manager   *ecstp.Manager
+	// Labels, if set, returns the labels recorded with the state, e.g. the names of held leases.
+	Labels func() []string
+	// Retention is the time a record is kept after protection ends or expires. Defaults to
+	// DefaultRetention.
+	Retention time.Duration
+	// RefreshInterval is the time between rewrites of the current state, which keep the labels and
+	// TTL current between protection updates. Defaults to DefaultRefreshInterval.
+	RefreshInterval time.Duration
+	Logger          *slog.Logger
+}
+
+// Run writes the current state, then writes it again on every protection update and every
+// RefreshInterval until ctx is done. Write errors are logged.
+func (r *Registry) Run(ctx context.Context) error {
+	events, cancel := r.Manager.Subscribe()
+	defer cancel()
+
+	interval := r.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	state := r.Manager.State()
+	for {
+		// the task is unknown until the Manager's first update
+		if state.TaskARN != "" {
+			if err := r.Put(ctx, state); err != nil && ctx.Err() == nil {
+				r.logger().ErrorContext(ctx, "unable to write protection state to registry",
+					slog.String("table", r.TableName),
+					slog.Any("error", err),
+				)
+			}
+		}
+
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			state = event.State
+		case <-ticker.C:
+			state = r.Manager.State()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Put writes state to the table.
+func (r *Registry) Put(ctx context.Context, state ecstp.State) error {
+	_, err := r.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.TableName),
+		Item:      r.item(state, time.Now()),
+	})
+
+	return err
+}
+
+// item returns the record of state written at now.
+func (r *Registry) item(state ecstp.State, now time.Time) map[string]types.AttributeValue {
+	retention := r.Retention
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	expires := now
+	if state.Protected && state.ExpiresAt != nil && state.ExpiresAt.After(now) {
+		expires = *state.ExpiresAt
+	}
+
+	item := map[string]types.AttributeValue{
+		AttrTaskARN:   &types.AttributeValueMemberS{Value: state.TaskARN},
+		AttrCluster:   &types.AttributeValueMemberS{Value: state.Cluster},
+		AttrProtected: &types.AttributeValueMemberBOOL{Value: state.Protected},
+		AttrStopping:  &types.AttributeValueMemberBOOL{Value: state.Stopping},
+		AttrUpdatedAt: &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		AttrTTL:       &types.AttributeValueMemberN{Value: strconv.FormatInt(expires.Add(retention).Unix(), 10)},
+	}
+	if state.ExpiresAt != nil {
+		item[AttrExpiresAt] = &types.AttributeValueMemberS{Value: state.ExpiresAt.UTC().Format(time.RFC3339)}
+	}
+	if state.LastError != nil {
+		item[AttrLastError] = &types.AttributeValueMemberS{Value: state.LastError.Message}
+	}
+	if r.Labels != nil {
+		// string sets can't be empty
+		if labels := uniqueLabels(r.Labels()); len(labels) > 0 {
+			item[AttrLabels] = &types.AttributeValueMemberSS{Value: labels}
+		}
+	}
+
+	return item
+}
+
+// uniqueLabels returns the non-empty labels sorted and without duplicates, as string sets don't
+// allow duplicates.
+func uniqueLabels(labels []string) []string {
+	unique := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if label != "" {
+			unique = append(unique, label)
+		}
+	}
+	slices.Sort(unique)
+
+	return slices.Compact(unique)
+}
+
+func (r *Registry) logger() *slog.Logger {
+	if r.Logger == nil {
+		return slog.Default()
+	}
+
+	return r.Logger
+}
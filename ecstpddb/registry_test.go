@@ -0,0 +1,158 @@
+package ecstpddb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+type testDynamoDBClient struct {
+	err error
+
+	mu    sync.Mutex
+	items []map[string]types.AttributeValue
+}
+
+func (c *testDynamoDBClient) PutItem(
+	ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options),
+) (*dynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.items = append(c.items, params.Item)
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (c *testDynamoDBClient) Items() []map[string]types.AttributeValue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]map[string]types.AttributeValue(nil), c.items...)
+}
+
+func TestRegistry_item(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(30 * time.Minute)
+
+	tests := []struct {
+		name     string
+		registry Registry
+		state    ecstp.State
+		want     map[string]types.AttributeValue
+	}{
+		{
+			name:     "should record protection and expire after it",
+			registry: Registry{Labels: func() []string { return []string{"job-b", "job-a", "job-b", ""} }},
+			state:    ecstp.State{Protected: true, ExpiresAt: &expiresAt, Cluster: "test", TaskARN: "task"},
+			want: map[string]types.AttributeValue{
+				AttrTaskARN:   &types.AttributeValueMemberS{Value: "task"},
+				AttrCluster:   &types.AttributeValueMemberS{Value: "test"},
+				AttrProtected: &types.AttributeValueMemberBOOL{Value: true},
+				AttrStopping:  &types.AttributeValueMemberBOOL{Value: false},
+				AttrExpiresAt: &types.AttributeValueMemberS{Value: "2024-05-01T12:30:00Z"},
+				AttrLabels:    &types.AttributeValueMemberSS{Value: []string{"job-a", "job-b"}},
+				AttrUpdatedAt: &types.AttributeValueMemberS{Value: "2024-05-01T12:00:00Z"},
+				AttrTTL:       &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Add(DefaultRetention).Unix(), 10)},
+			},
+		},
+		{
+			name:     "should expire unprotected tasks after retention",
+			registry: Registry{Retention: 10 * time.Minute, Labels: func() []string { return nil }},
+			state: ecstp.State{
+				Cluster:   "test",
+				TaskARN:   "task",
+				Stopping:  true,
+				LastError: &ecstp.ErrorDetail{Message: "access denied"},
+			},
+			want: map[string]types.AttributeValue{
+				AttrTaskARN:   &types.AttributeValueMemberS{Value: "task"},
+				AttrCluster:   &types.AttributeValueMemberS{Value: "test"},
+				AttrProtected: &types.AttributeValueMemberBOOL{Value: false},
+				AttrStopping:  &types.AttributeValueMemberBOOL{Value: true},
+				AttrLastError: &types.AttributeValueMemberS{Value: "access denied"},
+				AttrUpdatedAt: &types.AttributeValueMemberS{Value: "2024-05-01T12:00:00Z"},
+				AttrTTL:       &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.registry.item(tt.state, now))
+		})
+	}
+}
+
+func TestRegistry_Run(t *testing.T) {
+	manager := ecstptest.NewManager(&ecstptest.ECSClient{})
+	client := &testDynamoDBClient{}
+	registry := &Registry{Client: client, TableName: "protection", Manager: manager}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- registry.Run(ctx)
+	}()
+
+	// nothing is written until the task is known
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, client.Items())
+
+	_, err := manager.Protect(ctx, nil)
+	require.NoError(t, err)
+	_, err = manager.Unprotect(ctx)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(client.Items()) == 2 }, time.Second, 10*time.Millisecond)
+	items := client.Items()
+	assert.Equal(t, &types.AttributeValueMemberBOOL{Value: true}, items[0][AttrProtected])
+	assert.Equal(t, &types.AttributeValueMemberBOOL{Value: false}, items[1][AttrProtected])
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestRegistry_Put(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{
+			name: "should write the state",
+		},
+		{
+			name:    "should return write errors",
+			err:     errors.New("throttled"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &testDynamoDBClient{err: tt.err}
+			registry := &Registry{Client: client, TableName: "protection"}
+
+			err := registry.Put(context.Background(), ecstp.State{TaskARN: "task"})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, client.Items(), 1)
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "task"}, client.Items()[0][AttrTaskARN])
+		})
+	}
+}
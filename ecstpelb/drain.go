@@ -0,0 +1,166 @@
+// Package ecstpelb drains an ECS task from an Application or Network Load Balancer target group at
+// shutdown, so it's only unprotected once in-flight connections are done.
+package ecstpelb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// DefaultPollInterval is the default interval between target health checks while draining.
+const DefaultPollInterval = 5 * time.Second
+
+// ELBClient is the subset of the Elastic Load Balancing v2 client used by TargetGroupDrain.
+type ELBClient interface {
+	DeregisterTargets(
+		ctx context.Context, params *elbv2.DeregisterTargetsInput, optFns ...func(*elbv2.Options),
+	) (*elbv2.DeregisterTargetsOutput, error)
+	DescribeTargetHealth(
+		ctx context.Context, params *elbv2.DescribeTargetHealthInput, optFns ...func(*elbv2.Options),
+	) (*elbv2.DescribeTargetHealthOutput, error)
+}
+
+// TargetGroupDrain is an ecstp.DrainStep that deregisters the task from a target group and waits
+// for connection draining to complete, polling the target's health until the load balancer
+// reports it unused.
+//
+//	err := ecstp.Drain(ctx, manager, &ecstpelb.TargetGroupDrain{
+//		Client:         elbv2.NewFromConfig(cfg),
+//		TargetGroupARN: targetGroupARN,
+//		Port:           8080,
+//	})
+//
+// The wait is bounded by the target group's deregistration delay and by ctx, which should expire
+// before the task's stop timeout.
+type TargetGroupDrain struct {
+	Client         ELBClient
+	TargetGroupARN string
+	// TargetID is the task's target, its IP address for awsvpc tasks. Defaults to the task's IPv4
+	// address from the task metadata endpoint.
+	TargetID string
+	// Port is the port the task is registered on.
+	Port int32
+	// PollInterval is the time between target health checks. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+}
+
+// Drain implements ecstp.DrainStep.
+func (d *TargetGroupDrain) Drain(ctx context.Context) error {
+	targetID := d.TargetID
+	if targetID == "" {
+		var err error
+		if targetID, err = TaskIPv4Address(ctx); err != nil {
+			return fmt.Errorf("unable to resolve target ID: %w", err)
+		}
+	}
+	target := []types.TargetDescription{{Id: aws.String(targetID)}}
+	if d.Port != 0 {
+		target[0].Port = aws.Int32(d.Port)
+	}
+
+	if _, err := d.Client.DeregisterTargets(ctx, &elbv2.DeregisterTargetsInput{
+		TargetGroupArn: aws.String(d.TargetGroupARN),
+		Targets:        target,
+	}); err != nil {
+		return err
+	}
+
+	interval := d.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		out, err := d.Client.DescribeTargetHealth(ctx, &elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: aws.String(d.TargetGroupARN),
+			Targets:        target,
+		})
+		var invalid *types.InvalidTargetException
+		if errors.As(err, &invalid) {
+			// the target is no longer known to the target group
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if drained(out.TargetHealthDescriptions, targetID) {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("target %s still draining: %w", targetID, ctx.Err())
+		}
+	}
+}
+
+// drained reports whether the target has finished draining.
+func drained(descriptions []types.TargetHealthDescription, targetID string) bool {
+	for _, desc := range descriptions {
+		if desc.Target == nil || aws.ToString(desc.Target.Id) != targetID || desc.TargetHealth == nil {
+			continue
+		}
+		if desc.TargetHealth.State != types.TargetHealthStateEnumUnused {
+			return false
+		}
+	}
+
+	return true
+}
+
+// taskMetadata is the subset of the task metadata response describing the task's networks.
+type taskMetadata struct {
+	Containers []struct {
+		Networks []struct {
+			IPv4Addresses []string `json:"IPv4Addresses"`
+		} `json:"Networks"`
+	} `json:"Containers"`
+}
+
+// TaskIPv4Address returns the task's private IPv4 address from the task metadata endpoint, which
+// is its target ID in target groups of the ip target type.
+func TaskIPv4Address(ctx context.Context) (string, error) {
+	endpoint, ok := os.LookupEnv("ECS_CONTAINER_METADATA_URI_V4")
+	if !ok {
+		// the version 3 endpoint set by older agents reports the same networks
+		if endpoint, ok = os.LookupEnv("ECS_CONTAINER_METADATA_URI"); !ok {
+			return "", errors.New("can't get Metadata URI")
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/task", nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var metadata taskMetadata
+	if err := json.NewDecoder(res.Body).Decode(&metadata); err != nil {
+		return "", err
+	}
+	for _, container := range metadata.Containers {
+		for _, network := range container.Networks {
+			if len(network.IPv4Addresses) > 0 {
+				return network.IPv4Addresses[0], nil
+			}
+		}
+	}
+
+	return "", errors.New("task metadata has no IPv4 address")
+}
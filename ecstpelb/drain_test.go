@@ -0,0 +1,135 @@
+package ecstpelb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testELBClient reports the target as draining for a number of health checks after deregistration.
+type testELBClient struct {
+	drainingChecks int
+	deregisterErr  error
+	forget         bool
+
+	deregistered []types.TargetDescription
+	checks       int
+}
+
+func (c *testELBClient) DeregisterTargets(
+	ctx context.Context, params *elbv2.DeregisterTargetsInput, optFns ...func(*elbv2.Options),
+) (*elbv2.DeregisterTargetsOutput, error) {
+	if c.deregisterErr != nil {
+		return nil, c.deregisterErr
+	}
+	c.deregistered = params.Targets
+
+	return &elbv2.DeregisterTargetsOutput{}, nil
+}
+
+func (c *testELBClient) DescribeTargetHealth(
+	ctx context.Context, params *elbv2.DescribeTargetHealthInput, optFns ...func(*elbv2.Options),
+) (*elbv2.DescribeTargetHealthOutput, error) {
+	c.checks++
+	if c.forget {
+		return nil, &types.InvalidTargetException{Message: aws.String("target not registered")}
+	}
+
+	state := types.TargetHealthStateEnumUnused
+	if c.checks <= c.drainingChecks {
+		state = types.TargetHealthStateEnumDraining
+	}
+
+	return &elbv2.DescribeTargetHealthOutput{
+		TargetHealthDescriptions: []types.TargetHealthDescription{{
+			Target:       &params.Targets[0],
+			TargetHealth: &types.TargetHealth{State: state},
+		}},
+	}, nil
+}
+
+func TestTargetGroupDrain_Drain(t *testing.T) {
+	tests := []struct {
+		name       string
+		client     *testELBClient
+		timeout    time.Duration
+		wantChecks int
+		wantErr    bool
+	}{
+		{
+			name:       "should wait for draining to complete",
+			client:     &testELBClient{drainingChecks: 2},
+			timeout:    time.Second,
+			wantChecks: 3,
+		},
+		{
+			name:       "should finish once the target is unknown",
+			client:     &testELBClient{forget: true},
+			timeout:    time.Second,
+			wantChecks: 1,
+		},
+		{
+			name:    "should fail when deregistration fails",
+			client:  &testELBClient{deregisterErr: errors.New("AccessDenied")},
+			timeout: time.Second,
+			wantErr: true,
+		},
+		{
+			name:       "should give up when ctx is done",
+			client:     &testELBClient{drainingChecks: 1000},
+			timeout:    50 * time.Millisecond,
+			wantChecks: -1,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &TargetGroupDrain{
+				Client:         tt.client,
+				TargetGroupARN: "arn:aws:elasticloadbalancing:eu-west-2:123456789012:targetgroup/web/abc",
+				TargetID:       "10.0.0.1",
+				Port:           8080,
+				PollInterval:   time.Millisecond,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			err := d.Drain(ctx)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				require.Len(t, tt.client.deregistered, 1)
+				assert.Equal(t, "10.0.0.1", aws.ToString(tt.client.deregistered[0].Id))
+				assert.Equal(t, int32(8080), aws.ToInt32(tt.client.deregistered[0].Port))
+			}
+			if tt.wantChecks >= 0 {
+				assert.Equal(t, tt.wantChecks, tt.client.checks)
+			}
+		})
+	}
+}
+
+func TestTaskIPv4Address(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Cluster":"test_cluster","Containers":[{"Name":"app","Networks":[{"NetworkMode":"awsvpc","IPv4Addresses":["10.0.0.1"]}]}]}`)
+	}))
+	defer ts.Close()
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", ts.URL)
+
+	got, err := TaskIPv4Address(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", got)
+}
@@ -0,0 +1,159 @@
+// Package ecstpgql keeps an ECS task protected while it executes expensive GraphQL operations with
+// github.com/99designs/gqlgen, e.g. large exports, without protecting it for cheap queries.
+package ecstpgql
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// Operation describes a GraphQL operation being executed.
+type Operation struct {
+	Name string
+	// Complexity is the complexity of the operation, as calculated by gqlgen's complexity
+	// extension.
+	Complexity int
+}
+
+// Extension is a gqlgen handler extension protecting the task while expensive operations execute.
+// S, H and R are gqlgen's graphql.ExecutableSchema, graphql.ResponseHandler and *graphql.Response:
+//
+//	srv.Use(&ecstpgql.Extension[graphql.ExecutableSchema, graphql.ResponseHandler, *graphql.Response]{
+//		Manager: manager,
+//		Operation: func(ctx context.Context) ecstpgql.Operation {
+//			op := ecstpgql.Operation{Name: graphql.GetOperationContext(ctx).OperationName}
+//			if stats := extension.GetComplexityStats(ctx); stats != nil {
+//				op.Complexity = stats.Complexity
+//			}
+//			return op
+//		},
+//		ComplexityThreshold: 500,
+//		DurationThreshold:   10 * time.Second,
+//	})
+//
+// An operation is protected from the start if its complexity reaches ComplexityThreshold, or once
+// it has executed for DurationThreshold otherwise. With neither set, every operation is protected.
+// Operations named in Deny, or not named in Allow if it's set, are never protected. Failing to
+// enable protection is logged and doesn't fail the operation.
+//
+// Each protected operation acquires a Hold of Manager, so protection is enabled when the first
+// operation is protected and renewed as decided by the Renewal of the Client's Profile until the
+// last one completes, at which point protection is disabled.
+type Extension[S any, H ~func(context.Context) R, R any] struct {
+	Manager *ecstp.Manager
+	// Operation describes the operation being executed by ctx.
+	Operation func(ctx context.Context) Operation
+	// ComplexityThreshold, if set, is the complexity from which operations are protected.
+	ComplexityThreshold int
+	// DurationThreshold, if set, is the execution time after which operations are protected.
+	DurationThreshold time.Duration
+	// Allow, if set, are the names of the only operations that may be protected.
+	Allow []string
+	// Deny are the names of operations never protected.
+	Deny   []string
+	Logger *slog.Logger
+}
+
+// ExtensionName implements graphql.HandlerExtension.
+func (e *Extension[S, H, R]) ExtensionName() string {
+	return "TaskProtection"
+}
+
+// Validate implements graphql.HandlerExtension.
+func (e *Extension[S, H, R]) Validate(schema S) error {
+	return nil
+}
+
+// InterceptResponse implements graphql.ResponseInterceptor, protecting the task while next executes
+// an expensive operation.
+func (e *Extension[S, H, R]) InterceptResponse(ctx context.Context, next H) R {
+	op := e.Operation(ctx)
+	if !e.allowed(op.Name) {
+		return next(ctx)
+	}
+	if op.Name != "" {
+		ctx = ecstp.ContextWithLabels(ctx, map[string]string{"operation": op.Name})
+	}
+
+	thresholds := e.ComplexityThreshold > 0 || e.DurationThreshold > 0
+	if !thresholds || e.ComplexityThreshold > 0 && op.Complexity >= e.ComplexityThreshold {
+		if hold := e.acquire(ctx, op); hold != nil {
+			defer e.release(ctx, hold)
+		}
+		return next(ctx)
+	}
+	if e.DurationThreshold <= 0 {
+		return next(ctx)
+	}
+
+	var (
+		mu   sync.Mutex
+		done bool
+		hold *ecstp.Hold
+	)
+	timer := time.AfterFunc(e.DurationThreshold, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !done {
+			hold = e.acquire(ctx, op)
+		}
+	})
+	defer func() {
+		timer.Stop()
+		mu.Lock()
+		done = true
+		mu.Unlock()
+		if hold != nil {
+			e.release(ctx, hold)
+		}
+	}()
+
+	return next(ctx)
+}
+
+// allowed reports whether the operation called name may be protected.
+func (e *Extension[S, H, R]) allowed(name string) bool {
+	if slices.Contains(e.Deny, name) {
+		return false
+	}
+
+	return len(e.Allow) == 0 || slices.Contains(e.Allow, name)
+}
+
+// acquire protects the task for op, returning its hold, or nil if protection failed.
+func (e *Extension[S, H, R]) acquire(ctx context.Context, op Operation) *ecstp.Hold {
+	hold, err := e.Manager.Acquire(ctx)
+	if err != nil {
+		e.logger().ErrorContext(ctx, "unable to protect task for GraphQL operation",
+			slog.String("operation", op.Name),
+			slog.Int("complexity", op.Complexity),
+			slog.Any("error", err),
+		)
+		return nil
+	}
+
+	return hold
+}
+
+// release releases the hold of an operation, disabling protection if it was the last.
+func (e *Extension[S, H, R]) release(ctx context.Context, hold *ecstp.Hold) {
+	// released once the operation completes, even if its request was canceled meanwhile
+	if err := hold.Release(context.WithoutCancel(ctx)); err != nil {
+		e.logger().ErrorContext(ctx, "unable to disable protection after GraphQL operations",
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (e *Extension[S, H, R]) logger() *slog.Logger {
+	if e.Logger == nil {
+		return slog.Default()
+	}
+
+	return e.Logger
+}
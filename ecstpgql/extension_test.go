@@ -0,0 +1,149 @@
+package ecstpgql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+// testResponse and testResponseHandler stand in for gqlgen's *graphql.Response and
+// graphql.ResponseHandler.
+type testResponse struct {
+	data string
+}
+
+type testResponseHandler func(ctx context.Context) *testResponse
+
+type testExtension = Extension[any, testResponseHandler, *testResponse]
+
+func TestExtension_InterceptResponse(t *testing.T) {
+	tests := []struct {
+		name                string
+		ecsErr              error
+		op                  Operation
+		complexityThreshold int
+		durationThreshold   time.Duration
+		allow               []string
+		deny                []string
+		duration            time.Duration
+		wantProtected       bool
+	}{
+		{
+			name:          "should protect every operation without thresholds",
+			op:            Operation{Name: "Users", Complexity: 1},
+			wantProtected: true,
+		},
+		{
+			name:                "should protect operations reaching the complexity threshold",
+			op:                  Operation{Name: "Export", Complexity: 500},
+			complexityThreshold: 500,
+			wantProtected:       true,
+		},
+		{
+			name:                "should not protect operations below the complexity threshold",
+			op:                  Operation{Name: "Users", Complexity: 499},
+			complexityThreshold: 500,
+		},
+		{
+			name:              "should protect operations exceeding the duration threshold",
+			op:                Operation{Name: "Export"},
+			durationThreshold: 10 * time.Millisecond,
+			duration:          time.Second,
+			wantProtected:     true,
+		},
+		{
+			name:              "should not protect operations completing within the duration threshold",
+			op:                Operation{Name: "Users"},
+			durationThreshold: time.Second,
+		},
+		{
+			name:                "should protect cheap operations exceeding the duration threshold",
+			op:                  Operation{Name: "Export", Complexity: 1},
+			complexityThreshold: 500,
+			durationThreshold:   10 * time.Millisecond,
+			duration:            time.Second,
+			wantProtected:       true,
+		},
+		{
+			name: "should not protect denied operations",
+			op:   Operation{Name: "Users"},
+			deny: []string{"Users"},
+		},
+		{
+			name:  "should not protect operations missing from the allow list",
+			op:    Operation{Name: "Users"},
+			allow: []string{"Export"},
+		},
+		{
+			name:   "should execute operations if protection fails",
+			ecsErr: errors.New("throttled"),
+			op:     Operation{Name: "Export"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &ecstptest.ECSClient{}
+			ecsClient.SetErr(tt.ecsErr)
+			e := &testExtension{
+				Manager:             ecstptest.NewManager(ecsClient),
+				Operation:           func(ctx context.Context) Operation { return tt.op },
+				ComplexityThreshold: tt.complexityThreshold,
+				DurationThreshold:   tt.durationThreshold,
+				Allow:               tt.allow,
+				Deny:                tt.deny,
+			}
+
+			protected := false
+			res := e.InterceptResponse(context.Background(), func(ctx context.Context) *testResponse {
+				// long operations run until they're protected, or for at most duration
+				deadline := time.Now().Add(tt.duration)
+				for {
+					protected = e.Manager.State().Protected
+					if protected || !time.Now().Before(deadline) {
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+				return &testResponse{data: "ok"}
+			})
+
+			assert.Equal(t, &testResponse{data: "ok"}, res)
+			assert.Equal(t, tt.wantProtected, protected)
+			assert.False(t, e.Manager.State().Protected, "protection should be disabled once the operation completes")
+		})
+	}
+}
+
+func TestExtension_InterceptResponse_Concurrent(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	e := &testExtension{
+		Manager:   ecstptest.NewManager(ecsClient),
+		Operation: func(ctx context.Context) Operation { return Operation{Name: "Export"} },
+	}
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.InterceptResponse(context.Background(), func(ctx context.Context) *testResponse {
+			close(started)
+			<-finish
+			return nil
+		})
+	}()
+	<-started
+
+	e.InterceptResponse(context.Background(), func(ctx context.Context) *testResponse { return nil })
+	assert.True(t, e.Manager.State().Protected, "protection should be held by the running operation")
+
+	close(finish)
+	<-done
+	assert.False(t, e.Manager.State().Protected)
+	protects, _ := ecsClient.Updates()
+	assert.Equal(t, 1, protects, "protection should only be enabled once")
+}
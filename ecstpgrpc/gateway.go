@@ -0,0 +1,115 @@
+package ecstpgrpc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+const (
+	// metadataKey is the gRPC metadata key tagging calls proxied by the gateway middleware.
+	metadataKey = "ecstp-counted"
+	// gatewayHeader is the HTTP header grpc-gateway forwards as metadataKey by default.
+	gatewayHeader = "Grpc-Metadata-" + metadataKey
+)
+
+// GatewayMiddleware returns an http.Handler keeping the task protected while next, typically a
+// grpc-gateway runtime.ServeMux, handles requests:
+//
+//	http.ListenAndServe(addr, guard.GatewayMiddleware(gatewayMux))
+//
+// Requests are tagged with a header that grpc-gateway's default header matcher forwards as gRPC
+// metadata, so the interceptors of g don't count them again when they're proxied to the local gRPC
+// server. A custom header matcher must forward the Grpc-Metadata- prefix.
+//
+// Streaming responses, e.g. server-sent events or chunked downloads, are counted until the response
+// body has been flushed to the client or the client disconnects, whichever comes first. A hijacked
+// connection is counted until it's closed.
+func (g *Guard) GatewayMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		end := g.Begin(r.Context())
+		// the request context is also canceled if the client disconnects midway through a stream
+		stop := context.AfterFunc(r.Context(), end)
+
+		sw := &streamWriter{ResponseWriter: w, end: end, stop: stop}
+		r = r.Clone(r.Context())
+		r.Header.Set(gatewayHeader, g.gatewayToken())
+		next.ServeHTTP(sw, r)
+
+		hijacked, streamed := sw.status()
+		if hijacked {
+			return
+		}
+		if streamed {
+			// flush the rest of the stream before the request is no longer counted
+			http.NewResponseController(w).Flush()
+		}
+		end()
+	})
+}
+
+// streamWriter is an http.ResponseWriter recording whether the response is streamed, i.e. flushed
+// by the handler, and whether the connection was hijacked, in which case the request is counted
+// until the connection is closed.
+type streamWriter struct {
+	http.ResponseWriter
+	end  func()
+	stop func() bool
+
+	mu       sync.Mutex
+	hijacked bool
+	streamed bool
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements http.Flusher.
+func (w *streamWriter) Flush() {
+	w.mu.Lock()
+	w.streamed = true
+	w.mu.Unlock()
+
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker.
+func (w *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop() {
+		// still counted, until the connection is closed
+		w.hijacked = true
+		conn = &hijackedConn{Conn: conn, end: w.end}
+	}
+
+	return conn, rw, nil
+}
+
+func (w *streamWriter) status() (hijacked, streamed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.hijacked, w.streamed
+}
+
+// hijackedConn is a hijacked connection ending the count of its request once it's closed.
+type hijackedConn struct {
+	net.Conn
+	end func()
+}
+
+func (c *hijackedConn) Close() error {
+	defer c.end()
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,145 @@
+package ecstpgrpc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+func TestGuard_GatewayMiddleware(t *testing.T) {
+	client := &ecstptest.ECSClient{}
+	g := newTestGuard(client)
+	g.IncomingMetadata = incomingMetadata
+	interceptor := UnaryServerInterceptor[*testUnaryServerInfo, testUnaryHandler](g)
+
+	var inFlight []int
+	// gateway stands in for a grpc-gateway mux proxying to the local gRPC server, forwarding
+	// Grpc-Metadata- headers as metadata
+	gateway := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md := map[string][]string{}
+		for name, values := range r.Header {
+			if key, ok := strings.CutPrefix(name, "Grpc-Metadata-"); ok {
+				md[strings.ToLower(key)] = values
+			}
+		}
+		inFlight = append(inFlight, g.InFlight())
+
+		_, err := interceptor(withIncomingMetadata(context.Background(), md), "req", &testUnaryServerInfo{},
+			func(ctx context.Context, req any) (any, error) {
+				inFlight = append(inFlight, g.InFlight())
+				return nil, nil
+			})
+		assert.NoError(t, err)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/things", nil)
+	req.Header.Set("Grpc-Metadata-Ecstp-Counted", "forged")
+	rec := httptest.NewRecorder()
+	g.GatewayMiddleware(gateway).ServeHTTP(rec, req)
+
+	assert.Equal(t, []int{1, 1}, inFlight, "proxied requests should only be counted once")
+	assert.Equal(t, "forged", req.Header.Get("Grpc-Metadata-Ecstp-Counted"), "the caller's request shouldn't be modified")
+	protects, _ := client.Updates()
+	assert.Equal(t, 1, protects)
+	assert.Equal(t, int64(0), g.Work.Value())
+	assert.False(t, g.Manager.State().Protected)
+}
+
+func TestGuard_GatewayMiddleware_Streaming(t *testing.T) {
+	g := newTestGuard(&ecstptest.ECSClient{})
+
+	sent, release := make(chan struct{}), make(chan struct{})
+	handlerDone := make(chan struct{})
+	server := httptest.NewServer(g.GatewayMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(handlerDone)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		close(sent)
+		// a handler ignoring the disconnect keeps running
+		<-release
+	})))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	res, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "data: 1\n", line)
+	<-sent
+	assert.Equal(t, 1, g.InFlight(), "streams should be counted while they're open")
+	assert.True(t, g.Manager.State().Protected)
+
+	cancel()
+	assert.Eventually(t, func() bool { return g.InFlight() == 0 }, time.Second, 5*time.Millisecond,
+		"streams should no longer be counted once the client disconnects")
+	assert.False(t, g.Manager.State().Protected)
+	select {
+	case <-handlerDone:
+		t.Fatal("handler should still be running")
+	default:
+	}
+}
+
+func TestGuard_GatewayMiddleware_Buffered(t *testing.T) {
+	g := newTestGuard(&ecstptest.ECSClient{})
+	handler := g.GatewayMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, "ok", rec.Body.String())
+	assert.False(t, rec.Flushed, "buffered responses shouldn't be flushed")
+	assert.Equal(t, 0, g.InFlight())
+}
+
+func TestGuard_GatewayMiddleware_Hijacked(t *testing.T) {
+	g := newTestGuard(&ecstptest.ECSClient{})
+
+	conns := make(chan net.Conn, 1)
+	server := httptest.NewServer(g.GatewayMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		conns <- conn
+	})))
+	defer server.Close()
+
+	client, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	fmt.Fprint(client, "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+	res, err := http.ReadResponse(bufio.NewReader(client), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+
+	conn := <-conns
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, g.InFlight(), "hijacked connections should be counted until they're closed")
+
+	require.NoError(t, conn.Close())
+	assert.Equal(t, 0, g.InFlight())
+	assert.False(t, g.Manager.State().Protected)
+}
@@ -0,0 +1,50 @@
+// Package ecstpgrpc keeps an ECS task protected while it serves gRPC requests, including those
+// proxied by an in-process grpc-gateway, so a scale-in doesn't interrupt a call midway.
+//
+// The gRPC interceptors and the gateway middleware of a Guard share its WorkGauge. A request
+// proxied by the gateway to the local gRPC server is tagged so that it's only counted once.
+package ecstpgrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"github.com/Thumbscrew/ecs-task-protection/ecstphttp"
+)
+
+// Guard protects the task while any request is in flight, like an ecstphttp.Guard, which it embeds
+// to count requests and hold protection:
+//
+//	guard := &ecstpgrpc.Guard{
+//		Guard:            ecstphttp.Guard{Manager: manager},
+//		IncomingMetadata: metadata.ValueFromIncomingContext,
+//	}
+//
+// Renewal and the delay before protection is disabled after the last request are those of the
+// Client's Profile. It's safe for concurrent use.
+type Guard struct {
+	ecstphttp.Guard
+	// IncomingMetadata returns the values of the gRPC metadata key of an incoming call, and is
+	// typically metadata.ValueFromIncomingContext. It's required to recognize requests already
+	// counted by the gateway middleware.
+	IncomingMetadata func(ctx context.Context, key string) []string
+
+	tokenOnce sync.Once
+	token     string
+}
+
+// gatewayToken returns the value tagging requests counted by the gateway middleware. It's random,
+// so clients can't tag their own requests to avoid being counted.
+func (g *Guard) gatewayToken() string {
+	g.tokenOnce.Do(func() {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		g.token = hex.EncodeToString(b)
+	})
+
+	return g.token
+}
@@ -0,0 +1,94 @@
+package ecstpgrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstphttp"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+func newTestGuard(client *ecstptest.ECSClient, opts ...ecstp.Option) *Guard {
+	return &Guard{Guard: ecstphttp.Guard{Manager: ecstptest.NewManager(client, opts...), Work: &ecstp.WorkGauge{}}}
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	client := &ecstptest.ECSClient{}
+	g := newTestGuard(client)
+
+	end1 := g.Begin(ctx)
+	end2 := g.Begin(ctx)
+	assert.True(t, g.Manager.State().Protected)
+	assert.Equal(t, 2, g.InFlight())
+	assert.Equal(t, int64(2), g.Work.Value())
+	protects, _ := client.Updates()
+	assert.Equal(t, 1, protects, "protection should only be enabled once")
+
+	end1()
+	end1()
+	assert.True(t, g.Manager.State().Protected, "task should stay protected while a request is in flight")
+	assert.Equal(t, int64(1), g.Work.Value(), "ending a request twice should only count once")
+
+	end2()
+	assert.False(t, g.Manager.State().Protected)
+	assert.Equal(t, 0, g.InFlight())
+	assert.Equal(t, int64(0), g.Work.Value())
+}
+
+func TestGuard_ProtectError(t *testing.T) {
+	ctx := context.Background()
+	client := &ecstptest.ECSClient{}
+	client.SetErr(errors.New("throttled"))
+	g := newTestGuard(client)
+
+	end1 := g.Begin(ctx)
+	assert.False(t, g.Manager.State().Protected)
+	assert.Equal(t, 1, g.InFlight(), "requests should be counted even if protection failed")
+
+	client.SetErr(nil)
+	end2 := g.Begin(ctx)
+	assert.True(t, g.Manager.State().Protected, "protection should be retried with the next request")
+
+	end1()
+	end2()
+	assert.False(t, g.Manager.State().Protected)
+}
+
+func TestGuard_Renew(t *testing.T) {
+	client := &ecstptest.ECSClient{}
+	g := newTestGuard(client, ecstp.WithProfile(ecstp.Profile{Renewal: ecstp.FixedInterval{Interval: 10 * time.Millisecond}}))
+
+	end := g.Begin(context.Background())
+	assert.Eventually(t, func() bool {
+		protects, _ := client.Updates()
+		return protects >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	end()
+	protects, _ := client.Updates()
+	time.Sleep(50 * time.Millisecond)
+	renewed, _ := client.Updates()
+	assert.Equal(t, protects, renewed, "renewal should stop once no requests are in flight")
+}
+
+func TestGuard_Debounce(t *testing.T) {
+	ctx := context.Background()
+	client := &ecstptest.ECSClient{}
+	g := newTestGuard(client, ecstp.WithProfile(ecstp.Profile{Debounce: 50 * time.Millisecond}))
+
+	for i := 0; i < 3; i++ {
+		g.Begin(ctx)()
+		assert.True(t, g.Manager.State().Protected, "protection should be kept during the debounce")
+	}
+	protects, _ := client.Updates()
+	assert.Equal(t, 1, protects, "calls within the debounce should share protection")
+
+	assert.Eventually(t, func() bool { return !g.Manager.State().Protected }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, g.InFlight())
+}
@@ -0,0 +1,51 @@
+package ecstpgrpc
+
+import (
+	"context"
+	"slices"
+)
+
+// ServerStream is the part of a gRPC server stream used by StreamServerInterceptor. It's
+// implemented by grpc.ServerStream.
+type ServerStream interface {
+	Context() context.Context
+}
+
+// UnaryServerInterceptor returns a gRPC unary server interceptor keeping the task protected while
+// calls are handled by g. I and H are grpc.UnaryServerInfo and grpc.UnaryHandler:
+//
+//	grpc.ChainUnaryInterceptor(ecstpgrpc.UnaryServerInterceptor[*grpc.UnaryServerInfo, grpc.UnaryHandler](guard))
+func UnaryServerInterceptor[I any, H ~func(ctx context.Context, req any) (any, error)](g *Guard) func(ctx context.Context, req any, info I, handler H) (any, error) {
+	return func(ctx context.Context, req any, info I, handler H) (any, error) {
+		if !g.counted(ctx) {
+			defer g.Begin(ctx)()
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC stream server interceptor keeping the task protected
+// while streams are handled by g. S, I and H are grpc.ServerStream, *grpc.StreamServerInfo and
+// grpc.StreamHandler:
+//
+//	grpc.ChainStreamInterceptor(ecstpgrpc.StreamServerInterceptor[grpc.ServerStream, *grpc.StreamServerInfo, grpc.StreamHandler](guard))
+func StreamServerInterceptor[S ServerStream, I any, H ~func(srv any, stream S) error](g *Guard) func(srv any, stream S, info I, handler H) error {
+	return func(srv any, stream S, info I, handler H) error {
+		if ctx := stream.Context(); !g.counted(ctx) {
+			defer g.Begin(ctx)()
+		}
+
+		return handler(srv, stream)
+	}
+}
+
+// counted reports whether the call of ctx was proxied by the gateway middleware, which already
+// counts it.
+func (g *Guard) counted(ctx context.Context) bool {
+	if g.IncomingMetadata == nil {
+		return false
+	}
+
+	return slices.Contains(g.IncomingMetadata(ctx, metadataKey), g.gatewayToken())
+}
@@ -0,0 +1,107 @@
+package ecstpgrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+// testUnaryServerInfo, testUnaryHandler, testStreamServerInfo and testStreamHandler stand in for
+// their grpc counterparts.
+type (
+	testUnaryServerInfo  struct{}
+	testUnaryHandler     func(ctx context.Context, req any) (any, error)
+	testStreamServerInfo struct{}
+	testStreamHandler    func(srv any, stream *testServerStream) error
+)
+
+type testServerStream struct {
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+type incomingMetadataKey struct{}
+
+// withIncomingMetadata returns a context carrying gRPC metadata, as read by incomingMetadata.
+func withIncomingMetadata(ctx context.Context, md map[string][]string) context.Context {
+	return context.WithValue(ctx, incomingMetadataKey{}, md)
+}
+
+func incomingMetadata(ctx context.Context, key string) []string {
+	md, _ := ctx.Value(incomingMetadataKey{}).(map[string][]string)
+
+	return md[key]
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	g := newTestGuard(&ecstptest.ECSClient{})
+	g.IncomingMetadata = incomingMetadata
+	interceptor := UnaryServerInterceptor[*testUnaryServerInfo, testUnaryHandler](g)
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		err          error
+		wantInFlight int
+	}{
+		{
+			name:         "should count calls while they're handled",
+			ctx:          context.Background(),
+			wantInFlight: 1,
+		},
+		{
+			name:         "should count failed calls while they're handled",
+			ctx:          context.Background(),
+			err:          errors.New("boom"),
+			wantInFlight: 1,
+		},
+		{
+			name:         "should not count calls proxied by the gateway middleware",
+			ctx:          withIncomingMetadata(context.Background(), map[string][]string{"ecstp-counted": {g.gatewayToken()}}),
+			wantInFlight: 0,
+		},
+		{
+			name:         "should count calls tagged by clients",
+			ctx:          withIncomingMetadata(context.Background(), map[string][]string{"ecstp-counted": {"forged"}}),
+			wantInFlight: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inFlight := -1
+			res, err := interceptor(tt.ctx, "req", &testUnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				inFlight = g.InFlight()
+				return "res", tt.err
+			})
+
+			assert.Equal(t, "res", res)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.wantInFlight, inFlight)
+			assert.Equal(t, 0, g.InFlight())
+			assert.False(t, g.Manager.State().Protected)
+		})
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	g := newTestGuard(&ecstptest.ECSClient{})
+	interceptor := StreamServerInterceptor[*testServerStream, *testStreamServerInfo, testStreamHandler](g)
+
+	protected := false
+	err := interceptor("srv", &testServerStream{ctx: context.Background()}, &testStreamServerInfo{},
+		func(srv any, stream *testServerStream) error {
+			protected = g.Manager.State().Protected
+			return nil
+		})
+
+	assert.NoError(t, err)
+	assert.True(t, protected, "stream should be handled while protected")
+	assert.False(t, g.Manager.State().Protected)
+}
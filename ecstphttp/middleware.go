@@ -0,0 +1,86 @@
+// Package ecstphttp keeps an ECS task protected while it serves HTTP requests with net/http, so a
+// scale-in doesn't interrupt long requests midway.
+package ecstphttp
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// Guard protects the task while any request is in flight.
+//
+// Every request acquires a Hold of Manager, so protection is enabled when the first request starts
+// and renewed as decided by the Renewal of the Client's Profile until the last one completes, at
+// which point protection is disabled after the Profile's Debounce. A Profile such as
+// ecstp.ProfileWeb debounces bursts of short requests, so they don't update protection for every
+// request. Failing to enable protection is logged and doesn't fail the request. It's safe for
+// concurrent use.
+type Guard struct {
+	Manager *ecstp.Manager
+	// Work, if set, counts the requests in flight, e.g. for an ecstp.ExpiryWatch.
+	Work   *ecstp.WorkGauge
+	Logger *slog.Logger
+
+	inFlight atomic.Int64
+}
+
+// Middleware returns an http.Handler keeping the task protected while next handles requests:
+//
+//	http.ListenAndServe(addr, guard.Middleware(mux))
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer g.Begin(r.Context())()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of requests in flight.
+func (g *Guard) InFlight() int {
+	return int(g.inFlight.Load())
+}
+
+// Begin records a request in flight, protecting the task while it is, and returns the function
+// recording its completion, which only counts once if called again. It lets requests that aren't
+// served by Middleware, e.g. the interceptors of other frameworks, share the Guard.
+func (g *Guard) Begin(ctx context.Context) (end func()) {
+	if g.Work != nil {
+		g.Work.Add(1)
+	}
+	g.inFlight.Add(1)
+
+	hold, err := g.Manager.Acquire(ctx)
+	if err != nil {
+		g.logger().ErrorContext(ctx, "unable to protect task for request", slog.Any("error", err))
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if hold != nil {
+				// the request context is canceled once the client has gone
+				ctx := context.WithoutCancel(ctx)
+				if err := hold.Release(ctx); err != nil {
+					g.logger().ErrorContext(ctx, "unable to disable protection after requests", slog.Any("error", err))
+				}
+			}
+			g.inFlight.Add(-1)
+			if g.Work != nil {
+				g.Work.Add(-1)
+			}
+		})
+	}
+}
+
+func (g *Guard) logger() *slog.Logger {
+	if g.Logger == nil {
+		return slog.Default()
+	}
+
+	return g.Logger
+}
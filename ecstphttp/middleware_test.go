@@ -0,0 +1,127 @@
+package ecstphttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+func newTestGuard(client *ecstptest.ECSClient, opts ...ecstp.Option) *Guard {
+	return &Guard{Manager: ecstptest.NewManager(client, opts...), Work: &ecstp.WorkGauge{}}
+}
+
+func TestGuard_Middleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantProtected bool
+	}{
+		{
+			name:          "should protect the task while requests are in flight",
+			wantProtected: true,
+		},
+		{
+			name: "should serve requests if protection fails",
+			err:  errors.New("throttled"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &ecstptest.ECSClient{}
+			client.SetErr(tt.err)
+			g := newTestGuard(client)
+			var protected bool
+			handler := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				protected = g.Manager.State().Protected
+				assert.Equal(t, 1, g.InFlight())
+				assert.Equal(t, int64(1), g.Work.Value())
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, http.StatusTeapot, rec.Code)
+			assert.Equal(t, tt.wantProtected, protected)
+			assert.False(t, g.Manager.State().Protected, "protection should be disabled after the last request")
+			assert.Equal(t, 0, g.InFlight())
+			assert.Equal(t, int64(0), g.Work.Value())
+		})
+	}
+}
+
+func TestGuard_Concurrent(t *testing.T) {
+	client := &ecstptest.ECSClient{}
+	g := newTestGuard(client)
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	handler := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+	<-started
+	<-started
+	assert.Equal(t, 2, g.InFlight())
+	assert.True(t, g.Manager.State().Protected)
+
+	close(release)
+	wg.Wait()
+	protects, unprotects := client.Updates()
+	assert.Equal(t, 1, protects, "protection should only be enabled once")
+	assert.Equal(t, 1, unprotects, "protection should only be disabled after the last request")
+}
+
+func TestGuard_Debounce(t *testing.T) {
+	client := &ecstptest.ECSClient{}
+	g := newTestGuard(client, ecstp.WithProfile(ecstp.Profile{Debounce: 50 * time.Millisecond}))
+	handler := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.True(t, g.Manager.State().Protected, "protection should be kept during the debounce")
+	}
+	protects, unprotects := client.Updates()
+	assert.Equal(t, 1, protects)
+	assert.Equal(t, 0, unprotects)
+
+	assert.Eventually(t, func() bool { return !g.Manager.State().Protected }, time.Second, 5*time.Millisecond)
+	_, unprotects = client.Updates()
+	assert.Equal(t, 1, unprotects)
+}
+
+func TestGuard_Renewal(t *testing.T) {
+	client := &ecstptest.ECSClient{}
+	g := newTestGuard(client, ecstp.WithProfile(ecstp.Profile{Renewal: ecstp.FixedInterval{Interval: 10 * time.Millisecond}}))
+	done := make(chan struct{})
+	handler := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-done }))
+
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Eventually(t, func() bool {
+		protects, _ := client.Updates()
+		return protects >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	close(done)
+	assert.Eventually(t, func() bool { return g.InFlight() == 0 }, time.Second, 5*time.Millisecond)
+	protects, _ := client.Updates()
+	time.Sleep(50 * time.Millisecond)
+	renewed, _ := client.Updates()
+	assert.Equal(t, protects, renewed, "renewal should stop with the last request")
+}
@@ -0,0 +1,30 @@
+package ecstpkafka
+
+import (
+	"context"
+	"log/slog"
+)
+
+// OnPartitionsAssigned returns a hook recording assigned partitions with g, for the
+// kgo.OnPartitionsAssigned option of franz-go. C is the client type, *kgo.Client.
+func OnPartitionsAssigned[C any](g *PartitionGuard) func(ctx context.Context, client C, assigned map[string][]int32) {
+	return func(ctx context.Context, client C, assigned map[string][]int32) {
+		g.Assigned(assigned)
+	}
+}
+
+// OnPartitionsRevoked returns a hook recording revoked partitions with g, for the
+// kgo.OnPartitionsRevoked and kgo.OnPartitionsLost options of franz-go. C is the client type,
+// *kgo.Client. Errors disabling protection are logged.
+//
+// franz-go accepts a single hook per option, so a consumer committing on revocation should call
+// PartitionGuard.Revoked from its own hook after committing instead.
+func OnPartitionsRevoked[C any](g *PartitionGuard) func(ctx context.Context, client C, revoked map[string][]int32) {
+	return func(ctx context.Context, client C, revoked map[string][]int32) {
+		if err := g.Revoked(ctx, revoked); err != nil {
+			g.logger().ErrorContext(ctx, "unable to disable protection for revoked partitions",
+				slog.Any("error", err),
+			)
+		}
+	}
+}
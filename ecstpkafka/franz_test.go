@@ -0,0 +1,29 @@
+package ecstpkafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+// testKafkaClient stands in for *kgo.Client.
+type testKafkaClient struct{}
+
+func TestOnPartitionsHooks(t *testing.T) {
+	ctx := context.Background()
+	guard, manager := newTestGuard(&ecstptest.ECSClient{})
+	assigned := OnPartitionsAssigned[*testKafkaClient](guard)
+	revoked := OnPartitionsRevoked[*testKafkaClient](guard)
+
+	assigned(ctx, &testKafkaClient{}, map[string][]int32{"orders": {0, 1}})
+	assert.Equal(t, []TopicPartition{{"orders", 0}, {"orders", 1}}, guard.Partitions())
+
+	require.NoError(t, guard.Received(ctx, "orders", 1, 3))
+	revoked(ctx, &testKafkaClient{}, map[string][]int32{"orders": {1}})
+	assert.Equal(t, []TopicPartition{{"orders", 0}}, guard.Partitions())
+	assert.False(t, manager.State().Protected, "revoking the last uncommitted partition should unprotect the task")
+}
@@ -0,0 +1,240 @@
+// Package ecstpkafka keeps an ECS task protected while it consumes Kafka partitions with records
+// that haven't been processed and committed, so a scale-in doesn't force them to be reprocessed by
+// another member of the consumer group. It integrates with github.com/IBM/sarama and
+// github.com/twmb/franz-go without depending on either.
+package ecstpkafka
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// TopicPartition identifies a partition of a topic.
+type TopicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+// PartitionGuard protects the task while any assigned partition has uncommitted records. With
+// franz-go, it's driven once per poll:
+//
+//	client, err := kgo.NewClient(
+//		kgo.OnPartitionsAssigned(ecstpkafka.OnPartitionsAssigned[*kgo.Client](guard)),
+//		kgo.OnPartitionsRevoked(ecstpkafka.OnPartitionsRevoked[*kgo.Client](guard)),
+//		kgo.OnPartitionsLost(ecstpkafka.OnPartitionsRevoked[*kgo.Client](guard)),
+//		...
+//	)
+//	fetches := client.PollFetches(ctx)
+//	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+//		guard.Received(ctx, p.Topic, p.Partition, len(p.Records))
+//	})
+//	// process the records
+//	err = client.CommitUncommittedOffsets(ctx)
+//	guard.CommittedAll(ctx)
+//
+// With sarama, wrap the group's handler with a ConsumerGroupHandler instead.
+//
+// Every partition with uncommitted records acquires a Hold of Manager, so protection is enabled
+// once records are received and renewed as decided by the Renewal of the Client's Profile until
+// every assigned partition is committed or revoked, at which point protection is disabled. It's
+// safe for concurrent use.
+type PartitionGuard struct {
+	Manager *ecstp.Manager
+	// Work, if set, tracks the number of uncommitted records, e.g. for an ecstp.ExpiryWatch.
+	Work   *ecstp.WorkGauge
+	Logger *slog.Logger
+
+	mu         sync.Mutex
+	partitions map[TopicPartition]*partition
+}
+
+// partition is an assigned partition.
+type partition struct {
+	// pending is the number of uncommitted records.
+	pending int
+	// hold is held while pending isn't zero.
+	hold *ecstp.Hold
+}
+
+// Assigned records that the partitions of assigned, keyed by topic, have been assigned to the
+// consumer.
+func (g *PartitionGuard) Assigned(assigned map[string][]int32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for topic, partitions := range assigned {
+		for _, partition := range partitions {
+			g.partitionLocked(TopicPartition{Topic: topic, Partition: partition})
+		}
+	}
+}
+
+// Received records n records of a partition as uncommitted, enabling protection if they're the
+// first. It should be called before the records are processed. If enabling protection fails, the
+// records are still recorded and protection is retried with the next call.
+func (g *PartitionGuard) Received(ctx context.Context, topic string, partition int32, n int) error {
+	tp := TopicPartition{Topic: topic, Partition: partition}
+
+	g.mu.Lock()
+	p := g.partitionLocked(tp)
+	g.setPendingLocked(p, p.pending+n)
+	held := p.hold != nil || p.pending == 0
+	g.mu.Unlock()
+	if held {
+		return nil
+	}
+
+	hold, err := g.Manager.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	if p, ok := g.partitions[tp]; ok && p.hold == nil && p.pending > 0 {
+		p.hold, hold = hold, nil
+	}
+	g.mu.Unlock()
+	if hold != nil {
+		// the partition was committed or revoked meanwhile
+		return hold.Release(ctx)
+	}
+
+	return nil
+}
+
+// Committed records that every record received for a partition has been committed, disabling
+// protection if no other partition has uncommitted records.
+func (g *PartitionGuard) Committed(ctx context.Context, topic string, partition int32) error {
+	g.mu.Lock()
+	var holds []*ecstp.Hold
+	if p, ok := g.partitions[TopicPartition{Topic: topic, Partition: partition}]; ok {
+		holds = g.commitLocked(p, holds)
+	}
+	g.mu.Unlock()
+
+	return release(ctx, holds)
+}
+
+// CommittedAll records that every record received has been committed, disabling protection.
+func (g *PartitionGuard) CommittedAll(ctx context.Context) error {
+	g.mu.Lock()
+	var holds []*ecstp.Hold
+	for _, p := range g.partitions {
+		holds = g.commitLocked(p, holds)
+	}
+	g.mu.Unlock()
+
+	return release(ctx, holds)
+}
+
+// Revoked records that the partitions of revoked, keyed by topic, have been revoked or lost,
+// discarding their uncommitted records and disabling protection if no other partition has any.
+// Records that should survive a rebalance must be committed before it's called.
+func (g *PartitionGuard) Revoked(ctx context.Context, revoked map[string][]int32) error {
+	g.mu.Lock()
+	var holds []*ecstp.Hold
+	for topic, partitions := range revoked {
+		for _, partition := range partitions {
+			tp := TopicPartition{Topic: topic, Partition: partition}
+			if p, ok := g.partitions[tp]; ok {
+				holds = g.commitLocked(p, holds)
+				delete(g.partitions, tp)
+			}
+		}
+	}
+	g.mu.Unlock()
+
+	return release(ctx, holds)
+}
+
+// Partitions returns the assigned partitions, sorted by topic and partition.
+func (g *PartitionGuard) Partitions() []TopicPartition {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	partitions := make([]TopicPartition, 0, len(g.partitions))
+	for tp := range g.partitions {
+		partitions = append(partitions, tp)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+
+	return partitions
+}
+
+// Pending returns the number of uncommitted records across all assigned partitions.
+func (g *PartitionGuard) Pending() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.pendingLocked()
+}
+
+func (g *PartitionGuard) pendingLocked() int {
+	pending := 0
+	for _, p := range g.partitions {
+		pending += p.pending
+	}
+
+	return pending
+}
+
+// partitionLocked returns the assigned partition tp, recording it if it isn't already.
+func (g *PartitionGuard) partitionLocked(tp TopicPartition) *partition {
+	if g.partitions == nil {
+		g.partitions = make(map[TopicPartition]*partition)
+	}
+	p, ok := g.partitions[tp]
+	if !ok {
+		p = &partition{}
+		g.partitions[tp] = p
+	}
+
+	return p
+}
+
+// setPendingLocked sets the number of uncommitted records of p, keeping Work in step.
+func (g *PartitionGuard) setPendingLocked(p *partition, n int) {
+	if g.Work != nil {
+		g.Work.Add(int64(n - p.pending))
+	}
+	p.pending = n
+}
+
+// commitLocked discards the uncommitted records of p, appending its hold, if any, to holds.
+func (g *PartitionGuard) commitLocked(p *partition, holds []*ecstp.Hold) []*ecstp.Hold {
+	g.setPendingLocked(p, 0)
+	if p.hold != nil {
+		holds = append(holds, p.hold)
+		p.hold = nil
+	}
+
+	return holds
+}
+
+// release releases holds, disabling protection if they're the last.
+func release(ctx context.Context, holds []*ecstp.Hold) error {
+	var errs []error
+	for _, hold := range holds {
+		errs = append(errs, hold.Release(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (g *PartitionGuard) logger() *slog.Logger {
+	if g.Logger == nil {
+		return slog.Default()
+	}
+
+	return g.Logger
+}
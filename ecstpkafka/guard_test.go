@@ -0,0 +1,92 @@
+package ecstpkafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+func newTestGuard(client *ecstptest.ECSClient, opts ...ecstp.Option) (*PartitionGuard, *ecstp.Manager) {
+	manager := ecstptest.NewManager(client, opts...)
+
+	return &PartitionGuard{Manager: manager, Work: &ecstp.WorkGauge{}}, manager
+}
+
+func TestPartitionGuard(t *testing.T) {
+	ctx := context.Background()
+	client := &ecstptest.ECSClient{}
+	guard, manager := newTestGuard(client)
+
+	guard.Assigned(map[string][]int32{"orders": {1, 0}, "audit": {0}})
+	assert.Equal(t, []TopicPartition{{"audit", 0}, {"orders", 0}, {"orders", 1}}, guard.Partitions())
+	assert.False(t, manager.State().Protected, "assigned partitions without records shouldn't protect the task")
+
+	require.NoError(t, guard.Received(ctx, "orders", 0, 10))
+	require.NoError(t, guard.Received(ctx, "orders", 1, 5))
+	assert.True(t, manager.State().Protected)
+	assert.Equal(t, 15, guard.Pending())
+	assert.Equal(t, int64(15), guard.Work.Value())
+	protects, _ := client.Updates()
+	assert.Equal(t, 1, protects, "protection should only be enabled once")
+
+	require.NoError(t, guard.Committed(ctx, "orders", 0))
+	assert.True(t, manager.State().Protected, "task should stay protected while orders/1 is uncommitted")
+	assert.Equal(t, int64(5), guard.Work.Value())
+
+	require.NoError(t, guard.Revoked(ctx, map[string][]int32{"orders": {1}, "unknown": {3}}))
+	assert.False(t, manager.State().Protected)
+	assert.Equal(t, 0, guard.Pending())
+	assert.Equal(t, int64(0), guard.Work.Value())
+	assert.Equal(t, []TopicPartition{{"audit", 0}, {"orders", 0}}, guard.Partitions())
+
+	require.NoError(t, guard.Received(ctx, "audit", 0, 1))
+	require.NoError(t, guard.Received(ctx, "orders", 0, 1))
+	assert.True(t, manager.State().Protected)
+	protects, _ = client.Updates()
+	assert.Equal(t, 2, protects)
+
+	require.NoError(t, guard.CommittedAll(ctx))
+	assert.False(t, manager.State().Protected)
+	assert.Equal(t, int64(0), guard.Work.Value())
+}
+
+func TestPartitionGuard_Received_Error(t *testing.T) {
+	ctx := context.Background()
+	client := &ecstptest.ECSClient{}
+	client.SetErr(errors.New("throttled"))
+	guard, manager := newTestGuard(client)
+
+	assert.Error(t, guard.Received(ctx, "orders", 0, 1))
+	assert.Equal(t, 1, guard.Pending(), "records should be tracked even if protection failed")
+	assert.False(t, manager.State().Protected)
+
+	client.SetErr(nil)
+	require.NoError(t, guard.Received(ctx, "orders", 0, 1))
+	assert.True(t, manager.State().Protected, "protection should be retried with the next records")
+}
+
+func TestPartitionGuard_Renew(t *testing.T) {
+	client := &ecstptest.ECSClient{}
+	guard, _ := newTestGuard(client, ecstp.WithProfile(ecstp.Profile{
+		Renewal: ecstp.FixedInterval{Interval: 10 * time.Millisecond},
+	}))
+
+	require.NoError(t, guard.Received(context.Background(), "orders", 0, 1))
+	assert.Eventually(t, func() bool {
+		protects, _ := client.Updates()
+		return protects >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, guard.CommittedAll(context.Background()))
+	protects, _ := client.Updates()
+	time.Sleep(50 * time.Millisecond)
+	after, _ := client.Updates()
+	assert.Equal(t, protects, after, "renewal should stop once all records are committed")
+}
@@ -0,0 +1,92 @@
+package ecstpkafka
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Session is the part of a consumer group session used by a ConsumerGroupHandler. It's
+// implemented by sarama.ConsumerGroupSession.
+type Session interface {
+	Claims() map[string][]int32
+	Context() context.Context
+}
+
+// Claim is the part of a consumer group claim used by a ConsumerGroupHandler. It's implemented by
+// sarama.ConsumerGroupClaim, with M being *sarama.ConsumerMessage.
+type Claim[M any] interface {
+	Topic() string
+	Partition() int32
+	Messages() <-chan M
+}
+
+// ConsumerGroupHandler is a sarama.ConsumerGroupHandler handling each message with Handler while
+// Guard protects the task:
+//
+//	handler := &ecstpkafka.ConsumerGroupHandler[sarama.ConsumerGroupSession, sarama.ConsumerGroupClaim, *sarama.ConsumerMessage]{
+//		Guard: guard,
+//		Handler: func(ctx context.Context, session sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) error {
+//			err := process(ctx, msg.Value)
+//			session.MarkMessage(msg, "")
+//			return err
+//		},
+//	}
+//	err = group.Consume(ctx, topics, handler)
+//
+// A message counts as uncommitted from when it's received until Handler returns, so Handler should
+// mark it before returning. Partitions are recorded as assigned in Setup and revoked in Cleanup.
+type ConsumerGroupHandler[S Session, C Claim[M], M any] struct {
+	Guard   *PartitionGuard
+	Handler func(ctx context.Context, session S, msg M) error
+}
+
+// Setup implements sarama.ConsumerGroupHandler.
+func (h *ConsumerGroupHandler[S, C, M]) Setup(session S) error {
+	h.Guard.Assigned(session.Claims())
+
+	return nil
+}
+
+// Cleanup implements sarama.ConsumerGroupHandler.
+func (h *ConsumerGroupHandler[S, C, M]) Cleanup(session S) error {
+	return h.Guard.Revoked(context.WithoutCancel(session.Context()), session.Claims())
+}
+
+// ConsumeClaim implements sarama.ConsumerGroupHandler. It returns once the claim's messages are
+// closed or the session ends, or with the error of Handler. If protection can't be enabled for a
+// message, it's logged and the message is handled anyway, preserving the order of the partition.
+func (h *ConsumerGroupHandler[S, C, M]) ConsumeClaim(session S, claim C) error {
+	ctx := session.Context()
+	topic, partition := claim.Topic(), claim.Partition()
+
+	for {
+		var msg M
+		select {
+		case m, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			msg = m
+		case <-ctx.Done():
+			return nil
+		}
+
+		if err := h.Guard.Received(ctx, topic, partition, 1); err != nil {
+			h.Guard.logger().ErrorContext(ctx, "unable to protect task for message",
+				slog.String("topic", topic),
+				slog.Int("partition", int(partition)),
+				slog.Any("error", err),
+			)
+		}
+		err := h.Handler(ctx, session, msg)
+		// released once handled, even if the session ended meanwhile
+		if commitErr := h.Guard.Committed(context.WithoutCancel(ctx), topic, partition); commitErr != nil {
+			h.Guard.logger().ErrorContext(ctx, "unable to disable protection after message",
+				slog.Any("error", commitErr),
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ecstpkafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+// testSession stands in for sarama.ConsumerGroupSession.
+type testSession struct {
+	ctx    context.Context
+	claims map[string][]int32
+	marked []string
+}
+
+func (s *testSession) Claims() map[string][]int32 {
+	return s.claims
+}
+
+func (s *testSession) Context() context.Context {
+	return s.ctx
+}
+
+// testClaim stands in for sarama.ConsumerGroupClaim.
+type testClaim struct {
+	messages chan string
+}
+
+func (c *testClaim) Topic() string {
+	return "orders"
+}
+
+func (c *testClaim) Partition() int32 {
+	return 0
+}
+
+func (c *testClaim) Messages() <-chan string {
+	return c.messages
+}
+
+func TestConsumerGroupHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		messages   []string
+		failOn     string
+		wantMarked []string
+		wantErr    bool
+	}{
+		{
+			name:       "should handle every message while protected",
+			messages:   []string{"a", "b", "c"},
+			wantMarked: []string{"a", "b", "c"},
+		},
+		{
+			name:       "should stop at the first failed message",
+			messages:   []string{"a", "b", "c"},
+			failOn:     "b",
+			wantMarked: []string{"a"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard, manager := newTestGuard(&ecstptest.ECSClient{})
+			h := &ConsumerGroupHandler[*testSession, *testClaim, string]{
+				Guard: guard,
+				Handler: func(ctx context.Context, session *testSession, msg string) error {
+					assert.True(t, manager.State().Protected, "message should be handled while protected")
+					if msg == tt.failOn {
+						return errors.New("boom")
+					}
+					session.marked = append(session.marked, msg)
+					return nil
+				},
+			}
+			session := &testSession{ctx: context.Background(), claims: map[string][]int32{"orders": {0}}}
+			claim := &testClaim{messages: make(chan string, len(tt.messages))}
+			for _, msg := range tt.messages {
+				claim.messages <- msg
+			}
+			close(claim.messages)
+
+			assert.NoError(t, h.Setup(session))
+			assert.Equal(t, []TopicPartition{{"orders", 0}}, guard.Partitions())
+
+			err := h.ConsumeClaim(session, claim)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantMarked, session.marked)
+			assert.False(t, manager.State().Protected)
+
+			assert.NoError(t, h.Cleanup(session))
+			assert.Empty(t, guard.Partitions())
+		})
+	}
+}
+
+func TestConsumerGroupHandler_SessionEnded(t *testing.T) {
+	guard, _ := newTestGuard(&ecstptest.ECSClient{})
+	h := &ConsumerGroupHandler[*testSession, *testClaim, string]{
+		Guard: guard,
+		Handler: func(ctx context.Context, session *testSession, msg string) error {
+			return nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.NoError(t, h.ConsumeClaim(&testSession{ctx: ctx}, &testClaim{messages: make(chan string)}))
+}
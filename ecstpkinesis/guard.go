@@ -0,0 +1,156 @@
+// Package ecstpkinesis keeps an ECS task protected while it's consuming a Kinesis data stream
+// (e.g. as a KCL-style worker) and holds shard leases with records that haven't been checkpointed,
+// so a scale-in doesn't force the records to be reprocessed by another worker.
+package ecstpkinesis
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// ShardGuard protects the task while any shard lease it owns has uncheckpointed records. Its
+// methods map onto the lifecycle of a KCL record processor:
+//
+//	guard.Acquire(shardID)                     // Initialize
+//	guard.Received(ctx, shardID, len(records)) // ProcessRecords, before processing
+//	guard.Checkpointed(ctx, shardID)           // after checkpointing
+//	guard.Release(ctx, shardID)                // ShutdownRequested after the final checkpoint, or LeaseLost
+//
+// Every shard with uncheckpointed records acquires a Hold of Manager, so protection is enabled once
+// records are received and renewed as decided by the Renewal of the Client's Profile until every
+// owned shard is checkpointed or its lease released, at which point protection is disabled. It's
+// safe for concurrent use by the processors of different shards.
+type ShardGuard struct {
+	Manager *ecstp.Manager
+
+	mu     sync.Mutex
+	shards map[string]*shard
+}
+
+// shard is an owned shard lease.
+type shard struct {
+	// pending is the number of uncheckpointed records.
+	pending int
+	// hold is held while pending isn't zero.
+	hold *ecstp.Hold
+}
+
+// Acquire records that the worker owns the lease of shardID.
+func (g *ShardGuard) Acquire(shardID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.shardLocked(shardID)
+}
+
+// Received records n records of shardID as uncheckpointed, enabling protection if they're the
+// first. It should be called before the records are processed. If enabling protection fails, the
+// records are still recorded and protection is retried with the next call.
+func (g *ShardGuard) Received(ctx context.Context, shardID string, n int) error {
+	g.mu.Lock()
+	s := g.shardLocked(shardID)
+	s.pending += n
+	held := s.hold != nil || s.pending == 0
+	g.mu.Unlock()
+	if held {
+		return nil
+	}
+
+	hold, err := g.Manager.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	if s, ok := g.shards[shardID]; ok && s.hold == nil && s.pending > 0 {
+		s.hold, hold = hold, nil
+	}
+	g.mu.Unlock()
+	if hold != nil {
+		// the shard was checkpointed or released meanwhile
+		return hold.Release(ctx)
+	}
+
+	return nil
+}
+
+// Checkpointed records that every record received for shardID has been checkpointed, disabling
+// protection if no other shard has uncheckpointed records.
+func (g *ShardGuard) Checkpointed(ctx context.Context, shardID string) error {
+	g.mu.Lock()
+	var hold *ecstp.Hold
+	if s, ok := g.shards[shardID]; ok {
+		s.pending = 0
+		hold, s.hold = s.hold, nil
+	}
+	g.mu.Unlock()
+
+	return release(ctx, hold)
+}
+
+// Release records that the lease of shardID has been transferred or lost, discarding its
+// uncheckpointed records and disabling protection if no other shard has any.
+func (g *ShardGuard) Release(ctx context.Context, shardID string) error {
+	g.mu.Lock()
+	var hold *ecstp.Hold
+	if s, ok := g.shards[shardID]; ok {
+		hold = s.hold
+		delete(g.shards, shardID)
+	}
+	g.mu.Unlock()
+
+	return release(ctx, hold)
+}
+
+// Shards returns the IDs of the shards whose leases are owned, sorted.
+func (g *ShardGuard) Shards() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	shards := make([]string, 0, len(g.shards))
+	for shardID := range g.shards {
+		shards = append(shards, shardID)
+	}
+	sort.Strings(shards)
+
+	return shards
+}
+
+// Pending returns the number of uncheckpointed records across all owned shards.
+func (g *ShardGuard) Pending() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pending := 0
+	for _, s := range g.shards {
+		pending += s.pending
+	}
+
+	return pending
+}
+
+// shardLocked returns the owned shard shardID, recording it if it isn't already.
+func (g *ShardGuard) shardLocked(shardID string) *shard {
+	if g.shards == nil {
+		g.shards = make(map[string]*shard)
+	}
+	s, ok := g.shards[shardID]
+	if !ok {
+		s = &shard{}
+		g.shards[shardID] = s
+	}
+
+	return s
+}
+
+// release releases hold, if any.
+func release(ctx context.Context, hold *ecstp.Hold) error {
+	if hold == nil {
+		return nil
+	}
+
+	return hold.Release(ctx)
+}
@@ -0,0 +1,86 @@
+package ecstpkinesis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+func newTestGuard(client *ecstptest.ECSClient, opts ...ecstp.Option) (*ShardGuard, *ecstp.Manager) {
+	manager := ecstptest.NewManager(client, opts...)
+
+	return &ShardGuard{Manager: manager}, manager
+}
+
+func TestShardGuard(t *testing.T) {
+	ctx := context.Background()
+	client := &ecstptest.ECSClient{}
+	guard, manager := newTestGuard(client)
+
+	guard.Acquire("shard-1")
+	guard.Acquire("shard-2")
+	assert.Equal(t, []string{"shard-1", "shard-2"}, guard.Shards())
+	assert.False(t, manager.State().Protected, "owning leases without records shouldn't protect the task")
+
+	require.NoError(t, guard.Received(ctx, "shard-1", 10))
+	require.NoError(t, guard.Received(ctx, "shard-2", 5))
+	assert.True(t, manager.State().Protected)
+	assert.Equal(t, 15, guard.Pending())
+	protects, _ := client.Updates()
+	assert.Equal(t, 1, protects, "protection should only be enabled once")
+
+	require.NoError(t, guard.Checkpointed(ctx, "shard-1"))
+	assert.True(t, manager.State().Protected, "task should stay protected while shard-2 is uncheckpointed")
+
+	require.NoError(t, guard.Release(ctx, "shard-2"))
+	assert.False(t, manager.State().Protected)
+	assert.Equal(t, 0, guard.Pending())
+	assert.Equal(t, []string{"shard-1"}, guard.Shards())
+
+	require.NoError(t, guard.Received(ctx, "shard-1", 1))
+	assert.True(t, manager.State().Protected)
+	protects, _ = client.Updates()
+	assert.Equal(t, 2, protects)
+}
+
+func TestShardGuard_Received_Error(t *testing.T) {
+	ctx := context.Background()
+	client := &ecstptest.ECSClient{}
+	client.SetErr(errors.New("throttled"))
+	guard, manager := newTestGuard(client)
+
+	guard.Acquire("shard-1")
+	assert.Error(t, guard.Received(ctx, "shard-1", 1))
+	assert.Equal(t, 1, guard.Pending(), "records should be tracked even if protection failed")
+	assert.False(t, manager.State().Protected)
+
+	client.SetErr(nil)
+	require.NoError(t, guard.Received(ctx, "shard-1", 1))
+	assert.True(t, manager.State().Protected, "protection should be retried with the next records")
+}
+
+func TestShardGuard_Renew(t *testing.T) {
+	client := &ecstptest.ECSClient{}
+	guard, _ := newTestGuard(client, ecstp.WithProfile(ecstp.Profile{
+		Renewal: ecstp.FixedInterval{Interval: 10 * time.Millisecond},
+	}))
+
+	require.NoError(t, guard.Received(context.Background(), "shard-1", 1))
+	assert.Eventually(t, func() bool {
+		protects, _ := client.Updates()
+		return protects >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, guard.Checkpointed(context.Background(), "shard-1"))
+	protects, _ := client.Updates()
+	time.Sleep(50 * time.Millisecond)
+	after, _ := client.Updates()
+	assert.Equal(t, protects, after, "renewal should stop once all records are checkpointed")
+}
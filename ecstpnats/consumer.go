@@ -0,0 +1,127 @@
+// Package ecstpnats keeps an ECS task protected while it handles NATS JetStream messages, so a
+// scale-in doesn't interrupt a worker midway through a message and force its redelivery.
+package ecstpnats
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// Msg is the part of a JetStream message used by a Consumer. It's implemented by jetstream.Msg of
+// github.com/nats-io/nats.go/jetstream.
+type Msg interface {
+	Ack() error
+	Nak() error
+	InProgress() error
+}
+
+// message settles a Msg for Manager.HandleMessage, naking it both when handling it failed and
+// when it wasn't handled.
+type message struct {
+	Msg
+}
+
+func (m message) Nack() error {
+	return m.Nak()
+}
+
+func (m message) Requeue() error {
+	return m.Nak()
+}
+
+// Consumer handles JetStream messages with Handler, holding a protection lease for each message in
+// flight:
+//
+//	consumer := &ecstpnats.Consumer[jetstream.Msg]{Manager: manager, Handler: handle}
+//	cc, err := stream.Consume(consumer.MessageHandler(ctx))
+//
+// Each message is handled with Manager.HandleMessage, so the task is protected from when the first
+// message is received until the last one in flight has been acked, or naked for redelivery if
+// Handler fails. JetStream redelivers the messages of a worker that crashed once their AckWait
+// passes, which InProgressInterval keeps from happening to messages that are merely slow.
+//
+// A Consumer is safe for concurrent use, e.g. with messages handled in parallel.
+type Consumer[M Msg] struct {
+	Manager *ecstp.Manager
+	Handler func(ctx context.Context, msg M) error
+	// InProgressInterval, if set, is the time between InProgress calls for each message in flight,
+	// resetting its AckWait while a long handler runs.
+	InProgressInterval time.Duration
+	Logger             *slog.Logger
+
+	inFlight atomic.Int64
+}
+
+// Handle handles msg with Manager.HandleMessage, acking it if Handler succeeds and naking it
+// otherwise. If protection can't be enabled, msg is naked without being handled, so it's
+// redelivered to a worker that can protect itself.
+func (c *Consumer[M]) Handle(ctx context.Context, msg M) error {
+	return c.Manager.HandleMessage(ctx, message{msg}, func(ctx context.Context) error {
+		c.inFlight.Add(1)
+		defer c.inFlight.Add(-1)
+
+		stopInProgress := c.inProgress(msg)
+		defer stopInProgress()
+
+		return c.Handler(ctx, msg)
+	})
+}
+
+// MessageHandler returns a function calling Handle with ctx for every message, e.g. to pass to
+// jetstream.Consumer's Consume. Errors are logged.
+func (c *Consumer[M]) MessageHandler(ctx context.Context) func(msg M) {
+	return func(msg M) {
+		if err := c.Handle(ctx, msg); err != nil {
+			c.logger().ErrorContext(ctx, "unable to handle message", slog.Any("error", err))
+		}
+	}
+}
+
+// InFlight returns the number of messages being handled.
+func (c *Consumer[M]) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+// inProgress calls msg.InProgress every InProgressInterval until the returned function is called.
+func (c *Consumer[M]) inProgress(msg M) func() {
+	if c.InProgressInterval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(c.InProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			if err := msg.InProgress(); err != nil {
+				c.logger().WarnContext(ctx, "unable to mark message in progress", slog.Any("error", err))
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (c *Consumer[M]) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+
+	return c.Logger
+}
@@ -0,0 +1,178 @@
+package ecstpnats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+// testMsg records the acknowledgements of a message.
+type testMsg struct {
+	ackErr error
+
+	mu          sync.Mutex
+	acks        []string
+	inProgress  int
+	protectedAt func() bool
+}
+
+func (m *testMsg) Ack() error {
+	return m.record("ack")
+}
+
+func (m *testMsg) Nak() error {
+	return m.record("nak")
+}
+
+func (m *testMsg) InProgress() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inProgress++
+
+	return nil
+}
+
+func (m *testMsg) record(ack string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.protectedAt != nil && !m.protectedAt() {
+		ack += " (unprotected)"
+	}
+	m.acks = append(m.acks, ack)
+
+	return m.ackErr
+}
+
+func (m *testMsg) InProgressCalls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.inProgress
+}
+
+func newTestConsumer(
+	ecsClient *ecstptest.ECSClient, handler func(ctx context.Context, msg *testMsg) error, opts ...ecstp.Option,
+) *Consumer[*testMsg] {
+	return &Consumer[*testMsg]{Manager: ecstptest.NewManager(ecsClient, opts...), Handler: handler}
+}
+
+func TestConsumer_Handle(t *testing.T) {
+	tests := []struct {
+		name      string
+		ecsErr    error
+		handleErr error
+		ackErr    error
+		wantAcks  []string
+		wantErr   bool
+	}{
+		{
+			name:     "should ack handled messages while protected",
+			wantAcks: []string{"ack"},
+		},
+		{
+			name:      "should nak failed messages while protected",
+			handleErr: errors.New("boom"),
+			wantAcks:  []string{"nak"},
+			wantErr:   true,
+		},
+		{
+			name:     "should return ack failures",
+			ackErr:   errors.New("timeout"),
+			wantAcks: []string{"ack"},
+			wantErr:  true,
+		},
+		{
+			name:     "should nak messages without handling them if protection fails",
+			ecsErr:   errors.New("throttled"),
+			wantAcks: []string{"nak (unprotected)"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &ecstptest.ECSClient{}
+			ecsClient.SetErr(tt.ecsErr)
+			handled := false
+			c := newTestConsumer(ecsClient, func(ctx context.Context, msg *testMsg) error {
+				handled = true
+				return tt.handleErr
+			})
+			msg := &testMsg{ackErr: tt.ackErr, protectedAt: func() bool { return c.Manager.State().Protected }}
+
+			err := c.Handle(context.Background(), msg)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAcks, msg.acks)
+			assert.Equal(t, tt.ecsErr == nil, handled)
+			assert.False(t, c.Manager.State().Protected, "protection should be released once acknowledged")
+			assert.Equal(t, 0, c.InFlight())
+		})
+	}
+}
+
+func TestConsumer_Concurrent(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	c := newTestConsumer(&ecstptest.ECSClient{}, func(ctx context.Context, msg *testMsg) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	handler := c.MessageHandler(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(&testMsg{})
+		}()
+	}
+	<-started
+	<-started
+	assert.Equal(t, 2, c.InFlight())
+	assert.True(t, c.Manager.State().Protected)
+
+	close(release)
+	wg.Wait()
+	assert.False(t, c.Manager.State().Protected)
+}
+
+func TestConsumer_Renewal(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	msg := &testMsg{}
+	done := make(chan struct{})
+	c := newTestConsumer(ecsClient, func(ctx context.Context, msg *testMsg) error {
+		<-done
+		return nil
+	}, ecstp.WithProfile(ecstp.Profile{Renewal: ecstp.FixedInterval{Interval: 10 * time.Millisecond}}))
+	c.InProgressInterval = 10 * time.Millisecond
+
+	result := make(chan error)
+	go func() { result <- c.Handle(context.Background(), msg) }()
+
+	assert.Eventually(t, func() bool {
+		protects, _ := ecsClient.Updates()
+		return protects >= 3 && msg.InProgressCalls() >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	close(done)
+	require.NoError(t, <-result)
+	protects, _ := ecsClient.Updates()
+	inProgress := msg.InProgressCalls()
+	time.Sleep(50 * time.Millisecond)
+	after, _ := ecsClient.Updates()
+	assert.Equal(t, protects, after, "renewal should stop with the last message")
+	assert.Equal(t, inProgress, msg.InProgressCalls(), "in progress calls should stop once the message is handled")
+}
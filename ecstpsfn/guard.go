@@ -0,0 +1,150 @@
+// Package ecstpsfn keeps an ECS task protected while it holds outstanding Step Functions task
+// tokens, e.g. when it's used as an activity worker or a callback (.waitForTaskToken) target in a
+// long-running state machine.
+package ecstpsfn
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// ErrTokenClosed is returned when a Token is completed or released more than once.
+var ErrTokenClosed = errors.New("task token already closed")
+
+// TaskTokenSender reports the outcome of Step Functions tasks. It is typically implemented by a thin
+// wrapper around the SendTaskSuccess, SendTaskFailure and SendTaskHeartbeat calls of a Step
+// Functions client.
+type TaskTokenSender interface {
+	SendTaskSuccess(ctx context.Context, taskToken, output string) error
+	SendTaskFailure(ctx context.Context, taskToken, errorCode, cause string) error
+	SendTaskHeartbeat(ctx context.Context, taskToken string) error
+}
+
+// Guard protects the task while any Token begun through it is outstanding.
+//
+// Every token acquires a Hold of Manager, so protection is enabled when the first token is begun
+// and renewed as decided by the Renewal of the Client's Profile until the last token is completed
+// or released, at which point protection is disabled. Tokens of a worker that crashed are failed
+// by Step Functions once their state's HeartbeatSeconds pass without a heartbeat, to be retried
+// by another worker.
+type Guard struct {
+	Manager *ecstp.Manager
+	Sender  TaskTokenSender
+	// HeartbeatInterval, if set, is the time between SendTaskHeartbeat calls for each outstanding
+	// token, for states with HeartbeatSeconds.
+	HeartbeatInterval time.Duration
+	Logger            *slog.Logger
+
+	outstanding atomic.Int64
+}
+
+// Token is an outstanding Step Functions task token.
+type Token struct {
+	guard         *Guard
+	token         string
+	hold          *ecstp.Hold
+	stopHeartbeat func()
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Begin records token as outstanding, enabling protection if it's the first one.
+func (g *Guard) Begin(ctx context.Context, token string) (*Token, error) {
+	hold, err := g.Manager.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g.outstanding.Add(1)
+
+	t := &Token{guard: g, token: token, hold: hold, stopHeartbeat: func() {}}
+	if g.HeartbeatInterval > 0 {
+		heartbeatCtx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		t.stopHeartbeat = func() {
+			cancel()
+			<-done
+		}
+		go func() {
+			defer close(done)
+			t.heartbeat(heartbeatCtx)
+		}()
+	}
+
+	return t, nil
+}
+
+// Outstanding returns the number of outstanding tokens.
+func (g *Guard) Outstanding() int {
+	return int(g.outstanding.Load())
+}
+
+func (g *Guard) logger() *slog.Logger {
+	if g.Logger == nil {
+		return slog.Default()
+	}
+
+	return g.Logger
+}
+
+// Succeed sends output as the task's result and releases the token. If sending fails, the token
+// stays outstanding so the call can be retried.
+func (t *Token) Succeed(ctx context.Context, output string) error {
+	return t.complete(ctx, func() error {
+		return t.guard.Sender.SendTaskSuccess(ctx, t.token, output)
+	})
+}
+
+// Fail reports the task as failed and releases the token. If sending fails, the token stays
+// outstanding so the call can be retried.
+func (t *Token) Fail(ctx context.Context, errorCode, cause string) error {
+	return t.complete(ctx, func() error {
+		return t.guard.Sender.SendTaskFailure(ctx, t.token, errorCode, cause)
+	})
+}
+
+// Release releases the token without reporting an outcome, e.g. once the state has timed out.
+func (t *Token) Release(ctx context.Context) error {
+	return t.complete(ctx, func() error { return nil })
+}
+
+func (t *Token) complete(ctx context.Context, send func() error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return ErrTokenClosed
+	}
+	if err := send(); err != nil {
+		return err
+	}
+	t.closed = true
+	t.stopHeartbeat()
+	t.guard.outstanding.Add(-1)
+
+	return t.hold.Release(ctx)
+}
+
+// heartbeat sends a heartbeat for the token every HeartbeatInterval until ctx is done.
+func (t *Token) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(t.guard.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if err := t.guard.Sender.SendTaskHeartbeat(ctx, t.token); err != nil && ctx.Err() == nil {
+			t.guard.logger().WarnContext(ctx, "unable to send task heartbeat", slog.Any("error", err))
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package ecstpsfn
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+type testSender struct {
+	err error
+
+	mu         sync.Mutex
+	sent       []string
+	heartbeats int
+}
+
+func (s *testSender) SendTaskSuccess(ctx context.Context, taskToken, output string) error {
+	return s.record("success:" + taskToken)
+}
+
+func (s *testSender) SendTaskFailure(ctx context.Context, taskToken, errorCode, cause string) error {
+	return s.record("failure:" + taskToken + ":" + errorCode)
+}
+
+func (s *testSender) SendTaskHeartbeat(ctx context.Context, taskToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heartbeats++
+
+	return nil
+}
+
+func (s *testSender) record(call string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, call)
+
+	return nil
+}
+
+func (s *testSender) Heartbeats() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.heartbeats
+}
+
+func newTestGuard(ecsClient *ecstptest.ECSClient, sender TaskTokenSender, opts ...ecstp.Option) *Guard {
+	return &Guard{Manager: ecstptest.NewManager(ecsClient, opts...), Sender: sender}
+}
+
+func TestGuard(t *testing.T) {
+	sender := &testSender{}
+	g := newTestGuard(&ecstptest.ECSClient{}, sender)
+
+	first, err := g.Begin(context.Background(), "a")
+	require.NoError(t, err)
+	second, err := g.Begin(context.Background(), "b")
+	require.NoError(t, err)
+	assert.True(t, g.Manager.State().Protected)
+	assert.Equal(t, 2, g.Outstanding())
+
+	require.NoError(t, first.Succeed(context.Background(), `{}`))
+	assert.True(t, g.Manager.State().Protected, "task should stay protected while a token is outstanding")
+
+	require.NoError(t, second.Fail(context.Background(), "Worker.Error", "boom"))
+	assert.False(t, g.Manager.State().Protected)
+	assert.Equal(t, 0, g.Outstanding())
+	assert.Equal(t, []string{"success:a", "failure:b:Worker.Error"}, sender.sent)
+
+	assert.ErrorIs(t, first.Release(context.Background()), ErrTokenClosed)
+}
+
+func TestGuard_SendFailure(t *testing.T) {
+	sender := &testSender{err: errors.New("throttled")}
+	g := newTestGuard(&ecstptest.ECSClient{}, sender)
+
+	token, err := g.Begin(context.Background(), "a")
+	require.NoError(t, err)
+
+	assert.Error(t, token.Succeed(context.Background(), `{}`))
+	assert.True(t, g.Manager.State().Protected, "token should stay outstanding when the outcome wasn't sent")
+
+	require.NoError(t, token.Release(context.Background()))
+	assert.False(t, g.Manager.State().Protected)
+}
+
+func TestGuard_Renewal(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	sender := &testSender{}
+	g := newTestGuard(ecsClient, sender,
+		ecstp.WithProfile(ecstp.Profile{Renewal: ecstp.FixedInterval{Interval: 10 * time.Millisecond}}))
+	g.HeartbeatInterval = 10 * time.Millisecond
+
+	token, err := g.Begin(context.Background(), "a")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		protects, _ := ecsClient.Updates()
+		return protects >= 3 && sender.Heartbeats() >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, token.Succeed(context.Background(), `{}`))
+	protects, _ := ecsClient.Updates()
+	heartbeats := sender.Heartbeats()
+	time.Sleep(50 * time.Millisecond)
+	renewed, _ := ecsClient.Updates()
+	assert.Equal(t, protects, renewed, "renewal should stop with the last token")
+	assert.Equal(t, heartbeats, sender.Heartbeats(), "heartbeats should stop once the token is completed")
+}
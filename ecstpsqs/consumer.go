@@ -0,0 +1,196 @@
+// Package ecstpsqs keeps an ECS task protected while it handles batches of Amazon SQS messages, so
+// a scale-in doesn't interrupt a worker midway through a batch and force its redelivery.
+package ecstpsqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// Client is the part of the SQS client used by a Consumer.
+type Client interface {
+	ReceiveMessage(
+		ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options),
+	) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageBatch(
+		ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options),
+	) (*sqs.DeleteMessageBatchOutput, error)
+}
+
+// Consumer receives batches of messages from an SQS queue and handles them with Handler while the
+// task is protected:
+//
+//	consumer := &ecstpsqs.Consumer{
+//		Client:   sqs.NewFromConfig(cfg),
+//		QueueURL: queueURL,
+//		Manager:  manager,
+//		Handler:  handle,
+//	}
+//	err := consumer.Run(ctx)
+//
+// Protection is held with Manager.Acquire before the first batch is handed to Handler and while
+// batches keep arriving, so it's renewed as decided by the Renewal of the Client's Profile and
+// handling a batch may take longer than the protection period. It's released once the queue is
+// drained, i.e. a receive returns no messages, when a receive fails, or when Run returns. Failing to
+// enable protection is logged and doesn't keep the batch from being handled.
+//
+// The messages of a batch are deleted once Handler succeeds. If it fails, they're left to be
+// redelivered once their visibility timeout expires.
+type Consumer struct {
+	Client   Client
+	QueueURL string
+	Manager  *ecstp.Manager
+	Handler  func(ctx context.Context, messages []types.Message) error
+	// MaxMessages is the maximum number of messages in a batch, up to 10. Defaults to 10.
+	MaxMessages int32
+	// WaitTimeSeconds is the long polling time of receives, up to 20. Defaults to 20 if nil, while
+	// 0 makes receives short poll.
+	WaitTimeSeconds *int32
+	// Retry is the policy of retrying failed receives, with ecstp.Do. Defaults to its zero value.
+	Retry  ecstp.RetryPolicy
+	Logger *slog.Logger
+
+	mu   sync.Mutex
+	hold *ecstp.Hold
+}
+
+// Run receives and handles batches until ctx is done, returning ctx.Err(), or a receive fails
+// after the retries of Retry, returning its error. Protection is released before it returns.
+func (c *Consumer) Run(ctx context.Context) error {
+	defer c.release(context.WithoutCancel(ctx))
+
+	for {
+		output, err := ecstp.Do(ctx, c.Retry, c.receive)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("unable to receive messages: %w", err)
+		}
+		if len(output.Messages) == 0 {
+			c.release(ctx)
+			continue
+		}
+
+		c.acquire(ctx)
+		c.handle(ctx, output.Messages)
+	}
+}
+
+// Protected reports whether the Consumer holds protection.
+func (c *Consumer) Protected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hold != nil
+}
+
+// receive receives a batch, releasing protection if it fails, as no batch is being handled and SQS
+// may keep failing for longer than the protection period.
+func (c *Consumer) receive(ctx context.Context) (*sqs.ReceiveMessageOutput, error) {
+	output, err := c.Client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(c.QueueURL),
+		MaxNumberOfMessages: c.maxMessages(),
+		WaitTimeSeconds:     c.waitTimeSeconds(),
+	})
+	if err != nil && ctx.Err() == nil {
+		c.logger().ErrorContext(ctx, "failed to receive messages", slog.Any("error", err))
+		c.release(ctx)
+	}
+
+	return output, err
+}
+
+// handle handles a batch with Handler, deleting its messages if it succeeds.
+func (c *Consumer) handle(ctx context.Context, messages []types.Message) {
+	if err := c.Handler(ctx, messages); err != nil {
+		c.logger().ErrorContext(ctx, "unable to handle messages, leaving them to be redelivered",
+			slog.Int("messages", len(messages)),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	entries := make([]types.DeleteMessageBatchRequestEntry, len(messages))
+	for i, msg := range messages {
+		entries[i] = types.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: msg.ReceiptHandle,
+		}
+	}
+	output, err := c.Client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(c.QueueURL),
+		Entries:  entries,
+	})
+	if err == nil && len(output.Failed) > 0 {
+		err = errors.New(aws.ToString(output.Failed[0].Message))
+	}
+	if err != nil {
+		c.logger().ErrorContext(ctx, "failed to delete handled messages", slog.Any("error", err))
+	}
+}
+
+// acquire holds protection if the Consumer doesn't already.
+func (c *Consumer) acquire(ctx context.Context) {
+	if c.Protected() {
+		return
+	}
+	hold, err := c.Manager.Acquire(ctx)
+	if err != nil {
+		c.logger().ErrorContext(ctx, "unable to protect task for messages", slog.Any("error", err))
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hold = hold
+}
+
+// release releases the protection held by the Consumer, if any.
+func (c *Consumer) release(ctx context.Context) {
+	c.mu.Lock()
+	hold := c.hold
+	c.hold = nil
+	c.mu.Unlock()
+
+	if hold == nil {
+		return
+	}
+	if err := hold.Release(ctx); err != nil {
+		c.logger().ErrorContext(ctx, "unable to disable protection after messages", slog.Any("error", err))
+	}
+}
+
+func (c *Consumer) maxMessages() int32 {
+	if c.MaxMessages <= 0 || c.MaxMessages > 10 {
+		return 10
+	}
+
+	return c.MaxMessages
+}
+
+func (c *Consumer) waitTimeSeconds() int32 {
+	if c.WaitTimeSeconds == nil {
+		return 20
+	}
+
+	return min(max(*c.WaitTimeSeconds, 0), 20)
+}
+
+func (c *Consumer) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+
+	return c.Logger
+}
@@ -0,0 +1,234 @@
+package ecstpsqs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+	"github.com/Thumbscrew/ecs-task-protection/ecstptest"
+)
+
+// testSQSClient returns batches in order, and then no messages, or err if it's set.
+type testSQSClient struct {
+	mu       sync.Mutex
+	batches  [][]types.Message
+	err      error
+	receives []*sqs.ReceiveMessageInput
+	deleted  []string
+	drained  bool
+}
+
+func (c *testSQSClient) ReceiveMessage(
+	ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options),
+) (*sqs.ReceiveMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.receives = append(c.receives, params)
+	if len(c.batches) == 0 {
+		c.drained = true
+		if c.err != nil {
+			return nil, c.err
+		}
+		time.Sleep(time.Millisecond)
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+
+	batch := c.batches[0]
+	c.batches = c.batches[1:]
+
+	return &sqs.ReceiveMessageOutput{Messages: batch}, nil
+}
+
+func (c *testSQSClient) DeleteMessageBatch(
+	ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options),
+) (*sqs.DeleteMessageBatchOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range params.Entries {
+		c.deleted = append(c.deleted, aws.ToString(entry.ReceiptHandle))
+	}
+
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+// Drained reports whether the queue was polled once every batch was received.
+func (c *testSQSClient) Drained() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.drained
+}
+
+func (c *testSQSClient) Deleted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.deleted...)
+}
+
+func (c *testSQSClient) Receives() []*sqs.ReceiveMessageInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*sqs.ReceiveMessageInput(nil), c.receives...)
+}
+
+func testBatch(handles ...string) []types.Message {
+	messages := make([]types.Message, len(handles))
+	for i, handle := range handles {
+		messages[i] = types.Message{ReceiptHandle: aws.String(handle)}
+	}
+
+	return messages
+}
+
+func newTestConsumer(ecsClient *ecstptest.ECSClient, sqsClient *testSQSClient, opts ...ecstp.Option) *Consumer {
+	return &Consumer{
+		Client:   sqsClient,
+		QueueURL: "test_queue",
+		Manager:  ecstptest.NewManager(ecsClient, opts...),
+	}
+}
+
+func TestConsumer_Run(t *testing.T) {
+	tests := []struct {
+		name          string
+		ecsErr        error
+		handleErr     error
+		wantProtected bool
+		wantDeleted   []string
+		wantProtects  int
+		wantUpdates   int
+	}{
+		{
+			name:          "should handle batches while protected until the queue is drained",
+			wantProtected: true,
+			wantDeleted:   []string{"a", "b", "c"},
+			wantProtects:  1,
+			wantUpdates:   1,
+		},
+		{
+			name:          "should leave failed batches to be redelivered",
+			handleErr:     errors.New("boom"),
+			wantProtected: true,
+			wantProtects:  1,
+			wantUpdates:   1,
+		},
+		{
+			name:         "should handle batches if protection fails",
+			ecsErr:       errors.New("throttled"),
+			wantDeleted:  []string{"a", "b", "c"},
+			wantProtects: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &ecstptest.ECSClient{}
+			ecsClient.SetErr(tt.ecsErr)
+			sqsClient := &testSQSClient{batches: [][]types.Message{testBatch("a", "b"), testBatch("c")}}
+			c := newTestConsumer(ecsClient, sqsClient)
+			var protected []bool
+			c.Handler = func(ctx context.Context, messages []types.Message) error {
+				protected = append(protected, c.Manager.State().Protected)
+				return tt.handleErr
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- c.Run(ctx) }()
+
+			require.Eventually(t, sqsClient.Drained, time.Second, time.Millisecond)
+			assert.Eventually(t, func() bool { return !c.Protected() }, time.Second, time.Millisecond,
+				"protection should be released once the queue is drained")
+			cancel()
+			assert.ErrorIs(t, <-done, context.Canceled)
+
+			assert.Equal(t, []bool{tt.wantProtected, tt.wantProtected}, protected)
+			assert.Equal(t, tt.wantDeleted, sqsClient.Deleted())
+			protects, unprotects := ecsClient.Updates()
+			assert.Equal(t, tt.wantProtects, protects, "consecutive batches should share protection")
+			assert.Equal(t, tt.wantUpdates, unprotects)
+			assert.False(t, c.Manager.State().Protected)
+		})
+	}
+}
+
+func TestConsumer_Renewal(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	sqsClient := &testSQSClient{batches: [][]types.Message{testBatch("a")}}
+	c := newTestConsumer(ecsClient, sqsClient,
+		ecstp.WithProfile(ecstp.Profile{Renewal: ecstp.FixedInterval{Interval: 10 * time.Millisecond}}))
+	c.Handler = func(ctx context.Context, messages []types.Message) error {
+		// a batch taking longer than the protection period
+		assert.Eventually(t, func() bool {
+			protects, _ := ecsClient.Updates()
+			return protects >= 3
+		}, time.Second, 5*time.Millisecond)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	require.Eventually(t, sqsClient.Drained, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	protects, _ := ecsClient.Updates()
+	time.Sleep(50 * time.Millisecond)
+	renewed, _ := ecsClient.Updates()
+	assert.Equal(t, protects, renewed, "renewal should stop once the queue is drained")
+	assert.Equal(t, []string{"a"}, sqsClient.Deleted())
+}
+
+func TestConsumer_ReceiveError(t *testing.T) {
+	ecsClient := &ecstptest.ECSClient{}
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException"}
+	sqsClient := &testSQSClient{batches: [][]types.Message{testBatch("a")}, err: throttled}
+	c := newTestConsumer(ecsClient, sqsClient)
+	c.Retry = ecstp.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	c.Handler = func(ctx context.Context, messages []types.Message) error { return nil }
+
+	err := c.Run(context.Background())
+	assert.ErrorIs(t, err, throttled, "Run should return once receives keep failing")
+	assert.Len(t, sqsClient.Receives(), 4, "failed receives should be retried")
+	assert.False(t, c.Protected())
+	assert.False(t, c.Manager.State().Protected, "protection should be released while receives fail")
+	_, unprotects := ecsClient.Updates()
+	assert.Equal(t, 1, unprotects)
+}
+
+func TestConsumer_WaitTimeSeconds(t *testing.T) {
+	tests := []struct {
+		name            string
+		waitTimeSeconds *int32
+		want            int32
+	}{
+		{name: "should long poll by default", want: 20},
+		{name: "should short poll", waitTimeSeconds: aws.Int32(0), want: 0},
+		{name: "should cap the long polling time", waitTimeSeconds: aws.Int32(60), want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqsClient := &testSQSClient{err: errors.New("denied")}
+			c := newTestConsumer(&ecstptest.ECSClient{}, sqsClient)
+			c.WaitTimeSeconds = tt.waitTimeSeconds
+
+			assert.Error(t, c.Run(context.Background()))
+			if receives := sqsClient.Receives(); assert.Len(t, receives, 1, "other errors should not be retried") {
+				assert.Equal(t, tt.want, receives[0].WaitTimeSeconds)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Package ecstpssm provides an ecstp.BlackoutSource backed by an SSM parameter, so operators can
+// pause or shorten task protection across a fleet while a deployment rolls out.
+package ecstpssm
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// DefaultCacheTTL is the default time a parameter value is cached for.
+const DefaultCacheTTL = 30 * time.Second
+
+// SSMClient is the subset of the SSM client used by ParameterBlackout.
+type SSMClient interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// ParameterBlackout is an ecstp.BlackoutSource reporting a blackout while the SSM parameter Name
+// is set to a true value (as parsed by strconv.ParseBool). A missing parameter means no blackout.
+//
+//	client := ecstp.NewClient(ecsClient, ecstp.WithBlackout(&ecstpssm.ParameterBlackout{
+//		Client: ssm.NewFromConfig(cfg),
+//		Name:   "/platform/deployment-blackout",
+//	}, 5))
+type ParameterBlackout struct {
+	Client SSMClient
+	Name   string
+	// CacheTTL is the time a value is cached for, as the source is consulted before every protect
+	// and renewal. Defaults to DefaultCacheTTL.
+	CacheTTL time.Duration
+
+	mu        sync.Mutex
+	active    bool
+	fetchedAt time.Time
+}
+
+var _ ecstp.BlackoutSource = (*ParameterBlackout)(nil)
+
+// Blackout implements ecstp.BlackoutSource.
+func (p *ParameterBlackout) Blackout(ctx context.Context, _ *ecstp.MetadataBody) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ttl := p.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if !p.fetchedAt.IsZero() && time.Since(p.fetchedAt) < ttl {
+		return p.active, nil
+	}
+
+	active := false
+	out, err := p.Client.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(p.Name)})
+	var notFound *types.ParameterNotFound
+	switch {
+	case errors.As(err, &notFound):
+	case err != nil:
+		return false, err
+	case out.Parameter != nil:
+		active, _ = strconv.ParseBool(strings.TrimSpace(aws.ToString(out.Parameter.Value)))
+	}
+
+	p.active, p.fetchedAt = active, time.Now()
+
+	return active, nil
+}
@@ -0,0 +1,94 @@
+package ecstpssm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSSMClient struct {
+	value *string
+	err   error
+	calls int
+}
+
+func (c *testSSMClient) GetParameter(
+	ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options),
+) (*ssm.GetParameterOutput, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return &ssm.GetParameterOutput{Parameter: &types.Parameter{Name: params.Name, Value: c.value}}, nil
+}
+
+func TestParameterBlackout_Blackout(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *testSSMClient
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "should report a blackout while the parameter is true",
+			client: &testSSMClient{value: aws.String("true\n")},
+			want:   true,
+		},
+		{
+			name:   "should not report a blackout while the parameter is false",
+			client: &testSSMClient{value: aws.String("false")},
+		},
+		{
+			name:   "should not report a blackout for unparseable values",
+			client: &testSSMClient{value: aws.String("maybe")},
+		},
+		{
+			name:   "should not report a blackout for a missing parameter",
+			client: &testSSMClient{err: &types.ParameterNotFound{}},
+		},
+		{
+			name:    "should return other errors",
+			client:  &testSSMClient{err: errors.New("access denied")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ParameterBlackout{Client: tt.client, Name: "/blackout"}
+
+			got, err := p.Blackout(context.Background(), nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParameterBlackout_Cache(t *testing.T) {
+	client := &testSSMClient{value: aws.String("true")}
+	p := &ParameterBlackout{Client: client, Name: "/blackout", CacheTTL: 20 * time.Millisecond}
+
+	for i := 0; i < 3; i++ {
+		got, err := p.Blackout(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.True(t, got)
+	}
+	assert.Equal(t, 1, client.calls)
+
+	time.Sleep(30 * time.Millisecond)
+	client.value = aws.String("false")
+	got, err := p.Blackout(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.False(t, got)
+	assert.Equal(t, 2, client.calls)
+}
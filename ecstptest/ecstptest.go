@@ -0,0 +1,203 @@
+// Package ecstptest provides a fake ECS client for testing code that uses task protection, without
+// AWS credentials or network access.
+package ecstptest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// Metadata of the task managed by NewManager.
+const (
+	Cluster = "arn:aws:ecs:eu-west-2:123456789012:cluster/test_cluster"
+	TaskARN = "arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/0123456789abcdef0123456789abcdef"
+)
+
+// DefaultExpiry is the protection period ECS sets when none is requested.
+const DefaultExpiry = 2 * time.Hour
+
+// Operations recorded in Calls.
+const (
+	OperationUpdateTaskProtection = ecstp.OperationUpdateTaskProtection
+	OperationGetTaskProtection    = ecstp.OperationGetTaskProtection
+)
+
+// Call is a call made to an ECSClient.
+type Call struct {
+	Operation        string
+	Cluster          string
+	Tasks            []string
+	Protect          bool
+	ExpiresInMinutes *int32
+}
+
+// ECSClient is a fake ECS keeping the protection of tasks in memory. It implements ecstp.ECSClient
+// and ecstp.TaskProtectionGetter, and is safe for concurrent use.
+type ECSClient struct {
+	mu        sync.Mutex
+	err       error
+	failures  map[string]string
+	expiresAt map[string]time.Time
+	calls     []Call
+}
+
+// NewManager returns a Manager of the task TaskARN updating protection through c, configured with
+// opts.
+func NewManager(c *ECSClient, opts ...ecstp.Option) *ecstp.Manager {
+	return ecstp.NewManager(ecstp.NewClient(c, opts...), &ecstp.MetadataBody{Cluster: Cluster, TaskARN: TaskARN})
+}
+
+// SetErr makes every following call fail with err, or succeed again if err is nil.
+func (c *ECSClient) SetErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.err = err
+}
+
+// FailTask makes ECS report a failure with reason for task, e.g. "TASK_NOT_VALID", or succeed
+// again if reason is empty.
+func (c *ECSClient) FailTask(task, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failures == nil {
+		c.failures = map[string]string{}
+	}
+	if reason == "" {
+		delete(c.failures, task)
+		return
+	}
+	c.failures[task] = reason
+}
+
+// Protected reports whether task is protected, and until when.
+func (c *ECSClient) Protected(task string) (bool, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt, ok := c.expiresAt[task]
+	if !ok || !expiresAt.After(time.Now()) {
+		return false, time.Time{}
+	}
+
+	return true, expiresAt
+}
+
+// Calls returns the calls made so far.
+func (c *ECSClient) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Call(nil), c.calls...)
+}
+
+// Updates counts the UpdateTaskProtection calls made so far enabling and disabling protection.
+func (c *ECSClient) Updates() (protects, unprotects int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, call := range c.calls {
+		switch {
+		case call.Operation != OperationUpdateTaskProtection:
+		case call.Protect:
+			protects++
+		default:
+			unprotects++
+		}
+	}
+
+	return protects, unprotects
+}
+
+// UpdateTaskProtection implements ecstp.ECSClient.
+func (c *ECSClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{
+		Operation:        OperationUpdateTaskProtection,
+		Cluster:          aws.ToString(params.Cluster),
+		Tasks:            params.Tasks,
+		Protect:          params.ProtectionEnabled,
+		ExpiresInMinutes: params.ExpiresInMinutes,
+	})
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.expiresAt == nil {
+		c.expiresAt = map[string]time.Time{}
+	}
+
+	output := &ecs.UpdateTaskProtectionOutput{}
+	for _, task := range params.Tasks {
+		if reason, ok := c.failures[task]; ok {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(task), Reason: aws.String(reason)})
+			continue
+		}
+		if !params.ProtectionEnabled {
+			delete(c.expiresAt, task)
+			output.ProtectedTasks = append(output.ProtectedTasks, types.ProtectedTask{TaskArn: aws.String(task)})
+			continue
+		}
+
+		expiry := DefaultExpiry
+		if params.ExpiresInMinutes != nil {
+			expiry = time.Duration(*params.ExpiresInMinutes) * time.Minute
+		}
+		c.expiresAt[task] = time.Now().Add(expiry)
+		output.ProtectedTasks = append(output.ProtectedTasks, protectedTask(task, c.expiresAt[task]))
+	}
+
+	return output, nil
+}
+
+// GetTaskProtection implements ecstp.TaskProtectionGetter.
+func (c *ECSClient) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{
+		Operation: OperationGetTaskProtection,
+		Cluster:   aws.ToString(params.Cluster),
+		Tasks:     params.Tasks,
+	})
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	output := &ecs.GetTaskProtectionOutput{}
+	for _, task := range params.Tasks {
+		if reason, ok := c.failures[task]; ok {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(task), Reason: aws.String(reason)})
+			continue
+		}
+		expiresAt, ok := c.expiresAt[task]
+		if !ok || !expiresAt.After(time.Now()) {
+			output.ProtectedTasks = append(output.ProtectedTasks, types.ProtectedTask{TaskArn: aws.String(task)})
+			continue
+		}
+		output.ProtectedTasks = append(output.ProtectedTasks, protectedTask(task, expiresAt))
+	}
+
+	return output, nil
+}
+
+func protectedTask(task string, expiresAt time.Time) types.ProtectedTask {
+	return types.ProtectedTask{
+		TaskArn:           aws.String(task),
+		ProtectionEnabled: true,
+		ExpirationDate:    aws.Time(expiresAt),
+	}
+}
@@ -0,0 +1,105 @@
+package ecstptest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+func TestECSClient(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		failure          string
+		expiresInMinutes *int32
+		wantProtected    bool
+		wantExpiry       time.Duration
+		wantErr          bool
+	}{
+		{
+			name:          "should protect for the ECS default",
+			wantProtected: true,
+			wantExpiry:    DefaultExpiry,
+		},
+		{
+			name:             "should protect for the requested period",
+			expiresInMinutes: aws.Int32(30),
+			wantProtected:    true,
+			wantExpiry:       30 * time.Minute,
+		},
+		{
+			name:    "should report task failures",
+			failure: "TASK_NOT_VALID",
+			wantErr: true,
+		},
+		{
+			name:    "should fail calls",
+			err:     errors.New("throttled"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ECSClient{}
+			c.SetErr(tt.err)
+			c.FailTask(TaskARN, tt.failure)
+			m := NewManager(c)
+
+			state, err := m.Protect(context.Background(), tt.expiresInMinutes)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			protected, expiresAt := c.Protected(TaskARN)
+			assert.Equal(t, tt.wantProtected, protected)
+			assert.Equal(t, tt.wantProtected, state.Protected)
+			if tt.wantProtected {
+				assert.WithinDuration(t, time.Now().Add(tt.wantExpiry), expiresAt, time.Second)
+			}
+			calls := c.Calls()
+			require.Len(t, calls, 1)
+			assert.Equal(t, Call{
+				Operation:        OperationUpdateTaskProtection,
+				Cluster:          Cluster,
+				Tasks:            []string{TaskARN},
+				Protect:          true,
+				ExpiresInMinutes: tt.expiresInMinutes,
+			}, calls[0])
+		})
+	}
+}
+
+func TestECSClient_GetTaskProtection(t *testing.T) {
+	c := &ECSClient{}
+	m := NewManager(c)
+	remote := ecstp.NewRemote(ecstp.NewClient(c))
+	task := []ecstp.MetadataBody{{Cluster: Cluster, TaskARN: TaskARN}}
+	ctx := context.Background()
+
+	_, err := m.Protect(ctx, aws.Int32(10))
+	require.NoError(t, err)
+	states, err := remote.Status(ctx, task)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.True(t, states[0].Protected)
+
+	_, err = m.Unprotect(ctx)
+	require.NoError(t, err)
+	states, err = remote.Status(ctx, task)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.False(t, states[0].Protected)
+
+	protects, unprotects := c.Updates()
+	assert.Equal(t, 1, protects)
+	assert.Equal(t, 1, unprotects, "reads should not be counted")
+}
@@ -0,0 +1,179 @@
+// Package ecstpv1 adapts an aws-sdk-go (v1) ECS client to the ecstp.ECSClient interface, so code
+// still on the v1 SDK can use the same protection Client and Manager as code on the v2 SDK.
+//
+// It's a separate module so that the main module doesn't depend on the v1 SDK.
+package ecstpv1
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	credentialsv1 "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	ecsv1 "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/smithy-go"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// Client wraps a v1 ECS client, implementing ecstp.ECSClient and ecstp.TaskProtectionGetter.
+//
+// Of the v2 per-call options, only Credentials is honored, so ecstp.WithCredentials and
+// per-call credential overrides keep working. v1 errors are converted to smithy.APIErrors, so
+// ecstp.ErrorDetail reports their codes and retryability as it does for the v2 SDK.
+type Client struct {
+	API ecsiface.ECSAPI
+	// Options are applied to every v1 request.
+	Options []request.Option
+}
+
+var (
+	_ ecstp.ECSClient            = (*Client)(nil)
+	_ ecstp.TaskProtectionGetter = (*Client)(nil)
+)
+
+// New returns a Client wrapping api.
+func New(api ecsiface.ECSAPI, opts ...request.Option) *Client {
+	return &Client{API: api, Options: opts}
+}
+
+// UpdateTaskProtection implements ecstp.ECSClient.
+func (c *Client) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	input := &ecsv1.UpdateTaskProtectionInput{
+		Cluster:           params.Cluster,
+		Tasks:             awsv1.StringSlice(params.Tasks),
+		ProtectionEnabled: awsv1.Bool(params.ProtectionEnabled),
+	}
+	if params.ExpiresInMinutes != nil {
+		input.ExpiresInMinutes = awsv1.Int64(int64(*params.ExpiresInMinutes))
+	}
+
+	out, err := c.API.UpdateTaskProtectionWithContext(ctx, input, c.requestOptions(optFns)...)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &ecs.UpdateTaskProtectionOutput{
+		ProtectedTasks: convertProtectedTasks(out.ProtectedTasks),
+		Failures:       convertFailures(out.Failures),
+	}, nil
+}
+
+// GetTaskProtection implements ecstp.TaskProtectionGetter.
+func (c *Client) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	out, err := c.API.GetTaskProtectionWithContext(ctx, &ecsv1.GetTaskProtectionInput{
+		Cluster: params.Cluster,
+		Tasks:   awsv1.StringSlice(params.Tasks),
+	}, c.requestOptions(optFns)...)
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return &ecs.GetTaskProtectionOutput{
+		ProtectedTasks: convertProtectedTasks(out.ProtectedTasks),
+		Failures:       convertFailures(out.Failures),
+	}, nil
+}
+
+// requestOptions returns the v1 request options for a call with the v2 options optFns.
+func (c *Client) requestOptions(optFns []func(*ecs.Options)) []request.Option {
+	var o ecs.Options
+	for _, fn := range optFns {
+		fn(&o)
+	}
+
+	opts := append([]request.Option(nil), c.Options...)
+	if o.Credentials != nil {
+		creds := credentialsv1.NewCredentials(&credentialsProvider{provider: o.Credentials})
+		opts = append(opts, func(r *request.Request) {
+			r.Config.Credentials = creds
+		})
+	}
+
+	return opts
+}
+
+// credentialsProvider is a v1 credentials provider backed by a v2 one.
+type credentialsProvider struct {
+	provider  aws.CredentialsProvider
+	expires   time.Time
+	canExpire bool
+}
+
+func (p *credentialsProvider) Retrieve() (credentialsv1.Value, error) {
+	creds, err := p.provider.Retrieve(context.Background())
+	if err != nil {
+		return credentialsv1.Value{}, err
+	}
+	p.expires, p.canExpire = creds.Expires, creds.CanExpire
+
+	return credentialsv1.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		ProviderName:    creds.Source,
+	}, nil
+}
+
+func (p *credentialsProvider) IsExpired() bool {
+	return p.canExpire && !time.Now().Before(p.expires)
+}
+
+func convertProtectedTasks(tasks []*ecsv1.ProtectedTask) []types.ProtectedTask {
+	converted := make([]types.ProtectedTask, 0, len(tasks))
+	for _, task := range tasks {
+		if task == nil {
+			continue
+		}
+		converted = append(converted, types.ProtectedTask{
+			TaskArn:           task.TaskArn,
+			ProtectionEnabled: awsv1.BoolValue(task.ProtectionEnabled),
+			ExpirationDate:    task.ExpirationDate,
+		})
+	}
+
+	return converted
+}
+
+func convertFailures(failures []*ecsv1.Failure) []types.Failure {
+	converted := make([]types.Failure, 0, len(failures))
+	for _, failure := range failures {
+		if failure == nil {
+			continue
+		}
+		converted = append(converted, types.Failure{
+			Arn:    failure.Arn,
+			Reason: failure.Reason,
+			Detail: failure.Detail,
+		})
+	}
+
+	return converted
+}
+
+// convertError converts v1 API errors to smithy.APIErrors, keeping other errors as they are.
+func convertError(err error) error {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return err
+	}
+	if awsErr.Code() == request.CanceledErrorCode {
+		return context.Canceled
+	}
+
+	return &smithy.GenericAPIError{
+		Code:    awsErr.Code(),
+		Message: awsErr.Message(),
+	}
+}
@@ -0,0 +1,236 @@
+package ecstpv1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	ecsv1 "github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+const testTaskARN = "arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/a"
+
+// testECSAPI is a v1 ECS client recording the input and request of its task protection calls.
+type testECSAPI struct {
+	ecsiface.ECSAPI
+
+	err          error
+	updateOutput *ecsv1.UpdateTaskProtectionOutput
+	getOutput    *ecsv1.GetTaskProtectionOutput
+
+	updateInput *ecsv1.UpdateTaskProtectionInput
+	request     *request.Request
+}
+
+func (a *testECSAPI) UpdateTaskProtectionWithContext(
+	ctx awsv1.Context, input *ecsv1.UpdateTaskProtectionInput, opts ...request.Option,
+) (*ecsv1.UpdateTaskProtectionOutput, error) {
+	a.updateInput = input
+	a.apply(opts)
+	if a.err != nil {
+		return nil, a.err
+	}
+	if a.updateOutput == nil {
+		return &ecsv1.UpdateTaskProtectionOutput{}, nil
+	}
+
+	return a.updateOutput, nil
+}
+
+func (a *testECSAPI) GetTaskProtectionWithContext(
+	ctx awsv1.Context, input *ecsv1.GetTaskProtectionInput, opts ...request.Option,
+) (*ecsv1.GetTaskProtectionOutput, error) {
+	a.apply(opts)
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	return a.getOutput, nil
+}
+
+// apply applies opts to a new request, kept in a.request.
+func (a *testECSAPI) apply(opts []request.Option) {
+	a.request = &request.Request{}
+	a.request.ApplyOptions(opts...)
+}
+
+func TestClient_UpdateTaskProtection(t *testing.T) {
+	expiresAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	api := &testECSAPI{updateOutput: &ecsv1.UpdateTaskProtectionOutput{
+		ProtectedTasks: []*ecsv1.ProtectedTask{
+			nil,
+			{TaskArn: awsv1.String(testTaskARN), ProtectionEnabled: awsv1.Bool(true), ExpirationDate: &expiresAt},
+		},
+		Failures: []*ecsv1.Failure{
+			nil,
+			{Arn: awsv1.String("b"), Reason: awsv1.String("TASK_NOT_VALID"), Detail: awsv1.String("stopped")},
+		},
+	}}
+
+	out, err := New(api).UpdateTaskProtection(context.Background(), &ecs.UpdateTaskProtectionInput{
+		Cluster:           aws.String("test_cluster"),
+		Tasks:             []string{testTaskARN, "b"},
+		ProtectionEnabled: true,
+		ExpiresInMinutes:  aws.Int32(60),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, &ecsv1.UpdateTaskProtectionInput{
+		Cluster:           awsv1.String("test_cluster"),
+		Tasks:             awsv1.StringSlice([]string{testTaskARN, "b"}),
+		ProtectionEnabled: awsv1.Bool(true),
+		ExpiresInMinutes:  awsv1.Int64(60),
+	}, api.updateInput)
+	assert.Equal(t, []types.ProtectedTask{
+		{TaskArn: aws.String(testTaskARN), ProtectionEnabled: true, ExpirationDate: &expiresAt},
+	}, out.ProtectedTasks, "nil tasks should be skipped")
+	assert.Equal(t, []types.Failure{
+		{Arn: aws.String("b"), Reason: aws.String("TASK_NOT_VALID"), Detail: aws.String("stopped")},
+	}, out.Failures, "nil failures should be skipped")
+}
+
+func TestClient_GetTaskProtection(t *testing.T) {
+	api := &testECSAPI{getOutput: &ecsv1.GetTaskProtectionOutput{
+		ProtectedTasks: []*ecsv1.ProtectedTask{{TaskArn: awsv1.String(testTaskARN)}},
+	}}
+
+	out, err := New(api).GetTaskProtection(context.Background(), &ecs.GetTaskProtectionInput{
+		Cluster: aws.String("test_cluster"),
+		Tasks:   []string{testTaskARN},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []types.ProtectedTask{{TaskArn: aws.String(testTaskARN)}}, out.ProtectedTasks)
+	assert.Empty(t, out.Failures)
+}
+
+func TestClient_Credentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials aws.CredentialsProvider
+		wantExpired bool
+	}{
+		{
+			name: "should bridge credentials that don't expire",
+			credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+				return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token", Source: "test"}, nil
+			}),
+		},
+		{
+			name: "should bridge the expiry of credentials",
+			credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+				return aws.Credentials{
+					AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token", Source: "test",
+					CanExpire: true, Expires: time.Now().Add(-time.Minute),
+				}, nil
+			}),
+			wantExpired: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &testECSAPI{}
+
+			_, err := New(api).UpdateTaskProtection(context.Background(), &ecs.UpdateTaskProtectionInput{
+				Cluster: aws.String("test_cluster"),
+				Tasks:   []string{testTaskARN},
+			}, func(o *ecs.Options) {
+				o.Credentials = tt.credentials
+			})
+
+			require.NoError(t, err)
+			require.NotNil(t, api.request.Config.Credentials)
+			creds, err := api.request.Config.Credentials.Get()
+			require.NoError(t, err)
+			assert.Equal(t, "AKID", creds.AccessKeyID)
+			assert.Equal(t, "secret", creds.SecretAccessKey)
+			assert.Equal(t, "token", creds.SessionToken)
+			assert.Equal(t, tt.wantExpired, api.request.Config.Credentials.IsExpired())
+		})
+	}
+}
+
+func TestClient_Options(t *testing.T) {
+	api := &testECSAPI{}
+	client := New(api, request.WithLogLevel(awsv1.LogDebug))
+
+	_, err := client.UpdateTaskProtection(context.Background(), &ecs.UpdateTaskProtectionInput{
+		Cluster: aws.String("test_cluster"),
+		Tasks:   []string{testTaskARN},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, awsv1.LogDebug, api.request.Config.LogLevel.Value())
+	assert.Nil(t, api.request.Config.Credentials, "credentials should only be set if passed")
+}
+
+func TestConvertError(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name      string
+		err       error
+		wantErr   error
+		wantCode  string
+		wantMatch error
+	}{
+		{
+			name:    "should keep errors that aren't v1 API errors",
+			err:     boom,
+			wantErr: boom,
+		},
+		{
+			name:    "should convert canceled requests to context.Canceled",
+			err:     awserr.New(request.CanceledErrorCode, "canceled", context.Canceled),
+			wantErr: context.Canceled,
+		},
+		{
+			name:      "should convert API errors to smithy.APIErrors",
+			err:       awserr.New("ThrottlingException", "Rate exceeded", nil),
+			wantCode:  "ThrottlingException",
+			wantMatch: ecstp.ErrThrottled,
+		},
+		{
+			name:     "should convert request failures",
+			err:      awserr.NewRequestFailure(awserr.New("InvalidParameterException", "bad task", nil), 400, "id"),
+			wantCode: "InvalidParameterException",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &testECSAPI{err: tt.err}
+			client := ecstp.NewClient(New(api))
+
+			_, err := client.UpdateTaskProtection(context.Background(), &ecstp.UpdateTaskProtectionInput{
+				Metadata: &ecstp.MetadataBody{Cluster: "test_cluster", TaskARN: testTaskARN},
+				Protect:  true,
+			})
+
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, tt.wantErr, convertError(tt.err))
+			}
+			if tt.wantCode != "" {
+				var apiErr smithy.APIError
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.wantCode, apiErr.ErrorCode())
+			}
+			if tt.wantMatch != nil {
+				assert.ErrorIs(t, err, tt.wantMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/aws/smithy-go"
+)
+
+// Operation names used in ErrorDetail.
+const (
+	OperationGetTaskMetadata      = "GetTaskMetadata"
+	OperationUpdateTaskProtection = "UpdateTaskProtection"
+	OperationGetTaskProtection    = "GetTaskProtection"
+)
+
+// Reason codes used in ErrorDetail for errors that don't originate from an AWS API.
+const (
+	ReasonCanceled = "Canceled"
+	ReasonTimeout  = "Timeout"
+	ReasonUnknown  = "Unknown"
+)
+
+// Failure reason reported by ECS for tasks it doesn't know.
+const failureReasonMissing = "MISSING"
+
+// Sentinel errors classifying the failures of ECS calls and task metadata requests, matched with
+// errors.Is by the errors returned by a Client, which keep wrapping the underlying SDK error.
+var (
+	// ErrMetadataUnavailable is matched when the task metadata endpoint isn't configured or can't be
+	// reached, e.g. outside ECS.
+	ErrMetadataUnavailable = errors.New("task metadata unavailable")
+	// ErrThrottled is matched when ECS throttled the call.
+	ErrThrottled = errors.New("throttled by ECS")
+	// ErrTaskNotFound is matched when ECS doesn't know the task, e.g. because it has stopped.
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrDeploymentBlocking is matched when protection is refused because it would hold up a
+	// deployment, e.g. ErrDeploymentBlackout.
+	ErrDeploymentBlocking = errors.New("deployment in progress")
+	// ErrExpirationOutOfRange is matched when the protection period is outside the range accepted by
+	// ECS, 1 to 2880 minutes.
+	ErrExpirationOutOfRange = errors.New("protection expiration out of range")
+)
+
+// classifiedError is an error of the ECS API that also matches the sentinel error of its class.
+type classifiedError struct {
+	err   error
+	class error
+}
+
+// classifyError returns err matching the sentinel error of its class with errors.Is, if any.
+func classifyError(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	if class := errorClass(apiErr.ErrorCode(), apiErr.ErrorMessage()); class != nil {
+		return &classifiedError{err: err, class: class}
+	}
+
+	return err
+}
+
+// errorClass returns the sentinel error for the error code or failure reason reported by ECS, or
+// nil if it isn't classified.
+func errorClass(code, message string) error {
+	switch code {
+	case "ThrottlingException", "Throttling", "TooManyRequestsException", "RequestLimitExceeded":
+		return ErrThrottled
+	case failureReasonMissing:
+		return ErrTaskNotFound
+	case "InvalidParameterException":
+		if strings.Contains(strings.ToLower(message), "expiresinminutes") {
+			return ErrExpirationOutOfRange
+		}
+	}
+
+	return nil
+}
+
+// Error implements error.
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error and the sentinel error of its class.
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.err, e.class}
+}
+
+// maxErrorMessageLength is the maximum length of ErrorDetail.Message and MetadataDecodeError.Body.
+const maxErrorMessageLength = 256
+
+// ErrMetadataDecode is matched by a *MetadataDecodeError with errors.Is.
+var ErrMetadataDecode = errors.New("unable to decode task metadata")
+
+// MetadataDecodeError is returned when the response of the task metadata endpoint isn't a JSON
+// metadata document, e.g. when the agent returns an HTML error page or a truncated body.
+type MetadataDecodeError struct {
+	StatusCode  int
+	ContentType string
+	// Body is a sample of the response body, truncated and stripped of anything resembling
+	// credentials.
+	Body string
+	Err  error
+}
+
+func newMetadataDecodeError(res *http.Response, body []byte, err error) *MetadataDecodeError {
+	sample := redactSecrets(string(body))
+	if len(sample) > maxErrorMessageLength {
+		sample = sample[:maxErrorMessageLength] + "..."
+	}
+
+	return &MetadataDecodeError{
+		StatusCode:  res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        sample,
+		Err:         err,
+	}
+}
+
+// Error implements error.
+func (e *MetadataDecodeError) Error() string {
+	return fmt.Sprintf("%v (status %d, content type %q): %v: %q", ErrMetadataDecode, e.StatusCode, e.ContentType, e.Err, e.Body)
+}
+
+// Is reports whether target is ErrMetadataDecode.
+func (e *MetadataDecodeError) Is(target error) bool {
+	return target == ErrMetadataDecode
+}
+
+// Unwrap returns the underlying error.
+func (e *MetadataDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorDetail is a structured description of an error that is safe to ship to logs and external
+// systems.
+//
+// The message is stripped of anything resembling credentials or signatures and truncated, so the
+// original error should be used for local debugging only.
+type ErrorDetail struct {
+	Operation string `json:"operation" yaml:"operation"`
+	TaskARN   string `json:"taskArn,omitempty" yaml:"taskArn,omitempty"`
+	Code      string `json:"code" yaml:"code"`
+	Message   string `json:"message,omitempty" yaml:"message,omitempty"`
+	Retryable bool   `json:"retryable" yaml:"retryable"`
+}
+
+// NewErrorDetail returns an ErrorDetail describing err, which occurred during operation on
+// taskARN.
+func NewErrorDetail(operation, taskARN string, err error) *ErrorDetail {
+	detail := &ErrorDetail{
+		Operation: operation,
+		TaskARN:   taskARN,
+		Code:      ReasonUnknown,
+		Retryable: IsRetryable(err),
+	}
+
+	var (
+		apiErr  smithy.APIError
+		failure *ProtectionFailureError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		detail.Code = apiErr.ErrorCode()
+		detail.Message = apiErr.ErrorMessage()
+	case errors.As(err, &failure):
+		// keeps matching the class of the failure, e.g. ErrTaskNotFound
+		detail.Code = failure.Reason
+		detail.Message = err.Error()
+	case errors.Is(err, context.Canceled):
+		detail.Code = ReasonCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		detail.Code = ReasonTimeout
+	default:
+		detail.Message = err.Error()
+	}
+	detail.Message = sanitizeMessage(detail.Message)
+
+	return detail
+}
+
+// Is reports whether target is the sentinel error of the class of d's code, e.g. ErrTaskNotFound.
+func (d *ErrorDetail) Is(target error) bool {
+	class := errorClass(d.Code, d.Message)
+
+	return class != nil && class == target
+}
+
+// Error implements error.
+func (d *ErrorDetail) Error() string {
+	s := d.Operation + ": " + d.Code
+	if d.Message != "" {
+		s += ": " + d.Message
+	}
+
+	return s
+}
+
+// LogValue implements slog.LogValuer.
+func (d *ErrorDetail) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("operation", d.Operation),
+		slog.String("task_arn", d.TaskARN),
+		slog.String("code", d.Code),
+		slog.String("message", d.Message),
+		slog.Bool("retryable", d.Retryable),
+	)
+}
+
+var secretPatterns = []*regexp.Regexp{
+	// access key IDs
+	regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`),
+	// signing parameters in headers and presigned URLs
+	regexp.MustCompile(`(?i)(X-Amz-(Security-Token|Signature|Credential)|Signature|Credential|SecretAccessKey|SessionToken|Token)(["']?\s*[=:]\s*["']?)[^\s,&"']+`),
+	// user info and query strings of URLs, which may carry tokens
+	regexp.MustCompile(`(https?://)[^/\s@]+@`),
+	regexp.MustCompile(`(https?://[^\s?"]+)\?[^\s"]*`),
+}
+
+// redactSecrets removes credentials and tokens from msg.
+func redactSecrets(msg string) string {
+	msg = secretPatterns[0].ReplaceAllString(msg, "[REDACTED]")
+	msg = secretPatterns[1].ReplaceAllString(msg, "$1$3[REDACTED]")
+	msg = secretPatterns[2].ReplaceAllString(msg, "$1")
+	msg = secretPatterns[3].ReplaceAllString(msg, "$1")
+
+	return msg
+}
+
+// sanitizeMessage removes credentials, tokens and JSON documents from msg and truncates it.
+func sanitizeMessage(msg string) string {
+	msg = redactSecrets(msg)
+
+	// never include metadata or other JSON documents
+	if i := indexJSONDocument(msg); i >= 0 {
+		msg = msg[:i] + "[document omitted]"
+	}
+
+	if len(msg) > maxErrorMessageLength {
+		msg = msg[:maxErrorMessageLength] + "..."
+	}
+
+	return msg
+}
+
+var jsonDocumentPattern = regexp.MustCompile(`[{\[]\s*"`)
+
+func indexJSONDocument(msg string) int {
+	loc := jsonDocumentPattern.FindStringIndex(msg)
+	if loc == nil {
+		return -1
+	}
+
+	return loc[0]
+}
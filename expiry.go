@@ -0,0 +1,58 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// ProtectUntil enables protection of the task until t, rounded up to the minute. It returns an
+// error wrapping ErrExpirationOutOfRange if t isn't in the future or is more than
+// MaxExpiresInMinutes away. See UpdateTaskProtection.
+func (c *Client) ProtectUntil(ctx context.Context, t time.Time) (*ecs.UpdateTaskProtectionOutput, error) {
+	minutes, err := durationMinutes(time.Until(t))
+	if err != nil {
+		return nil, fmt.Errorf("unable to protect task until %s: %w", t.Format(time.RFC3339), err)
+	}
+
+	return c.UpdateTaskProtection(ctx, &UpdateTaskProtectionInput{
+		Protect:          true,
+		ExpiresInMinutes: &minutes,
+	})
+}
+
+// resolveExpiresIn returns input with its ExpiresIn converted to ExpiresInMinutes, if set.
+func resolveExpiresIn(input *UpdateTaskProtectionInput) (*UpdateTaskProtectionInput, error) {
+	if input.ExpiresIn == 0 {
+		return input, nil
+	}
+	if input.ExpiresInMinutes != nil {
+		return nil, &ValidationError{Field: "ExpiresIn", Reason: "must not be set along with ExpiresInMinutes"}
+	}
+	minutes, err := durationMinutes(input.ExpiresIn)
+	if err != nil {
+		return nil, &ValidationError{
+			Field:  "ExpiresIn",
+			Reason: fmt.Sprintf("must be between 1 and %d minutes, got %s", MaxExpiresInMinutes, input.ExpiresIn),
+			Err:    ErrExpirationOutOfRange,
+		}
+	}
+
+	resolved := *input
+	resolved.ExpiresIn = 0
+	resolved.ExpiresInMinutes = &minutes
+
+	return &resolved, nil
+}
+
+// durationMinutes converts d to the whole minutes accepted by ECS, rounding up. It returns an error
+// wrapping ErrExpirationOutOfRange unless d is positive and at most MaxExpiresInMinutes.
+func durationMinutes(d time.Duration) (int32, error) {
+	if d <= 0 || d > MaxExpiresInMinutes*time.Minute {
+		return 0, fmt.Errorf("%w: %s isn't between 1 and %d minutes", ErrExpirationOutOfRange, d, MaxExpiresInMinutes)
+	}
+
+	return int32((d + time.Minute - 1) / time.Minute), nil
+}
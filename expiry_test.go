@@ -0,0 +1,87 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_UpdateTaskProtection_ExpiresIn(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       *UpdateTaskProtectionInput
+		wantMinutes int32
+		wantErr     error
+	}{
+		{
+			name:        "should convert the duration to minutes",
+			input:       &UpdateTaskProtectionInput{Protect: true, ExpiresIn: 90 * time.Minute},
+			wantMinutes: 90,
+		},
+		{
+			name:        "should round up to the minute",
+			input:       &UpdateTaskProtectionInput{Protect: true, ExpiresIn: 90*time.Minute + time.Second},
+			wantMinutes: 91,
+		},
+		{
+			name:        "should accept the maximum period",
+			input:       &UpdateTaskProtectionInput{Protect: true, ExpiresIn: 48 * time.Hour},
+			wantMinutes: MaxExpiresInMinutes,
+		},
+		{
+			name:    "should reject longer periods",
+			input:   &UpdateTaskProtectionInput{Protect: true, ExpiresIn: 48*time.Hour + time.Second},
+			wantErr: ErrExpirationOutOfRange,
+		},
+		{
+			name:    "should reject negative periods",
+			input:   &UpdateTaskProtectionInput{Protect: true, ExpiresIn: -time.Minute},
+			wantErr: ErrExpirationOutOfRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditor := &recordingAuditor{}
+			c := NewClient(&SuccessfulTestClient{}, WithAuditor(auditor))
+			tt.input.Metadata = &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"}
+
+			_, err := c.UpdateTaskProtection(context.Background(), tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, auditor.records)
+				return
+			}
+			if assert.NoError(t, err) && assert.Len(t, auditor.records, 1) {
+				assert.Equal(t, tt.wantMinutes, *auditor.records[0].ExpiresInMinutes)
+			}
+		})
+	}
+}
+
+func TestClient_ProtectUntil(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`)
+	}))
+	defer ts.Close()
+
+	auditor := &recordingAuditor{}
+	c := NewClient(&SuccessfulTestClient{}, WithMetadataEndpoint(ts.URL), WithAuditor(auditor))
+
+	_, err := c.ProtectUntil(context.Background(), time.Now().Add(2*time.Hour))
+	if assert.NoError(t, err) && assert.Len(t, auditor.records, 1) {
+		assert.True(t, auditor.records[0].Protect)
+		assert.Equal(t, int32(120), *auditor.records[0].ExpiresInMinutes)
+	}
+
+	_, err = c.ProtectUntil(context.Background(), time.Now().Add(-time.Minute))
+	assert.ErrorIs(t, err, ErrExpirationOutOfRange)
+
+	_, err = c.ProtectUntil(context.Background(), time.Now().Add(49*time.Hour))
+	assert.ErrorIs(t, err, ErrExpirationOutOfRange)
+	assert.Len(t, auditor.records, 1)
+}
@@ -0,0 +1,54 @@
+package ecstp
+
+import (
+	"context"
+	"time"
+)
+
+// ProtectionExport is the protection of a set of tasks at a point in time, e.g. captured for an
+// audit with Remote.Export and reproduced with Remote.Import. It marshals to JSON.
+type ProtectionExport struct {
+	ExportedAt time.Time         `json:"exportedAt"`
+	Selectors  []TaskSelector    `json:"selectors"`
+	Tasks      []SavedProtection `json:"tasks"`
+}
+
+// Export returns the protection of the tasks matched by selectors, e.g. the tasks of a service or
+// of a whole cluster. Tasks matched by several selectors are exported once. It requires an ECS
+// client implementing TaskProtectionGetter, and TaskLister or TaskDescriber as required by
+// Resolve.
+func (r *Remote) Export(ctx context.Context, selectors ...TaskSelector) (ProtectionExport, error) {
+	export := ProtectionExport{Selectors: selectors}
+
+	seen := map[string]bool{}
+	for _, selector := range selectors {
+		tasks, err := r.Resolve(ctx, selector)
+		if err != nil {
+			return export, err
+		}
+
+		var unseen []MetadataBody
+		for _, task := range tasks {
+			if !seen[task.TaskARN] {
+				seen[task.TaskARN] = true
+				unseen = append(unseen, task)
+			}
+		}
+		saved, err := r.save(ctx, unseen)
+		if err != nil {
+			return export, err
+		}
+		export.Tasks = append(export.Tasks, saved...)
+	}
+	export.ExportedAt = time.Now().UTC()
+
+	return export, nil
+}
+
+// Import re-applies the protection of export: tasks that were protected are protected until their
+// exported expiry, if it hasn't passed, and others are unprotected. Tasks whose protection couldn't
+// be exported are left unchanged. The state of every task is returned, along with the errors of the
+// tasks that failed, e.g. because they have stopped since, joined together.
+func (r *Remote) Import(ctx context.Context, export ProtectionExport) ([]State, error) {
+	return r.restore(ctx, export.Tasks, time.Now())
+}
@@ -0,0 +1,53 @@
+package ecstp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemote_ExportImport(t *testing.T) {
+	a, b, c := remoteTestARNPrefix+"a", remoteTestARNPrefix+"b", remoteTestARNPrefix+"c"
+	client := &RemoteTestClient{protected: map[string]bool{a: true}}
+	r := NewRemote(NewClient(client))
+	ctx := context.Background()
+
+	export, err := r.Export(ctx,
+		TaskSelector{Cluster: "test_cluster", Service: "web"},
+		TaskSelector{Cluster: "test_cluster"},
+	)
+	require.NoError(t, err)
+	var exported []string
+	for _, task := range export.Tasks {
+		exported = append(exported, task.TaskARN)
+	}
+	assert.Equal(t, []string{a, b, c}, exported, "tasks matched by several selectors should be exported once")
+	assert.False(t, export.ExportedAt.IsZero())
+
+	data, err := json.Marshal(export)
+	require.NoError(t, err)
+	var imported ProtectionExport
+	require.NoError(t, json.Unmarshal(data, &imported))
+
+	_, err = r.Unprotect(ctx, []MetadataBody{{Cluster: "test_cluster", TaskARN: a}})
+	require.NoError(t, err)
+	_, err = r.Protect(ctx, []MetadataBody{{Cluster: "test_cluster", TaskARN: c}}, nil)
+	require.NoError(t, err)
+
+	states, err := r.Import(ctx, imported)
+	require.NoError(t, err)
+	assert.Len(t, states, 3)
+	assert.Equal(t, map[string]bool{a: true, b: false, c: false}, client.protected,
+		"the exported protection should be reproduced")
+}
+
+func TestRemote_Export_Error(t *testing.T) {
+	r := NewRemote(NewClient(&RemoteTestClient{}))
+
+	_, err := r.Export(context.Background(), TaskSelector{Cluster: "test_cluster", Service: "worker"})
+
+	assert.ErrorIs(t, err, ErrNoTasks)
+}
@@ -0,0 +1,48 @@
+package ecstp
+
+import (
+	"expvar"
+	"time"
+)
+
+// ExpvarName is the name under which PublishExpvar publishes the state of a Manager.
+const ExpvarName = "ecstp"
+
+// PublishExpvar publishes the state of m as the expvar ExpvarName, served on /debug/vars by the
+// expvar package's handler. Like expvar.Publish, it panics if the name is already in use, so it
+// should be called once per process.
+func PublishExpvar(m *Manager) {
+	expvar.Publish(ExpvarName, ExpvarVar(m))
+}
+
+// ExpvarVar returns an expvar.Var reporting the state of m as a map of:
+//
+//	protected          1 if the task is protected, otherwise 0
+//	seconds_to_expiry  seconds until protection expires, or 0 if it doesn't
+//	renewal_failures   number of failed updates enabling protection
+//	stopping           1 if ECS reported the task stopping, otherwise 0
+func ExpvarVar(m *Manager) expvar.Var {
+	return expvar.Func(func() any {
+		m.mu.Lock()
+		state, failures := m.state, m.renewalFailures
+		m.mu.Unlock()
+
+		vars := map[string]any{
+			"protected":         0,
+			"seconds_to_expiry": 0.0,
+			"renewal_failures":  failures,
+			"stopping":          0,
+		}
+		if protectedAt(state, time.Now()) {
+			vars["protected"] = 1
+			if state.ExpiresAt != nil {
+				vars["seconds_to_expiry"] = time.Until(*state.ExpiresAt).Seconds()
+			}
+		}
+		if state.Stopping {
+			vars["stopping"] = 1
+		}
+
+		return vars
+	})
+}
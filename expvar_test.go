@@ -0,0 +1,55 @@
+package ecstp
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvarVar(t *testing.T) {
+	ecsClient := &ExpiringTestClient{expiry: time.Hour}
+	m := NewManager(NewClient(ecsClient), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	v := ExpvarVar(m)
+
+	vars := func() map[string]float64 {
+		var vars map[string]float64
+		require.NoError(t, json.Unmarshal([]byte(v.String()), &vars))
+		return vars
+	}
+
+	assert.Equal(t, map[string]float64{
+		"protected":         0,
+		"seconds_to_expiry": 0,
+		"renewal_failures":  0,
+		"stopping":          0,
+	}, vars())
+
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+	got := vars()
+	assert.Equal(t, 1.0, got["protected"])
+	assert.InDelta(t, time.Hour.Seconds(), got["seconds_to_expiry"], 5)
+
+	ecsClient.fail.Store(true)
+	_, err = m.Protect(context.Background(), nil)
+	require.Error(t, err)
+	_, err = m.Unprotect(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, 1.0, vars()["renewal_failures"], "only failures enabling protection should be counted")
+}
+
+func TestPublishExpvar(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	// expvars can't be unpublished, e.g. when the test runs repeatedly
+	if expvar.Get(ExpvarName) == nil {
+		PublishExpvar(m)
+	}
+
+	assert.NotNil(t, expvar.Get(ExpvarName))
+	assert.Panics(t, func() { PublishExpvar(m) })
+}
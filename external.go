@@ -0,0 +1,59 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// LaunchTypeExternal is the launch type of ECS Anywhere tasks, which run on external instances,
+// e.g. on premises.
+const LaunchTypeExternal = "EXTERNAL"
+
+// External reports whether the task runs on an external instance with ECS Anywhere.
+func (m *MetadataBody) External() bool {
+	return m.LaunchType == LaunchTypeExternal
+}
+
+// Region returns the region of the task from its Task ARN or, failing that, its Availability Zone,
+// which isn't reported for ECS Anywhere tasks. It returns an empty string if neither is known.
+func (m *MetadataBody) Region() string {
+	if taskARN, err := ParseTaskARN(m.TaskARN); err == nil {
+		return taskARN.Region
+	}
+	// e.g. eu-west-2a; local zones are resolved from the Task ARN above
+	if az := m.AvailabilityZone; strings.Count(az, "-") == 2 && len(az) > 1 {
+		return az[:len(az)-1]
+	}
+
+	return ""
+}
+
+// taskRegion resolves the region of the current task from its metadata.
+func (c *Client) taskRegion(ctx context.Context) (string, error) {
+	metadata, err := c.GetTaskArn(ctx)
+	if err != nil {
+		return "", err
+	}
+	region := metadata.Region()
+	if region == "" {
+		return "", errors.New("unable to resolve the region of the task from its metadata")
+	}
+
+	return region, nil
+}
+
+// skipInstanceProtection reports whether the task described by metadata runs on ECS Anywhere,
+// outside any Auto Scaling group, logging that its instance protection is skipped.
+func (c *Client) skipInstanceProtection(ctx context.Context, metadata *MetadataBody) bool {
+	if !metadata.External() {
+		return false
+	}
+
+	c.log().WarnContext(ctx, "skipping instance protection of ECS Anywhere task",
+		slog.String("task_arn", metadata.TaskARN),
+	)
+
+	return true
+}
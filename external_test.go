@@ -0,0 +1,99 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const externalTaskMetadata = `{
+	"Cluster": "on-prem",
+	"TaskARN": "arn:aws:ecs:eu-west-2:123456789012:task/on-prem/0123456789abcdef",
+	"LaunchType": "EXTERNAL"
+}`
+
+func TestMetadataBody_Region(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata MetadataBody
+		want     string
+	}{
+		{
+			name:     "should use the region of the task ARN",
+			metadata: MetadataBody{TaskARN: "arn:aws:ecs:eu-west-2:123456789012:task/prod/abc", AvailabilityZone: "us-east-1-bos-1a"},
+			want:     "eu-west-2",
+		},
+		{
+			name:     "should resolve the region of external tasks without an Availability Zone",
+			metadata: MetadataBody{TaskARN: "arn:aws:ecs:eu-west-2:123456789012:task/on-prem/abc", LaunchType: LaunchTypeExternal},
+			want:     "eu-west-2",
+		},
+		{
+			name:     "should fall back to the Availability Zone",
+			metadata: MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn", AvailabilityZone: "eu-west-2a"},
+			want:     "eu-west-2",
+		},
+		{
+			name:     "should return an empty region if unknown",
+			metadata: MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+			want:     "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.metadata.Region())
+		})
+	}
+}
+
+func TestClient_GetTaskArn_External(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, externalTaskMetadata)
+	}))
+	defer ts.Close()
+
+	c := NewClient(nil)
+	c.MetadataEndpointOverride = ts.URL
+
+	got, err := c.GetTaskArn(context.Background())
+	require.NoError(t, err)
+	assert.True(t, got.External())
+	assert.Equal(t, "arn:aws:ecs:eu-west-2:123456789012:cluster/on-prem", got.Cluster)
+	assert.Equal(t, "eu-west-2", got.Region())
+}
+
+func TestNewDefaultClient_ExternalRegion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, externalTaskMetadata)
+	}))
+	defer ts.Close()
+
+	// no region is configured on external instances without EC2 instance metadata
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", ts.URL)
+
+	c, err := NewDefaultClient(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-2", c.ECSClient.(*ecs.Client).Options().Region)
+}
+
+func TestClient_UpdateTaskProtection_ExternalInstanceProtection(t *testing.T) {
+	setter := &InstanceProtectionTestSetter{}
+	c := NewClient(&SuccessfulTestClient{}, WithInstanceProtection(setter, "test_asg", "i-0123456789abcdef0"))
+
+	_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+		Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn", LaunchType: LaunchTypeExternal},
+		Protect:  true,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, setter.calls, "instance protection should be skipped for external tasks")
+}
@@ -0,0 +1,147 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Source reports the desired protection of tasks, e.g. from task tags or a DynamoDB table.
+type Source interface {
+	Desired(ctx context.Context) (map[Key]Desired, error)
+}
+
+// SourceFunc is an adapter to allow the use of ordinary functions as Sources.
+type SourceFunc func(ctx context.Context) (map[Key]Desired, error)
+
+// Desired calls f(ctx).
+func (f SourceFunc) Desired(ctx context.Context) (map[Key]Desired, error) {
+	return f(ctx)
+}
+
+// Controller declares the desired protection of a Fleet from Sources and reconciles it, so that
+// protection can be declared rather than imperatively toggled:
+//
+//	c := &fleet.Controller{
+//		Fleet:   fleet.New(ecsClient),
+//		Sources: []fleet.Source{&fleet.TagSource{Client: ecsClient, Clusters: clusters}},
+//	}
+//	go c.Run(ctx)
+//
+// A task reported by several Sources is protected if any of them desires it, until the latest of
+// their Until. Tasks no longer reported by any Source are unprotected, then removed from the Fleet.
+type Controller struct {
+	Fleet   *Fleet
+	Sources []Source
+	// Interval is the time between syncs. Defaults to DefaultInterval.
+	Interval time.Duration
+	Logger   *slog.Logger
+
+	mu sync.Mutex
+	// managed are the tasks set by the Controller.
+	managed map[Key]bool
+}
+
+// Run syncs and reconciles the Fleet every Interval until ctx is done. Errors are logged.
+func (c *Controller) Run(ctx context.Context) error {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Sync(ctx); err != nil && ctx.Err() == nil {
+			c.logger().ErrorContext(ctx, "unable to read desired protection", slog.Any("error", err))
+		}
+		if err := c.Fleet.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			c.logger().ErrorContext(ctx, "unable to reconcile fleet protection", slog.Any("error", err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sync reads the desired protection from the Sources and sets it on the Fleet. If a Source fails,
+// its error is returned and tasks it may have reported are left unchanged.
+func (c *Controller) Sync(ctx context.Context) error {
+	desired := map[Key]Desired{}
+	var errs []error
+	for _, source := range c.Sources {
+		tasks, err := source.Desired(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for key, d := range tasks {
+			if existing, ok := desired[key]; ok {
+				d = merge(existing, d)
+			}
+			desired[key] = d
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.managed == nil {
+		c.managed = map[Key]bool{}
+	}
+	for key, d := range desired {
+		c.Fleet.Set(key, d)
+		c.managed[key] = true
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for key := range c.managed {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		status, ok := c.Fleet.Status(key)
+		switch {
+		case !ok:
+			delete(c.managed, key)
+		case status.Desired.Protect:
+			c.Fleet.Set(key, Desired{Reason: "no longer declared"})
+		case status.InSync:
+			c.Fleet.Remove(key)
+			delete(c.managed, key)
+		}
+	}
+
+	return nil
+}
+
+func (c *Controller) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+
+	return c.Logger
+}
+
+// merge returns the protection desired by either a or b.
+func merge(a, b Desired) Desired {
+	now := time.Now()
+	switch {
+	case !b.protectAt(now):
+		return a
+	case !a.protectAt(now):
+		return b
+	case a.Until == nil:
+		return a
+	case b.Until == nil || b.Until.After(*a.Until):
+		return b
+	default:
+		return a
+	}
+}
@@ -0,0 +1,83 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticSource reports the desired protection in desired, or fails with err.
+type staticSource struct {
+	desired map[Key]Desired
+	err     error
+}
+
+func (s *staticSource) Desired(ctx context.Context) (map[Key]Desired, error) {
+	return s.desired, s.err
+}
+
+func TestController_Sync(t *testing.T) {
+	ecsClient := &testECSClient{}
+	key, other := Key{Cluster: "a", TaskARN: "a/task-1"}, Key{Cluster: "a", TaskARN: "a/task-2"}
+	later := time.Now().Add(2 * time.Hour)
+	tags := &staticSource{desired: map[Key]Desired{
+		key:   {Protect: true, Until: aws.Time(time.Now().Add(time.Hour))},
+		other: {Protect: true},
+	}}
+	table := &staticSource{desired: map[Key]Desired{
+		key: {Protect: true, Until: aws.Time(later)},
+	}}
+	c := &Controller{Fleet: New(ecsClient), Sources: []Source{tags, table}}
+	ctx := context.Background()
+
+	require.NoError(t, c.Sync(ctx))
+	require.NoError(t, c.Fleet.Reconcile(ctx))
+	status, _ := c.Fleet.Status(key)
+	assert.True(t, status.Protected)
+	assert.Equal(t, later, *status.Desired.Until, "the latest declared protection should win")
+
+	table.err = errors.New("throttled")
+	delete(tags.desired, key)
+	assert.Error(t, c.Sync(ctx))
+	status, _ = c.Fleet.Status(key)
+	assert.True(t, status.Desired.Protect, "tasks should be left unchanged while a source fails")
+
+	table.err = nil
+	table.desired = nil
+	require.NoError(t, c.Sync(ctx))
+	require.NoError(t, c.Fleet.Reconcile(ctx))
+	status, ok := c.Fleet.Status(key)
+	require.True(t, ok)
+	assert.False(t, status.Protected, "undeclared tasks should be unprotected")
+
+	require.NoError(t, c.Sync(ctx))
+	_, ok = c.Fleet.Status(key)
+	assert.False(t, ok, "undeclared tasks should be removed once unprotected")
+	_, ok = c.Fleet.Status(other)
+	assert.True(t, ok)
+}
+
+func TestController_Run(t *testing.T) {
+	ecsClient := &testECSClient{}
+	key := Key{Cluster: "a", TaskARN: "a/task"}
+	c := &Controller{
+		Fleet:    New(ecsClient),
+		Sources:  []Source{SourceFunc(func(ctx context.Context) (map[Key]Desired, error) { return map[Key]Desired{key: {Protect: true}}, nil })},
+		Interval: time.Hour,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	assert.Eventually(t, func() bool {
+		status, _ := c.Fleet.Status(key)
+		return status.Protected
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
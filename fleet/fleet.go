@@ -0,0 +1,465 @@
+// Package fleet maintains the desired protection of many tasks from a single process, as the
+// building block of operations dashboards and controllers acting on tasks across clusters.
+package fleet
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+const (
+	// MaxUpdateBatchSize is the maximum number of tasks ECS accepts in a single
+	// UpdateTaskProtection call.
+	MaxUpdateBatchSize = 10
+	// MaxGetBatchSize is the maximum number of tasks ECS accepts in a single GetTaskProtection
+	// call.
+	MaxGetBatchSize = 100
+	// DefaultProtectionMinutes is the default protection period, renewed while protection is
+	// desired. It's shorter than ECS's default, ecstp.DefaultExpiresInMinutes, so that protection
+	// no longer desired by a crashed reconciler lapses sooner.
+	DefaultProtectionMinutes = 60
+	// DefaultInterval is the default time between reconciliations.
+	DefaultInterval = 30 * time.Second
+)
+
+// Key identifies a task of the fleet.
+type Key struct {
+	Cluster string `json:"cluster"`
+	TaskARN string `json:"taskArn"`
+}
+
+// Desired is the desired protection of a task.
+type Desired struct {
+	Protect bool `json:"protect"`
+	// Until, if set, is when protection is no longer desired.
+	Until *time.Time `json:"until,omitempty"`
+	// ExpiresInMinutes is the protection period, renewed halfway through. Defaults to
+	// DefaultProtectionMinutes, and is shortened to end at Until.
+	ExpiresInMinutes int32  `json:"expiresInMinutes,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+}
+
+// protectAt reports whether protection is desired at now.
+func (d Desired) protectAt(now time.Time) bool {
+	return d.Protect && (d.Until == nil || d.Until.After(now))
+}
+
+// expiresInMinutes returns the protection period to set at now.
+func (d Desired) expiresInMinutes(now time.Time) int32 {
+	minutes := d.ExpiresInMinutes
+	if minutes <= 0 {
+		minutes = DefaultProtectionMinutes
+	}
+	if d.Until != nil {
+		minutes = min(minutes, int32(math.Ceil(d.Until.Sub(now).Minutes())))
+	}
+
+	return min(max(minutes, 1), ecstp.MaxExpiresInMinutes)
+}
+
+// Status is the desired and actual protection of a task of the fleet.
+type Status struct {
+	Key
+	Desired   Desired    `json:"desired"`
+	Protected bool       `json:"protected"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// InSync reports whether the actual protection matches the desired protection.
+	InSync    bool               `json:"inSync"`
+	UpdatedAt *time.Time         `json:"updatedAt,omitempty"`
+	Error     *ecstp.ErrorDetail `json:"error,omitempty"`
+}
+
+// task is the tracked protection of a task.
+type task struct {
+	desired   Desired
+	protected bool
+	expiresAt *time.Time
+	renewAt   time.Time
+	updatedAt *time.Time
+	err       *ecstp.ErrorDetail
+}
+
+// protectedAt reports whether the task is known to be protected at now.
+func (t *task) protectedAt(now time.Time) bool {
+	return t.protected && (t.expiresAt == nil || t.expiresAt.After(now))
+}
+
+// Fleet maintains the desired protection of many tasks, across clusters.
+//
+// Each Reconcile refreshes the actual protection of the tasks with batched GetTaskProtection calls,
+// if the ECS client supports them, then enables, renews or disables protection where it differs
+// from the desired protection, with UpdateTaskProtection calls batching the tasks of a cluster.
+// Run reconciles every Interval. A Fleet is safe for concurrent use.
+type Fleet struct {
+	// Interval is the time between reconciliations of Run. Defaults to DefaultInterval.
+	Interval time.Duration
+	// Auditor, if set, receives a record for every task updated.
+	Auditor ecstp.Auditor
+	Logger  *slog.Logger
+
+	ecs ecstp.ECSClient
+
+	mu    sync.Mutex
+	tasks map[Key]*task
+	wake  chan struct{}
+}
+
+// New returns a Fleet that updates task protection using ecsClient.
+func New(ecsClient ecstp.ECSClient) *Fleet {
+	return &Fleet{
+		Interval: DefaultInterval,
+		ecs:      ecsClient,
+		tasks:    make(map[Key]*task),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Set sets the desired protection of the task identified by key, adding it to the fleet. A
+// running Run reconciles it without waiting for the next Interval.
+func (f *Fleet) Set(key Key, desired Desired) {
+	f.mu.Lock()
+	t, ok := f.tasks[key]
+	if !ok {
+		t = &task{}
+		f.tasks[key] = t
+	}
+	if desired.Protect != t.desired.Protect || desired.expiresInMinutes(time.Now()) != t.desired.expiresInMinutes(time.Now()) {
+		// apply a new protection period now rather than at the next renewal
+		t.renewAt = time.Time{}
+	}
+	t.desired = desired
+	f.mu.Unlock()
+
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Remove removes the task identified by key from the fleet without changing its protection,
+// reporting whether it was part of it. To release protection first, Set it as unprotected and
+// Reconcile.
+func (f *Fleet) Remove(key Key) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.tasks[key]
+	delete(f.tasks, key)
+
+	return ok
+}
+
+// Status returns the status of the task identified by key, or false if it isn't part of the fleet.
+func (f *Fleet) Status(key Key) (Status, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tasks[key]
+	if !ok {
+		return Status{}, false
+	}
+
+	return t.status(key, time.Now()), true
+}
+
+// Statuses returns the status of every task of the fleet, ordered by cluster and task ARN.
+func (f *Fleet) Statuses() []Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]Status, 0, len(f.tasks))
+	for key, t := range f.tasks {
+		statuses = append(statuses, t.status(key, now))
+	}
+	slices.SortFunc(statuses, func(a, b Status) int { return compareKeys(a.Key, b.Key) })
+
+	return statuses
+}
+
+func (t *task) status(key Key, now time.Time) Status {
+	protected := t.protectedAt(now)
+
+	return Status{
+		Key:       key,
+		Desired:   t.desired,
+		Protected: protected,
+		ExpiresAt: t.expiresAt,
+		InSync:    t.err == nil && protected == t.desired.protectAt(now),
+		UpdatedAt: t.updatedAt,
+		Error:     t.err,
+	}
+}
+
+// Run reconciles the fleet every Interval, and whenever a desired protection is set, until ctx is
+// done. Errors are logged.
+func (f *Fleet) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.interval())
+	defer ticker.Stop()
+
+	for {
+		if err := f.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			f.logger().ErrorContext(ctx, "unable to reconcile fleet protection", slog.Any("error", err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-f.wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Reconcile refreshes the actual protection of the fleet and updates it where it differs from the
+// desired protection, or is due for renewal. Failures for individual tasks are reported in their
+// Status; the returned error joins the errors of failed calls.
+func (f *Fleet) Reconcile(ctx context.Context) error {
+	refreshErr := f.refresh(ctx)
+
+	var errs []error
+	for _, batch := range f.plan(time.Now()) {
+		if err := f.apply(ctx, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(refreshErr, errors.Join(errs...))
+}
+
+// refresh reads the actual protection of the fleet, if the ECS client supports GetTaskProtection.
+func (f *Fleet) refresh(ctx context.Context) error {
+	getter, ok := f.ecs.(ecstp.TaskProtectionGetter)
+	if !ok {
+		return nil
+	}
+
+	var errs []error
+	for _, batch := range batches(f.keys(), MaxGetBatchSize) {
+		tasks := taskARNs(batch)
+		output, err := getter.GetTaskProtection(ctx, &ecs.GetTaskProtectionInput{
+			Cluster: aws.String(batch[0].Cluster),
+			Tasks:   tasks,
+		})
+		if err == nil {
+			err = ecstp.NewGetResult(output).Verify(tasks...)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to get protection of %d tasks in %s: %w", len(tasks), batch[0].Cluster, err))
+			continue
+		}
+
+		gets := ecstp.NewGetResult(output)
+		now := time.Now().UTC()
+		f.mu.Lock()
+		for _, key := range batch {
+			t, ok := f.tasks[key]
+			result, found := gets.Task(key.TaskARN)
+			if !ok || !found {
+				continue
+			}
+			if result.Failed {
+				t.err = ecstp.NewErrorDetail(ecstp.OperationGetTaskProtection, key.TaskARN, gets.Failure(key.TaskARN))
+				continue
+			}
+			if !result.ProtectedAt(now) && t.protectedAt(now) {
+				// protection was lost, e.g. disabled by another process
+				t.renewAt = time.Time{}
+			}
+			t.protected = result.ProtectionEnabled
+			t.expiresAt = result.ExpiresAt
+			t.updatedAt = aws.Time(now)
+		}
+		f.mu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// change is a change of protection, shared by the tasks of an update.
+type change struct {
+	cluster          string
+	protect          bool
+	expiresInMinutes int32
+}
+
+// update is a change of protection for a batch of tasks of the same cluster.
+type update struct {
+	change
+	keys []Key
+}
+
+// plan returns the updates bringing the fleet to its desired protection at now.
+func (f *Fleet) plan(now time.Time) []update {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	groups := map[change][]Key{}
+	for key, t := range f.tasks {
+		want, protected := t.desired.protectAt(now), t.protectedAt(now)
+		var c change
+		switch {
+		case want && (!protected || !now.Before(t.renewAt)):
+			c = change{cluster: key.Cluster, protect: true, expiresInMinutes: t.desired.expiresInMinutes(now)}
+		case !want && protected:
+			c = change{cluster: key.Cluster}
+		default:
+			continue
+		}
+		groups[c] = append(groups[c], key)
+	}
+
+	var updates []update
+	for c, keys := range groups {
+		slices.SortFunc(keys, compareKeys)
+		for _, batch := range batches(keys, MaxUpdateBatchSize) {
+			updates = append(updates, update{change: c, keys: batch})
+		}
+	}
+	// keys of different changes never overlap
+	slices.SortFunc(updates, func(a, b update) int { return compareKeys(a.keys[0], b.keys[0]) })
+
+	return updates
+}
+
+// apply sends a single UpdateTaskProtection call for u and records the per-task results.
+func (f *Fleet) apply(ctx context.Context, u update) error {
+	tasks := taskARNs(u.keys)
+	var expiresInMinutes *int32
+	if u.protect {
+		expiresInMinutes = aws.Int32(u.expiresInMinutes)
+	}
+
+	output, err := f.ecs.UpdateTaskProtection(ctx, &ecs.UpdateTaskProtectionInput{
+		Cluster:           aws.String(u.cluster),
+		Tasks:             tasks,
+		ProtectionEnabled: u.protect,
+		ExpiresInMinutes:  expiresInMinutes,
+	})
+	if err == nil {
+		err = ecstp.NewUpdateResult(output).Verify(tasks...)
+	}
+
+	updates := ecstp.NewUpdateResult(output)
+	now := time.Now().UTC()
+	f.mu.Lock()
+	var records []ecstp.AuditRecord
+	for _, key := range u.keys {
+		t, ok := f.tasks[key]
+		if !ok {
+			continue
+		}
+		record := ecstp.AuditRecord{
+			Time:             now,
+			Cluster:          key.Cluster,
+			TaskARN:          key.TaskARN,
+			Protect:          u.protect,
+			ExpiresInMinutes: expiresInMinutes,
+			Reason:           t.desired.Reason,
+		}
+
+		result, found := updates.Task(key.TaskARN)
+		switch {
+		case err != nil:
+			t.err = ecstp.NewErrorDetail(ecstp.OperationUpdateTaskProtection, key.TaskARN, err)
+		case !found || result.Failed:
+			t.err = ecstp.NewErrorDetail(ecstp.OperationUpdateTaskProtection, key.TaskARN, updates.Failure(key.TaskARN))
+			record.Failure = result.FailureReason
+		default:
+			t.err = nil
+			t.protected = result.ProtectionEnabled
+			t.expiresAt = result.ExpiresAt
+			t.updatedAt = aws.Time(now)
+			t.renewAt = now.Add(time.Duration(u.expiresInMinutes) * time.Minute / 2)
+			record.ExpiresAt = result.ExpiresAt
+		}
+		record.Error = t.err
+		records = append(records, record)
+	}
+	f.mu.Unlock()
+
+	if f.Auditor != nil {
+		for _, record := range records {
+			f.Auditor.Audit(ctx, record)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to update protection of %d tasks in %s: %w", len(tasks), u.cluster, err)
+	}
+
+	return nil
+}
+
+// keys returns the keys of the fleet grouped by cluster.
+func (f *Fleet) keys() []Key {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]Key, 0, len(f.tasks))
+	for key := range f.tasks {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, compareKeys)
+
+	return keys
+}
+
+func (f *Fleet) interval() time.Duration {
+	if f.Interval <= 0 {
+		return DefaultInterval
+	}
+
+	return f.Interval
+}
+
+func (f *Fleet) logger() *slog.Logger {
+	if f.Logger == nil {
+		return slog.Default()
+	}
+
+	return f.Logger
+}
+
+// batches splits keys, sorted by cluster, into batches of at most size tasks of the same cluster.
+func batches(keys []Key, size int) [][]Key {
+	var batches [][]Key
+	for len(keys) > 0 {
+		n := 1
+		for n < len(keys) && n < size && keys[n].Cluster == keys[0].Cluster {
+			n++
+		}
+		batches = append(batches, keys[:n])
+		keys = keys[n:]
+	}
+
+	return batches
+}
+
+func taskARNs(keys []Key) []string {
+	tasks := make([]string, 0, len(keys))
+	for _, key := range keys {
+		tasks = append(tasks, key.TaskARN)
+	}
+
+	return tasks
+}
+
+func compareKeys(a, b Key) int {
+	if c := cmp.Compare(a.Cluster, b.Cluster); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.TaskARN, b.TaskARN)
+}
@@ -0,0 +1,251 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// testECSClient keeps the protection of tasks, failing any task listed in failTasks, and records
+// the calls made. Tasks are reported with arnPrefix prepended, e.g. to respond with the ARNs of
+// tasks identified by ID.
+type testECSClient struct {
+	failTasks map[string]bool
+	getErr    error
+	arnPrefix string
+
+	mu        sync.Mutex
+	protected map[string]time.Time
+	updates   []*ecs.UpdateTaskProtectionInput
+	gets      int
+}
+
+func (c *testECSClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updates = append(c.updates, params)
+	if c.protected == nil {
+		c.protected = map[string]time.Time{}
+	}
+
+	output := &ecs.UpdateTaskProtectionOutput{}
+	for _, task := range params.Tasks {
+		if c.failTasks[task] {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(c.arnPrefix + task), Reason: aws.String("MISSING")})
+			continue
+		}
+		protected := types.ProtectedTask{TaskArn: aws.String(c.arnPrefix + task), ProtectionEnabled: params.ProtectionEnabled}
+		if params.ProtectionEnabled {
+			expiresAt := time.Now().Add(time.Duration(aws.ToInt32(params.ExpiresInMinutes)) * time.Minute)
+			c.protected[task] = expiresAt
+			protected.ExpirationDate = aws.Time(expiresAt)
+		} else {
+			delete(c.protected, task)
+		}
+		output.ProtectedTasks = append(output.ProtectedTasks, protected)
+	}
+
+	return output, nil
+}
+
+func (c *testECSClient) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	if c.getErr != nil {
+		return nil, c.getErr
+	}
+
+	output := &ecs.GetTaskProtectionOutput{}
+	for _, task := range params.Tasks {
+		protected := types.ProtectedTask{TaskArn: aws.String(c.arnPrefix + task)}
+		if expiresAt, ok := c.protected[task]; ok {
+			protected.ProtectionEnabled = true
+			protected.ExpirationDate = aws.Time(expiresAt)
+		}
+		output.ProtectedTasks = append(output.ProtectedTasks, protected)
+	}
+
+	return output, nil
+}
+
+// Unprotect disables protection of task behind the Fleet's back.
+func (c *testECSClient) Unprotect(task string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.protected, task)
+}
+
+// Updates returns the number of tasks of each UpdateTaskProtection call.
+func (c *testECSClient) Updates() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sizes := make([]int, 0, len(c.updates))
+	for _, update := range c.updates {
+		sizes = append(sizes, len(update.Tasks))
+	}
+	c.updates = nil
+
+	return sizes
+}
+
+func testKeys(cluster string, n int) []Key {
+	keys := make([]Key, 0, n)
+	for i := 0; i < n; i++ {
+		keys = append(keys, Key{Cluster: cluster, TaskARN: fmt.Sprintf("%s/task-%02d", cluster, i)})
+	}
+
+	return keys
+}
+
+func TestFleet_Reconcile(t *testing.T) {
+	ecsClient := &testECSClient{}
+	f := New(ecsClient)
+	keys := append(testKeys("a", 12), testKeys("b", 3)...)
+	for _, key := range keys {
+		f.Set(key, Desired{Protect: true, ExpiresInMinutes: 30})
+	}
+
+	require.NoError(t, f.Reconcile(context.Background()))
+	assert.Equal(t, []int{10, 2, 3}, ecsClient.Updates(), "tasks should be batched per cluster")
+	statuses := f.Statuses()
+	require.Len(t, statuses, 15)
+	for _, status := range statuses {
+		assert.True(t, status.Protected)
+		assert.True(t, status.InSync)
+		assert.NotNil(t, status.ExpiresAt)
+	}
+
+	require.NoError(t, f.Reconcile(context.Background()))
+	assert.Empty(t, ecsClient.Updates(), "compliant tasks should not be updated before renewal is due")
+
+	ecsClient.Unprotect(keys[3].TaskARN)
+	f.Set(keys[14], Desired{Protect: false})
+	require.NoError(t, f.Reconcile(context.Background()))
+	assert.Equal(t, []int{1, 1}, ecsClient.Updates(), "drifted and unwanted protection should be reconciled")
+	status, ok := f.Status(keys[3])
+	require.True(t, ok)
+	assert.True(t, status.Protected)
+	status, _ = f.Status(keys[14])
+	assert.False(t, status.Protected)
+	assert.True(t, status.InSync)
+}
+
+func TestFleet_Reconcile_Until(t *testing.T) {
+	tests := []struct {
+		name        string
+		until       time.Time
+		wantProtect bool
+		wantMinutes int32
+	}{
+		{
+			name:        "should shorten protection to end at until",
+			until:       time.Now().Add(10 * time.Minute),
+			wantProtect: true,
+			wantMinutes: 10,
+		},
+		{
+			name:  "should not protect past until",
+			until: time.Now().Add(-time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &testECSClient{}
+			f := New(ecsClient)
+			key := Key{Cluster: "a", TaskARN: "a/task"}
+			f.Set(key, Desired{Protect: true, Until: aws.Time(tt.until)})
+
+			require.NoError(t, f.Reconcile(context.Background()))
+
+			status, _ := f.Status(key)
+			assert.Equal(t, tt.wantProtect, status.Protected)
+			assert.True(t, status.InSync)
+			if tt.wantProtect {
+				require.Len(t, ecsClient.updates, 1)
+				assert.Equal(t, tt.wantMinutes, aws.ToInt32(ecsClient.updates[0].ExpiresInMinutes))
+			} else {
+				assert.Empty(t, ecsClient.updates)
+			}
+		})
+	}
+}
+
+func TestFleet_Reconcile_Failures(t *testing.T) {
+	ecsClient := &testECSClient{failTasks: map[string]bool{"a/task-01": true}, getErr: errors.New("throttled")}
+	var audited []ecstp.AuditRecord
+	f := New(ecsClient)
+	f.Auditor = ecstp.AuditorFunc(func(ctx context.Context, record ecstp.AuditRecord) {
+		audited = append(audited, record)
+	})
+	keys := testKeys("a", 2)
+	for _, key := range keys {
+		f.Set(key, Desired{Protect: true, Reason: "migration"})
+	}
+
+	err := f.Reconcile(context.Background())
+	assert.ErrorContains(t, err, "throttled", "failed calls should be returned")
+
+	ok, _ := f.Status(keys[0])
+	assert.True(t, ok.InSync)
+	failed, _ := f.Status(keys[1])
+	assert.False(t, failed.InSync)
+	if assert.NotNil(t, failed.Error) {
+		assert.Equal(t, "MISSING", failed.Error.Code)
+		assert.ErrorIs(t, failed.Error, ecstp.ErrTaskNotFound, "task failures should keep their class")
+	}
+	require.Len(t, audited, 2)
+	assert.Equal(t, "migration", audited[0].Reason)
+	assert.Equal(t, "MISSING", audited[1].Failure)
+}
+
+func TestFleet_Reconcile_TaskID(t *testing.T) {
+	ecsClient := &testECSClient{arnPrefix: "arn:aws:ecs:eu-west-2:123456789012:task/a/"}
+	f := New(ecsClient)
+	key := Key{Cluster: "a", TaskARN: "0123456789abcdef"}
+	f.Set(key, Desired{Protect: true})
+
+	require.NoError(t, f.Reconcile(context.Background()))
+
+	status, _ := f.Status(key)
+	assert.True(t, status.InSync, "the result of a task identified by ID should be matched by its ARN")
+	assert.Nil(t, status.Error)
+	assert.NotNil(t, status.ExpiresAt)
+}
+
+func TestFleet_Run(t *testing.T) {
+	ecsClient := &testECSClient{}
+	f := New(ecsClient)
+	f.Interval = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- f.Run(ctx) }()
+
+	key := Key{Cluster: "a", TaskARN: "a/task"}
+	f.Set(key, Desired{Protect: true})
+	assert.Eventually(t, func() bool {
+		status, _ := f.Status(key)
+		return status.Protected
+	}, time.Second, 5*time.Millisecond, "setting desired protection should wake Run")
+
+	assert.True(t, f.Remove(key))
+	assert.False(t, f.Remove(key))
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,143 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// DefaultTagKey is the default task tag declaring protection.
+const DefaultTagKey = "protect-until"
+
+// ECSTasksClient is the subset of the ECS client used by TagSource.
+type ECSTasksClient interface {
+	ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error)
+	DescribeTasks(
+		ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options),
+	) (*ecs.DescribeTasksOutput, error)
+}
+
+// TagSource reads the desired protection of the running tasks of Clusters from a task tag, e.g.
+// protect-until=2024-06-01T18:00:00Z. The tag's value is an RFC 3339 timestamp protecting the task
+// until then, or a boolean protecting it indefinitely or not at all. Tasks without the tag, or
+// with an invalid value, which is logged, aren't reported.
+type TagSource struct {
+	Client   ECSTasksClient
+	Clusters []string
+	// Key is the tag declaring protection. Defaults to DefaultTagKey.
+	Key string
+	// ExpiresInMinutes is set as the protection period of tagged tasks.
+	ExpiresInMinutes int32
+	Logger           *slog.Logger
+}
+
+// describeTasksBatchSize is the maximum number of tasks ECS describes in a single call.
+const describeTasksBatchSize = 100
+
+// Desired returns the desired protection of every tagged task.
+func (s *TagSource) Desired(ctx context.Context) (map[Key]Desired, error) {
+	desired := map[Key]Desired{}
+	for _, cluster := range s.Clusters {
+		tasks, err := s.listTasks(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		for len(tasks) > 0 {
+			n := min(len(tasks), describeTasksBatchSize)
+			output, err := s.Client.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+				Cluster: aws.String(cluster),
+				Tasks:   tasks[:n],
+				Include: []types.TaskField{types.TaskFieldTags},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to describe tasks in %s: %w", cluster, err)
+			}
+			tasks = tasks[n:]
+
+			for _, task := range output.Tasks {
+				d, ok := s.desired(ctx, task)
+				if ok {
+					desired[Key{Cluster: cluster, TaskARN: aws.ToString(task.TaskArn)}] = d
+				}
+			}
+		}
+	}
+
+	return desired, nil
+}
+
+// listTasks returns the ARNs of the running tasks of cluster.
+func (s *TagSource) listTasks(ctx context.Context, cluster string) ([]string, error) {
+	var tasks []string
+	input := &ecs.ListTasksInput{Cluster: aws.String(cluster), DesiredStatus: types.DesiredStatusRunning}
+	for {
+		output, err := s.Client.ListTasks(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list tasks in %s: %w", cluster, err)
+		}
+		tasks = append(tasks, output.TaskArns...)
+		if output.NextToken == nil {
+			return tasks, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// desired returns the protection declared by the tags of task, or false if it declares none.
+func (s *TagSource) desired(ctx context.Context, task types.Task) (Desired, bool) {
+	key := s.Key
+	if key == "" {
+		key = DefaultTagKey
+	}
+
+	for _, tag := range task.Tags {
+		if aws.ToString(tag.Key) != key {
+			continue
+		}
+		d, err := ParseDesired(aws.ToString(tag.Value))
+		if err != nil {
+			s.logger().WarnContext(ctx, "ignoring invalid protection tag",
+				slog.String("task_arn", aws.ToString(task.TaskArn)),
+				slog.Any("error", err),
+			)
+			return Desired{}, false
+		}
+		d.ExpiresInMinutes = s.ExpiresInMinutes
+		d.Reason = "tag " + key
+
+		return d, true
+	}
+
+	return Desired{}, false
+}
+
+func (s *TagSource) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+
+	return s.Logger
+}
+
+// ParseDesired parses a declared protection: an RFC 3339 timestamp protecting a task until then,
+// or a boolean protecting it indefinitely or not at all.
+func ParseDesired(value string) (Desired, error) {
+	value = strings.TrimSpace(value)
+	if until, err := time.Parse(time.RFC3339, value); err == nil {
+		return Desired{Protect: true, Until: &until}, nil
+	}
+	protect, err := strconv.ParseBool(value)
+	if err != nil {
+		return Desired{}, fmt.Errorf("invalid protection %q: expected an RFC 3339 timestamp or a boolean", value)
+	}
+
+	return Desired{Protect: protect}, nil
+}
@@ -0,0 +1,119 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTasksClient lists tasks in pages of one, with the tags of tags.
+type testTasksClient struct {
+	err  error
+	tags map[string]map[string]string
+}
+
+func (c *testTasksClient) ListTasks(
+	ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options),
+) (*ecs.ListTasksOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	var tasks []string
+	for task := range c.tags {
+		tasks = append(tasks, task)
+	}
+	slices.Sort(tasks)
+	i := 0
+	if params.NextToken != nil {
+		i = len(aws.ToString(params.NextToken))
+	}
+	output := &ecs.ListTasksOutput{TaskArns: tasks[i : i+1]}
+	if i+1 < len(tasks) {
+		output.NextToken = aws.String(string(make([]byte, i+1)))
+	}
+
+	return output, nil
+}
+
+func (c *testTasksClient) DescribeTasks(
+	ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options),
+) (*ecs.DescribeTasksOutput, error) {
+	output := &ecs.DescribeTasksOutput{}
+	for _, task := range params.Tasks {
+		described := types.Task{TaskArn: aws.String(task)}
+		for key, value := range c.tags[task] {
+			described.Tags = append(described.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+		output.Tasks = append(output.Tasks, described)
+	}
+
+	return output, nil
+}
+
+func TestTagSource_Desired(t *testing.T) {
+	until := time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC)
+	client := &testTasksClient{tags: map[string]map[string]string{
+		"task-1": {DefaultTagKey: "2024-06-01T18:00:00Z", "team": "data"},
+		"task-2": {DefaultTagKey: "true"},
+		"task-3": {DefaultTagKey: "false"},
+		"task-4": {DefaultTagKey: "tomorrow"},
+		"task-5": {"team": "data"},
+	}}
+	source := &TagSource{Client: client, Clusters: []string{"prod"}, ExpiresInMinutes: 30}
+
+	desired, err := source.Desired(context.Background())
+
+	require.NoError(t, err)
+	reason := "tag " + DefaultTagKey
+	assert.Equal(t, map[Key]Desired{
+		{Cluster: "prod", TaskARN: "task-1"}: {Protect: true, Until: aws.Time(until), ExpiresInMinutes: 30, Reason: reason},
+		{Cluster: "prod", TaskARN: "task-2"}: {Protect: true, ExpiresInMinutes: 30, Reason: reason},
+		{Cluster: "prod", TaskARN: "task-3"}: {Protect: false, ExpiresInMinutes: 30, Reason: reason},
+	}, desired)
+}
+
+func TestTagSource_Desired_Error(t *testing.T) {
+	source := &TagSource{Client: &testTasksClient{err: errors.New("throttled")}, Clusters: []string{"prod"}}
+
+	_, err := source.Desired(context.Background())
+
+	assert.ErrorContains(t, err, "throttled")
+}
+
+func TestParseDesired(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Desired
+		wantErr bool
+	}{
+		{
+			name:  "should protect until a timestamp",
+			value: "2024-06-01T18:00:00Z",
+			want:  Desired{Protect: true, Until: aws.Time(time.Date(2024, 6, 1, 18, 0, 0, 0, time.UTC))},
+		},
+		{name: "should protect indefinitely", value: " true ", want: Desired{Protect: true}},
+		{name: "should not protect", value: "false", want: Desired{}},
+		{name: "should reject other values", value: "tomorrow", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDesired(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// ErrGetTaskProtectionUnsupported is returned when protection has to be read from ECS but the ECS
+// client doesn't implement TaskProtectionGetter.
+var ErrGetTaskProtectionUnsupported = errors.New("ECS client does not support GetTaskProtection")
+
+// GetTaskProtectionInput defines the parameters of GetTaskProtection.
+//
+// If Metadata is nil, GetTaskProtection will attempt to get the metadata via GetTaskArn.
+// Credentials, if set, is used to sign this call instead of the credentials configured on the ECS
+// client or via WithCredentials.
+type GetTaskProtectionInput struct {
+	Metadata    *MetadataBody
+	Credentials aws.CredentialsProvider
+}
+
+// GetTaskProtectionOutput is the protection of a task as reported by GetTaskProtection.
+type GetTaskProtectionOutput struct {
+	Cluster   string
+	TaskARN   string
+	Protected bool
+	// ExpiresAt is when protection expires, if Protected.
+	ExpiresAt *time.Time
+	// Output is the output of the ECS API.
+	Output *ecs.GetTaskProtectionOutput
+}
+
+// Remaining returns how long the task stays protected from now, or 0 if it isn't protected.
+func (o *GetTaskProtectionOutput) Remaining() time.Duration {
+	if !o.Protected || o.ExpiresAt == nil {
+		return 0
+	}
+
+	return max(time.Until(*o.ExpiresAt), 0)
+}
+
+// GetTaskProtection returns the current protection of the task, e.g. to decide whether it needs to
+// be renewed. It calls GetTaskArn to retrieve the Cluster and Task ARN if not provided via Metadata
+// in input, and then calls the GetTaskProtection ECS API.
+//
+// The ECS client must implement TaskProtectionGetter, which the ECS client of the AWS SDK does;
+// otherwise an error wrapping ErrGetTaskProtectionUnsupported is returned. Metadata without a
+// cluster or task ARN is rejected with a *ValidationError before any ECS call. If ECS reports a
+// failure for the task or doesn't report it, the error is a *ProtectionFailureError describing it,
+// like for UpdateTaskProtection with WithFailOnFailures. If the Client was created with
+// WithAgentEndpoint, the protection is read via the ECS agent endpoint unless it's unavailable.
+func (c *Client) GetTaskProtection(ctx context.Context, input *GetTaskProtectionInput) (*GetTaskProtectionOutput, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	getter, ok := c.protectionClient(input.Credentials).(TaskProtectionGetter)
+	if !ok {
+		return nil, fmt.Errorf("unable to get task protection: %w", ErrGetTaskProtectionUnsupported)
+	}
+
+	var metadata *MetadataBody
+	if input.Metadata == nil {
+		var err error
+		metadata, err = c.GetTaskArn(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		metadata = normalizeMetadata(input.Metadata)
+	}
+	if err := validateMetadata(metadata); err != nil {
+		return nil, err
+	}
+
+	output, err := retryCall(ctx, c.retry, func(ctx context.Context) (*ecs.GetTaskProtectionOutput, error) {
+		output, err := getter.GetTaskProtection(ctx, &ecs.GetTaskProtectionInput{
+			Cluster: aws.String(metadata.Cluster),
+			Tasks:   []string{metadata.TaskARN},
+		}, c.retriedECSOptions(c.retry, input.Credentials)...)
+		return output, classifyError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := NewGetResult(output)
+	if err := result.Verify(metadata.TaskARN); err != nil {
+		return nil, err
+	}
+
+	if err := result.Failure(metadata.TaskARN); err != nil {
+		return nil, err
+	}
+	task, _ := result.Task(metadata.TaskARN)
+
+	return &GetTaskProtectionOutput{
+		Cluster:   metadata.Cluster,
+		TaskARN:   metadata.TaskARN,
+		Protected: task.ProtectionEnabled,
+		ExpiresAt: task.ExpiresAt,
+		Output:    output,
+	}, nil
+}
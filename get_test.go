@@ -0,0 +1,82 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetTaskProtection(t *testing.T) {
+	protected, unprotected, missing := remoteTestARNPrefix+"a", remoteTestARNPrefix+"b", remoteTestARNPrefix+"missing"
+	trackingClient := &ExpiryTrackingTestClient{
+		missing:   map[string]bool{missing: true},
+		expiresAt: map[string]time.Time{protected: time.Now().Add(10 * time.Minute)},
+	}
+
+	tests := []struct {
+		name          string
+		ecsClient     ECSClient
+		task          string
+		wantProtected bool
+		wantRemaining time.Duration
+		wantErr       error
+		wantFailure   *ProtectionFailureError
+	}{
+		{
+			name:          "should return the expiry of a protected task",
+			ecsClient:     trackingClient,
+			task:          protected,
+			wantProtected: true,
+			wantRemaining: 10 * time.Minute,
+		},
+		{
+			name:      "should report an unprotected task",
+			ecsClient: trackingClient,
+			task:      unprotected,
+		},
+		{
+			name:        "should return task failures as a ProtectionFailureError",
+			ecsClient:   trackingClient,
+			task:        missing,
+			wantFailure: &ProtectionFailureError{TaskARN: missing, Reason: "MISSING"},
+		},
+		{
+			name:      "should reject metadata without a task ARN",
+			ecsClient: trackingClient,
+			wantErr:   ErrInvalidInput,
+		},
+		{
+			name:      "should fail if the ECS client doesn't support GetTaskProtection",
+			ecsClient: &SuccessfulTestClient{},
+			task:      protected,
+			wantErr:   ErrGetTaskProtectionUnsupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(tt.ecsClient)
+
+			got, err := c.GetTaskProtection(context.Background(), &GetTaskProtectionInput{
+				Metadata: &MetadataBody{TaskARN: tt.task},
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if tt.wantFailure != nil {
+				var failureErr *ProtectionFailureError
+				require.ErrorAs(t, err, &failureErr)
+				assert.Equal(t, tt.wantFailure, failureErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.task, got.TaskARN)
+			assert.Equal(t, "arn:aws:ecs:eu-west-2:123456789012:cluster/test_cluster", got.Cluster)
+			assert.Equal(t, tt.wantProtected, got.Protected)
+			assert.InDelta(t, tt.wantRemaining, got.Remaining(), float64(time.Second))
+		})
+	}
+}
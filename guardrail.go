@@ -0,0 +1,107 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// ErrProtectionNotAllowed is returned when a protection guardrail rejects enabling protection.
+var ErrProtectionNotAllowed = errors.New("protection not allowed")
+
+// TagLister is implemented by ECS clients that support the ListTagsForResource API.
+type TagLister interface {
+	ListTagsForResource(
+		ctx context.Context, params *ecs.ListTagsForResourceInput, optFns ...func(*ecs.Options),
+	) (*ecs.ListTagsForResourceOutput, error)
+}
+
+// TaskDescriber is implemented by ECS clients that support the DescribeTasks API.
+type TaskDescriber interface {
+	DescribeTasks(
+		ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options),
+	) (*ecs.DescribeTasksOutput, error)
+}
+
+// requiredTag is a tag that must be present on a task or its service before protection is enabled.
+type requiredTag struct {
+	key   string
+	value string
+}
+
+// checkRequiredTag returns an error wrapping ErrProtectionNotAllowed if neither the task nor the
+// service that started it carry the required tag.
+//
+// The service is only looked up if the task isn't tagged and the ECS client implements
+// TaskDescriber.
+func (c *Client) checkRequiredTag(ctx context.Context, metadata *MetadataBody, credentials aws.CredentialsProvider) error {
+	lister, ok := c.ECSClient.(TagLister)
+	if !ok {
+		return fmt.Errorf("%w: ECS client does not implement ListTagsForResource", ErrProtectionNotAllowed)
+	}
+	optFns := c.ecsOptions(credentials)
+
+	found, err := c.hasRequiredTag(ctx, lister, metadata.TaskARN, optFns)
+	if err != nil || found {
+		return err
+	}
+
+	if describer, ok := c.ECSClient.(TaskDescriber); ok {
+		out, err := describer.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(metadata.Cluster),
+			Tasks:   []string{metadata.TaskARN},
+		}, optFns...)
+		if err != nil {
+			return err
+		}
+
+		for _, task := range out.Tasks {
+			name, ok := strings.CutPrefix(aws.ToString(task.Group), "service:")
+			if !ok {
+				continue
+			}
+			found, err := c.hasRequiredTag(ctx, lister, serviceARN(metadata.TaskARN, name), optFns)
+			if err != nil || found {
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: task %s (or its service) is missing tag %s=%s",
+		ErrProtectionNotAllowed, metadata.TaskARN, c.requiredTag.key, c.requiredTag.value)
+}
+
+func (c *Client) hasRequiredTag(ctx context.Context, lister TagLister, resourceARN string, optFns []func(*ecs.Options)) (bool, error) {
+	out, err := lister.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{
+		ResourceArn: aws.String(resourceARN),
+	}, optFns...)
+	if err != nil {
+		return false, err
+	}
+
+	for _, tag := range out.Tags {
+		if aws.ToString(tag.Key) == c.requiredTag.key && aws.ToString(tag.Value) == c.requiredTag.value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// serviceARN returns the ARN of the service with the given name in the same cluster as taskARN.
+func serviceARN(taskARN, name string) string {
+	prefix, rest, ok := strings.Cut(taskARN, ":task/")
+	if !ok {
+		return name
+	}
+
+	if cluster, _, ok := strings.Cut(rest, "/"); ok {
+		return prefix + ":service/" + cluster + "/" + name
+	}
+
+	return prefix + ":service/" + name
+}
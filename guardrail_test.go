@@ -0,0 +1,118 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type TaggedTestClient struct {
+	SuccessfulTestClient
+	tags  map[string][]types.Tag
+	group string
+}
+
+func (c *TaggedTestClient) ListTagsForResource(
+	ctx context.Context, params *ecs.ListTagsForResourceInput, optFns ...func(*ecs.Options),
+) (*ecs.ListTagsForResourceOutput, error) {
+	return &ecs.ListTagsForResourceOutput{Tags: c.tags[aws.ToString(params.ResourceArn)]}, nil
+}
+
+func (c *TaggedTestClient) DescribeTasks(
+	ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options),
+) (*ecs.DescribeTasksOutput, error) {
+	return &ecs.DescribeTasksOutput{
+		Tasks: []types.Task{{TaskArn: aws.String(params.Tasks[0]), Group: aws.String(c.group)}},
+	}, nil
+}
+
+func TestClient_UpdateTaskProtection_RequiredTag(t *testing.T) {
+	const (
+		taskARN    = "arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/abc"
+		serviceARN = "arn:aws:ecs:eu-west-2:123456789012:service/test_cluster/web"
+	)
+	allowed := []types.Tag{{Key: aws.String("task-protection"), Value: aws.String("allowed")}}
+
+	tests := []struct {
+		name      string
+		ecsClient ECSClient
+		protect   bool
+		wantErr   bool
+	}{
+		{
+			name:      "should protect a tagged task",
+			ecsClient: &TaggedTestClient{tags: map[string][]types.Tag{taskARN: allowed}},
+			protect:   true,
+		},
+		{
+			name: "should protect a task whose service is tagged",
+			ecsClient: &TaggedTestClient{
+				tags:  map[string][]types.Tag{serviceARN: allowed},
+				group: "service:web",
+			},
+			protect: true,
+		},
+		{
+			name: "should reject a task without the tag",
+			ecsClient: &TaggedTestClient{
+				tags:  map[string][]types.Tag{taskARN: {{Key: aws.String("task-protection"), Value: aws.String("denied")}}},
+				group: "family:web",
+			},
+			protect: true,
+			wantErr: true,
+		},
+		{
+			name:      "should always allow disabling protection",
+			ecsClient: &TaggedTestClient{},
+			protect:   false,
+		},
+		{
+			name:      "should reject when tags can't be listed",
+			ecsClient: &SuccessfulTestClient{},
+			protect:   true,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(tt.ecsClient, WithRequiredTag("task-protection", "allowed"))
+			_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+				Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: taskARN},
+				Protect:  tt.protect,
+			})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrProtectionNotAllowed)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_serviceARN(t *testing.T) {
+	tests := []struct {
+		name    string
+		taskARN string
+		want    string
+	}{
+		{
+			name:    "should use the cluster from a long format task ARN",
+			taskARN: "arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/abc",
+			want:    "arn:aws:ecs:eu-west-2:123456789012:service/test_cluster/web",
+		},
+		{
+			name:    "should support short format task ARNs",
+			taskARN: "arn:aws:ecs:eu-west-2:123456789012:task/abc",
+			want:    "arn:aws:ecs:eu-west-2:123456789012:service/web",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serviceARN(tt.taskARN, "web"))
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package ecstp
+
+import (
+	"sync"
+	"time"
+)
+
+// Health is the level of protection of a Manager while protection is wanted.
+type Health string
+
+// Health levels reported with HealthEvents.
+const (
+	// HealthHealthy is reported once protection is enabled or renewed.
+	HealthHealthy Health = "healthy"
+	// HealthDegraded is reported when protection couldn't be renewed, or released, but is still in
+	// effect for the remaining time.
+	HealthDegraded Health = "degraded"
+	// HealthFailed is reported when protection couldn't be enabled, or expired while degraded.
+	HealthFailed Health = "failed"
+)
+
+// Action is the response recommended to an application for a Health level.
+type Action string
+
+// Actions recommended with HealthEvents.
+const (
+	// ActionContinue recommends carrying on with work.
+	ActionContinue Action = "continue"
+	// ActionCheckpoint recommends saving progress, so that work can resume elsewhere if the task
+	// is scaled in once protection expires.
+	ActionCheckpoint Action = "checkpoint"
+	// ActionAbort recommends stopping work, or not starting it, as the task may be scaled in at
+	// any time.
+	ActionAbort Action = "abort"
+)
+
+// HealthReport describes the Health of a Manager.
+type HealthReport struct {
+	Level Health `json:"level"`
+	// Remaining is the protection time left by the local clock, 0 if protection has failed or
+	// ECS didn't report an expiry.
+	Remaining time.Duration `json:"remaining"`
+	Action    Action        `json:"action"`
+}
+
+// HealthEvent is delivered to the subscribers of SubscribeHealth when the Health of a Manager
+// changes.
+type HealthEvent struct {
+	HealthReport
+	// Previous is the level before the change, empty if protection wasn't wanted.
+	Previous Health    `json:"previous,omitempty"`
+	Time     time.Time `json:"time"`
+	State    State     `json:"state"`
+}
+
+// healthActions maps each Health level to the recommended Action.
+var healthActions = map[Health]Action{
+	HealthHealthy:  ActionContinue,
+	HealthDegraded: ActionCheckpoint,
+	HealthFailed:   ActionAbort,
+}
+
+// Health returns the current Health of m, or false if protection isn't wanted, e.g. before it's
+// enabled or once it's been released.
+func (m *Manager) Health() (HealthReport, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.health == "" {
+		return HealthReport{}, false
+	}
+
+	return m.healthReportLocked(m.health), true
+}
+
+// SubscribeHealth returns a channel receiving a HealthEvent whenever the Health of m changes, and a
+// function that ends the subscription. Events are dropped for subscribers that don't keep up.
+func (m *Manager) SubscribeHealth() (<-chan HealthEvent, func()) {
+	ch := make(chan HealthEvent, eventBufferSize)
+
+	m.subMu.Lock()
+	m.healthSubs[ch] = struct{}{}
+	m.subMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.subMu.Lock()
+			delete(m.healthSubs, ch)
+			m.subMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// updateHealthLocked derives the Health level following t, delivering a HealthEvent if it
+// changed. Protection lapsing as requested, or being released, resets it without an event.
+func (m *Manager) updateHealthLocked(t Transition) {
+	var level Health
+	switch {
+	case t.To == PhaseProtected:
+		level = HealthHealthy
+	case t.To == PhaseDegraded:
+		level = HealthDegraded
+	case t.From == PhaseProtecting && t.Trigger == TriggerFailed,
+		t.From == PhaseDegraded && t.Trigger == TriggerExpired:
+		level = HealthFailed
+	case t.To == PhaseUnprotected:
+		m.health = ""
+		return
+	default:
+		return
+	}
+
+	m.scheduleExpiryLocked(level)
+	if level == m.health {
+		return
+	}
+	event := HealthEvent{
+		HealthReport: m.healthReportLocked(level),
+		Previous:     m.health,
+		Time:         time.Now().UTC(),
+		State:        m.state,
+	}
+	m.health = level
+
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for ch := range m.healthSubs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// scheduleExpiryLocked arms a timer recording the expiry of degraded protection as it happens, so
+// that HealthFailed is reported without waiting for the next update.
+func (m *Manager) scheduleExpiryLocked(level Health) {
+	if m.expiryTimer != nil {
+		m.expiryTimer.Stop()
+		m.expiryTimer = nil
+	}
+	if level != HealthDegraded || m.state.ExpiresAt == nil {
+		return
+	}
+
+	m.expiryTimer = time.AfterFunc(time.Until(*m.state.ExpiresAt), func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		m.expireLocked()
+	})
+}
+
+func (m *Manager) healthReportLocked(level Health) HealthReport {
+	report := HealthReport{Level: level, Action: healthActions[level]}
+	if level != HealthFailed && m.state.ExpiresAt != nil {
+		report.Remaining = max(time.Until(*m.state.ExpiresAt), 0)
+	}
+
+	return report
+}
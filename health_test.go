@@ -0,0 +1,107 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receiveHealth(t *testing.T, ch <-chan HealthEvent) HealthEvent {
+	t.Helper()
+
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for health event")
+		return HealthEvent{}
+	}
+}
+
+func TestManager_SubscribeHealth(t *testing.T) {
+	client := &ExpiringTestClient{expiry: 50 * time.Millisecond}
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	events, cancel := m.SubscribeHealth()
+	defer cancel()
+	ctx := context.Background()
+
+	_, ok := m.Health()
+	assert.False(t, ok, "health should not be reported before protection is wanted")
+
+	_, err := m.Protect(ctx, nil)
+	require.NoError(t, err)
+	event := receiveHealth(t, events)
+	assert.Equal(t, HealthHealthy, event.Level)
+	assert.Equal(t, Health(""), event.Previous)
+	assert.Equal(t, ActionContinue, event.Action)
+	assert.Positive(t, event.Remaining)
+
+	_, err = m.Protect(ctx, nil)
+	require.NoError(t, err)
+
+	client.fail.Store(true)
+	_, err = m.Protect(ctx, nil)
+	require.Error(t, err)
+	event = receiveHealth(t, events)
+	assert.Equal(t, HealthDegraded, event.Level)
+	assert.Equal(t, HealthHealthy, event.Previous)
+	assert.Equal(t, ActionCheckpoint, event.Action)
+	assert.Positive(t, event.Remaining, "degraded protection should still be in effect")
+
+	event = receiveHealth(t, events)
+	assert.Equal(t, HealthFailed, event.Level, "expiry while degraded should be reported without an update")
+	assert.Equal(t, HealthDegraded, event.Previous)
+	assert.Equal(t, ActionAbort, event.Action)
+	assert.Zero(t, event.Remaining)
+	assert.Equal(t, PhaseUnprotected, m.Phase())
+
+	report, ok := m.Health()
+	assert.True(t, ok)
+	assert.Equal(t, HealthFailed, report.Level)
+}
+
+func TestManager_Health(t *testing.T) {
+	tests := []struct {
+		name      string
+		fail      bool
+		unprotect bool
+		want      HealthReport
+		wantOk    bool
+	}{
+		{
+			name:   "should be healthy once protected",
+			want:   HealthReport{Level: HealthHealthy, Action: ActionContinue},
+			wantOk: true,
+		},
+		{
+			name:   "should fail if protection can't be enabled",
+			fail:   true,
+			want:   HealthReport{Level: HealthFailed, Action: ActionAbort},
+			wantOk: true,
+		},
+		{
+			name:      "should not report health once protection is released",
+			unprotect: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &ExpiringTestClient{}
+			client.fail.Store(tt.fail)
+			m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+			m.Protect(context.Background(), nil)
+			if tt.unprotect {
+				_, err := m.Unprotect(context.Background())
+				require.NoError(t, err)
+			}
+
+			got, ok := m.Health()
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
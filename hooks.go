@@ -0,0 +1,184 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EnvHooks is the environment variable read by Manager.EnableHooksFromEnv.
+const EnvHooks = "ECSTP_HOOKS"
+
+// ErrUnknownHook is returned when enabling a hook that wasn't registered.
+var ErrUnknownHook = errors.New("unknown hook")
+
+// Hook is an optional integration, e.g. a metrics sink, notifier or policy check, packaged
+// separately and enabled by name, typically from configuration, so the core package doesn't import
+// its dependencies. A package providing a hook registers it from its init function:
+//
+//	func init() {
+//		ecstp.Register("slack", ecstp.HookFunc(enableSlack))
+//	}
+//
+// Hooks typically observe a Manager with Subscribe, or veto protection with AddPolicy.
+type Hook interface {
+	// Enable enables the hook for m with config, returning a function disabling it.
+	Enable(ctx context.Context, m *Manager, config map[string]string) (func(), error)
+}
+
+// HookFunc is a Hook calling itself.
+type HookFunc func(ctx context.Context, m *Manager, config map[string]string) (func(), error)
+
+// Enable implements Hook.
+func (f HookFunc) Enable(ctx context.Context, m *Manager, config map[string]string) (func(), error) {
+	return f(ctx, m, config)
+}
+
+var (
+	hooksMu sync.RWMutex
+	hooks   = map[string]Hook{}
+)
+
+// Register makes hook available by name. It panics if hook is nil or name is already registered.
+func Register(name string, hook Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	if hook == nil {
+		panic("ecstp: Register hook is nil")
+	}
+	if _, ok := hooks[name]; ok {
+		panic("ecstp: Register called twice for hook " + name)
+	}
+	hooks[name] = hook
+}
+
+// Hooks returns the names of the registered hooks, sorted.
+func Hooks() []string {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// EnableHook enables the hook registered as name for m, returning a function disabling it.
+func (m *Manager) EnableHook(ctx context.Context, name string, config map[string]string) (func(), error) {
+	hooksMu.RLock()
+	hook, ok := hooks[name]
+	hooksMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHook, name)
+	}
+
+	disable, err := hook.Enable(ctx, m, config)
+	if err != nil {
+		return nil, fmt.Errorf("enabling hook %s: %w", name, err)
+	}
+	if disable == nil {
+		disable = func() {}
+	}
+
+	return disable, nil
+}
+
+// EnableHooks enables the hooks listed in spec for m, returning a function disabling them all. If
+// a hook fails to enable, the hooks enabled before it are disabled again.
+//
+// spec is a comma-separated list of hook names, each optionally followed by its configuration in
+// URL query syntax, e.g. "cloudwatch?namespace=MyApp,slack?channel=%23ops".
+func (m *Manager) EnableHooks(ctx context.Context, spec string) (func(), error) {
+	var disables []func()
+	disableAll := func() {
+		for i := len(disables) - 1; i >= 0; i-- {
+			disables[i]()
+		}
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, query, _ := strings.Cut(entry, "?")
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			disableAll()
+			return nil, fmt.Errorf("invalid configuration of hook %s: %w", name, err)
+		}
+		config := make(map[string]string, len(values))
+		for key := range values {
+			config[key] = values.Get(key)
+		}
+
+		disable, err := m.EnableHook(ctx, name, config)
+		if err != nil {
+			disableAll()
+			return nil, err
+		}
+		disables = append(disables, disable)
+	}
+
+	return disableAll, nil
+}
+
+// EnableHooksFromEnv enables the hooks listed in the ECSTP_HOOKS environment variable, see
+// EnableHooks.
+func (m *Manager) EnableHooksFromEnv(ctx context.Context) (func(), error) {
+	return m.EnableHooks(ctx, os.Getenv(EnvHooks))
+}
+
+// Policy is a check run before protection is enabled through a Manager. A non-nil error refuses
+// protection, see AddPolicy.
+type Policy func(ctx context.Context, state State) error
+
+type registeredPolicy struct {
+	id     uint64
+	policy Policy
+}
+
+// AddPolicy adds a policy checked before protection is enabled or renewed, returning a function
+// removing it. When a policy refuses protection, its error is returned wrapped with
+// ErrProtectionNotAllowed and EventProtectionRefused is published, without calling ECS.
+func (m *Manager) AddPolicy(policy Policy) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextRegistrationID++
+	id := m.nextRegistrationID
+	m.policies = append(m.policies, registeredPolicy{id: id, policy: policy})
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		for i, p := range m.policies {
+			if p.id == id {
+				m.policies = append(m.policies[:i:i], m.policies[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// checkPoliciesLocked returns the error of the first policy refusing protection, wrapping
+// ErrProtectionNotAllowed.
+func (m *Manager) checkPoliciesLocked(ctx context.Context) error {
+	for _, p := range m.policies {
+		if err := p.policy(ctx, m.state); err != nil {
+			return fmt.Errorf("%w: %w", ErrProtectionNotAllowed, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHook records the configuration it was enabled with and whether it's enabled.
+type recordingHook struct {
+	err     error
+	config  map[string]string
+	enabled bool
+}
+
+func (h *recordingHook) Enable(ctx context.Context, m *Manager, config map[string]string) (func(), error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	h.config, h.enabled = config, true
+
+	return func() { h.enabled = false }, nil
+}
+
+func TestManager_EnableHooks(t *testing.T) {
+	notifier := &recordingHook{}
+	metrics := &recordingHook{}
+	broken := &recordingHook{err: errors.New("missing credentials")}
+	Register("test-notifier", notifier)
+	Register("test-metrics", metrics)
+	Register("test-broken", broken)
+	assert.Subset(t, Hooks(), []string{"test-broken", "test-metrics", "test-notifier"})
+	assert.Panics(t, func() { Register("test-notifier", notifier) }, "registering a name twice should panic")
+
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+	tests := []struct {
+		name        string
+		spec        string
+		wantErr     error
+		wantEnabled bool
+	}{
+		{
+			name:        "should enable hooks with their configuration",
+			spec:        "test-notifier?channel=%23ops&mention=oncall, test-metrics",
+			wantEnabled: true,
+		},
+		{
+			name:    "should fail for unknown hooks",
+			spec:    "test-notifier,test-unknown",
+			wantErr: ErrUnknownHook,
+		},
+		{
+			name: "should disable enabled hooks if one fails",
+			spec: "test-notifier,test-broken",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disable, err := m.EnableHooks(context.Background(), tt.spec)
+			if !tt.wantEnabled {
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				} else {
+					assert.Error(t, err)
+				}
+				assert.False(t, notifier.enabled, "hooks enabled before the failure should be disabled")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, notifier.enabled)
+			assert.True(t, metrics.enabled)
+			assert.Equal(t, map[string]string{"channel": "#ops", "mention": "oncall"}, notifier.config)
+			assert.Empty(t, metrics.config)
+
+			disable()
+			assert.False(t, notifier.enabled)
+			assert.False(t, metrics.enabled)
+		})
+	}
+}
+
+func TestManager_AddPolicy(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	events, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+
+	errBudget := errors.New("protection budget exhausted")
+	remove := m.AddPolicy(func(ctx context.Context, state State) error { return errBudget })
+
+	state, err := m.Protect(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrProtectionNotAllowed)
+	assert.ErrorIs(t, err, errBudget)
+	assert.False(t, state.Protected)
+	assert.Equal(t, EventProtectionRefused, (<-events).Type)
+
+	_, err = m.Unprotect(context.Background())
+	assert.NoError(t, err, "policies shouldn't prevent disabling protection")
+	<-events
+
+	remove()
+	state, err = m.Protect(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.True(t, state.Protected)
+}
@@ -0,0 +1,112 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// InstanceProtectionSetter sets the scale-in protection of instances in an Auto Scaling group. It
+// is typically implemented by a thin wrapper around the SetInstanceProtection call of an Auto
+// Scaling client.
+type InstanceProtectionSetter interface {
+	SetInstanceProtection(ctx context.Context, autoScalingGroupName string, instanceIDs []string, protected bool) error
+}
+
+// InstanceProtectionError is returned when the task's protection was updated but the scale-in
+// protection of its container instance couldn't be updated to match.
+type InstanceProtectionError struct {
+	AutoScalingGroupName string
+	InstanceID           string
+	Protect              bool
+	Err                  error
+}
+
+func (e *InstanceProtectionError) Error() string {
+	action := "disable"
+	if e.Protect {
+		action = "enable"
+	}
+
+	return fmt.Sprintf("task protection updated, but unable to %s scale-in protection of instance %q in %s: %v",
+		action, e.InstanceID, e.AutoScalingGroupName, e.Err)
+}
+
+func (e *InstanceProtectionError) Unwrap() error {
+	return e.Err
+}
+
+// instanceProtection mirrors task protection onto the scale-in protection of the container
+// instance.
+type instanceProtection struct {
+	setter InstanceProtectionSetter
+	group  string
+
+	// mu guards instanceID, which is resolved by the first successful lookup.
+	mu         sync.Mutex
+	instanceID string
+}
+
+// resolveInstanceID returns the configured instance ID, or looks it up via the EC2 instance
+// metadata service until a lookup succeeds. Failed lookups, e.g. of a canceled call, are retried by
+// later calls.
+func (p *instanceProtection) resolveInstanceID(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.instanceID != "" {
+		return p.instanceID, nil
+	}
+
+	out, err := imds.New(imds.Options{}).GetMetadata(ctx, &imds.GetMetadataInput{Path: "instance-id"})
+	if err != nil {
+		return "", err
+	}
+	defer out.Content.Close()
+
+	b, err := io.ReadAll(out.Content)
+	if err != nil {
+		return "", err
+	}
+	p.instanceID = strings.TrimSpace(string(b))
+
+	return p.instanceID, nil
+}
+
+// updateInstanceProtection sets the container instance's scale-in protection to match the task's,
+// once ECS has confirmed the task protection update in output.
+func (c *Client) updateInstanceProtection(
+	ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput, output *ecs.UpdateTaskProtectionOutput,
+) error {
+	updated := false
+	for _, task := range output.ProtectedTasks {
+		if aws.ToString(task.TaskArn) == metadata.TaskARN {
+			updated = true
+		}
+	}
+	if !updated {
+		return nil
+	}
+
+	p := c.instanceProtection
+	instanceID, err := p.resolveInstanceID(ctx)
+	if err == nil {
+		err = p.setter.SetInstanceProtection(ctx, p.group, []string{instanceID}, input.Protect)
+	}
+	if err != nil {
+		return &InstanceProtectionError{
+			AutoScalingGroupName: p.group,
+			InstanceID:           instanceID,
+			Protect:              input.Protect,
+			Err:                  err,
+		}
+	}
+
+	return nil
+}
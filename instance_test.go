@@ -0,0 +1,81 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type InstanceProtectionTestSetter struct {
+	err   error
+	calls []bool
+}
+
+func (s *InstanceProtectionTestSetter) SetInstanceProtection(
+	ctx context.Context, autoScalingGroupName string, instanceIDs []string, protected bool,
+) error {
+	s.calls = append(s.calls, protected)
+	return s.err
+}
+
+func TestClient_UpdateTaskProtection_InstanceProtection(t *testing.T) {
+	tests := []struct {
+		name      string
+		ecsClient ECSClient
+		setterErr error
+		protect   bool
+		wantCalls []bool
+		wantErr   bool
+	}{
+		{
+			name:      "should enable instance protection with task protection",
+			ecsClient: &SuccessfulTestClient{},
+			protect:   true,
+			wantCalls: []bool{true},
+		},
+		{
+			name:      "should disable instance protection with task protection",
+			ecsClient: &SuccessfulTestClient{},
+			protect:   false,
+			wantCalls: []bool{false},
+		},
+		{
+			name:      "should not update the instance when the task update failed",
+			ecsClient: &FailureTestClient{},
+			protect:   true,
+		},
+		{
+			name:      "should return an InstanceProtectionError when the instance update failed",
+			ecsClient: &SuccessfulTestClient{},
+			setterErr: errors.New("AccessDenied"),
+			protect:   true,
+			wantCalls: []bool{true},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setter := &InstanceProtectionTestSetter{err: tt.setterErr}
+			c := NewClient(tt.ecsClient, WithInstanceProtection(setter, "test_asg", "i-0123456789abcdef0"))
+
+			output, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+				Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:  tt.protect,
+			})
+
+			assert.NotNil(t, output)
+			assert.Equal(t, tt.wantCalls, setter.calls)
+			if tt.wantErr {
+				var instanceErr *InstanceProtectionError
+				if assert.ErrorAs(t, err, &instanceErr) {
+					assert.Equal(t, "i-0123456789abcdef0", instanceErr.InstanceID)
+					assert.Equal(t, "test_asg", instanceErr.AutoScalingGroupName)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Job is a unit of work that should run to completion without the task being scaled in. It's the
+// only method a homegrown worker loop needs to implement to integrate protection with Wrap.
+type Job interface {
+	Run(ctx context.Context) error
+}
+
+// JobFunc is a Job calling itself.
+type JobFunc func(ctx context.Context) error
+
+// Run calls f.
+func (f JobFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// JobPanicError is returned by a Job returned by Wrap when the wrapped job panicked.
+type JobPanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *JobPanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.Value)
+}
+
+// Wrap returns a Job running job while m keeps the task protected:
+//
+//	for task := range tasks {
+//		err := ecstp.Wrap(manager, task).Run(ctx)
+//	}
+//
+// Protection is enabled when the first wrapped job of m starts and renewed as decided by the
+// Renewal of the Client's Profile, or the Adaptive strategy, until the last one returns, at which
+// point protection is disabled, after the Profile's Debounce if it's set. If protection can't be
+// enabled, job isn't run. A panic in job is recovered and returned as a *JobPanicError once
+// protection has been released.
+//
+// The returned error joins the errors of enabling protection, job and disabling protection after
+// the last running job.
+func Wrap(m *Manager, job Job) Job {
+	return JobFunc(func(ctx context.Context) error {
+		if err := m.hold(ctx); err != nil {
+			return err
+		}
+
+		err := runJob(ctx, job)
+
+		return errors.Join(err, m.releaseHold(ctx, false))
+	})
+}
+
+// Hold is a claim on protection of the task returned by Manager.Acquire, e.g. by a worker of a
+// pool while it's busy.
+type Hold struct {
+	m       *Manager
+	release sync.Once
+}
+
+// Acquire claims protection of the task until the returned Hold is released:
+//
+//	hold, err := manager.Acquire(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer hold.Release(ctx)
+//
+// Holds are counted, and shared with jobs wrapped with Wrap: protection is only enabled by the
+// first Acquire and disabled once the last Hold is released, so that concurrent workers don't
+// update protection for every job. It's renewed in the meantime like for wrapped jobs. Once a
+// ShutdownGuard of m has stopped holding protection, Acquire fails with ErrShuttingDown.
+func (m *Manager) Acquire(ctx context.Context) (*Hold, error) {
+	if err := m.hold(ctx); err != nil {
+		return nil, err
+	}
+
+	return &Hold{m: m}, nil
+}
+
+// Release releases the Hold, disabling protection if it was the last one, after the Debounce of the
+// Client's Profile if it's set. Releasing a Hold again does nothing.
+func (h *Hold) Release(ctx context.Context) error {
+	var err error
+	h.release.Do(func() {
+		err = h.m.releaseHold(ctx, false)
+	})
+
+	return err
+}
+
+// runJob runs job, recovering a panic as a *JobPanicError.
+func runJob(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &JobPanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+
+	return job.Run(ctx)
+}
+
+// hold records a running job, enabling protection if it's the first. Calls made while protection
+// is being enabled or disabled for holds wait for the update, or for ctx to be done, so no lock is
+// held during the ECS call.
+func (m *Manager) hold(ctx context.Context) error {
+	m.holdMu.Lock()
+	for {
+		if m.holdsStopped {
+			m.holdMu.Unlock()
+			return ErrShuttingDown
+		}
+		if m.holdRelease != nil {
+			// protection is still held while a debounced release is pending
+			m.holdRelease.Stop()
+			m.holdRelease = nil
+		}
+		if m.holdProtected {
+			m.holds++
+			m.holdMu.Unlock()
+			return nil
+		}
+		update := m.holdUpdate
+		if update == nil {
+			break
+		}
+		m.holdMu.Unlock()
+		select {
+		case <-update:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		m.holdMu.Lock()
+	}
+	update := make(chan struct{})
+	m.holdUpdate = update
+	m.holdMu.Unlock()
+
+	strategy := m.client.renewalStrategy(Adaptive{})
+	_, err := m.Protect(ctx, expiresInMinutes(strategy.NextExpiry(m.State())))
+
+	m.holdMu.Lock()
+	defer m.holdMu.Unlock()
+
+	m.holdUpdate = nil
+	close(update)
+	if err != nil {
+		return err
+	}
+	if m.holdsStopped {
+		// protection is disabled by the shutdown that waited for this update
+		return ErrShuttingDown
+	}
+	renewCtx, cancel := context.WithCancel(context.Background())
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		m.renewHolds(renewCtx, strategy)
+	}()
+	m.stopHolds = func() {
+		cancel()
+		<-renewed
+	}
+	m.holdProtected = true
+	m.holds++
+
+	return nil
+}
+
+// releaseHold records that a job returned, disabling protection if it was the last. Unless
+// immediate is set, disabling protection is delayed by the Debounce of the Client's Profile, and
+// its errors are logged rather than returned.
+func (m *Manager) releaseHold(ctx context.Context, immediate bool) error {
+	m.holdMu.Lock()
+
+	m.holds--
+	if m.holds > 0 || !m.holdProtected {
+		m.holdMu.Unlock()
+		return nil
+	}
+
+	debounce := m.client.profile.Debounce
+	if immediate || debounce <= 0 {
+		unhold := m.unholdLocked()
+		m.holdMu.Unlock()
+		return unhold(ctx)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	var release *time.Timer
+	release = time.AfterFunc(debounce, func() {
+		m.holdMu.Lock()
+		// a job started in the meantime, stopping the timer too late
+		if m.holds > 0 || m.holdRelease != release || !m.holdProtected {
+			m.holdMu.Unlock()
+			return
+		}
+		m.holdRelease = nil
+		unhold := m.unholdLocked()
+		m.holdMu.Unlock()
+
+		if err := unhold(ctx); err != nil {
+			m.client.log().ErrorContext(ctx, "unable to disable protection after jobs", slog.Any("error", err))
+		}
+	})
+	m.holdRelease = release
+	m.holdMu.Unlock()
+
+	return nil
+}
+
+// unholdLocked marks protection for holds as being disabled and returns the function disabling
+// it, to be called without m.holdMu.
+func (m *Manager) unholdLocked() func(ctx context.Context) error {
+	stop := m.stopHolds
+	update := make(chan struct{})
+	m.holdProtected = false
+	m.holdUpdate = update
+
+	return func(ctx context.Context) error {
+		stop()
+		_, err := m.FinalUnprotect(ctx)
+
+		m.holdMu.Lock()
+		m.holdUpdate = nil
+		close(update)
+		m.holdMu.Unlock()
+
+		return err
+	}
+}
+
+// stopHolding stops renewing protection for jobs and holds without disabling it, e.g. because it's
+// about to be disabled at shutdown, waiting for an update in progress. Jobs and holds released
+// afterwards don't update protection, and new ones fail with ErrShuttingDown.
+func (m *Manager) stopHolding() {
+	m.holdMu.Lock()
+	m.holdsStopped = true
+	for m.holdUpdate != nil {
+		update := m.holdUpdate
+		m.holdMu.Unlock()
+		<-update
+		m.holdMu.Lock()
+	}
+	if m.holdRelease != nil {
+		m.holdRelease.Stop()
+		m.holdRelease = nil
+	}
+	stop := m.stopHolds
+	protected := m.holdProtected
+	m.holdProtected = false
+	m.holdMu.Unlock()
+
+	if protected {
+		stop()
+	}
+}
+
+// renewHolds extends protection as decided by strategy until ctx is done.
+func (m *Manager) renewHolds(ctx context.Context, strategy RenewalStrategy) {
+	timer := time.NewTimer(time.Until(strategy.NextRenewal(m.State())))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if _, err := m.Protect(ctx, expiresInMinutes(strategy.NextExpiry(m.State()))); err != nil && ctx.Err() == nil {
+			m.holdMu.Lock()
+			holds := m.holds
+			m.holdMu.Unlock()
+			m.client.log().ErrorContext(ctx, "unable to renew protection for running jobs",
+				slog.Int("jobs", holds),
+				slog.Any("error", err),
+			)
+		}
+		timer.Reset(time.Until(strategy.NextRenewal(m.State())))
+	}
+}
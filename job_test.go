@@ -0,0 +1,183 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTestJob = errors.New("job failed")
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name      string
+		fail      bool
+		job       JobFunc
+		wantRun   bool
+		wantErr   error
+		wantPanic any
+	}{
+		{
+			name:    "should run the job while protected",
+			job:     func(ctx context.Context) error { return nil },
+			wantRun: true,
+		},
+		{
+			name:    "should return the error of the job",
+			job:     func(ctx context.Context) error { return errTestJob },
+			wantRun: true,
+			wantErr: errTestJob,
+		},
+		{
+			name:      "should recover a panic in the job",
+			job:       func(ctx context.Context) error { panic("boom") },
+			wantRun:   true,
+			wantPanic: "boom",
+		},
+		{
+			name: "should not run the job if protection fails",
+			fail: true,
+			job:  func(ctx context.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &ExpiringTestClient{}
+			client.fail.Store(tt.fail)
+			m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+			run := false
+			err := Wrap(m, JobFunc(func(ctx context.Context) error {
+				run = true
+				assert.True(t, m.State().Protected, "job should run while protected")
+				return tt.job(ctx)
+			})).Run(context.Background())
+
+			assert.Equal(t, tt.wantRun, run)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantPanic != nil:
+				var panicErr *JobPanicError
+				if assert.ErrorAs(t, err, &panicErr) {
+					assert.Equal(t, tt.wantPanic, panicErr.Value)
+					assert.Contains(t, string(panicErr.Stack), "job_test.go")
+				}
+			case tt.fail:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+			assert.False(t, m.State().Protected, "protection should be released once the job returns")
+		})
+	}
+}
+
+func TestWrap_Overlapping(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Wrap(m, JobFunc(func(ctx context.Context) error {
+			close(started)
+			<-finish
+			return nil
+		})).Run(context.Background())
+	}()
+	<-started
+
+	require.NoError(t, Wrap(m, JobFunc(func(ctx context.Context) error { return nil })).Run(context.Background()))
+	assert.True(t, m.State().Protected, "protection should be held by the running job")
+
+	close(finish)
+	require.NoError(t, <-done)
+	assert.False(t, m.State().Protected)
+}
+
+func TestManager_Acquire(t *testing.T) {
+	client := &CountingTestClient{}
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	ctx := context.Background()
+
+	holds := make([]*Hold, 3)
+	for i := range holds {
+		hold, err := m.Acquire(ctx)
+		require.NoError(t, err)
+		holds[i] = hold
+	}
+	assert.Equal(t, int32(1), client.protects.Load(), "only the first hold should enable protection")
+
+	for _, hold := range holds[:2] {
+		require.NoError(t, hold.Release(ctx))
+		require.NoError(t, hold.Release(ctx), "releasing a hold again should do nothing")
+	}
+	assert.True(t, m.State().Protected, "protection should be kept by the last hold")
+	assert.Zero(t, client.unprotects.Load())
+
+	require.NoError(t, holds[2].Release(ctx))
+	assert.False(t, m.State().Protected)
+	assert.Equal(t, int32(1), client.unprotects.Load())
+}
+
+func TestManager_Acquire_Error(t *testing.T) {
+	client := &ExpiringTestClient{}
+	client.fail.Store(true)
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+	hold, err := m.Acquire(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, hold)
+
+	client.fail.Store(false)
+	hold, err = m.Acquire(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, hold.Release(context.Background()))
+	assert.False(t, m.State().Protected)
+}
+
+// GatedTestClient blocks calls enabling protection until gate is closed.
+type GatedTestClient struct {
+	CountingTestClient
+	gate chan struct{}
+}
+
+func (c *GatedTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if params.ProtectionEnabled {
+		<-c.gate
+	}
+
+	return c.CountingTestClient.UpdateTaskProtection(ctx, params, optFns...)
+}
+
+func TestManager_Acquire_Waiting(t *testing.T) {
+	client := &GatedTestClient{gate: make(chan struct{})}
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+	first := make(chan error, 1)
+	go func() {
+		hold, err := m.Acquire(context.Background())
+		if err == nil {
+			err = hold.Release(context.Background())
+		}
+		first <- err
+	}()
+	require.Eventually(t, func() bool { return m.Phase() == PhaseProtecting }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := m.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "waiting for protection should end with the context")
+
+	close(client.gate)
+	require.NoError(t, <-first)
+	assert.Equal(t, int32(1), client.protects.Load(), "the waiting hold should not enable protection again")
+	assert.Equal(t, int32(1), client.unprotects.Load())
+}
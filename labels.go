@@ -0,0 +1,48 @@
+package ecstp
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+)
+
+type labelsKey struct{}
+
+// ContextWithLabels returns a copy of ctx carrying correlation labels, e.g. a job, tenant or
+// request ID, added to any labels ctx already carries.
+//
+// Protection updates made with the context are annotated with the labels: they are included in
+// audit records, logs, the State and events of a Manager and the metric data derived from it, so
+// protection activity can be joined with application telemetry. Labels set on an
+// UpdateTaskProtectionInput take precedence.
+func ContextWithLabels(ctx context.Context, labels map[string]string) context.Context {
+	return context.WithValue(ctx, labelsKey{}, mergeLabels(LabelsFromContext(ctx), labels))
+}
+
+// LabelsFromContext returns the correlation labels carried by ctx, or nil if there are none.
+func LabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+
+	return labels
+}
+
+// mergeLabels returns the labels of base overridden by those of overrides, or nil if there are
+// none. The maps aren't modified.
+func mergeLabels(base, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return base
+	}
+	if len(base) == 0 {
+		return maps.Clone(overrides)
+	}
+
+	merged := maps.Clone(base)
+	maps.Copy(merged, overrides)
+
+	return merged
+}
+
+// labelsAttr returns a log attribute holding labels.
+func labelsAttr(labels map[string]string) slog.Attr {
+	return slog.Any("labels", labels)
+}
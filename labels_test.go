@@ -0,0 +1,48 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextWithLabels(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, LabelsFromContext(ctx))
+
+	ctx = ContextWithLabels(ctx, map[string]string{"tenant": "acme", "job": "1"})
+	inner := ContextWithLabels(ctx, map[string]string{"job": "2"})
+
+	assert.Equal(t, map[string]string{"tenant": "acme", "job": "1"}, LabelsFromContext(ctx))
+	assert.Equal(t, map[string]string{"tenant": "acme", "job": "2"}, LabelsFromContext(inner))
+}
+
+func TestManager_Labels(t *testing.T) {
+	var records []AuditRecord
+	auditor := AuditorFunc(func(_ context.Context, record AuditRecord) {
+		records = append(records, record)
+	})
+	m := NewManager(NewClient(&SuccessfulTestClient{}, WithAuditor(auditor)), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	events, cancel := m.Subscribe()
+	defer cancel()
+
+	ctx := ContextWithLabels(context.Background(), map[string]string{"tenant": "acme", "job": "1"})
+	_, err := m.update(ctx, &UpdateTaskProtectionInput{
+		Protect: true,
+		Labels:  map[string]string{"job": "2"},
+	})
+	require.NoError(t, err)
+
+	want := map[string]string{"tenant": "acme", "job": "2"}
+	require.Len(t, records, 1)
+	assert.Equal(t, want, records[0].Labels)
+	assert.Equal(t, want, m.State().Labels)
+	assert.Equal(t, want, (<-events).State.Labels)
+
+	_, err = m.Unprotect(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, records[1].Labels)
+	assert.Nil(t, m.State().Labels, "labels should describe the last update")
+}
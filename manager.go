@@ -0,0 +1,473 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// State is a snapshot of the protection state tracked by a Manager.
+type State struct {
+	Protected bool `json:"protected" yaml:"protected"`
+	// ExpiresAt is when protection expires by the local clock, adjusted for ClockSkew.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	// ClockSkew is how far the clock of AWS was ahead of the local clock at the last update.
+	ClockSkew time.Duration `json:"clockSkew,omitempty" yaml:"clockSkew,omitempty"`
+	// ProtectedSince is when the current continuous protection began.
+	ProtectedSince *time.Time   `json:"protectedSince,omitempty" yaml:"protectedSince,omitempty"`
+	Cluster        string       `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	TaskARN        string       `json:"taskArn,omitempty" yaml:"taskArn,omitempty"`
+	UpdatedAt      time.Time    `json:"updatedAt" yaml:"updatedAt"`
+	LastError      *ErrorDetail `json:"lastError,omitempty" yaml:"lastError,omitempty"`
+	// Stopping is set once ECS reports that the task is stopping, see Manager.MarkStopping.
+	Stopping   bool   `json:"stopping,omitempty" yaml:"stopping,omitempty"`
+	StopReason string `json:"stopReason,omitempty" yaml:"stopReason,omitempty"`
+	// Labels are the correlation labels of the last update, see ContextWithLabels.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// Event types published by a Manager.
+const (
+	EventProtected    = "protected"
+	EventUnprotected  = "unprotected"
+	EventUpdateFailed = "update_failed"
+	EventTaskStopping = "task_stopping"
+	// EventProtectionRefused is published instead of EventUpdateFailed when protection is requested
+	// outside the windows allowed by the Client's Calendar, during a deployment blackout or against
+	// a Policy.
+	EventProtectionRefused = "protection_refused"
+	// EventWindowClosed is published when the allowed protection window ends while the task is
+	// protected, before protection is released.
+	EventWindowClosed = "window_closed"
+	// EventMaxProtectionReached is published when protection has been held continuously for the
+	// maximum set with WithMaxContinuousProtection, before protection is released.
+	EventMaxProtectionReached = "max_protection_reached"
+	// EventHeartbeatMissed is published by a Renewer when heartbeats stop arriving within its
+	// HeartbeatTimeout and protection is left to lapse.
+	EventHeartbeatMissed = "heartbeat_missed"
+	// EventWorkStalled is published by a Watchdog when no progress has been reported within its
+	// Threshold, before protection is released. Its Detail describes the last progress.
+	EventWorkStalled = "work_stalled"
+)
+
+// ErrMaxContinuousProtection is returned by a Manager when protection is renewed after being held
+// for the maximum set with WithMaxContinuousProtection. It wraps ErrProtectionNotAllowed.
+var ErrMaxContinuousProtection = fmt.Errorf("%w: maximum continuous protection reached", ErrProtectionNotAllowed)
+
+// Event describes a protection state transition.
+//
+// ID increases monotonically for every event published by a Manager and can be passed to
+// SubscribeSince to resume a subscription.
+type Event struct {
+	ID    uint64    `json:"id,omitempty"`
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	State State     `json:"state"`
+	// Detail is an optional diagnostic message.
+	Detail string `json:"detail,omitempty"`
+}
+
+// eventBufferSize is the number of events buffered per subscriber before further events are
+// dropped for it.
+const eventBufferSize = 16
+
+// eventHistorySize is the number of past events kept for SubscribeSince.
+const eventHistorySize = 64
+
+// releaseTimeout bounds the release of protection at the end of an allowed window or once the
+// maximum continuous protection is reached.
+const releaseTimeout = 30 * time.Second
+
+// Manager tracks the protection state of the current task as it's enabled and disabled through
+// it. It's safe for concurrent use.
+type Manager struct {
+	client   *Client
+	metadata *MetadataBody
+
+	mu           sync.Mutex
+	state        State
+	releaseTimer *time.Timer
+	leaseSource  func() []Lease
+	policies     []registeredPolicy
+	guards       []registeredGuard
+	// transitionFuncs are called for every phase transition, see OnTransition.
+	transitionFuncs    []registeredTransitionFunc
+	nextRegistrationID uint64
+	// renewalFailures counts failed updates enabling protection.
+	renewalFailures uint64
+
+	// phaseMu guards phase and phaseState, the state as of the last transition, so that Phase
+	// doesn't wait for updates in progress.
+	phaseMu    sync.Mutex
+	phase      Phase
+	phaseState State
+	// health is the current Health level, empty while protection isn't wanted.
+	health      Health
+	expiryTimer *time.Timer
+
+	holdMu sync.Mutex
+	// holds counts the running jobs wrapped with Wrap and the unreleased Holds.
+	holds         int
+	holdProtected bool
+	// holdUpdate is closed once the update of protection for holds in progress, if any, is done;
+	// it's made without holdMu, which calls waiting for it don't hold either.
+	holdUpdate  chan struct{}
+	holdRelease *time.Timer
+	// holdsStopped is set by stopHolding, after which holds fail with ErrShuttingDown.
+	holdsStopped bool
+	// stopHolds stops renewing protection for holds, waiting for a renewal in progress.
+	stopHolds func()
+
+	subMu       sync.Mutex
+	subscribers map[chan Event]struct{}
+	healthSubs  map[chan HealthEvent]struct{}
+	lastEventID uint64
+	history     []Event
+}
+
+// NewManager returns a Manager that updates protection using client.
+//
+// If metadata is nil, the task is resolved via the task metadata endpoint on first use.
+func NewManager(client *Client, metadata *MetadataBody) *Manager {
+	if metadata != nil {
+		metadata = normalizeMetadata(metadata)
+	}
+
+	return &Manager{
+		client:      client,
+		metadata:    metadata,
+		phase:       PhaseUnprotected,
+		subscribers: make(map[chan Event]struct{}),
+		healthSubs:  make(map[chan HealthEvent]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving an Event for every protection update and a function that
+// ends the subscription. Events are dropped for subscribers that don't keep up.
+func (m *Manager) Subscribe() (<-chan Event, func()) {
+	_, ch, cancel := m.SubscribeSince(math.MaxUint64)
+
+	return ch, cancel
+}
+
+// SubscribeSince is like Subscribe, but also returns the retained past events published after the
+// event with ID lastID, so that a client can resume a subscription without missing events.
+func (m *Manager) SubscribeSince(lastID uint64) ([]Event, <-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	m.subMu.Lock()
+	var missed []Event
+	for _, event := range m.history {
+		if event.ID > lastID {
+			missed = append(missed, event)
+		}
+	}
+	m.subscribers[ch] = struct{}{}
+	m.subMu.Unlock()
+
+	var once sync.Once
+	return missed, ch, func() {
+		once.Do(func() {
+			m.subMu.Lock()
+			delete(m.subscribers, ch)
+			m.subMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (m *Manager) publish(eventType string, state State) {
+	m.publishDetail(eventType, state, "")
+}
+
+func (m *Manager) publishDetail(eventType string, state State, detail string) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	m.lastEventID++
+	event := Event{
+		ID:     m.lastEventID,
+		Type:   eventType,
+		Time:   time.Now().UTC(),
+		State:  state,
+		Detail: detail,
+	}
+
+	m.history = append(m.history, event)
+	if len(m.history) > eventHistorySize {
+		m.history = m.history[len(m.history)-eventHistorySize:]
+	}
+
+	for ch := range m.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Protect enables protection, optionally expiring after expiresInMinutes. Without it, protection
+// expires after the Expiry of the Client's Profile, if set, or the ECS default of 2 hours.
+func (m *Manager) Protect(ctx context.Context, expiresInMinutes *int32) (State, error) {
+	if expiresInMinutes == nil {
+		expiresInMinutes = m.client.defaultExpiresInMinutes()
+	}
+
+	return m.update(ctx, &UpdateTaskProtectionInput{
+		Protect:          true,
+		ExpiresInMinutes: expiresInMinutes,
+	})
+}
+
+// Unprotect disables protection.
+func (m *Manager) Unprotect(ctx context.Context) (State, error) {
+	return m.update(ctx, &UpdateTaskProtectionInput{
+		Protect: false,
+	})
+}
+
+// FinalUnprotectTimeout bounds the unprotect call made by Manager.FinalUnprotect.
+const FinalUnprotectTimeout = 10 * time.Second
+
+// FinalUnprotect disables protection as cleanup once work is done, e.g. in a deferred call. Unlike
+// Unprotect, it runs even if ctx has been canceled, as a job's context often is by then: the call
+// uses a context detached from ctx's cancelation, keeping its values such as correlation labels,
+// and bounded by FinalUnprotectTimeout.
+func (m *Manager) FinalUnprotect(ctx context.Context) (State, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), FinalUnprotectTimeout)
+	defer cancel()
+
+	return m.Unprotect(ctx)
+}
+
+// State returns the current protection state.
+func (m *Manager) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state
+}
+
+// MarkStopping records that ECS reported the task as stopping, e.g. from an ECS task state change
+// event. Protection no longer applies to a stopping task, so the tracked state is reset to
+// unprotected without calling ECS and EventTaskStopping is published. It does nothing if the task
+// is already marked as stopping.
+func (m *Manager) MarkStopping(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state.Stopping {
+		return
+	}
+
+	m.state.Protected = false
+	m.state.ExpiresAt = nil
+	m.state.ProtectedSince = nil
+	m.state.Stopping = true
+	m.state.StopReason = reason
+	m.state.UpdatedAt = time.Now().UTC()
+	m.transitionLocked(TriggerStopping)
+	m.publish(EventTaskStopping, m.state)
+}
+
+func (m *Manager) update(ctx context.Context, input *UpdateTaskProtectionInput) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateLocked(ctx, input)
+}
+
+func (m *Manager) updateLocked(ctx context.Context, input *UpdateTaskProtectionInput) (State, error) {
+	if m.metadata == nil {
+		metadata, err := m.client.GetTaskArn(ctx)
+		if err != nil {
+			m.state.LastError = NewErrorDetail(OperationGetTaskMetadata, "", err)
+			m.publish(EventUpdateFailed, m.state)
+			return m.state, err
+		}
+		m.metadata = metadata
+	}
+	input.Metadata = m.metadata
+	m.state.Cluster = m.metadata.Cluster
+	m.state.TaskARN = m.metadata.TaskARN
+	m.state.Labels = mergeLabels(LabelsFromContext(ctx), input.Labels)
+
+	// renewals past the maximum continuous protection release it instead
+	if input.Protect && m.client.maxContinuous > 0 && protectedAt(m.state, time.Now()) {
+		if eventType, reason := m.releaseReasonLocked(time.Now()); eventType == EventMaxProtectionReached {
+			m.publish(eventType, m.state)
+			state, err := m.updateLocked(ctx, &UpdateTaskProtectionInput{Protect: false, Reason: reason})
+			if err != nil {
+				return state, err
+			}
+			return state, fmt.Errorf("%w: task %s", ErrMaxContinuousProtection, m.metadata.TaskARN)
+		}
+	}
+
+	if input.Protect {
+		if err := m.checkPoliciesLocked(ctx); err != nil {
+			m.state.LastError = NewErrorDetail(OperationUpdateTaskProtection, m.metadata.TaskARN, err)
+			m.publish(EventProtectionRefused, m.state)
+			return m.state, err
+		}
+	}
+
+	wasProtected := protectedAt(m.state, time.Now())
+	trigger := TriggerRelease
+	switch {
+	case input.Protect && wasProtected:
+		trigger = TriggerRenew
+	case input.Protect:
+		trigger = TriggerProtect
+	}
+	if err := m.beginTransitionLocked(ctx, trigger); err != nil {
+		m.state.LastError = NewErrorDetail(OperationUpdateTaskProtection, m.metadata.TaskARN, err)
+		if input.Protect {
+			m.publish(EventProtectionRefused, m.state)
+		} else {
+			m.publish(EventUpdateFailed, m.state)
+		}
+		return m.state, err
+	}
+
+	output, err := m.client.UpdateTaskProtection(ctx, input)
+	if err == nil {
+		err = protectionResult(m.metadata.TaskARN, output, &m.state)
+	}
+	if err != nil {
+		m.state.LastError = NewErrorDetail(OperationUpdateTaskProtection, m.metadata.TaskARN, err)
+		if input.Protect {
+			m.renewalFailures++
+		}
+		if errors.Is(err, ErrOutsideWindow) || errors.Is(err, ErrDeploymentBlackout) {
+			m.publish(EventProtectionRefused, m.state)
+		} else {
+			m.publish(EventUpdateFailed, m.state)
+		}
+		m.endTransitionLocked(err)
+		return m.state, err
+	}
+
+	m.state.LastError = nil
+	m.state.UpdatedAt = time.Now().UTC()
+	switch {
+	case !m.state.Protected:
+		m.state.ProtectedSince = nil
+	case !wasProtected || m.state.ProtectedSince == nil:
+		m.state.ProtectedSince = aws.Time(m.state.UpdatedAt)
+	}
+	if m.state.Protected {
+		m.publish(EventProtected, m.state)
+	} else {
+		m.publish(EventUnprotected, m.state)
+	}
+	m.endTransitionLocked(nil)
+	m.scheduleReleaseLocked()
+
+	return m.state, nil
+}
+
+// scheduleReleaseLocked arms a timer releasing protection when the allowed window of the Client's
+// Calendar ends or the maximum continuous protection is reached, or stops it if the task isn't
+// protected.
+func (m *Manager) scheduleReleaseLocked() {
+	if m.releaseTimer != nil {
+		m.releaseTimer.Stop()
+		m.releaseTimer = nil
+	}
+	if !m.state.Protected {
+		return
+	}
+
+	var at time.Time
+	if m.client.calendar != nil {
+		allowed, until := m.client.calendar.Allowed(time.Now())
+		if !allowed {
+			at = time.Now()
+		} else if !until.IsZero() {
+			at = until
+		}
+	}
+	if max := m.client.maxContinuous; max > 0 && m.state.ProtectedSince != nil {
+		if deadline := m.state.ProtectedSince.Add(max); at.IsZero() || deadline.Before(at) {
+			at = deadline
+		}
+	}
+	if at.IsZero() {
+		return
+	}
+	m.releaseTimer = time.AfterFunc(time.Until(at), m.releaseDue)
+}
+
+// releaseDue releases protection once it's no longer allowed, publishing EventWindowClosed or
+// EventMaxProtectionReached first.
+func (m *Manager) releaseDue() {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.state.Protected {
+		return
+	}
+	// the calendar may have been extended, e.g. by a blackout being lifted
+	eventType, reason := m.releaseReasonLocked(time.Now())
+	if eventType == "" {
+		m.scheduleReleaseLocked()
+		return
+	}
+
+	m.publish(eventType, m.state)
+	m.updateLocked(ctx, &UpdateTaskProtectionInput{
+		Protect: false,
+		Reason:  reason,
+	})
+}
+
+// releaseReasonLocked returns the event type and reason for releasing protection at now, or empty
+// strings if protection may still be held.
+func (m *Manager) releaseReasonLocked(now time.Time) (string, string) {
+	if max := m.client.maxContinuous; max > 0 && m.state.ProtectedSince != nil && now.Sub(*m.state.ProtectedSince) >= max {
+		return EventMaxProtectionReached, "maximum continuous protection reached"
+	}
+	if m.client.calendar != nil {
+		if allowed, _ := m.client.calendar.Allowed(now); !allowed {
+			return EventWindowClosed, "protection window closed"
+		}
+	}
+
+	return "", ""
+}
+
+// protectedAt reports whether state describes protection in effect at now.
+func protectedAt(state State, now time.Time) bool {
+	return state.Protected && (state.ExpiresAt == nil || state.ExpiresAt.After(now))
+}
+
+// protectionResult applies the result for taskARN in output to state. If the update failed for
+// the task, it returns an error wrapping a *ProtectionFailureError. The expiry is converted to the
+// local clock, using the clock skew observed from the response.
+func protectionResult(taskARN string, output *ecs.UpdateTaskProtectionOutput, state *State) error {
+	if err := NewUpdateResult(output).Failure(taskARN); err != nil {
+		return fmt.Errorf("unable to update protection: %w", err)
+	}
+	result, _ := NewUpdateResult(output).Task(taskARN)
+
+	state.Protected = result.ProtectionEnabled
+	state.ExpiresAt = result.ExpiresAt
+	if skew, ok := ClockSkew(output.ResultMetadata); ok {
+		state.ClockSkew = skew
+		if state.ExpiresAt != nil {
+			state.ExpiresAt = aws.Time(LocalTime(*state.ExpiresAt, skew))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Protect(t *testing.T) {
+	tests := []struct {
+		name          string
+		ecsClient     ECSClient
+		wantProtected bool
+		wantErr       bool
+	}{
+		{
+			name:          "should track enabled protection",
+			ecsClient:     &SuccessfulTestClient{},
+			wantProtected: true,
+		},
+		{
+			name:      "should return an error for failed tasks",
+			ecsClient: &FailureTestClient{},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(NewClient(tt.ecsClient), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+			got, err := m.Protect(context.Background(), aws.Int32(10))
+			if tt.wantErr {
+				var failure *ProtectionFailureError
+				assert.ErrorAs(t, err, &failure)
+				assert.NotNil(t, got.LastError)
+			} else {
+				assert.NoError(t, err)
+				assert.Nil(t, got.LastError)
+			}
+			assert.Equal(t, tt.wantProtected, got.Protected)
+			assert.Equal(t, "test_arn", got.TaskARN)
+			assert.Equal(t, got, m.State())
+		})
+	}
+}
+
+func TestManager_Unprotect(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+	_, err := m.Protect(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.True(t, m.State().Protected)
+
+	got, err := m.Unprotect(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, got.Protected)
+}
+
+// ContextTestClient fails calls made with a done context and records the deadline of the last
+// call.
+type ContextTestClient struct {
+	SuccessfulTestClient
+	deadline time.Time
+}
+
+func (c *ContextTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.deadline, _ = ctx.Deadline()
+
+	return c.SuccessfulTestClient.UpdateTaskProtection(ctx, params, optFns...)
+}
+
+func TestManager_FinalUnprotect(t *testing.T) {
+	client := &ContextTestClient{}
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(ContextWithLabels(context.Background(), map[string]string{"job": "42"}))
+	cancel()
+
+	_, err = m.Unprotect(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "unprotecting with a canceled context should fail")
+
+	got, err := m.FinalUnprotect(ctx)
+	if assert.NoError(t, err) {
+		assert.False(t, got.Protected)
+		assert.Equal(t, map[string]string{"job": "42"}, got.Labels)
+		assert.WithinDuration(t, time.Now().Add(FinalUnprotectTimeout), client.deadline, time.Second)
+	}
+}
+
+func TestManager_MarkStopping(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	_, err := m.Protect(context.Background(), nil)
+	assert.NoError(t, err)
+
+	events, cancel := m.Subscribe()
+	defer cancel()
+
+	m.MarkStopping("Scaling activity initiated by deployment")
+	m.MarkStopping("ignored")
+
+	got := m.State()
+	assert.False(t, got.Protected)
+	assert.True(t, got.Stopping)
+	assert.Equal(t, "Scaling activity initiated by deployment", got.StopReason)
+	assert.Equal(t, EventTaskStopping, (<-events).Type)
+	assert.Len(t, events, 0, "marking an already stopping task should not publish")
+}
+
+func TestManager_ResolvesMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`)
+	}))
+	defer ts.Close()
+
+	c := NewClient(&SuccessfulTestClient{})
+	c.MetadataEndpointOverride = ts.URL
+	m := NewManager(c, nil)
+
+	got, err := m.Protect(context.Background(), nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test_cluster", got.Cluster)
+		assert.Equal(t, "test_arn", got.TaskARN)
+	}
+}
+
+func TestManager_Subscribe(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	events, cancel := m.Subscribe()
+
+	_, err := m.Protect(context.Background(), nil)
+	assert.NoError(t, err)
+	_, err = m.Unprotect(context.Background())
+	assert.NoError(t, err)
+
+	failing := NewManager(NewClient(&FailureTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	failingEvents, cancelFailing := failing.Subscribe()
+	_, err = failing.Protect(context.Background(), nil)
+	assert.Error(t, err)
+
+	assert.Equal(t, EventProtected, (<-events).Type)
+	assert.Equal(t, EventUnprotected, (<-events).Type)
+	assert.Equal(t, EventUpdateFailed, (<-failingEvents).Type)
+
+	cancel()
+	cancel()
+	cancelFailing()
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestManager_SubscribeSince(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	for i := 0; i < 3; i++ {
+		_, err := m.Protect(context.Background(), nil)
+		assert.NoError(t, err)
+	}
+
+	missed, events, cancel := m.SubscribeSince(1)
+	defer cancel()
+	if assert.Len(t, missed, 2) {
+		assert.Equal(t, uint64(2), missed[0].ID)
+		assert.Equal(t, uint64(3), missed[1].ID)
+	}
+
+	_, err := m.Unprotect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), (<-events).ID)
+}
+
+func TestManager_MaxContinuousProtection(t *testing.T) {
+	t.Run("should keep the start of continuous protection across renewals", func(t *testing.T) {
+		m := NewManager(NewClient(&SuccessfulTestClient{}, WithMaxContinuousProtection(time.Hour)), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+		first, err := m.Protect(context.Background(), nil)
+		require.NoError(t, err)
+		require.NotNil(t, first.ProtectedSince)
+
+		renewed, err := m.Protect(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, first.ProtectedSince, renewed.ProtectedSince)
+
+		unprotected, err := m.Unprotect(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, unprotected.ProtectedSince)
+	})
+
+	t.Run("should release protection once the maximum is reached", func(t *testing.T) {
+		m := NewManager(NewClient(&SuccessfulTestClient{}, WithMaxContinuousProtection(50*time.Millisecond)), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+		events, cancel := m.Subscribe()
+		defer cancel()
+
+		_, err := m.Protect(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, EventProtected, (<-events).Type)
+
+		assert.Equal(t, EventMaxProtectionReached, (<-events).Type)
+		assert.Equal(t, EventUnprotected, (<-events).Type)
+		assert.False(t, m.State().Protected)
+	})
+
+	t.Run("should refuse renewals past the maximum", func(t *testing.T) {
+		m := NewManager(NewClient(&SuccessfulTestClient{}, WithMaxContinuousProtection(time.Hour)), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+		_, err := m.Protect(context.Background(), nil)
+		require.NoError(t, err)
+		m.mu.Lock()
+		m.state.ProtectedSince = aws.Time(time.Now().Add(-2 * time.Hour))
+		m.mu.Unlock()
+
+		got, err := m.Protect(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrMaxContinuousProtection)
+		assert.ErrorIs(t, err, ErrProtectionNotAllowed)
+		assert.False(t, got.Protected)
+		assert.Nil(t, got.ProtectedSince)
+	})
+}
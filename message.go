@@ -0,0 +1,48 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+)
+
+// Message is a message consumed from a queue that's redelivered unless it's acknowledged, handled
+// with Manager.HandleMessage.
+type Message interface {
+	// Ack acknowledges the message once it's been handled.
+	Ack() error
+	// Nack negatively acknowledges the message once handling it failed.
+	Nack() error
+	// Requeue returns the message to the queue without it being handled, so it's redelivered.
+	Requeue() error
+}
+
+// HandleMessage handles msg with handle while holding protection of the task, and settles it before
+// releasing the Hold:
+//
+//	err := manager.HandleMessage(ctx, msg, func(ctx context.Context) error {
+//		return process(ctx, msg)
+//	})
+//
+// msg is acknowledged if handle succeeds and negatively acknowledged otherwise. Protection is only
+// released once that has been sent, even if ctx was canceled meanwhile, so the task isn't scaled in
+// between handling msg and settling it. If protection can't be enabled, msg is requeued without
+// being handled, so it's redelivered to a task that can protect itself.
+//
+// The returned error joins the errors of enabling protection, handle, settling msg and releasing
+// the Hold.
+func (m *Manager) HandleMessage(ctx context.Context, msg Message, handle func(ctx context.Context) error) error {
+	hold, err := m.Acquire(ctx)
+	if err != nil {
+		return errors.Join(err, msg.Requeue())
+	}
+
+	err = handle(ctx)
+	var settleErr error
+	if err == nil {
+		settleErr = msg.Ack()
+	} else {
+		settleErr = msg.Nack()
+	}
+
+	return errors.Join(err, settleErr, hold.Release(ctx))
+}
@@ -0,0 +1,97 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMessage records how it was settled, and whether the task was protected at the time.
+type testMessage struct {
+	m         *Manager
+	settled   string
+	protected bool
+	err       error
+}
+
+func (msg *testMessage) settle(how string) error {
+	msg.settled = how
+	msg.protected = msg.m.State().Protected
+	return msg.err
+}
+
+func (msg *testMessage) Ack() error     { return msg.settle("ack") }
+func (msg *testMessage) Nack() error    { return msg.settle("nack") }
+func (msg *testMessage) Requeue() error { return msg.settle("requeue") }
+
+func TestManager_HandleMessage(t *testing.T) {
+	errSettle := errors.New("channel closed")
+
+	tests := []struct {
+		name        string
+		fail        bool
+		handle      func(ctx context.Context) error
+		settleErr   error
+		wantHandled bool
+		wantSettled string
+		wantErr     error
+	}{
+		{
+			name:        "should ack a handled message while protected",
+			handle:      func(ctx context.Context) error { return nil },
+			wantHandled: true,
+			wantSettled: "ack",
+		},
+		{
+			name:        "should nack a message whose handling failed",
+			handle:      func(ctx context.Context) error { return errTestJob },
+			wantHandled: true,
+			wantSettled: "nack",
+			wantErr:     errTestJob,
+		},
+		{
+			name:        "should return the error of settling the message",
+			handle:      func(ctx context.Context) error { return nil },
+			settleErr:   errSettle,
+			wantHandled: true,
+			wantSettled: "ack",
+			wantErr:     errSettle,
+		},
+		{
+			name:        "should requeue the message without handling it if protection fails",
+			fail:        true,
+			handle:      func(ctx context.Context) error { return nil },
+			wantSettled: "requeue",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &ExpiringTestClient{}
+			client.fail.Store(tt.fail)
+			m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+			msg := &testMessage{m: m, err: tt.settleErr}
+
+			handled := false
+			err := m.HandleMessage(context.Background(), msg, func(ctx context.Context) error {
+				handled = true
+				assert.True(t, m.State().Protected, "message should be handled while protected")
+				return tt.handle(ctx)
+			})
+
+			assert.Equal(t, tt.wantHandled, handled)
+			assert.Equal(t, tt.wantSettled, msg.settled)
+			assert.Equal(t, tt.wantHandled, msg.protected, "message should be settled before protection is released")
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.fail:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+			assert.False(t, m.State().Protected, "protection should be released once the message is settled")
+		})
+	}
+}
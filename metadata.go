@@ -0,0 +1,179 @@
+package ecstp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultMetadataTimeout is the default timeout of a single task metadata request.
+const DefaultMetadataTimeout = 5 * time.Second
+
+// defaultMetadataRetry is the retry policy of the task metadata requests of a Client not created
+// with WithRetry, as the endpoint can fail transiently while the task starts.
+var defaultMetadataRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+// Environment variables ECS sets to the task metadata endpoint, in order of preference.
+const (
+	EnvMetadataURIV4 = "ECS_CONTAINER_METADATA_URI_V4"
+	EnvMetadataURIV3 = "ECS_CONTAINER_METADATA_URI"
+)
+
+// MetadataEndpointResolver resolves the task metadata endpoint of the current task, e.g. in
+// environments that don't provide it through the environment, like local emulators. If there's no
+// endpoint to resolve, the error should wrap ErrMetadataUnavailable.
+type MetadataEndpointResolver interface {
+	ResolveMetadataEndpoint(ctx context.Context) (string, error)
+}
+
+// MetadataEndpointResolverFunc is an adapter to allow the use of ordinary functions as
+// MetadataEndpointResolvers.
+type MetadataEndpointResolverFunc func(ctx context.Context) (string, error)
+
+// ResolveMetadataEndpoint calls f(ctx).
+func (f MetadataEndpointResolverFunc) ResolveMetadataEndpoint(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// EnvMetadataEndpointResolver resolves the task metadata endpoint from ECS_CONTAINER_METADATA_URI_V4,
+// falling back to the version 3 endpoint in ECS_CONTAINER_METADATA_URI set by older agents. Empty
+// variables are treated as unset. It's the default MetadataEndpointResolver of a Client.
+type EnvMetadataEndpointResolver struct{}
+
+// ResolveMetadataEndpoint implements MetadataEndpointResolver.
+func (EnvMetadataEndpointResolver) ResolveMetadataEndpoint(context.Context) (string, error) {
+	for _, env := range []string{EnvMetadataURIV4, EnvMetadataURIV3} {
+		if endpoint := os.Getenv(env); endpoint != "" {
+			return endpoint, nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to retrieve Task ARN - can't get Metadata URI: %w", ErrMetadataUnavailable)
+}
+
+// TaskMetadata is the task metadata document returned by the task metadata endpoint, see
+// https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-metadata-endpoint-v4-response.html.
+// Fields not reported for the task's launch type are left empty.
+type TaskMetadata struct {
+	MetadataBody
+	Family        string              `json:"Family"`
+	Revision      string              `json:"Revision"`
+	DesiredStatus string              `json:"DesiredStatus"`
+	KnownStatus   string              `json:"KnownStatus"`
+	Limits        *Limits             `json:"Limits,omitempty"`
+	Containers    []ContainerMetadata `json:"Containers,omitempty"`
+}
+
+// Limits are the resource limits of a task or container, with CPU in vCPUs for tasks and CPU
+// units for containers, and Memory in MiB.
+type Limits struct {
+	CPU    float64 `json:"CPU,omitempty"`
+	Memory int64   `json:"Memory,omitempty"`
+}
+
+// ContainerMetadata describes a container of a task in its TaskMetadata.
+type ContainerMetadata struct {
+	DockerID      string            `json:"DockerId"`
+	Name          string            `json:"Name"`
+	DockerName    string            `json:"DockerName"`
+	Image         string            `json:"Image"`
+	ImageID       string            `json:"ImageID"`
+	ContainerARN  string            `json:"ContainerARN,omitempty"`
+	Type          string            `json:"Type"`
+	DesiredStatus string            `json:"DesiredStatus"`
+	KnownStatus   string            `json:"KnownStatus"`
+	Labels        map[string]string `json:"Labels,omitempty"`
+	Limits        *Limits           `json:"Limits,omitempty"`
+	CreatedAt     *time.Time        `json:"CreatedAt,omitempty"`
+	StartedAt     *time.Time        `json:"StartedAt,omitempty"`
+}
+
+// GetTaskMetadata calls the task metadata endpoint to retrieve the metadata of the current task,
+// e.g. its family and revision or the status of its containers.
+//
+// The endpoint is MetadataEndpointOverride if set, and resolved by the MetadataEndpointResolver set
+// with WithMetadataEndpointResolver otherwise, defaulting to EnvMetadataEndpointResolver. If it
+// cannot be found or the endpoint was unreachable, the error matches ErrMetadataUnavailable.
+//
+// Each request times out after DefaultMetadataTimeout, or the timeout set with
+// WithMetadataTimeout. Requests failing transiently, i.e. with a connection error, a timeout or a
+// server error, are attempted up to 3 times, or according to the policy set with WithRetry. A
+// response that isn't a JSON metadata document results in a *MetadataDecodeError describing it.
+// The Cluster is normalized as by GetTaskArn.
+func (c *Client) GetTaskMetadata(ctx context.Context) (*TaskMetadata, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	c.mu.RLock()
+	ecsMetadataEndpoint := c.MetadataEndpointOverride
+	c.mu.RUnlock()
+
+	if ecsMetadataEndpoint == "" {
+		resolver := c.metadataResolver
+		if resolver == nil {
+			resolver = EnvMetadataEndpointResolver{}
+		}
+		var err error
+		if ecsMetadataEndpoint, err = resolver.ResolveMetadataEndpoint(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return retryCall(ctx, c.metadataRetry(), func(ctx context.Context) (*TaskMetadata, error) {
+		return c.fetchMetadata(ctx, ecsMetadataEndpoint)
+	})
+}
+
+// fetchMetadata requests the task metadata from endpoint.
+func (c *Client) fetchMetadata(ctx context.Context, endpoint string) (*TaskMetadata, error) {
+	timeout := c.metadataTimeout
+	if timeout <= 0 {
+		timeout = DefaultMetadataTimeout
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, "GET", endpoint+"/task", nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+			return nil, err
+		case reqCtx.Err() != nil:
+			return nil, fmt.Errorf("%w: request timed out after %s: %w", ErrMetadataUnavailable, timeout, err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrMetadataUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, newMetadataDecodeError(res, b, fmt.Errorf("unexpected status %s", res.Status))
+	}
+	var metadata *TaskMetadata
+	if err = json.Unmarshal(b, &metadata); err != nil {
+		return nil, newMetadataDecodeError(res, b, err)
+	}
+	if metadata == nil {
+		return nil, newMetadataDecodeError(res, b, errors.New("empty metadata document"))
+	}
+	metadata.MetadataBody = *normalizeMetadata(&metadata.MetadataBody)
+
+	return metadata, nil
+}
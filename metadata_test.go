@@ -0,0 +1,213 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fargateTaskMetadata = `{
+	"Cluster": "arn:aws:ecs:eu-west-2:123456789012:cluster/test_cluster",
+	"TaskARN": "arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/0123456789abcdef",
+	"Family": "worker",
+	"Revision": "7",
+	"DesiredStatus": "RUNNING",
+	"KnownStatus": "RUNNING",
+	"Limits": {"CPU": 0.25, "Memory": 512},
+	"PullStartedAt": "2024-05-01T11:59:50.000000000Z",
+	"AvailabilityZone": "eu-west-2a",
+	"LaunchType": "FARGATE",
+	"Containers": [
+		{
+			"DockerId": "0123456789abcdef-1234567890",
+			"Name": "worker",
+			"DockerName": "worker",
+			"Image": "123456789012.dkr.ecr.eu-west-2.amazonaws.com/worker:latest",
+			"ImageID": "sha256:0123",
+			"Labels": {"com.amazonaws.ecs.task-definition-family": "worker"},
+			"DesiredStatus": "RUNNING",
+			"KnownStatus": "RUNNING",
+			"Limits": {"CPU": 2},
+			"CreatedAt": "2024-05-01T12:00:00.000000000Z",
+			"StartedAt": "2024-05-01T12:00:01.000000000Z",
+			"Type": "NORMAL",
+			"ContainerARN": "arn:aws:ecs:eu-west-2:123456789012:container/test_cluster/0123456789abcdef/1234"
+		}
+	]
+}`
+
+func TestClient_GetTaskMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, fargateTaskMetadata)
+	}))
+	defer ts.Close()
+
+	c := NewClient(nil, WithMetadataEndpoint(ts.URL))
+
+	got, err := c.GetTaskMetadata(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:ecs:eu-west-2:123456789012:cluster/test_cluster", got.Cluster)
+	assert.Equal(t, "worker", got.Family)
+	assert.Equal(t, "7", got.Revision)
+	assert.Equal(t, "RUNNING", got.KnownStatus)
+	assert.Equal(t, "FARGATE", got.LaunchType)
+	assert.Equal(t, &Limits{CPU: 0.25, Memory: 512}, got.Limits)
+	if assert.Len(t, got.Containers, 1) {
+		container := got.Containers[0]
+		assert.Equal(t, "worker", container.Name)
+		assert.Equal(t, "NORMAL", container.Type)
+		assert.Equal(t, "worker", container.Labels["com.amazonaws.ecs.task-definition-family"])
+		assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 1, 0, time.UTC), *container.StartedAt)
+	}
+
+	body, err := c.GetTaskArn(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &got.MetadataBody, body)
+}
+
+func TestClient_GetTaskMetadata_Endpoint(t *testing.T) {
+	newServer := func(cluster string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"Cluster": "%s", "TaskARN": "test_arn"}`, cluster)
+		}))
+	}
+	v4, v3, resolved, override := newServer("v4"), newServer("v3"), newServer("resolved"), newServer("override")
+	for _, ts := range []*httptest.Server{v4, v3, resolved, override} {
+		defer ts.Close()
+	}
+	resolver := MetadataEndpointResolverFunc(func(context.Context) (string, error) {
+		return resolved.URL, nil
+	})
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		opts        []Option
+		wantCluster string
+	}{
+		{
+			name:        "should prefer the v4 endpoint",
+			env:         map[string]string{EnvMetadataURIV4: v4.URL, EnvMetadataURIV3: v3.URL},
+			wantCluster: "v4",
+		},
+		{
+			name:        "should fall back to the v3 endpoint",
+			env:         map[string]string{EnvMetadataURIV3: v3.URL},
+			wantCluster: "v3",
+		},
+		{
+			name:        "should fall back to the v3 endpoint if the v4 one is empty",
+			env:         map[string]string{EnvMetadataURIV4: "", EnvMetadataURIV3: v3.URL},
+			wantCluster: "v3",
+		},
+		{
+			name:        "should use the configured resolver",
+			env:         map[string]string{EnvMetadataURIV4: v4.URL},
+			opts:        []Option{WithMetadataEndpointResolver(resolver)},
+			wantCluster: "resolved",
+		},
+		{
+			name:        "should prefer the endpoint override",
+			opts:        []Option{WithMetadataEndpointResolver(resolver), WithMetadataEndpoint(override.URL)},
+			wantCluster: "override",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{EnvMetadataURIV4, EnvMetadataURIV3} {
+				t.Setenv(env, "")
+				if value, ok := tt.env[env]; ok {
+					os.Setenv(env, value)
+				} else {
+					os.Unsetenv(env)
+				}
+			}
+
+			got, err := NewClient(nil, tt.opts...).GetTaskMetadata(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCluster, got.Cluster)
+		})
+	}
+}
+
+func TestClient_GetTaskMetadata_Transient(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []int
+		opts      []Option
+		wantCalls int32
+		wantErr   error
+	}{
+		{
+			name:      "should retry server errors",
+			responses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusOK},
+			wantCalls: 3,
+		},
+		{
+			name:      "should retry slow requests",
+			responses: []int{0, http.StatusOK},
+			opts:      []Option{WithMetadataTimeout(50 * time.Millisecond)},
+			wantCalls: 2,
+		},
+		{
+			name:      "should give up after 3 attempts",
+			responses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK},
+			wantCalls: 3,
+		},
+		{
+			name:      "should time out slow requests",
+			responses: []int{0, 0, 0},
+			opts:      []Option{WithMetadataTimeout(50 * time.Millisecond)},
+			wantCalls: 3,
+			wantErr:   ErrMetadataUnavailable,
+		},
+		{
+			name:      "should not retry client errors",
+			responses: []int{http.StatusNotFound, http.StatusOK},
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch status := tt.responses[calls.Add(1)-1]; status {
+				case 0:
+					// slower than the timeout of the request
+					select {
+					case <-r.Context().Done():
+					case <-time.After(time.Second):
+					}
+				case http.StatusOK:
+					fmt.Fprint(w, `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`)
+				default:
+					w.WriteHeader(status)
+				}
+			}))
+			defer server.Close()
+
+			opts := append([]Option{WithMetadataEndpoint(server.URL)}, tt.opts...)
+			got, err := NewClient(nil, opts...).GetTaskMetadata(context.Background())
+			assert.Equal(t, tt.wantCalls, calls.Load())
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.responses[tt.wantCalls-1] != http.StatusOK:
+				var decodeErr *MetadataDecodeError
+				assert.ErrorAs(t, err, &decodeErr)
+			default:
+				if assert.NoError(t, err) {
+					assert.Equal(t, "test_arn", got.TaskARN)
+				}
+			}
+		})
+	}
+}
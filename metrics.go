@@ -0,0 +1,158 @@
+package ecstp
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// MetricProtectionExpiringWithWork is published by an ExpiryWatch while protection is about to
+// expire and work is still in flight, the condition that precedes a task being terminated
+// mid-work by scale-in.
+const MetricProtectionExpiringWithWork = "ProtectionExpiringWithWorkInFlight"
+
+const (
+	// DefaultExpiryThreshold is the default remaining protection time below which an ExpiryWatch
+	// reports expiring protection.
+	DefaultExpiryThreshold = 5 * time.Minute
+	// DefaultExpiryWatchInterval is the default interval between ExpiryWatch checks.
+	DefaultExpiryWatchInterval = 30 * time.Second
+)
+
+// MetricDatum is a single metric data point.
+type MetricDatum struct {
+	Name       string
+	Value      float64
+	Unit       string
+	Dimensions map[string]string
+	Time       time.Time
+	// Labels are correlation labels of the protection the datum describes, e.g. to record as
+	// exemplars. Unlike Dimensions, they aren't published to CloudWatch.
+	Labels map[string]string
+}
+
+// MetricsSink receives metric data points.
+type MetricsSink interface {
+	PutMetrics(ctx context.Context, data []MetricDatum) error
+}
+
+// MetricDataPutter puts metric data to CloudWatch. It is typically implemented by a thin wrapper
+// around the PutMetricData call of a CloudWatch client.
+type MetricDataPutter interface {
+	PutMetricData(ctx context.Context, namespace string, data []MetricDatum) error
+}
+
+// CloudWatchMetricsSink is a MetricsSink publishing to a CloudWatch namespace.
+type CloudWatchMetricsSink struct {
+	Client    MetricDataPutter
+	Namespace string
+}
+
+// PutMetrics implements MetricsSink.
+func (s *CloudWatchMetricsSink) PutMetrics(ctx context.Context, data []MetricDatum) error {
+	return s.Client.PutMetricData(ctx, s.Namespace, data)
+}
+
+// WorkGauge counts units of work in flight. It's safe for concurrent use.
+type WorkGauge struct {
+	n atomic.Int64
+}
+
+// Add adds delta, which may be negative, to the gauge.
+func (g *WorkGauge) Add(delta int64) {
+	g.n.Add(delta)
+}
+
+// Value returns the current amount of work in flight.
+func (g *WorkGauge) Value() int64 {
+	return g.n.Load()
+}
+
+// ExpiryWatch publishes MetricProtectionExpiringWithWork to Sink whenever the protection tracked
+// by Manager expires within Threshold while Work is non-zero, so an alarm can fire before ECS is
+// allowed to terminate the task mid-work.
+//
+// The metric has the value 1 with the Count unit and a ClusterName dimension. Nothing is published
+// while the condition doesn't hold, so alarms should treat missing data as not breaching.
+type ExpiryWatch struct {
+	Manager *Manager
+	Sink    MetricsSink
+	// Work reports the amount of work in flight, e.g. WorkGauge.Value.
+	Work func() int64
+	// Threshold is the remaining protection time below which the metric is published. Defaults
+	// to DefaultExpiryThreshold.
+	Threshold time.Duration
+	// Interval is the time between checks. Defaults to DefaultExpiryWatchInterval.
+	Interval time.Duration
+	Logger   *slog.Logger
+}
+
+// Run checks the condition every Interval until ctx is done.
+func (w *ExpiryWatch) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultExpiryWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.check(ctx, time.Now()); err != nil {
+			w.logger().WarnContext(ctx, "unable to publish protection expiry metric", slog.Any("error", err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// check publishes the metric if protection expires within the threshold of now while work is in
+// flight.
+func (w *ExpiryWatch) check(ctx context.Context, now time.Time) error {
+	state := w.Manager.State()
+	if !state.Protected || state.ExpiresAt == nil {
+		return nil
+	}
+
+	threshold := w.Threshold
+	if threshold <= 0 {
+		threshold = DefaultExpiryThreshold
+	}
+	remaining := state.ExpiresAt.Sub(now)
+	if remaining > threshold {
+		return nil
+	}
+
+	work := w.Work()
+	if work <= 0 {
+		return nil
+	}
+
+	w.logger().WarnContext(ctx, "task protection about to expire with work in flight",
+		slog.String("task_arn", state.TaskARN),
+		slog.Duration("remaining", remaining),
+		slog.Int64("work", work),
+		labelsAttr(state.Labels),
+	)
+
+	return w.Sink.PutMetrics(ctx, []MetricDatum{{
+		Name:       MetricProtectionExpiringWithWork,
+		Value:      1,
+		Unit:       "Count",
+		Dimensions: map[string]string{"ClusterName": state.Cluster},
+		Time:       now.UTC(),
+		Labels:     state.Labels,
+	}})
+}
+
+func (w *ExpiryWatch) logger() *slog.Logger {
+	if w.Logger == nil {
+		return slog.Default()
+	}
+
+	return w.Logger
+}
@@ -0,0 +1,91 @@
+package ecstp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testMetricsSink struct {
+	data []MetricDatum
+}
+
+func (s *testMetricsSink) PutMetrics(ctx context.Context, data []MetricDatum) error {
+	s.data = append(s.data, data...)
+	return nil
+}
+
+func TestExpiryWatch_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		protect     bool
+		expiresIn   int32
+		work        int64
+		wantPublish bool
+	}{
+		{
+			name:        "should publish when protection expires soon with work in flight",
+			protect:     true,
+			expiresIn:   3,
+			work:        2,
+			wantPublish: true,
+		},
+		{
+			name:      "should not publish without work in flight",
+			protect:   true,
+			expiresIn: 3,
+		},
+		{
+			name:      "should not publish while protection is far from expiring",
+			protect:   true,
+			expiresIn: 60,
+			work:      1,
+		},
+		{
+			name: "should not publish while unprotected",
+			work: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// dry run synthesizes the expiry of the protection
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			client := NewClient(&SuccessfulTestClient{}, WithDryRun(), WithLogger(logger))
+			m := NewManager(client, &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+			if tt.protect {
+				_, err := m.Protect(context.Background(), aws.Int32(tt.expiresIn))
+				require.NoError(t, err)
+			}
+
+			var gauge WorkGauge
+			gauge.Add(tt.work)
+			sink := &testMetricsSink{}
+			w := &ExpiryWatch{Manager: m, Sink: sink, Work: gauge.Value, Logger: logger}
+
+			require.NoError(t, w.check(context.Background(), time.Now()))
+
+			if !tt.wantPublish {
+				assert.Empty(t, sink.data)
+				return
+			}
+			require.Len(t, sink.data, 1)
+			assert.Equal(t, MetricProtectionExpiringWithWork, sink.data[0].Name)
+			assert.Equal(t, float64(1), sink.data[0].Value)
+			assert.Equal(t, "test_cluster", sink.data[0].Dimensions["ClusterName"])
+		})
+	}
+}
+
+func TestWorkGauge(t *testing.T) {
+	var g WorkGauge
+	g.Add(3)
+	g.Add(-1)
+
+	assert.Equal(t, int64(2), g.Value())
+}
@@ -0,0 +1,217 @@
+package ecstp
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Option configures a Client created with NewClient.
+type Option func(*Client)
+
+// WithDryRun makes the Client log the protection updates it would perform, including the target
+// Task ARN and computed expiry, without calling the ECS API.
+func WithDryRun() Option {
+	return func(c *Client) {
+		c.dryRun = true
+	}
+}
+
+// WithMetadataEndpoint sets the task metadata endpoint used instead of
+// ECS_CONTAINER_METADATA_URI_V4, like MetadataEndpointOverride.
+func WithMetadataEndpoint(endpoint string) Option {
+	return func(c *Client) {
+		c.MetadataEndpointOverride = endpoint
+	}
+}
+
+// WithMetadataEndpointResolver sets the resolver of the task metadata endpoint, used unless
+// MetadataEndpointOverride is set. Defaults to EnvMetadataEndpointResolver.
+func WithMetadataEndpointResolver(resolver MetadataEndpointResolver) Option {
+	return func(c *Client) {
+		c.metadataResolver = resolver
+	}
+}
+
+// WithTimeout bounds every call of GetTaskArn, UpdateTaskProtection and GetTaskProtection,
+// including the metadata request, verification and other calls made on their behalf, by timeout.
+// Calls aren't bounded by default, beyond their context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithFailOnFailures makes UpdateTaskProtection return a *ProtectionFailureError when ECS reports a
+// failure for the task, e.g. because it's not running, instead of only reporting it in the Failures
+// of the output.
+func WithFailOnFailures() Option {
+	return func(c *Client) {
+		c.failOnFailures = true
+	}
+}
+
+// WithMetadataTimeout sets the timeout of a single task metadata request, which is retried if it
+// times out. Defaults to DefaultMetadataTimeout.
+func WithMetadataTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.metadataTimeout = timeout
+	}
+}
+
+// WithLogger sets the logger used by the Client. Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithAuditor sets an Auditor that receives a record of every protection update attempted by the
+// Client.
+func WithAuditor(auditor Auditor) Option {
+	return func(c *Client) {
+		c.auditor = auditor
+	}
+}
+
+// WithCredentials sets the credentials used to sign ECS calls made by the Client, overriding the
+// credentials configured on the ECS client. Credentials can also be overridden per call via
+// UpdateTaskProtectionInput.
+func WithCredentials(provider aws.CredentialsProvider) Option {
+	return func(c *Client) {
+		c.credentials = provider
+	}
+}
+
+// WithAPIOptions registers smithy middleware on every ECS call made by the Client, e.g. to add
+// headers, mirror requests or customize signing, without having to construct the ECS client.
+func WithAPIOptions(fns ...func(*middleware.Stack) error) Option {
+	return func(c *Client) {
+		c.apiOptions = append(c.apiOptions, fns...)
+	}
+}
+
+// WithHTTPClient sets the HTTP client used by the Client, e.g. to route calls through a proxy,
+// customize TLS or trace requests. It's used for task metadata requests and every ECS call made by
+// the Client, and by NewDefaultClient to load the AWS configuration. Defaults to
+// http.DefaultClient for metadata requests and the ECS client's own HTTP client otherwise.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// WithConfigOptions customizes the loading of the default AWS configuration by NewDefaultClient,
+// e.g. to select a shared config profile or set the region. It has no effect on a Client created
+// with NewClient.
+func WithConfigOptions(fns ...func(*config.LoadOptions) error) Option {
+	return func(c *Client) {
+		c.loadOptions = append(c.loadOptions, fns...)
+	}
+}
+
+// WithRequiredTag makes the Client verify that the task, or the service that started it, is tagged
+// with key=value before enabling protection, so platform policy can restrict which workloads may
+// block scale-in. Requires an ECS client implementing TagLister, and TaskDescriber for service tags.
+func WithRequiredTag(key, value string) Option {
+	return func(c *Client) {
+		c.requiredTag = &requiredTag{key: key, value: value}
+	}
+}
+
+// WithQuota caps the number of tasks that may be protected simultaneously in a cluster, as tracked
+// by store. Protection requests beyond limit fail with a *QuotaExceededError.
+func WithQuota(store QuotaStore, limit int) Option {
+	return func(c *Client) {
+		c.quota = &quota{store: store, limit: limit}
+	}
+}
+
+// WithCalendar restricts when protection may be held to the windows allowed by cal. Protection
+// requested outside them fails with an error wrapping ErrOutsideWindow, and a Manager releases
+// protection it holds once the allowed window ends.
+func WithCalendar(cal *Calendar) Option {
+	return func(c *Client) {
+		c.calendar = cal
+	}
+}
+
+// WithMaxContinuousProtection caps how long a Manager holds protection continuously, as a backstop
+// for leases that are acquired and never released. Once max has passed since protection was
+// enabled, the Manager stops renewing it, publishes EventMaxProtectionReached and releases it;
+// renewals then fail with an error wrapping ErrMaxContinuousProtection.
+func WithMaxContinuousProtection(max time.Duration) Option {
+	return func(c *Client) {
+		c.maxContinuous = max
+	}
+}
+
+// WithBlackout makes the Client consult source before every protect or renewal, e.g. to let a
+// deployment roll out without waiting for protected tasks. During a blackout, the protection period
+// is capped to expiresInMinutes, or protection is refused with an error wrapping
+// ErrDeploymentBlackout if expiresInMinutes is 0. If source fails, protection is enabled as
+// requested.
+func WithBlackout(source BlackoutSource, expiresInMinutes int32) Option {
+	return func(c *Client) {
+		c.blackout = &blackout{source: source, expiresInMinutes: expiresInMinutes}
+	}
+}
+
+// WithInstanceProtection makes the Client set the scale-in protection of the task's container
+// instance in the Auto Scaling group autoScalingGroupName in lockstep with the task's protection,
+// for tasks on the EC2 launch type whose instances would otherwise be reaped by the group.
+//
+// If instanceID is empty, it is looked up via the EC2 instance metadata service. Disabling task
+// protection also disables the instance's scale-in protection, so this should only be used when a
+// single protectable task runs per instance. It's skipped for ECS Anywhere tasks, whose external
+// instances aren't part of an Auto Scaling group.
+func WithInstanceProtection(setter InstanceProtectionSetter, autoScalingGroupName, instanceID string) Option {
+	return func(c *Client) {
+		c.instanceProtection = &instanceProtection{
+			setter:     setter,
+			group:      autoScalingGroupName,
+			instanceID: instanceID,
+		}
+	}
+}
+
+// WithRetry makes the Client retry the ECS calls of UpdateTaskProtection and GetTaskProtection, and
+// task metadata requests, with policy, e.g. to ride out throttling when many tasks update their
+// protection at once. Unless policy.Retryable is set, ECS calls are retried for errors IsRetryable
+// reports as retryable, and metadata requests for connection errors and server errors of the
+// metadata endpoint. Retries stop when the context of the call is done, or its deadline would pass
+// while waiting. The retryer of the ECS client is disabled for these calls, so policy.MaxAttempts
+// is the total number of attempts.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = &policy
+	}
+}
+
+// WithVerification makes the Client re-read the task's protection via GetTaskProtection after every
+// update, retrying with policy until ECS reports the requested state, for callers who must be
+// certain protection is in effect before starting irreversible work. If it doesn't converge, the
+// ECS output of the update is returned along with an error wrapping ErrNotConverged. Requires an
+// ECS client implementing TaskProtectionGetter.
+//
+// Unless policy.Retryable is set, reads are retried while protection hasn't converged and for
+// errors IsRetryable reports as retryable.
+func WithVerification(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.verification = &policy
+	}
+}
+
+// WithAgentEndpoint makes the Client update and read the protection of its task via the task
+// protection endpoint of the ECS agent, see AgentClient, so the task role doesn't need ECS API
+// permissions. Calls the endpoint can't serve, e.g. outside ECS, on an agent predating it, for
+// other tasks or with per-call Credentials, are made with the ECS client instead, if any.
+func WithAgentEndpoint() Option {
+	return func(c *Client) {
+		c.agent = &AgentClient{}
+	}
+}
@@ -0,0 +1,158 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// restoreExpiryRounding is the granularity of the protection periods set by RestoreOverride.
+const restoreExpiryRounding = 5 * time.Minute
+
+// SavedProtection is the protection of a task at a point in time, as recorded by
+// Remote.ApplyOverride.
+type SavedProtection struct {
+	Cluster   string     `json:"cluster"`
+	TaskARN   string     `json:"taskArn"`
+	Protected bool       `json:"protected"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Error is set if the protection of the task couldn't be read, in which case it's left
+	// unchanged.
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+// FleetOverride is a temporary change of the protection of tasks, e.g. protecting every task of a
+// service during an incident, along with the protection of the tasks before it was applied. It
+// marshals to JSON, so that it can be restored by another process.
+type FleetOverride struct {
+	Protect          bool              `json:"protect"`
+	ExpiresInMinutes *int32            `json:"expiresInMinutes,omitempty"`
+	AppliedAt        time.Time         `json:"appliedAt"`
+	Prior            []SavedProtection `json:"prior"`
+}
+
+// ApplyOverride records the protection of tasks, then protects them, optionally expiring after
+// expiresInMinutes, or unprotects them. Tasks whose protection can't be read are left unchanged.
+// The override is returned along with the state of every changed task, and the errors of the
+// tasks that failed joined together. It requires an ECS client implementing TaskProtectionGetter.
+func (r *Remote) ApplyOverride(
+	ctx context.Context, tasks []MetadataBody, protect bool, expiresInMinutes *int32,
+) (FleetOverride, []State, error) {
+	override := FleetOverride{Protect: protect, ExpiresInMinutes: expiresInMinutes}
+	if !protect {
+		override.ExpiresInMinutes = nil
+	}
+
+	prior, err := r.save(ctx, tasks)
+	if err != nil {
+		return override, nil, err
+	}
+	override.Prior = prior
+	override.AppliedAt = time.Now().UTC()
+
+	var readable []MetadataBody
+	for _, saved := range prior {
+		if saved.Error == nil {
+			readable = append(readable, MetadataBody{Cluster: saved.Cluster, TaskARN: saved.TaskARN})
+		}
+	}
+	if len(readable) == 0 {
+		return override, nil, nil
+	}
+	states, err := r.update(ctx, readable, protect, override.ExpiresInMinutes)
+
+	return override, states, err
+}
+
+// RestoreOverride restores the protection of the tasks of override as it was before it was
+// applied: tasks that were protected are protected again until their original expiry rounded up
+// to 5 minutes, if it hasn't passed, and others are unprotected. The state of every restored task is returned, along
+// with the errors of the tasks that failed joined together. Tasks are updated as by Protect and
+// Unprotect.
+func (r *Remote) RestoreOverride(ctx context.Context, override FleetOverride) ([]State, error) {
+	return r.restore(ctx, override.Prior, time.Now())
+}
+
+// save returns the current protection of tasks.
+func (r *Remote) save(ctx context.Context, tasks []MetadataBody) ([]SavedProtection, error) {
+	states, err := r.Status(ctx, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make([]SavedProtection, len(states))
+	for i, state := range states {
+		saved[i] = SavedProtection{
+			Cluster:   state.Cluster,
+			TaskARN:   state.TaskARN,
+			Protected: state.Protected,
+			ExpiresAt: state.ExpiresAt,
+			Error:     state.LastError,
+		}
+	}
+
+	return saved, nil
+}
+
+// restore applies the protection of saved at now, skipping tasks whose protection couldn't be read.
+// Protection that has expired by now is restored as unprotected, and other protection until its
+// expiry rounded up to restoreExpiryRounding, so that tasks of similar expiry are updated together.
+func (r *Remote) restore(ctx context.Context, saved []SavedProtection, now time.Time) ([]State, error) {
+	type restoreKey struct {
+		protect          bool
+		expiresInMinutes int32
+	}
+	var (
+		keys   []restoreKey
+		groups = map[restoreKey][]int{}
+		tasks  []MetadataBody
+	)
+	for _, task := range saved {
+		if task.Error != nil {
+			continue
+		}
+
+		key := restoreKey{expiresInMinutes: -1}
+		switch {
+		case !task.Protected || (task.ExpiresAt != nil && !task.ExpiresAt.After(now)):
+			key.protect = false
+		case task.ExpiresAt == nil:
+			key.protect = true
+		default:
+			rounding := int32(restoreExpiryRounding / time.Minute)
+			minutes := int32(math.Ceil(task.ExpiresAt.Sub(now).Minutes()))
+			minutes = (minutes + rounding - 1) / rounding * rounding
+			key.protect, key.expiresInMinutes = true, min(minutes, MaxExpiresInMinutes)
+		}
+
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], len(tasks))
+		tasks = append(tasks, MetadataBody{Cluster: task.Cluster, TaskARN: task.TaskARN})
+	}
+
+	states := make([]State, len(tasks))
+	var errs []error
+	for _, key := range keys {
+		group := make([]MetadataBody, len(groups[key]))
+		for i, task := range groups[key] {
+			group[i] = tasks[task]
+		}
+
+		var expiresInMinutes *int32
+		if key.expiresInMinutes >= 0 {
+			expiresInMinutes = &key.expiresInMinutes
+		}
+		restored, err := r.update(ctx, group, key.protect, expiresInMinutes)
+		for i, task := range groups[key] {
+			states[task] = restored[i]
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return states, errors.Join(errs...)
+}
@@ -0,0 +1,189 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ExpiryTrackingTestClient keeps the expiry of protected tasks, failing GetTaskProtection for
+// tasks listed in missing. It counts UpdateTaskProtection calls in updates.
+type ExpiryTrackingTestClient struct {
+	missing map[string]bool
+
+	mu        sync.Mutex
+	expiresAt map[string]time.Time
+	updates   int
+}
+
+func (c *ExpiryTrackingTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.updates++
+	output := &ecs.UpdateTaskProtectionOutput{}
+	for _, task := range params.Tasks {
+		protected := types.ProtectedTask{TaskArn: aws.String(task), ProtectionEnabled: params.ProtectionEnabled}
+		if params.ProtectionEnabled {
+			minutes := int32(120)
+			if params.ExpiresInMinutes != nil {
+				minutes = *params.ExpiresInMinutes
+			}
+			c.expiresAt[task] = time.Now().Add(time.Duration(minutes) * time.Minute)
+			protected.ExpirationDate = aws.Time(c.expiresAt[task])
+		} else {
+			delete(c.expiresAt, task)
+		}
+		output.ProtectedTasks = append(output.ProtectedTasks, protected)
+	}
+
+	return output, nil
+}
+
+func (c *ExpiryTrackingTestClient) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	output := &ecs.GetTaskProtectionOutput{}
+	for _, task := range params.Tasks {
+		if c.missing[task] {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(task), Reason: aws.String("MISSING")})
+			continue
+		}
+		protected := types.ProtectedTask{TaskArn: aws.String(task)}
+		if expiresAt, ok := c.expiresAt[task]; ok {
+			protected.ProtectionEnabled = true
+			protected.ExpirationDate = aws.Time(expiresAt)
+		}
+		output.ProtectedTasks = append(output.ProtectedTasks, protected)
+	}
+
+	return output, nil
+}
+
+// Remaining returns the protection time left for task, or 0 if it isn't protected.
+func (c *ExpiryTrackingTestClient) Remaining(task string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if expiresAt, ok := c.expiresAt[task]; ok {
+		return time.Until(expiresAt)
+	}
+
+	return 0
+}
+
+func TestRemote_ApplyOverride(t *testing.T) {
+	a, b, missing := remoteTestARNPrefix+"a", remoteTestARNPrefix+"b", remoteTestARNPrefix+"missing"
+	client := &ExpiryTrackingTestClient{
+		missing:   map[string]bool{missing: true},
+		expiresAt: map[string]time.Time{a: time.Now().Add(10 * time.Minute)},
+	}
+	remote := NewRemote(NewClient(client))
+	tasks := []MetadataBody{
+		{Cluster: "test_cluster", TaskARN: a},
+		{Cluster: "test_cluster", TaskARN: b},
+		{Cluster: "test_cluster", TaskARN: missing},
+	}
+
+	override, states, err := remote.ApplyOverride(context.Background(), tasks, true, aws.Int32(240))
+	require.NoError(t, err)
+	assert.Len(t, states, 2, "tasks whose protection can't be read should be left unchanged")
+	require.Len(t, override.Prior, 3)
+	assert.True(t, override.Prior[0].Protected)
+	assert.False(t, override.Prior[1].Protected)
+	assert.NotNil(t, override.Prior[2].Error)
+	assert.Greater(t, client.Remaining(a), 3*time.Hour)
+	assert.Greater(t, client.Remaining(b), 3*time.Hour)
+
+	states, err = remote.RestoreOverride(context.Background(), override)
+	require.NoError(t, err)
+	assert.Len(t, states, 2)
+	assert.InDelta(t, 10*time.Minute, client.Remaining(a), float64(time.Minute), "protection should be restored until its original expiry")
+	assert.Zero(t, client.Remaining(b), "unprotected tasks should be unprotected again")
+}
+
+func TestRemote_restore(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name          string
+		saved         SavedProtection
+		wantRemaining time.Duration
+	}{
+		{
+			name:  "should unprotect tasks whose protection has since expired",
+			saved: SavedProtection{Protected: true, ExpiresAt: aws.Time(now.Add(-time.Minute))},
+		},
+		{
+			name:          "should protect tasks without a known expiry for the ECS default",
+			saved:         SavedProtection{Protected: true},
+			wantRemaining: 2 * time.Hour,
+		},
+		{
+			name:          "should cap restored protection to the ECS maximum",
+			saved:         SavedProtection{Protected: true, ExpiresAt: aws.Time(now.Add(72 * time.Hour))},
+			wantRemaining: MaxExpiresInMinutes * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := remoteTestARNPrefix + "a"
+			client := &ExpiryTrackingTestClient{expiresAt: map[string]time.Time{task: now.Add(time.Hour)}}
+			tt.saved.Cluster, tt.saved.TaskARN = "test_cluster", task
+
+			_, err := NewRemote(NewClient(client)).restore(context.Background(), []SavedProtection{tt.saved}, now)
+
+			require.NoError(t, err)
+			assert.InDelta(t, tt.wantRemaining, client.Remaining(task), float64(time.Minute))
+		})
+	}
+}
+
+func TestRemote_restore_Batches(t *testing.T) {
+	now := time.Now()
+	client := &ExpiryTrackingTestClient{expiresAt: map[string]time.Time{}}
+	var saved []SavedProtection
+	for i := 0; i < 12; i++ {
+		saved = append(saved, SavedProtection{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + fmt.Sprint(i)})
+	}
+	saved = append(saved,
+		SavedProtection{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "protected", Protected: true},
+		SavedProtection{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "11m", Protected: true, ExpiresAt: aws.Time(now.Add(11 * time.Minute))},
+		SavedProtection{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "13m", Protected: true, ExpiresAt: aws.Time(now.Add(13 * time.Minute))},
+	)
+
+	states, err := NewRemote(NewClient(client)).restore(context.Background(), saved, now)
+
+	require.NoError(t, err)
+	require.Len(t, states, len(saved))
+	for i, state := range states {
+		assert.Equal(t, saved[i].TaskARN, state.TaskARN, "states should be in the order of the saved tasks")
+		assert.Equal(t, saved[i].Protected, state.Protected)
+	}
+	assert.Equal(t, 4, client.updates, "tasks restored to similar protection should be updated up to 10 at a time")
+	assert.InDelta(t, 15*time.Minute, client.Remaining(remoteTestARNPrefix+"11m"), float64(time.Minute))
+}
+
+func TestRemote_restore_DryRun(t *testing.T) {
+	client := &ExpiryTrackingTestClient{expiresAt: map[string]time.Time{}}
+	saved := []SavedProtection{{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "a", Protected: true}}
+
+	states, err := NewRemote(NewClient(client, WithDryRun())).restore(context.Background(), saved, time.Now())
+
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.True(t, states[0].Protected)
+	assert.Zero(t, client.updates, "restores should honour the dry-run mode of the Client")
+}
@@ -0,0 +1,72 @@
+package ecstp
+
+import "context"
+
+// Overrides adjust protection updates made with a context, see ContextWithOverrides. Unset fields
+// leave the Client's behavior unchanged.
+type Overrides struct {
+	// ExpiresInMinutes replaces the protection period of updates enabling protection.
+	ExpiresInMinutes *int32
+	// DryRun replaces whether the Client only logs updates, see WithDryRun.
+	DryRun *bool
+	// Strict makes policy checks fail closed: if the blackout source of WithBlackout can't be
+	// consulted, protection is refused instead of enabled as requested.
+	Strict *bool
+}
+
+type overridesKey struct{}
+
+// ContextWithOverrides returns a copy of ctx carrying overrides for protection updates made with
+// it, merged over any overrides ctx already carries. This lets code that knows more about the work,
+// e.g. a handler that will run unusually long, tune updates made on its behalf without access to
+// the Client's configuration.
+func ContextWithOverrides(ctx context.Context, overrides Overrides) context.Context {
+	merged, _ := OverridesFromContext(ctx)
+	if overrides.ExpiresInMinutes != nil {
+		merged.ExpiresInMinutes = overrides.ExpiresInMinutes
+	}
+	if overrides.DryRun != nil {
+		merged.DryRun = overrides.DryRun
+	}
+	if overrides.Strict != nil {
+		merged.Strict = overrides.Strict
+	}
+
+	return context.WithValue(ctx, overridesKey{}, merged)
+}
+
+// OverridesFromContext returns the overrides carried by ctx, if any.
+func OverridesFromContext(ctx context.Context) (Overrides, bool) {
+	overrides, ok := ctx.Value(overridesKey{}).(Overrides)
+
+	return overrides, ok
+}
+
+// overrideInput returns input with the expiry overridden by ctx, if it is.
+func overrideInput(ctx context.Context, input *UpdateTaskProtectionInput) *UpdateTaskProtectionInput {
+	overrides, ok := OverridesFromContext(ctx)
+	if !ok || overrides.ExpiresInMinutes == nil || !input.Protect {
+		return input
+	}
+
+	overridden := *input
+	overridden.ExpiresInMinutes = overrides.ExpiresInMinutes
+
+	return &overridden
+}
+
+// isDryRun reports whether updates made with ctx should only be logged.
+func (c *Client) isDryRun(ctx context.Context) bool {
+	if overrides, ok := OverridesFromContext(ctx); ok && overrides.DryRun != nil {
+		return *overrides.DryRun
+	}
+
+	return c.dryRun
+}
+
+// isStrict reports whether policy checks of updates made with ctx should fail closed.
+func isStrict(ctx context.Context) bool {
+	overrides, _ := OverridesFromContext(ctx)
+
+	return overrides.Strict != nil && *overrides.Strict
+}
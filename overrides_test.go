@@ -0,0 +1,103 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_UpdateTaskProtection_Overrides(t *testing.T) {
+	failing := BlackoutFunc(func(context.Context, *MetadataBody) (bool, error) { return false, errors.New("throttled") })
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		overrides   []Overrides
+		protect     bool
+		wantCalls   int
+		wantMinutes *int32
+		wantErr     error
+	}{
+		{
+			name:        "should override the expiry of updates enabling protection",
+			overrides:   []Overrides{{ExpiresInMinutes: aws.Int32(240)}},
+			protect:     true,
+			wantCalls:   1,
+			wantMinutes: aws.Int32(240),
+		},
+		{
+			name:      "should not set an expiry on updates disabling protection",
+			overrides: []Overrides{{ExpiresInMinutes: aws.Int32(240)}},
+			wantCalls: 1,
+		},
+		{
+			name:      "should override dry-run mode",
+			overrides: []Overrides{{DryRun: aws.Bool(true)}},
+			protect:   true,
+		},
+		{
+			name:        "should override dry-run mode of the client",
+			opts:        []Option{WithDryRun()},
+			overrides:   []Overrides{{DryRun: aws.Bool(false)}},
+			protect:     true,
+			wantCalls:   1,
+			wantMinutes: aws.Int32(60),
+		},
+		{
+			name:        "should protect as requested when the blackout source fails",
+			opts:        []Option{WithBlackout(failing, 0)},
+			protect:     true,
+			wantCalls:   1,
+			wantMinutes: aws.Int32(60),
+		},
+		{
+			name:      "should refuse protection when the blackout source fails in strict mode",
+			opts:      []Option{WithBlackout(failing, 0)},
+			overrides: []Overrides{{Strict: aws.Bool(true)}},
+			protect:   true,
+			wantErr:   ErrProtectionNotAllowed,
+		},
+		{
+			name: "should merge nested overrides",
+			overrides: []Overrides{
+				{ExpiresInMinutes: aws.Int32(240), DryRun: aws.Bool(true)},
+				{DryRun: aws.Bool(false)},
+			},
+			protect:     true,
+			wantCalls:   1,
+			wantMinutes: aws.Int32(240),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &ExpiryTestClient{}
+			c := NewClient(ecsClient, tt.opts...)
+			ctx := context.Background()
+			for _, overrides := range tt.overrides {
+				ctx = ContextWithOverrides(ctx, overrides)
+			}
+
+			var expiresInMinutes *int32
+			if tt.protect {
+				expiresInMinutes = aws.Int32(60)
+			}
+			_, err := c.UpdateTaskProtection(ctx, &UpdateTaskProtectionInput{
+				Metadata:         &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:          tt.protect,
+				ExpiresInMinutes: expiresInMinutes,
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, ecsClient.calls)
+			if tt.wantCalls > 0 {
+				assert.Equal(t, tt.wantMinutes, ecsClient.expiresInMinutes)
+			}
+		})
+	}
+}
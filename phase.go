@@ -0,0 +1,259 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Phase is a state of the protection state machine of a Manager.
+//
+// A Manager starts Unprotected. Protect moves it through Protecting to Protected, and renewals
+// through Renewing back to Protected, or to Degraded if a renewal fails while protection is still in
+// effect. Unprotect moves it through Releasing to Unprotected. Protection expiring while Protected
+// or Degraded, or the task stopping, moves it to Unprotected.
+type Phase string
+
+// Phases of a Manager.
+const (
+	PhaseUnprotected Phase = "unprotected"
+	// PhaseProtecting is the phase while protection is being enabled.
+	PhaseProtecting Phase = "protecting"
+	PhaseProtected  Phase = "protected"
+	// PhaseRenewing is the phase while protection in effect is being extended.
+	PhaseRenewing Phase = "renewing"
+	// PhaseDegraded is the phase once protection couldn't be renewed, or released, but is still in
+	// effect until it expires.
+	PhaseDegraded Phase = "degraded"
+	// PhaseReleasing is the phase while protection is being disabled.
+	PhaseReleasing Phase = "releasing"
+)
+
+// Trigger is an event causing a transition between phases.
+type Trigger string
+
+// Triggers of transitions between phases. TriggerProtect, TriggerRenew and TriggerRelease start an
+// update and are checked by TransitionGuards; the other triggers record its outcome or an external
+// fact.
+const (
+	TriggerProtect   Trigger = "protect"
+	TriggerRenew     Trigger = "renew"
+	TriggerRelease   Trigger = "release"
+	TriggerSucceeded Trigger = "succeeded"
+	TriggerFailed    Trigger = "failed"
+	TriggerExpired   Trigger = "expired"
+	TriggerStopping  Trigger = "stopping"
+)
+
+var (
+	// ErrInvalidTransition is returned by Phase.Next for triggers that don't apply to the phase.
+	ErrInvalidTransition = errors.New("invalid protection phase transition")
+	// ErrTransitionRefused is returned by a Manager when a TransitionGuard refuses a transition.
+	ErrTransitionRefused = errors.New("protection phase transition refused")
+)
+
+// transitions maps each phase and trigger to the next phase.
+var transitions = map[Phase]map[Trigger]Phase{
+	PhaseUnprotected: {
+		TriggerProtect:  PhaseProtecting,
+		TriggerRelease:  PhaseReleasing,
+		TriggerStopping: PhaseUnprotected,
+	},
+	PhaseProtecting: {
+		TriggerSucceeded: PhaseProtected,
+		TriggerFailed:    PhaseUnprotected,
+		TriggerStopping:  PhaseUnprotected,
+	},
+	PhaseProtected: {
+		TriggerRenew:    PhaseRenewing,
+		TriggerRelease:  PhaseReleasing,
+		TriggerExpired:  PhaseUnprotected,
+		TriggerStopping: PhaseUnprotected,
+	},
+	PhaseRenewing: {
+		TriggerSucceeded: PhaseProtected,
+		TriggerFailed:    PhaseDegraded,
+		TriggerStopping:  PhaseUnprotected,
+	},
+	PhaseDegraded: {
+		TriggerRenew:    PhaseRenewing,
+		TriggerRelease:  PhaseReleasing,
+		TriggerExpired:  PhaseUnprotected,
+		TriggerStopping: PhaseUnprotected,
+	},
+	PhaseReleasing: {
+		TriggerSucceeded: PhaseUnprotected,
+		TriggerFailed:    PhaseDegraded,
+		TriggerStopping:  PhaseUnprotected,
+	},
+}
+
+// Next returns the phase following p on trigger, or ErrInvalidTransition if trigger doesn't apply
+// to p.
+func (p Phase) Next(trigger Trigger) (Phase, error) {
+	next, ok := transitions[p][trigger]
+	if !ok {
+		return p, fmt.Errorf("%w: %s on %s", ErrInvalidTransition, p, trigger)
+	}
+
+	return next, nil
+}
+
+// Transient reports whether p only lasts for the duration of an update.
+func (p Phase) Transient() bool {
+	return p == PhaseProtecting || p == PhaseRenewing || p == PhaseReleasing
+}
+
+// Transition is a change of phase of a Manager.
+type Transition struct {
+	From    Phase     `json:"from"`
+	To      Phase     `json:"to"`
+	Trigger Trigger   `json:"trigger"`
+	Time    time.Time `json:"time"`
+}
+
+// TransitionGuard is checked before a Manager starts a transition on TriggerProtect, TriggerRenew
+// or TriggerRelease, given the state before it. Returning an error refuses the transition.
+type TransitionGuard func(ctx context.Context, t Transition, state State) error
+
+type registeredGuard struct {
+	id    uint64
+	guard TransitionGuard
+}
+
+type registeredTransitionFunc struct {
+	id uint64
+	fn func(Transition)
+}
+
+// Phase returns the current phase of m. Unlike State, it doesn't wait for an update in progress,
+// so it reports the transient phases.
+func (m *Manager) Phase() Phase {
+	m.phaseMu.Lock()
+	defer m.phaseMu.Unlock()
+
+	if (m.phase == PhaseProtected || m.phase == PhaseDegraded) && !protectedAt(m.phaseState, time.Now()) {
+		// the transition is recorded with the next update
+		return PhaseUnprotected
+	}
+
+	return m.phase
+}
+
+// AddGuard adds a guard checked before transitions starting an update, returning a function
+// removing it. When a guard refuses a transition, its error is returned wrapped with
+// ErrTransitionRefused without calling ECS.
+func (m *Manager) AddGuard(guard TransitionGuard) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextRegistrationID++
+	id := m.nextRegistrationID
+	m.guards = append(m.guards, registeredGuard{id: id, guard: guard})
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		for i, g := range m.guards {
+			if g.id == id {
+				m.guards = append(m.guards[:i:i], m.guards[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// OnTransition adds fn to be called for every transition of m, returning a function removing it.
+// Transitions are reported in order. fn is called while m is locked, so it must not call methods of
+// m other than Phase.
+func (m *Manager) OnTransition(fn func(Transition)) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextRegistrationID++
+	id := m.nextRegistrationID
+	m.transitionFuncs = append(m.transitionFuncs, registeredTransitionFunc{id: id, fn: fn})
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		for i, f := range m.transitionFuncs {
+			if f.id == id {
+				m.transitionFuncs = append(m.transitionFuncs[:i:i], m.transitionFuncs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// beginTransitionLocked records the expiry of protection, if it happened, and starts the
+// transition on trigger once the guards allow it.
+func (m *Manager) beginTransitionLocked(ctx context.Context, trigger Trigger) error {
+	m.expireLocked()
+
+	from := m.currentPhase()
+	to, err := from.Next(trigger)
+	if err != nil {
+		return err
+	}
+	t := Transition{From: from, To: to, Trigger: trigger, Time: time.Now().UTC()}
+	for _, g := range m.guards {
+		if err := g.guard(ctx, t, m.state); err != nil {
+			return fmt.Errorf("%w: %s to %s: %w", ErrTransitionRefused, from, to, err)
+		}
+	}
+	m.setPhaseLocked(t)
+
+	return nil
+}
+
+// endTransitionLocked records the outcome of an update, following a failure with the expiry of
+// protection if it's no longer in effect.
+func (m *Manager) endTransitionLocked(err error) {
+	if err != nil {
+		m.transitionLocked(TriggerFailed)
+		m.expireLocked()
+		return
+	}
+	m.transitionLocked(TriggerSucceeded)
+}
+
+// expireLocked moves m to PhaseUnprotected if protection expired while it was held.
+func (m *Manager) expireLocked() {
+	phase := m.currentPhase()
+	if (phase == PhaseProtected || phase == PhaseDegraded) && !protectedAt(m.state, time.Now()) {
+		m.transitionLocked(TriggerExpired)
+	}
+}
+
+// transitionLocked moves m to the phase following the current one on trigger, if any.
+func (m *Manager) transitionLocked(trigger Trigger) {
+	from := m.currentPhase()
+	to, err := from.Next(trigger)
+	if err != nil {
+		return
+	}
+	m.setPhaseLocked(Transition{From: from, To: to, Trigger: trigger, Time: time.Now().UTC()})
+}
+
+func (m *Manager) setPhaseLocked(t Transition) {
+	m.phaseMu.Lock()
+	m.phase = t.To
+	m.phaseState = m.state
+	m.phaseMu.Unlock()
+
+	for _, f := range m.transitionFuncs {
+		f.fn(t)
+	}
+	m.updateHealthLocked(t)
+}
+
+func (m *Manager) currentPhase() Phase {
+	m.phaseMu.Lock()
+	defer m.phaseMu.Unlock()
+
+	return m.phase
+}
@@ -0,0 +1,133 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhase_Next(t *testing.T) {
+	tests := []struct {
+		name    string
+		phase   Phase
+		trigger Trigger
+		want    Phase
+		wantErr bool
+	}{
+		{name: "should start protecting", phase: PhaseUnprotected, trigger: TriggerProtect, want: PhaseProtecting},
+		{name: "should be protected once protecting succeeds", phase: PhaseProtecting, trigger: TriggerSucceeded, want: PhaseProtected},
+		{name: "should be unprotected if protecting fails", phase: PhaseProtecting, trigger: TriggerFailed, want: PhaseUnprotected},
+		{name: "should start renewing", phase: PhaseProtected, trigger: TriggerRenew, want: PhaseRenewing},
+		{name: "should be degraded if renewing fails", phase: PhaseRenewing, trigger: TriggerFailed, want: PhaseDegraded},
+		{name: "should renew when degraded", phase: PhaseDegraded, trigger: TriggerRenew, want: PhaseRenewing},
+		{name: "should be unprotected once degraded protection expires", phase: PhaseDegraded, trigger: TriggerExpired, want: PhaseUnprotected},
+		{name: "should be degraded if releasing fails", phase: PhaseReleasing, trigger: TriggerFailed, want: PhaseDegraded},
+		{name: "should be unprotected once the task is stopping", phase: PhaseRenewing, trigger: TriggerStopping, want: PhaseUnprotected},
+		{name: "should not renew unprotected tasks", phase: PhaseUnprotected, trigger: TriggerRenew, want: PhaseUnprotected, wantErr: true},
+		{name: "should not protect while releasing", phase: PhaseReleasing, trigger: TriggerProtect, want: PhaseReleasing, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.phase.Next(tt.trigger)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTransition)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// transitionLog records the transitions of a Manager as "from -trigger-> to".
+func transitionLog(m *Manager) *[]string {
+	var log []string
+	m.OnTransition(func(t Transition) {
+		log = append(log, string(t.From)+" -"+string(t.Trigger)+"-> "+string(t.To))
+	})
+
+	return &log
+}
+
+func TestManager_OnTransition(t *testing.T) {
+	client := &ExpiringTestClient{expiry: time.Hour}
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	log := transitionLog(m)
+	ctx := context.Background()
+
+	_, err := m.Protect(ctx, nil)
+	require.NoError(t, err)
+	_, err = m.Protect(ctx, nil)
+	require.NoError(t, err)
+	client.fail.Store(true)
+	_, err = m.Protect(ctx, nil)
+	require.Error(t, err)
+	assert.Equal(t, PhaseDegraded, m.Phase())
+	client.fail.Store(false)
+	_, err = m.Unprotect(ctx)
+	require.NoError(t, err)
+	m.MarkStopping("Scaling activity initiated")
+
+	assert.Equal(t, []string{
+		"unprotected -protect-> protecting",
+		"protecting -succeeded-> protected",
+		"protected -renew-> renewing",
+		"renewing -succeeded-> protected",
+		"protected -renew-> renewing",
+		"renewing -failed-> degraded",
+		"degraded -release-> releasing",
+		"releasing -succeeded-> unprotected",
+		"unprotected -stopping-> unprotected",
+	}, *log)
+	assert.Equal(t, PhaseUnprotected, m.Phase())
+}
+
+func TestManager_Phase_Expired(t *testing.T) {
+	client := &ExpiringTestClient{expiry: 20 * time.Millisecond}
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	log := transitionLog(m)
+
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseProtected, m.Phase())
+	assert.Eventually(t, func() bool { return m.Phase() == PhaseUnprotected }, time.Second, 5*time.Millisecond)
+
+	_, err = m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"unprotected -protect-> protecting",
+		"protecting -succeeded-> protected",
+		"protected -expired-> unprotected",
+		"unprotected -protect-> protecting",
+		"protecting -succeeded-> protected",
+	}, *log)
+}
+
+func TestManager_AddGuard(t *testing.T) {
+	client := &CountingTestClient{}
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	errDraining := errors.New("still draining")
+	remove := m.AddGuard(func(ctx context.Context, t Transition, state State) error {
+		if t.Trigger == TriggerRelease {
+			return errDraining
+		}
+		return nil
+	})
+
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+	_, err = m.Unprotect(context.Background())
+	assert.ErrorIs(t, err, ErrTransitionRefused)
+	assert.ErrorIs(t, err, errDraining)
+	assert.Equal(t, PhaseProtected, m.Phase())
+	assert.Equal(t, int32(0), client.unprotects.Load(), "refused transitions should not call ECS")
+
+	remove()
+	_, err = m.Unprotect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, PhaseUnprotected, m.Phase())
+}
@@ -0,0 +1,157 @@
+package ecstp
+
+import (
+	"context"
+	"time"
+)
+
+// PlanAction is the change a Plan makes to a task.
+type PlanAction string
+
+// Actions of a Plan.
+const (
+	// PlanProtect enables protection of an unprotected task.
+	PlanProtect PlanAction = "protect"
+	// PlanExtend moves the expiry of protection in effect later.
+	PlanExtend PlanAction = "extend"
+	// PlanUnprotect disables protection of a protected task.
+	PlanUnprotect PlanAction = "unprotect"
+	// PlanNoChange leaves a task already compliant unchanged.
+	PlanNoChange PlanAction = "none"
+	// PlanInvalid marks a task the update would fail for, described by the entry's Reason.
+	PlanInvalid PlanAction = "invalid"
+)
+
+// PlanEntry is the planned change to a single task.
+type PlanEntry struct {
+	Cluster string     `json:"cluster"`
+	TaskARN string     `json:"taskArn"`
+	Action  PlanAction `json:"action"`
+	// Current is the protection of the task as reported by ECS.
+	Current State `json:"current"`
+	// ExpiresAt is when protection would expire once the plan is applied, for tasks being
+	// protected.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// Plan is the diff between the current protection of tasks and a batch protect or unprotect,
+// returned by Remote.Plan and applied by Remote.Apply.
+type Plan struct {
+	Protect          bool        `json:"protect"`
+	ExpiresInMinutes *int32      `json:"expiresInMinutes,omitempty"`
+	Entries          []PlanEntry `json:"entries"`
+}
+
+// Changes returns the entries of tasks whose protection changes.
+func (p Plan) Changes() []PlanEntry {
+	return p.filter(func(action PlanAction) bool {
+		return action == PlanProtect || action == PlanExtend || action == PlanUnprotect
+	})
+}
+
+// Compliant returns the entries of tasks already in the planned state.
+func (p Plan) Compliant() []PlanEntry {
+	return p.filter(func(action PlanAction) bool { return action == PlanNoChange })
+}
+
+// Invalid returns the entries of tasks the update would fail for.
+func (p Plan) Invalid() []PlanEntry {
+	return p.filter(func(action PlanAction) bool { return action == PlanInvalid })
+}
+
+func (p Plan) filter(keep func(PlanAction) bool) []PlanEntry {
+	var entries []PlanEntry
+	for _, entry := range p.Entries {
+		if keep(entry.Action) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// Plan queries the current protection of tasks and returns the changes that protecting them,
+// optionally expiring after expiresInMinutes, or unprotecting them would make, without making them.
+// Tasks already protected until at least the planned expiry, or already unprotected, are compliant.
+// It requires an ECS client implementing TaskProtectionGetter.
+func (r *Remote) Plan(ctx context.Context, tasks []MetadataBody, protect bool, expiresInMinutes *int32) (Plan, error) {
+	plan := Plan{Protect: protect, ExpiresInMinutes: expiresInMinutes, Entries: make([]PlanEntry, len(tasks))}
+	if !protect {
+		plan.ExpiresInMinutes = nil
+	}
+
+	// tasks UpdateTaskProtection would reject are invalid whatever their state
+	input := &UpdateTaskProtectionInput{Protect: protect, ExpiresInMinutes: plan.ExpiresInMinutes}
+	var valid []MetadataBody
+	var indexes []int
+	for i, task := range tasks {
+		plan.Entries[i] = PlanEntry{Cluster: task.Cluster, TaskARN: task.TaskARN}
+		if err := validateInput(&task, input); err != nil {
+			plan.Entries[i].Action = PlanInvalid
+			plan.Entries[i].Reason = err.Error()
+			continue
+		}
+		valid = append(valid, task)
+		indexes = append(indexes, i)
+	}
+	if len(valid) == 0 {
+		return plan, nil
+	}
+
+	states, err := r.Status(ctx, valid)
+	if err != nil {
+		return plan, err
+	}
+
+	now := time.Now()
+	expiry := DefaultExpiresInMinutes * time.Minute
+	if expiresInMinutes != nil {
+		expiry = time.Duration(*expiresInMinutes) * time.Minute
+	}
+	for j, state := range states {
+		entry := &plan.Entries[indexes[j]]
+		entry.Current = state
+		protected := protectedAt(state, now)
+		switch {
+		case state.LastError != nil:
+			entry.Action = PlanInvalid
+			entry.Reason = state.LastError.Message
+		case !protect && protected:
+			entry.Action = PlanUnprotect
+		case !protect:
+			entry.Action = PlanNoChange
+		case !protected:
+			entry.Action = PlanProtect
+			entry.ExpiresAt = planExpiry(now, expiry)
+		case state.ExpiresAt != nil && state.ExpiresAt.Before(now.Add(expiry)):
+			entry.Action = PlanExtend
+			entry.ExpiresAt = planExpiry(now, expiry)
+		default:
+			entry.Action = PlanNoChange
+			entry.ExpiresAt = state.ExpiresAt
+		}
+	}
+
+	return plan, nil
+}
+
+// Apply makes the changes of plan, leaving compliant and invalid tasks unchanged. The state of every
+// changed task is returned, along with the errors of the tasks that failed joined together.
+func (r *Remote) Apply(ctx context.Context, plan Plan) ([]State, error) {
+	var tasks []MetadataBody
+	for _, entry := range plan.Changes() {
+		tasks = append(tasks, MetadataBody{Cluster: entry.Cluster, TaskARN: entry.TaskARN})
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	return r.update(ctx, tasks, plan.Protect, plan.ExpiresInMinutes)
+}
+
+func planExpiry(now time.Time, expiry time.Duration) *time.Time {
+	expiresAt := now.Add(expiry).UTC()
+
+	return &expiresAt
+}
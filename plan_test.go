@@ -0,0 +1,111 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemote_Plan(t *testing.T) {
+	tasks := []MetadataBody{
+		{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "a"},
+		{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "b"},
+		{TaskARN: remoteTestARNPrefix + "c"},
+	}
+
+	tests := []struct {
+		name             string
+		protect          bool
+		expiresInMinutes *int32
+		wantActions      []PlanAction
+	}{
+		{
+			name:        "should protect unprotected tasks",
+			protect:     true,
+			wantActions: []PlanAction{PlanNoChange, PlanProtect, PlanInvalid},
+		},
+		{
+			name:        "should unprotect protected tasks",
+			wantActions: []PlanAction{PlanUnprotect, PlanNoChange, PlanInvalid},
+		},
+		{
+			name:             "should reject invalid protection periods",
+			protect:          true,
+			expiresInMinutes: aws.Int32(3000),
+			wantActions:      []PlanAction{PlanInvalid, PlanInvalid, PlanInvalid},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &RemoteTestClient{protected: map[string]bool{remoteTestARNPrefix + "a": true}}
+			remote := NewRemote(NewClient(client))
+
+			plan, err := remote.Plan(context.Background(), tasks, tt.protect, tt.expiresInMinutes)
+			require.NoError(t, err)
+
+			var actions []PlanAction
+			for _, entry := range plan.Entries {
+				actions = append(actions, entry.Action)
+			}
+			assert.Equal(t, tt.wantActions, actions)
+			for _, entry := range plan.Invalid() {
+				assert.NotEmpty(t, entry.Reason)
+			}
+
+			states, err := remote.Apply(context.Background(), plan)
+			require.NoError(t, err)
+			assert.Len(t, states, len(plan.Changes()), "only changes should be applied")
+			after, err := remote.Plan(context.Background(), tasks[:2], tt.protect, tt.expiresInMinutes)
+			require.NoError(t, err)
+			assert.Empty(t, after.Changes(), "no changes should remain once applied")
+		})
+	}
+}
+
+// ExpiringRemoteTestClient reports protection of RemoteTestClient as expiring in 10 minutes.
+type ExpiringRemoteTestClient struct {
+	RemoteTestClient
+}
+
+func (c *ExpiringRemoteTestClient) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	output, err := c.RemoteTestClient.GetTaskProtection(ctx, params, optFns...)
+	for i := range output.ProtectedTasks {
+		if output.ProtectedTasks[i].ProtectionEnabled {
+			output.ProtectedTasks[i].ExpirationDate = aws.Time(time.Now().Add(10 * time.Minute))
+		}
+	}
+
+	return output, err
+}
+
+func TestRemote_Plan_Extend(t *testing.T) {
+	tests := []struct {
+		name             string
+		expiresInMinutes *int32
+		want             PlanAction
+	}{
+		{name: "should extend protection expiring earlier", expiresInMinutes: aws.Int32(60), want: PlanExtend},
+		{name: "should extend protection to the ECS default", want: PlanExtend},
+		{name: "should keep protection expiring later", expiresInMinutes: aws.Int32(5), want: PlanNoChange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := MetadataBody{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "a"}
+			client := &ExpiringRemoteTestClient{RemoteTestClient{protected: map[string]bool{task.TaskARN: true}}}
+
+			plan, err := NewRemote(NewClient(client)).Plan(context.Background(), []MetadataBody{task}, true, tt.expiresInMinutes)
+
+			require.NoError(t, err)
+			require.Len(t, plan.Entries, 1)
+			assert.Equal(t, tt.want, plan.Entries[0].Action)
+			assert.NotNil(t, plan.Entries[0].ExpiresAt)
+		})
+	}
+}
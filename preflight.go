@@ -0,0 +1,174 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/smithy-go"
+)
+
+// TaskProtectionGetter is implemented by ECS clients that support the GetTaskProtection API.
+type TaskProtectionGetter interface {
+	GetTaskProtection(
+		ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+	) (*ecs.GetTaskProtectionOutput, error)
+}
+
+// preflightTaskID is the task ID used to probe UpdateTaskProtection permissions without modifying
+// the protection of a real task.
+const preflightTaskID = "ecstp-preflight-probe"
+
+// PreflightCheck is the result of checking a single IAM action.
+type PreflightCheck struct {
+	Action string
+	Passed bool
+	Detail string
+	Hint   string
+}
+
+// PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	Cluster string
+	TaskARN string
+	Checks  []PreflightCheck
+}
+
+// Passed returns true if all checks in the report passed.
+func (r *PreflightReport) Passed() bool {
+	for _, check := range r.Checks {
+		if !check.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Print writes a human readable pass/fail report to w.
+func (r *PreflightReport) Print(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "cluster: %s\ntask:    %s\n\n", r.Cluster, r.TaskARN); err != nil {
+		return err
+	}
+	for _, check := range r.Checks {
+		status := "PASS"
+		if !check.Passed {
+			status = "FAIL"
+		}
+		if _, err := fmt.Fprintf(w, "[%s] %s: %s\n", status, check.Action, check.Detail); err != nil {
+			return err
+		}
+		if check.Hint != "" {
+			if _, err := fmt.Fprintf(w, "       hint: %s\n", check.Hint); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Preflight checks that the current credentials are allowed to call ecs:GetTaskProtection and
+// ecs:UpdateTaskProtection for the task described by metadata (or the current task if nil).
+//
+// ecs:GetTaskProtection is checked against the task itself. ecs:UpdateTaskProtection is checked by
+// disabling protection on a non-existent task in the same cluster, which is evaluated against the
+// same resource and ecs:cluster condition but never changes the protection of a real task. An error
+// is only returned if the task metadata can't be retrieved.
+func (c *Client) Preflight(ctx context.Context, metadata *MetadataBody) (*PreflightReport, error) {
+	if metadata == nil {
+		var err error
+		metadata, err = c.GetTaskArn(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := &PreflightReport{
+		Cluster: metadata.Cluster,
+		TaskARN: metadata.TaskARN,
+	}
+
+	getCheck := PreflightCheck{Action: "ecs:GetTaskProtection"}
+	if getter, ok := c.ECSClient.(TaskProtectionGetter); ok {
+		_, err := getter.GetTaskProtection(ctx, &ecs.GetTaskProtectionInput{
+			Cluster: aws.String(metadata.Cluster),
+			Tasks:   []string{metadata.TaskARN},
+		}, c.ecsOptions(nil)...)
+		getCheck = preflightCheck(getCheck.Action, metadata, err)
+	} else {
+		getCheck.Detail = "skipped: ECS client does not implement GetTaskProtection"
+		getCheck.Passed = true
+	}
+	report.Checks = append(report.Checks, getCheck)
+
+	_, err := c.ECSClient.UpdateTaskProtection(ctx, &ecs.UpdateTaskProtectionInput{
+		Cluster:           aws.String(metadata.Cluster),
+		Tasks:             []string{probeTaskARN(metadata.TaskARN)},
+		ProtectionEnabled: false,
+	}, c.ecsOptions(nil)...)
+	report.Checks = append(report.Checks, preflightCheck("ecs:UpdateTaskProtection", metadata, err))
+
+	return report, nil
+}
+
+// probeTaskARN replaces the task ID of taskARN so that it refers to a task in the same cluster
+// that doesn't exist.
+func probeTaskARN(taskARN string) string {
+	i := strings.LastIndex(taskARN, "/")
+
+	return taskARN[:i+1] + preflightTaskID
+}
+
+func preflightCheck(action string, metadata *MetadataBody, err error) PreflightCheck {
+	check := PreflightCheck{Action: action}
+
+	var apiErr smithy.APIError
+	switch {
+	case err == nil:
+		check.Passed = true
+		check.Detail = "allowed"
+	case errors.As(err, &apiErr) && isAccessDenied(apiErr.ErrorCode()):
+		check.Detail = "denied: " + sanitizeMessage(apiErr.ErrorMessage())
+		check.Hint = fmt.Sprintf(
+			"allow %s on arn:aws:ecs:<region>:<account>:task/<cluster>/* in the task role policy; "+
+				"if the statement has an ecs:cluster condition it must match %q",
+			action, metadata.Cluster,
+		)
+	case errors.As(err, &apiErr) && isInvalidCredentials(apiErr.ErrorCode()):
+		check.Detail = "credentials rejected: " + apiErr.ErrorCode()
+		check.Hint = "check that the task role is attached and the container can reach the credentials endpoint"
+	case errors.As(err, &apiErr):
+		// any other API error means the request was authorized before being rejected
+		check.Passed = true
+		check.Detail = "allowed (request rejected with " + apiErr.ErrorCode() + ")"
+	default:
+		check.Detail = "unable to call ECS: " + sanitizeMessage(err.Error())
+		check.Hint = "check network access to the ECS API endpoint and the configured region"
+	}
+
+	return check
+}
+
+func isAccessDenied(code string) bool {
+	switch code {
+	case "AccessDeniedException", "AccessDenied", "UnauthorizedOperation":
+		return true
+	}
+
+	return false
+}
+
+func isInvalidCredentials(code string) bool {
+	switch code {
+	case "UnrecognizedClientException", "InvalidSignatureException", "ExpiredTokenException",
+		"InvalidClientTokenId":
+		return true
+	}
+
+	return false
+}
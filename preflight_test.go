@@ -0,0 +1,115 @@
+package ecstp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type PreflightTestClient struct {
+	getErr      error
+	updateErr   error
+	updateTasks []string
+}
+
+func (c *PreflightTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.updateTasks = params.Tasks
+	if params.ProtectionEnabled {
+		return nil, errors.New("preflight must not enable protection")
+	}
+	return &ecs.UpdateTaskProtectionOutput{}, c.updateErr
+}
+
+func (c *PreflightTestClient) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	return &ecs.GetTaskProtectionOutput{}, c.getErr
+}
+
+func TestClient_Preflight(t *testing.T) {
+	tests := []struct {
+		name       string
+		client     *PreflightTestClient
+		wantPassed []bool
+	}{
+		{
+			name:       "should pass when both actions are allowed",
+			client:     &PreflightTestClient{},
+			wantPassed: []bool{true, true},
+		},
+		{
+			name: "should fail UpdateTaskProtection when access is denied",
+			client: &PreflightTestClient{
+				updateErr: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"},
+			},
+			wantPassed: []bool{true, false},
+		},
+		{
+			name: "should fail GetTaskProtection when credentials are rejected",
+			client: &PreflightTestClient{
+				getErr: &smithy.GenericAPIError{Code: "ExpiredTokenException"},
+			},
+			wantPassed: []bool{false, true},
+		},
+		{
+			name: "should pass when the request is authorized but rejected",
+			client: &PreflightTestClient{
+				updateErr: &smithy.GenericAPIError{Code: "InvalidParameterException"},
+			},
+			wantPassed: []bool{true, true},
+		},
+		{
+			name: "should fail when ECS can't be reached",
+			client: &PreflightTestClient{
+				getErr:    errors.New("dial tcp: timeout"),
+				updateErr: errors.New("dial tcp: timeout"),
+			},
+			wantPassed: []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(tt.client)
+			report, err := c.Preflight(context.Background(), &MetadataBody{
+				Cluster: "test_cluster",
+				TaskARN: "arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/abc",
+			})
+			if !assert.NoError(t, err) || !assert.Len(t, report.Checks, 2) {
+				return
+			}
+
+			wantAll := true
+			for i, want := range tt.wantPassed {
+				assert.Equal(t, want, report.Checks[i].Passed, report.Checks[i].Detail)
+				if !want {
+					wantAll = false
+					assert.NotEmpty(t, report.Checks[i].Hint)
+				}
+			}
+			assert.Equal(t, wantAll, report.Passed())
+			assert.Equal(t, []string{
+				"arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/" + preflightTaskID,
+			}, tt.client.updateTasks)
+
+			var buf bytes.Buffer
+			assert.NoError(t, report.Print(&buf))
+			assert.Contains(t, buf.String(), "ecs:UpdateTaskProtection")
+		})
+	}
+}
+
+func TestClient_Preflight_WithoutGetTaskProtection(t *testing.T) {
+	c := NewClient(&SuccessfulTestClient{})
+	report, err := c.Preflight(context.Background(), &MetadataBody{Cluster: "test_cluster", TaskARN: "test"})
+	if assert.NoError(t, err) && assert.Len(t, report.Checks, 2) {
+		assert.True(t, report.Passed())
+		assert.Contains(t, report.Checks[0].Detail, "skipped")
+	}
+}
@@ -0,0 +1,130 @@
+package ecstp
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// EnvProfile is the environment variable read by WithProfileFromEnv.
+const EnvProfile = "ECSTP_PROFILE"
+
+// Profile bundles settings suited to a workload shape. Zero fields keep the library defaults.
+//
+// The preset profiles can be adjusted before use:
+//
+//	profile := ecstp.ProfileBatch
+//	profile.Expiry = 2 * time.Hour
+//	client := ecstp.NewClient(ecsClient, ecstp.WithProfile(profile))
+type Profile struct {
+	Name string
+	// Expiry is the protection period set by Manager.Protect when it's called without one, instead
+	// of the ECS default of 2 hours.
+	Expiry time.Duration
+	// Renewal is the default RenewalStrategy of Renewers and jobs run with Wrap.
+	Renewal RenewalStrategy
+	// Debounce delays disabling protection once the last job run with Wrap returns, so a job
+	// starting meanwhile doesn't cause an unprotect and protect in quick succession.
+	Debounce time.Duration
+	// ShutdownTimeout caps the final unprotect of Manager.ShutdownUnprotect, instead of
+	// ShutdownUnprotectTimeout.
+	ShutdownTimeout time.Duration
+	// MaxContinuousProtection is set as with WithMaxContinuousProtection.
+	MaxContinuousProtection time.Duration
+}
+
+// Preset profiles, selectable by name with LookupProfile.
+var (
+	// ProfileWeb suits request-serving tasks: short protection, debounced across request bursts.
+	ProfileWeb = Profile{
+		Name:            "web",
+		Expiry:          10 * time.Minute,
+		Renewal:         FractionOfTTL{Expiry: 10 * time.Minute},
+		Debounce:        30 * time.Second,
+		ShutdownTimeout: 20 * time.Second,
+	}
+	// ProfileQueueWorker suits tasks consuming messages, renewing adaptively so a failed renewal
+	// is retried before protection lapses.
+	ProfileQueueWorker = Profile{
+		Name:            "queue-worker",
+		Expiry:          30 * time.Minute,
+		Renewal:         Adaptive{Expiry: 30 * time.Minute},
+		Debounce:        5 * time.Second,
+		ShutdownTimeout: ShutdownUnprotectTimeout,
+	}
+	// ProfileBatch suits long-running jobs, with protection lengthening as a job runs.
+	ProfileBatch = Profile{
+		Name:            "batch",
+		Expiry:          time.Hour,
+		Renewal:         EscalatingExpiry{Initial: 15 * time.Minute, Max: 4 * time.Hour},
+		ShutdownTimeout: 2 * time.Minute,
+	}
+	// ProfileDaemon suits tasks protected for most of their life, capping continuous protection
+	// so a deployment can eventually replace them.
+	ProfileDaemon = Profile{
+		Name:                    "daemon",
+		Expiry:                  2 * time.Hour,
+		Renewal:                 FixedInterval{Interval: 30 * time.Minute, Expiry: 2 * time.Hour},
+		Debounce:                time.Minute,
+		ShutdownTimeout:         ShutdownUnprotectTimeout,
+		MaxContinuousProtection: 24 * time.Hour,
+	}
+)
+
+// LookupProfile returns the preset profile called name.
+func LookupProfile(name string) (Profile, bool) {
+	for _, p := range []Profile{ProfileWeb, ProfileQueueWorker, ProfileBatch, ProfileDaemon} {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return Profile{}, false
+}
+
+// WithProfile configures the Client, and the Managers and Renewers using it, with profile. Options
+// following it override the settings of the profile, e.g. WithMaxContinuousProtection.
+func WithProfile(profile Profile) Option {
+	return func(c *Client) {
+		c.profile = profile
+		if profile.MaxContinuousProtection > 0 {
+			c.maxContinuous = profile.MaxContinuousProtection
+		}
+	}
+}
+
+// WithProfileFromEnv configures the Client with the preset profile named by the ECSTP_PROFILE
+// environment variable, if it's set. An unknown name is logged and ignored.
+func WithProfileFromEnv() Option {
+	return func(c *Client) {
+		name := os.Getenv(EnvProfile)
+		if name == "" {
+			return
+		}
+		profile, ok := LookupProfile(name)
+		if !ok {
+			c.log().Warn(fmt.Sprintf("unknown %s, using defaults", EnvProfile), "profile", name)
+			return
+		}
+		WithProfile(profile)(c)
+	}
+}
+
+// renewalStrategy returns the renewal strategy of the Client's profile, or fallback.
+func (c *Client) renewalStrategy(fallback RenewalStrategy) RenewalStrategy {
+	if c.profile.Renewal == nil {
+		return fallback
+	}
+
+	return c.profile.Renewal
+}
+
+// defaultExpiresInMinutes returns the protection period of the Client's profile, or nil if it
+// doesn't set one.
+func (c *Client) defaultExpiresInMinutes() *int32 {
+	if c.profile.Expiry <= 0 {
+		return nil
+	}
+
+	return expiresInMinutes(c.profile.Expiry)
+}
@@ -0,0 +1,112 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    Profile
+		wantOk  bool
+	}{
+		{name: "should find the web profile", profile: "web", want: ProfileWeb, wantOk: true},
+		{name: "should find the queue worker profile", profile: "queue-worker", want: ProfileQueueWorker, wantOk: true},
+		{name: "should find the batch profile", profile: "batch", want: ProfileBatch, wantOk: true},
+		{name: "should find the daemon profile", profile: "daemon", want: ProfileDaemon, wantOk: true},
+		{name: "should not find unknown profiles", profile: "cron"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LookupProfile(tt.profile)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	tests := []struct {
+		name              string
+		env               string
+		opts              []Option
+		expiresInMinutes  *int32
+		wantExpires       *int32
+		wantMaxContinuous time.Duration
+	}{
+		{
+			name:        "should keep the ECS default expiry without a profile",
+			wantExpires: nil,
+		},
+		{
+			name:              "should set the expiry of the profile",
+			opts:              []Option{WithProfile(ProfileDaemon)},
+			wantExpires:       aws.Int32(120),
+			wantMaxContinuous: 24 * time.Hour,
+		},
+		{
+			name:              "should keep an explicit expiry",
+			opts:              []Option{WithProfile(ProfileDaemon)},
+			expiresInMinutes:  aws.Int32(5),
+			wantExpires:       aws.Int32(5),
+			wantMaxContinuous: 24 * time.Hour,
+		},
+		{
+			name:              "should let later options override the profile",
+			opts:              []Option{WithProfile(ProfileDaemon), WithMaxContinuousProtection(time.Hour)},
+			wantExpires:       aws.Int32(120),
+			wantMaxContinuous: time.Hour,
+		},
+		{
+			name:        "should select the profile from the environment",
+			env:         "web",
+			opts:        []Option{WithProfileFromEnv()},
+			wantExpires: aws.Int32(10),
+		},
+		{
+			name:        "should ignore unknown profiles in the environment",
+			env:         "cron",
+			opts:        []Option{WithProfileFromEnv()},
+			wantExpires: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvProfile, tt.env)
+			ecsClient := &ExpiryTestClient{}
+			client := NewClient(ecsClient, tt.opts...)
+			m := NewManager(client, &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+
+			_, err := m.Protect(context.Background(), tt.expiresInMinutes)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExpires, ecsClient.expiresInMinutes)
+			assert.Equal(t, tt.wantMaxContinuous, client.maxContinuous)
+		})
+	}
+}
+
+func TestWrap_Debounce(t *testing.T) {
+	client := &CountingTestClient{}
+	profile := ProfileQueueWorker
+	profile.Debounce = 50 * time.Millisecond
+	m := NewManager(NewClient(client, WithProfile(profile)), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	job := Wrap(m, JobFunc(func(ctx context.Context) error { return nil }))
+
+	require.NoError(t, job.Run(context.Background()))
+	assert.True(t, m.State().Protected, "protection should be held during the debounce")
+
+	require.NoError(t, job.Run(context.Background()))
+	assert.Equal(t, int32(1), client.protects.Load(), "a job within the debounce should reuse protection")
+	assert.Equal(t, int32(0), client.unprotects.Load())
+
+	assert.Eventually(t, func() bool { return client.unprotects.Load() == 1 }, time.Second, 5*time.Millisecond)
+	assert.False(t, m.State().Protected, "protection should be released after the debounce")
+}
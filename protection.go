@@ -1,18 +1,27 @@
 // Package ecstp (ecs-task-protection) provides an easy function for enabling and disabling ECS
 // task termination protection and can be called from inside an ECS task. See
 // https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-scale-in-protection.html.
+//
+// Integrations with queues, RPC frameworks and schedulers live in the ecstp* subpackages, and
+// fakes for testing code using a Manager in ecstptest. Exported identifiers of this package and
+// its subpackages are only changed incompatibly in a new major version of the module. Manager,
+// Client and the metadata lookup share unexported state, so they stay in this package rather
+// than being split into subpackages.
 package ecstp
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"io"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go/middleware"
 )
 
 // ECSClient is an interface representing the AWS ECS Client.
@@ -26,18 +35,121 @@ type ECSClient interface {
 type MetadataBody struct {
 	Cluster string `json:"Cluster"`
 	TaskARN string `json:"TaskARN"`
+	// LaunchType is the launch type of the task, e.g. LaunchTypeExternal for ECS Anywhere tasks.
+	LaunchType string `json:"LaunchType,omitempty"`
+	// AvailabilityZone is the Availability Zone of the task, which isn't reported for ECS Anywhere
+	// tasks.
+	AvailabilityZone string `json:"AvailabilityZone,omitempty"`
 }
 
+// DefaultExpiresInMinutes is the protection period ECS applies when ExpiresInMinutes is not set.
+const DefaultExpiresInMinutes = 120
+
 // Client is a wrapper around an ECS Client that enables and disables ECS task protection.
+//
+// A Client is safe for concurrent use by multiple goroutines. Its configuration is fixed by the
+// Options it's created with; MetadataEndpointOverride must only be assigned before the Client is
+// shared, and changed with SetMetadataEndpointOverride afterwards. The Cluster and Task ARN of the
+// task are resolved once and cached until RefreshMetadata, so no other state is shared between
+// calls beyond that of ProtectDuring and of the configured quota store, auditor and other
+// dependencies, which must be safe for concurrent use themselves.
 type Client struct {
 	ECSClient
 	MetadataEndpointOverride string
+
+	// mu guards MetadataEndpointOverride once the Client is shared, and duringManagers.
+	mu             sync.RWMutex
+	duringManagers map[string]*duringManager
+
+	// metadataMu guards metadata, the cached result of GetTaskArn, and metadataFetch, the read of
+	// it in progress, if any, which concurrent calls share. It isn't held while reading.
+	metadataMu    sync.Mutex
+	metadata      *MetadataBody
+	metadataFetch *metadataFetch
+
+	dryRun          bool
+	timeout         time.Duration
+	metadataTimeout time.Duration
+	logger          *slog.Logger
+	auditor         Auditor
+	credentials     aws.CredentialsProvider
+	apiOptions      []func(*middleware.Stack) error
+	httpClient      *http.Client
+	requiredTag     *requiredTag
+	quota           *quota
+	calendar        *Calendar
+	blackout        *blackout
+
+	maxContinuous  time.Duration
+	failOnFailures bool
+	retry          *RetryPolicy
+	verification   *RetryPolicy
+	profile        Profile
+	loadOptions    []func(*config.LoadOptions) error
+
+	instanceProtection *instanceProtection
+	agent              *AgentClient
+	metadataResolver   MetadataEndpointResolver
 }
 
-func NewClient(ecsClient ECSClient) *Client {
-	return &Client{
+// NewClient returns a Client wrapping ecsClient, configured with any provided Options.
+func NewClient(ecsClient ECSClient, opts ...Option) *Client {
+	c := &Client{
 		ECSClient: ecsClient,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.agent != nil {
+		c.agent.HTTPClient = c.httpClient
+	}
+
+	return c
+}
+
+// NewDefaultClient returns a Client wrapping an ECS client created from the default AWS
+// configuration, configured with any provided Options. Middleware can be registered on its calls
+// with WithAPIOptions, the HTTP client set with WithHTTPClient and the loading of the configuration
+// customized with WithConfigOptions.
+//
+// If the configuration doesn't specify a region, the task's region is used, as resolved from the
+// task metadata. This lets ECS Anywhere tasks, which can't resolve their region from EC2 instance
+// metadata, use the default configuration.
+func NewDefaultClient(ctx context.Context, opts ...Option) (*Client, error) {
+	c := NewClient(nil, opts...)
+
+	var loadOpts []func(*config.LoadOptions) error
+	if c.httpClient != nil {
+		loadOpts = append(loadOpts, config.WithHTTPClient(c.httpClient))
+	}
+	loadOpts = append(loadOpts, c.loadOptions...)
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Region == "" {
+		// e.g. on ECS Anywhere, where there's no EC2 instance metadata to resolve it from
+		if cfg.Region, err = c.taskRegion(ctx); err != nil {
+			return nil, err
+		}
+	}
+	c.ECSClient = ecs.NewFromConfig(cfg)
+
+	return c, nil
+}
+
+// SetMetadataEndpointOverride sets MetadataEndpointOverride, the task metadata endpoint used
+// instead of ECS_CONTAINER_METADATA_URI_V4, safely while the Client is in use. The cached metadata
+// is discarded so the next call reads it from the new endpoint.
+func (c *Client) SetMetadataEndpointOverride(endpoint string) {
+	c.mu.Lock()
+	c.MetadataEndpointOverride = endpoint
+	c.mu.Unlock()
+
+	c.metadataMu.Lock()
+	c.metadata = nil
+	c.metadataFetch = nil
+	c.metadataMu.Unlock()
 }
 
 // UpdateTaskProtectionInput defines the parameters required for UpdateTaskProtection.
@@ -46,74 +158,393 @@ func NewClient(ecsClient ECSClient) *Client {
 // ExpiresInMinutes must be between 1 and 2880, but can be nil. Setting to nil will use the default
 // protection period. See
 // https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-scale-in-protection.html.
+// ExpiresIn sets the protection period as a duration instead, rounded up to the minute; only one of
+// them may be set.
+//
+// Reason is optional and is only used to annotate audit records.
+//
+// Credentials, if set, is used to sign this call instead of the credentials configured on the ECS
+// client or via WithCredentials, e.g. to use an elevated role for a forced unprotect.
+//
+// Labels are correlation labels annotating the call, added to those of the context, see
+// ContextWithLabels.
 type UpdateTaskProtectionInput struct {
 	Metadata         *MetadataBody
 	Protect          bool
 	ExpiresInMinutes *int32
+	ExpiresIn        time.Duration
+	Reason           string
+	Credentials      aws.CredentialsProvider
+	Labels           map[string]string
 }
 
 // GetTaskArn calls the Instance metadata API to retrieve the current Cluster and Task ARN.
 //
-// The Instance metadata API URI is obtained through the env variable `ECS_CONTAINER_METADATA_URI_V4`.
-// Returns a pointer to struct MetadataBody representing the API response or returns an error if the
-// env variable cannot be found, the API was unreachable or the response can't be unmarshalled.
+// As they don't change for the lifetime of the task, the first successful result is cached and
+// returned by later calls, including those of UpdateTaskProtection with nil Metadata, until
+// RefreshMetadata is called. Errors aren't cached.
+//
+// It returns the MetadataBody of the document returned by GetTaskMetadata, including its errors:
+// if the metadata endpoint cannot be resolved or the API was unreachable, the error matches
+// ErrMetadataUnavailable, and a response that isn't a JSON metadata document, e.g. an HTML error
+// page, results in a *MetadataDecodeError describing it, which matches ErrMetadataDecode.
+//
+// The Cluster is returned as the full cluster ARN, derived from the Task ARN if the metadata only
+// names the cluster or omits it.
+//
+// Concurrent calls share a single read of the metadata. A call whose ctx is done stops waiting for
+// it and returns ctx.Err(), while the read carries on for the other callers, bounded by the
+// metadata timeout and retry policy.
 func (c *Client) GetTaskArn(ctx context.Context) (*MetadataBody, error) {
-	ecsMetadataEndpoint := c.MetadataEndpointOverride
-
-	if ecsMetadataEndpoint == "" {
-		var ok bool
-		ecsMetadataEndpoint, ok = os.LookupEnv("ECS_CONTAINER_METADATA_URI_V4")
-		if !ok {
-			return nil, errors.New("unable to retrieve Task ARN - can't get Metadata URI")
-		}
+	c.metadataMu.Lock()
+	if c.metadata != nil {
+		// a copy, so callers can't alter the cache
+		metadata := *c.metadata
+		c.metadataMu.Unlock()
+		return &metadata, nil
 	}
+	fetch := c.fetchMetadataLocked(ctx)
+	c.metadataMu.Unlock()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", ecsMetadataEndpoint+"/task", nil)
-	if err != nil {
-		return nil, err
+	return fetch.wait(ctx)
+}
+
+// RefreshMetadata discards the metadata cached by GetTaskArn and reads it again, e.g. after the
+// metadata endpoint reported inconsistent values while the task was starting. On error, the cache
+// is left empty so the next call retries.
+func (c *Client) RefreshMetadata(ctx context.Context) (*MetadataBody, error) {
+	c.metadataMu.Lock()
+	c.metadata = nil
+	// a read in progress may have started before the values changed
+	c.metadataFetch = nil
+	fetch := c.fetchMetadataLocked(ctx)
+	c.metadataMu.Unlock()
+
+	return fetch.wait(ctx)
+}
+
+// metadataFetch is a read of the metadata cached by GetTaskArn.
+type metadataFetch struct {
+	done chan struct{}
+	// metadata and err are set once done is closed.
+	metadata *MetadataBody
+	err      error
+}
+
+// wait waits for f to be done or ctx to be done, returning a copy of the metadata read.
+func (f *metadataFetch) wait(ctx context.Context) (*MetadataBody, error) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+	if f.err != nil {
+		return nil, f.err
 	}
-	defer res.Body.Close()
+	metadata := *f.metadata
 
-	b, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
+	return &metadata, nil
+}
 
-	var metadata *MetadataBody
-	if err = json.Unmarshal(b, &metadata); err != nil {
-		return nil, err
+// fetchMetadataLocked returns the read of the metadata in progress, starting one if there's none.
+// The read isn't canceled with ctx, as other calls may be waiting for it, and its result is only
+// cached if it's still current once done. c.metadataMu must be held.
+func (c *Client) fetchMetadataLocked(ctx context.Context) *metadataFetch {
+	if c.metadataFetch != nil {
+		return c.metadataFetch
 	}
 
-	return metadata, nil
+	fetch := &metadataFetch{done: make(chan struct{})}
+	c.metadataFetch = fetch
+	go func() {
+		defer close(fetch.done)
+
+		metadata, err := c.GetTaskMetadata(context.WithoutCancel(ctx))
+		if err == nil {
+			fetch.metadata = &metadata.MetadataBody
+		}
+		fetch.err = err
+
+		c.metadataMu.Lock()
+		defer c.metadataMu.Unlock()
+		if c.metadataFetch != fetch {
+			return
+		}
+		c.metadataFetch = nil
+		if err == nil {
+			c.metadata = fetch.metadata
+		}
+	}()
+
+	return fetch
 }
 
 // UpdateTaskProtection uses the provided input to enable or disable task protection.
 //
 // UpdateTaskProtection calls GetTaskArn to retrieve the Cluster and Task ARN (if not provided via
-// Metadata in input) and then calls the UpdateTaskProtection ECS API to enable or disable
+// Metadata in input, in which case a missing or short cluster name is resolved to the full
+// cluster ARN the same way) and then calls the UpdateTaskProtection ECS API to enable or disable
 // protection. Directly returns the result of the UpdateTaskProtection.
+//
+// Input ECS would reject, e.g. an ExpiresInMinutes out of range or set when disabling protection,
+// or metadata without a cluster or task ARN, is rejected with a *ValidationError before any ECS call.
+//
+// If the Client was created with WithRequiredTag, protection is only enabled if the task or its
+// service carries the required tag; otherwise an error wrapping ErrProtectionNotAllowed is returned.
+//
+// If the Client was created with WithCalendar, enabling protection outside the allowed windows
+// fails with an error wrapping ErrOutsideWindow.
+//
+// If the Client was created with WithBlackout, the protection period is shortened, or protection
+// refused with an error wrapping ErrDeploymentBlackout, while a blackout is in effect.
+//
+// If the Client was created with WithQuota, enabling protection fails with a *QuotaExceededError
+// once the quota for the cluster is exhausted.
+//
+// If the Client was created with WithInstanceProtection, the scale-in protection of the container
+// instance is updated once ECS has updated the task; if that fails, the ECS output is returned
+// along with an *InstanceProtectionError.
+//
+// Errors of the ECS API are returned as is, additionally matching ErrThrottled or
+// ErrExpirationOutOfRange with errors.Is if they're of that class.
+//
+// If ECS reports the outcome for tasks other than the one in the metadata, the ECS output is
+// returned along with a *TaskARNMismatchError.
+//
+// If the Client was created with WithFailOnFailures and ECS reports a failure for the task, the ECS
+// output is returned along with a *ProtectionFailureError.
+//
+// If the Client was created with WithRetry, the ECS call and the metadata request are retried
+// according to its policy.
+//
+// If the Client was created with WithVerification, the update is verified via GetTaskProtection;
+// if ECS doesn't report the requested protection in time, the ECS output is returned along with an
+// error wrapping ErrNotConverged.
+//
+// If the Client was created with WithAgentEndpoint, the update is made via the ECS agent endpoint
+// unless it's unavailable.
+//
+// If the Client was created with WithDryRun, the ECS API is not called and no quota is acquired.
+// The intended update is logged instead. A synthesized output describing the would-be result is
+// returned.
+//
+// The expiry, dry-run mode and strictness of the call can be overridden through ctx, see
+// ContextWithOverrides.
 func (c *Client) UpdateTaskProtection(ctx context.Context, input *UpdateTaskProtectionInput) (*ecs.UpdateTaskProtectionOutput, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	input, err := resolveExpiresIn(input)
+	if err != nil {
+		return nil, err
+	}
+
 	var metadata *MetadataBody
 	if input.Metadata == nil {
-		var err error
 		metadata, err = c.GetTaskArn(ctx)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		metadata = input.Metadata
+		metadata = normalizeMetadata(input.Metadata)
 	}
 
-	return c.ECSClient.UpdateTaskProtection(ctx, &ecs.UpdateTaskProtectionInput{
-		Cluster: aws.String(metadata.Cluster),
-		Tasks: []string{
-			metadata.TaskARN,
-		},
-		ProtectionEnabled: input.Protect,
-		ExpiresInMinutes:  input.ExpiresInMinutes,
+	input, err = c.prepareUpdate(ctx, metadata, input)
+	if err != nil {
+		return nil, err
+	}
+	if c.isDryRun(ctx) {
+		output := c.dryRunUpdate(ctx, metadata, input)
+		c.audit(ctx, metadata, input, output, nil)
+		return output, nil
+	}
+
+	output, err := c.sendUpdate(ctx, metadata.Cluster, []string{metadata.TaskARN}, input)
+	if err == nil {
+		err = NewUpdateResult(output).Verify(metadata.TaskARN)
+	}
+
+	return output, c.finishUpdate(ctx, metadata, input, output, err)
+}
+
+// prepareUpdate applies the overrides of ctx to the input of an update of the task of metadata and
+// checks that the update is allowed, reserving quota for it unless in dry-run mode. The input to
+// send is returned, or the error of an update that isn't allowed once audited.
+func (c *Client) prepareUpdate(
+	ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput,
+) (*UpdateTaskProtectionInput, error) {
+	input = overrideInput(ctx, input)
+	if labels := mergeLabels(LabelsFromContext(ctx), input.Labels); len(labels) > 0 {
+		labeled := *input
+		labeled.Labels = labels
+		input = &labeled
+	}
+
+	if err := validateInput(metadata, input); err != nil {
+		c.audit(ctx, metadata, input, nil, err)
+		return nil, err
+	}
+
+	if input.Protect && c.calendar != nil {
+		if allowed, _ := c.calendar.Allowed(time.Now()); !allowed {
+			err := fmt.Errorf("%w: task %s", ErrOutsideWindow, metadata.TaskARN)
+			c.audit(ctx, metadata, input, nil, err)
+			return nil, err
+		}
+	}
+
+	if input.Protect && c.blackout != nil {
+		applied, err := c.applyBlackout(ctx, metadata, input)
+		if err != nil {
+			c.audit(ctx, metadata, input, nil, err)
+			return nil, err
+		}
+		input = applied
+	}
+
+	if input.Protect && c.requiredTag != nil {
+		if err := c.checkRequiredTag(ctx, metadata, input.Credentials); err != nil {
+			c.audit(ctx, metadata, input, nil, err)
+			return nil, err
+		}
+	}
+
+	if input.Protect && c.quota != nil && !c.isDryRun(ctx) {
+		if err := c.acquireQuota(ctx, metadata, input); err != nil {
+			c.audit(ctx, metadata, input, nil, err)
+			return nil, err
+		}
+	}
+
+	return input, nil
+}
+
+// sendUpdate makes the UpdateTaskProtection call of input for tasks of cluster.
+func (c *Client) sendUpdate(
+	ctx context.Context, cluster string, tasks []string, input *UpdateTaskProtectionInput,
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	return retryCall(ctx, c.retry, func(ctx context.Context) (*ecs.UpdateTaskProtectionOutput, error) {
+		output, err := c.protectionClient(input.Credentials).UpdateTaskProtection(ctx, &ecs.UpdateTaskProtectionInput{
+			Cluster:           aws.String(cluster),
+			Tasks:             tasks,
+			ProtectionEnabled: input.Protect,
+			ExpiresInMinutes:  input.ExpiresInMinutes,
+		}, c.retriedECSOptions(c.retry, input.Credentials)...)
+		return output, classifyError(err)
 	})
 }
+
+// finishUpdate checks, settles and audits the update of the task of metadata, which ECS answered
+// with output and err, returning its error.
+func (c *Client) finishUpdate(
+	ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput,
+	output *ecs.UpdateTaskProtectionOutput, err error,
+) error {
+	if err == nil && c.failOnFailures {
+		err = NewUpdateResult(output).Failure(metadata.TaskARN)
+	}
+	if err == nil && c.verification != nil {
+		err = c.verifyProtection(ctx, metadata, input)
+	}
+	if c.quota != nil {
+		c.settleQuota(ctx, metadata, input, output, err)
+	}
+	if err == nil && c.instanceProtection != nil && !c.skipInstanceProtection(ctx, metadata) {
+		err = c.updateInstanceProtection(ctx, metadata, input, output)
+	}
+	c.audit(ctx, metadata, input, output, err)
+
+	return err
+}
+
+// dryRunUpdate logs the update that would have been sent to ECS and returns an output describing
+// the expected result.
+func (c *Client) dryRunUpdate(ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput) *ecs.UpdateTaskProtectionOutput {
+	task := types.ProtectedTask{
+		TaskArn:           aws.String(metadata.TaskARN),
+		ProtectionEnabled: input.Protect,
+	}
+
+	attrs := []any{
+		slog.String("cluster", metadata.Cluster),
+		slog.String("task_arn", metadata.TaskARN),
+		slog.Bool("protect", input.Protect),
+	}
+	if len(input.Labels) > 0 {
+		attrs = append(attrs, labelsAttr(input.Labels))
+	}
+	if input.Protect {
+		minutes := int32(DefaultExpiresInMinutes)
+		if input.ExpiresInMinutes != nil {
+			minutes = *input.ExpiresInMinutes
+		}
+		task.ExpirationDate = aws.Time(time.Now().Add(time.Duration(minutes) * time.Minute))
+		attrs = append(attrs,
+			slog.Int("expires_in_minutes", int(minutes)),
+			slog.Time("expires_at", *task.ExpirationDate),
+		)
+	}
+
+	c.log().InfoContext(ctx, "dry run: skipping UpdateTaskProtection", attrs...)
+
+	return &ecs.UpdateTaskProtectionOutput{
+		ProtectedTasks: []types.ProtectedTask{task},
+	}
+}
+
+// withTimeout bounds ctx by the timeout set with WithTimeout, if any.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+func (c *Client) log() *slog.Logger {
+	if c.logger == nil {
+		return slog.Default()
+	}
+
+	return c.logger
+}
+
+// ecsOptions returns the per-call ECS client options, signing the call with credentials if set or
+// the Client's credentials otherwise, and registering the Client's middleware and HTTP client.
+func (c *Client) ecsOptions(credentials aws.CredentialsProvider) []func(*ecs.Options) {
+	if credentials == nil {
+		credentials = c.credentials
+	}
+
+	var optFns []func(*ecs.Options)
+	if credentials != nil {
+		optFns = append(optFns, func(o *ecs.Options) {
+			o.Credentials = credentials
+		})
+	}
+	if len(c.apiOptions) > 0 {
+		optFns = append(optFns, func(o *ecs.Options) {
+			o.APIOptions = append(o.APIOptions, c.apiOptions...)
+		})
+	}
+	if c.httpClient != nil {
+		optFns = append(optFns, func(o *ecs.Options) {
+			o.HTTPClient = c.httpClient
+		})
+	}
+
+	return optFns
+}
+
+// retriedECSOptions returns the ecsOptions of a call retried with policy. If policy is set, the
+// retryer of the ECS client is disabled, so that its attempts don't multiply those of policy.
+func (c *Client) retriedECSOptions(policy *RetryPolicy, credentials aws.CredentialsProvider) []func(*ecs.Options) {
+	optFns := c.ecsOptions(credentials)
+	if policy != nil {
+		optFns = append(optFns, func(o *ecs.Options) {
+			o.Retryer = aws.NopRetryer{}
+		})
+	}
+
+	return optFns
+}
@@ -4,16 +4,28 @@
 package ecstp
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type SuccessfulTestClient struct{}
@@ -79,6 +91,7 @@ func TestClient_UpdateTaskProtection(t *testing.T) {
 				ctx: context.Background(),
 				input: &UpdateTaskProtectionInput{
 					Metadata: &MetadataBody{
+						Cluster: "test_cluster",
 						TaskARN: "test",
 					},
 					Protect: true,
@@ -102,6 +115,7 @@ func TestClient_UpdateTaskProtection(t *testing.T) {
 				ctx: context.Background(),
 				input: &UpdateTaskProtectionInput{
 					Metadata: &MetadataBody{
+						Cluster: "test_cluster",
 						TaskARN: "test",
 					},
 					Protect: false,
@@ -125,6 +139,7 @@ func TestClient_UpdateTaskProtection(t *testing.T) {
 				ctx: context.Background(),
 				input: &UpdateTaskProtectionInput{
 					Metadata: &MetadataBody{
+						Cluster: "test_cluster",
 						TaskARN: "test",
 					},
 				},
@@ -155,22 +170,84 @@ func TestClient_UpdateTaskProtection(t *testing.T) {
 
 func TestClient_GetTaskArn(t *testing.T) {
 	tests := []struct {
-		name    string
-		want    *MetadataBody
-		wantErr bool
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        *MetadataBody
+		wantErr     *MetadataDecodeError
 	}{
 		{
-			name: "should return a MetadataBody with test cluster and task ARN",
+			name:        "should return a MetadataBody with test cluster and task ARN",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`,
 			want: &MetadataBody{
 				Cluster: "test_cluster",
 				TaskARN: "test_arn",
 			},
 		},
+		{
+			name:        "should accept any successful status",
+			status:      http.StatusNonAuthoritativeInfo,
+			contentType: "application/json",
+			body:        `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`,
+			want: &MetadataBody{
+				Cluster: "test_cluster",
+				TaskARN: "test_arn",
+			},
+		},
+		{
+			name:        "should describe HTML error pages",
+			status:      http.StatusBadGateway,
+			contentType: "text/html",
+			body:        "<html><body>502 Bad Gateway</body></html>",
+			wantErr: &MetadataDecodeError{
+				StatusCode:  http.StatusBadGateway,
+				ContentType: "text/html",
+				Body:        "<html><body>502 Bad Gateway</body></html>",
+			},
+		},
+		{
+			name:        "should describe truncated documents",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"Cluster": "test_cluster", "TaskA`,
+			wantErr: &MetadataDecodeError{
+				StatusCode:  http.StatusOK,
+				ContentType: "application/json",
+				Body:        `{"Cluster": "test_cluster", "TaskA`,
+			},
+		},
+		{
+			name:        "should describe empty documents",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        "null",
+			wantErr: &MetadataDecodeError{
+				StatusCode:  http.StatusOK,
+				ContentType: "application/json",
+				Body:        "null",
+			},
+		},
+		{
+			name:        "should truncate and redact the body sample",
+			status:      http.StatusInternalServerError,
+			contentType: "text/plain",
+			body:        "Token=secret " + strings.Repeat("x", 300),
+			wantErr: &MetadataDecodeError{
+				StatusCode:  http.StatusInternalServerError,
+				ContentType: "text/plain",
+				Body:        ("Token=[REDACTED] " + strings.Repeat("x", 300))[:maxErrorMessageLength] + "...",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				fmt.Fprint(w, `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`)
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
 			}))
 			defer ts.Close()
 
@@ -178,16 +255,105 @@ func TestClient_GetTaskArn(t *testing.T) {
 				MetadataEndpointOverride: ts.URL,
 			}
 			got, err := c.GetTaskArn(context.Background())
-			if assert.NoError(t, err) {
-				assert.Equal(t, &MetadataBody{
-					Cluster: "test_cluster",
-					TaskARN: "test_arn",
-				}, got)
+			if tt.wantErr == nil {
+				if assert.NoError(t, err) {
+					assert.Equal(t, tt.want, got)
+				}
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrMetadataDecode)
+			var decodeErr *MetadataDecodeError
+			if assert.ErrorAs(t, err, &decodeErr) {
+				assert.Equal(t, tt.wantErr.StatusCode, decodeErr.StatusCode)
+				assert.Equal(t, tt.wantErr.ContentType, decodeErr.ContentType)
+				assert.Equal(t, tt.wantErr.Body, decodeErr.Body)
+				assert.Error(t, decodeErr.Err)
 			}
 		})
 	}
 }
 
+func TestClient_GetTaskArn_Cache(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request fails
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"Cluster": "test_cluster", "TaskARN": "test_arn_%d"}`, calls.Load())
+	}))
+	defer server.Close()
+
+	ecsClient := &CountingTestClient{}
+	c := NewClient(ecsClient, WithMetadataEndpoint(server.URL))
+
+	_, err := c.GetTaskArn(context.Background())
+	assert.Error(t, err, "errors should not be cached")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{Protect: true})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(10), ecsClient.protects.Load())
+	assert.Equal(t, int32(2), calls.Load(), "the metadata should be read once")
+
+	got, err := c.GetTaskArn(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test_arn_2", got.TaskARN)
+	got.TaskARN = "altered"
+
+	got, err = c.RefreshMetadata(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test_arn_3", got.TaskARN)
+
+	got, err = c.GetTaskArn(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test_arn_3", got.TaskARN)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_GetTaskArn_Canceled(t *testing.T) {
+	var calls atomic.Int32
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-unblock
+		fmt.Fprint(w, `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`)
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	c := NewClient(&SuccessfulTestClient{}, WithMetadataEndpoint(server.URL))
+
+	waiting := make(chan error)
+	go func() {
+		_, err := c.GetTaskArn(context.Background())
+		waiting <- err
+	}()
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.GetTaskArn(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "waiting for a read in progress should stop with ctx")
+
+	unblock <- struct{}{}
+	assert.NoError(t, <-waiting)
+	got, err := c.GetTaskArn(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test_arn", got.TaskARN)
+	assert.Equal(t, int32(1), calls.Load(), "concurrent calls should share a single read")
+}
+
 func TestNewClient(t *testing.T) {
 	type args struct {
 		ecsClient ECSClient
@@ -209,3 +375,354 @@ func TestNewClient(t *testing.T) {
 		})
 	}
 }
+
+func TestNewDefaultClient_ConfigOptions(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-2")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+
+	c, err := NewDefaultClient(context.Background(), WithConfigOptions(config.WithRegion("us-east-1")))
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", c.ECSClient.(*ecs.Client).Options().Region)
+}
+
+func TestNewClient_Options(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Cluster": "test_cluster", "TaskARN": "%s0123456789abcdef"}`, remoteTestARNPrefix)
+	}))
+	defer ts.Close()
+
+	c := NewClient(&BlockingTestClient{}, WithMetadataEndpoint(ts.URL), WithTimeout(10*time.Millisecond))
+
+	metadata, err := c.GetTaskArn(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, remoteTestARNPrefix+"0123456789abcdef", metadata.TaskARN)
+
+	_, err = c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{Protect: true})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_UpdateTaskProtection_FailOnFailures(t *testing.T) {
+	input := &UpdateTaskProtectionInput{Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"}, Protect: true}
+
+	got, err := NewClient(&FailureTestClient{}).UpdateTaskProtection(context.Background(), input)
+	assert.NoError(t, err, "failures should only be reported in the output by default")
+	assert.Len(t, got.Failures, 1)
+
+	got, err = NewClient(&FailureTestClient{}, WithFailOnFailures()).UpdateTaskProtection(context.Background(), input)
+	var failure *ProtectionFailureError
+	if assert.ErrorAs(t, err, &failure) {
+		assert.Equal(t, &ProtectionFailureError{TaskARN: "test_arn", Reason: "failed"}, failure)
+	}
+	assert.Len(t, got.Failures, 1, "the output should be returned along with the error")
+
+	_, err = NewClient(&SuccessfulTestClient{}, WithFailOnFailures()).UpdateTaskProtection(context.Background(), input)
+	assert.NoError(t, err)
+}
+
+// BlockingTestClient blocks every call until its context is done.
+type BlockingTestClient struct{}
+
+func (c *BlockingTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type UnreachableTestClient struct {
+	t *testing.T
+}
+
+func (c *UnreachableTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.t.Fatal("ECS API should not be called")
+	return nil, nil
+}
+
+func TestClient_UpdateTaskProtection_DryRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       *UpdateTaskProtectionInput
+		wantExpiry  time.Duration
+		wantProtect bool
+	}{
+		{
+			name: "should compute the default expiry when enabling protection",
+			input: &UpdateTaskProtectionInput{
+				Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:  true,
+			},
+			wantExpiry:  DefaultExpiresInMinutes * time.Minute,
+			wantProtect: true,
+		},
+		{
+			name: "should compute the requested expiry when enabling protection",
+			input: &UpdateTaskProtectionInput{
+				Metadata:         &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:          true,
+				ExpiresInMinutes: aws.Int32(60),
+			},
+			wantExpiry:  60 * time.Minute,
+			wantProtect: true,
+		},
+		{
+			name: "should not set an expiry when disabling protection",
+			input: &UpdateTaskProtectionInput{
+				Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:  false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			c := NewClient(&UnreachableTestClient{t: t},
+				WithDryRun(),
+				WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+			)
+
+			got, err := c.UpdateTaskProtection(context.Background(), tt.input)
+			if !assert.NoError(t, err) || !assert.Len(t, got.ProtectedTasks, 1) {
+				return
+			}
+
+			task := got.ProtectedTasks[0]
+			assert.Equal(t, "test_arn", aws.ToString(task.TaskArn))
+			assert.Equal(t, tt.wantProtect, task.ProtectionEnabled)
+			if tt.wantExpiry == 0 {
+				assert.Nil(t, task.ExpirationDate)
+			} else {
+				assert.WithinDuration(t, time.Now().Add(tt.wantExpiry), *task.ExpirationDate, time.Minute)
+			}
+			assert.Contains(t, buf.String(), "task_arn=test_arn")
+		})
+	}
+}
+
+type CredentialsTestClient struct {
+	credentials aws.CredentialsProvider
+}
+
+func (c *CredentialsTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	var o ecs.Options
+	for _, fn := range optFns {
+		fn(&o)
+	}
+	c.credentials = o.Credentials
+
+	return &ecs.UpdateTaskProtectionOutput{}, nil
+}
+
+func TestClient_UpdateTaskProtection_Credentials(t *testing.T) {
+	clientCreds := credentials.NewStaticCredentialsProvider("client", "secret", "")
+	callCreds := credentials.NewStaticCredentialsProvider("call", "secret", "")
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		callCreds aws.CredentialsProvider
+		want      aws.CredentialsProvider
+	}{
+		{
+			name: "should not override credentials by default",
+		},
+		{
+			name: "should use the client credentials",
+			opts: []Option{WithCredentials(clientCreds)},
+			want: clientCreds,
+		},
+		{
+			name:      "should prefer the per-call credentials",
+			opts:      []Option{WithCredentials(clientCreds)},
+			callCreds: callCreds,
+			want:      callCreds,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecsClient := &CredentialsTestClient{}
+			c := NewClient(ecsClient, tt.opts...)
+
+			_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+				Metadata:    &MetadataBody{Cluster: "test_cluster", TaskARN: "test"},
+				Credentials: tt.callCreds,
+			})
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, ecsClient.credentials)
+			}
+		})
+	}
+}
+
+func TestClient_UpdateTaskProtection_APIOptions(t *testing.T) {
+	addHeader := func(name, value string) func(*middleware.Stack) error {
+		return func(stack *middleware.Stack) error {
+			return stack.Build.Add(middleware.BuildMiddlewareFunc("Add"+name, func(
+				ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler,
+			) (middleware.BuildOutput, middleware.Metadata, error) {
+				if req, ok := in.Request.(*smithyhttp.Request); ok {
+					req.Header.Set(name, value)
+				}
+				return next.HandleBuild(ctx, in)
+			}), middleware.After)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want http.Header
+	}{
+		{
+			name: "should not register middleware by default",
+			want: http.Header{},
+		},
+		{
+			name: "should register middleware on ECS calls",
+			opts: []Option{WithAPIOptions(addHeader("X-Mirror", "1"), addHeader("X-Team", "platform"))},
+			want: http.Header{"X-Mirror": {"1"}, "X-Team": {"platform"}},
+		},
+		{
+			name: "should register middleware alongside credentials",
+			opts: []Option{
+				WithCredentials(credentials.NewStaticCredentialsProvider("client", "secret", "")),
+				WithAPIOptions(addHeader("X-Mirror", "1")),
+			},
+			want: http.Header{"X-Mirror": {"1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := http.Header{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, name := range []string{"X-Mirror", "X-Team"} {
+					if value := r.Header.Get(name); value != "" {
+						got.Set(name, value)
+					}
+				}
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				fmt.Fprint(w, `{"protectedTasks":[],"failures":[]}`)
+			}))
+			defer server.Close()
+
+			ecsClient := ecs.New(ecs.Options{
+				Region:       "us-east-1",
+				BaseEndpoint: aws.String(server.URL),
+				Credentials:  credentials.NewStaticCredentialsProvider("default", "secret", ""),
+			})
+			c := NewClient(ecsClient, tt.opts...)
+
+			_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+				Metadata: &MetadataBody{Cluster: "test", TaskARN: "test"},
+			})
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+// countingTransport counts the requests sent through it by path.
+type countingTransport struct {
+	paths []string
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.paths = append(t.paths, req.URL.Path)
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_UpdateTaskProtection_HTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/task" {
+			fmt.Fprint(w, `{"Cluster":"test","TaskARN":"test_arn"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		fmt.Fprint(w, `{"protectedTasks":[{"taskArn":"test_arn","protectionEnabled":true}],"failures":[]}`)
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	ecsClient := ecs.New(ecs.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("default", "secret", ""),
+	})
+	c := NewClient(ecsClient, WithHTTPClient(&http.Client{Transport: transport}))
+	c.MetadataEndpointOverride = server.URL
+
+	_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{Protect: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/task", "/"}, transport.paths, "metadata and ECS calls should use the HTTP client")
+}
+
+// MismatchTestClient reports protection of a task other than the one requested.
+type MismatchTestClient struct{}
+
+func (c *MismatchTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	return &ecs.UpdateTaskProtectionOutput{
+		ProtectedTasks: []types.ProtectedTask{
+			{TaskArn: aws.String("other_task"), ProtectionEnabled: params.ProtectionEnabled},
+		},
+	}, nil
+}
+
+func TestClient_UpdateTaskProtection_ARNMismatch(t *testing.T) {
+	c := NewClient(&MismatchTestClient{})
+
+	output, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+		Metadata: &MetadataBody{Cluster: "test", TaskARN: "test_arn"},
+		Protect:  true,
+	})
+
+	var mismatch *TaskARNMismatchError
+	if assert.ErrorAs(t, err, &mismatch) {
+		assert.Equal(t, []string{"test_arn"}, mismatch.Requested)
+		assert.Equal(t, []string{"other_task"}, mismatch.Unexpected)
+	}
+	assert.NotNil(t, output, "the ECS output should be returned with the error")
+}
+
+func TestClient_Concurrent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Cluster":"test","TaskARN":"test_arn"}`)
+	}))
+	defer server.Close()
+
+	var audits atomic.Int32
+	c := NewClient(&SuccessfulTestClient{},
+		WithQuota(NewMemoryQuotaStore(), 100),
+		WithAuditor(AuditorFunc(func(ctx context.Context, record AuditRecord) {
+			audits.Add(1)
+		})),
+	)
+	c.MetadataEndpointOverride = server.URL
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if i%5 == 0 {
+				c.SetMetadataEndpointOverride(server.URL)
+			}
+			ctx := ContextWithLabels(context.Background(), map[string]string{"worker": fmt.Sprint(i)})
+			if i%2 == 0 {
+				ctx = ContextWithOverrides(ctx, Overrides{DryRun: aws.Bool(true)})
+			}
+			_, err := c.UpdateTaskProtection(ctx, &UpdateTaskProtectionInput{Protect: i%3 != 0})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(20), audits.Load())
+}
@@ -0,0 +1,160 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// QuotaStore tracks the tasks protected per cluster for WithQuota. Implementations backed by
+// shared storage allow the quota to be enforced across every task running the library.
+type QuotaStore interface {
+	// Acquire records taskARN as protected in cluster until expiresAt, unless limit tasks are
+	// already protected. Returns false if the quota is exhausted. Acquiring a task that is already
+	// recorded must succeed and update its expiry.
+	Acquire(ctx context.Context, cluster, taskARN string, limit int, expiresAt time.Time) (bool, error)
+	// Release removes taskARN from cluster.
+	Release(ctx context.Context, cluster, taskARN string) error
+}
+
+// QuotaExceededError is returned when enabling protection would exceed the quota configured with
+// WithQuota.
+type QuotaExceededError struct {
+	Cluster string
+	TaskARN string
+	Limit   int
+}
+
+// Error implements error.
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("protection quota of %d tasks exceeded in cluster %s", e.Limit, e.Cluster)
+}
+
+// quota is the protection quota configured with WithQuota.
+type quota struct {
+	store QuotaStore
+	limit int
+
+	// mu guards protected, the tasks whose protection was last enabled by the Client, and which
+	// therefore still count against the quota if renewing it fails.
+	mu        sync.Mutex
+	protected map[string]bool
+}
+
+// acquireQuota reserves quota for the task before protection is enabled.
+func (c *Client) acquireQuota(ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput) error {
+	minutes := int32(DefaultExpiresInMinutes)
+	if input.ExpiresInMinutes != nil {
+		minutes = *input.ExpiresInMinutes
+	}
+	expiresAt := time.Now().Add(time.Duration(minutes) * time.Minute)
+
+	ok, err := c.quota.store.Acquire(ctx, metadata.Cluster, metadata.TaskARN, c.quota.limit, expiresAt)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &QuotaExceededError{
+			Cluster: metadata.Cluster,
+			TaskARN: metadata.TaskARN,
+			Limit:   c.quota.limit,
+		}
+	}
+
+	return nil
+}
+
+// settleQuota releases quota held by the task once it's no longer protected, either because
+// protection was disabled or enabling it failed. If renewing protection fails, the task keeps its
+// quota as it's still protected.
+func (c *Client) settleQuota(
+	ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput,
+	output *ecs.UpdateTaskProtectionOutput, err error,
+) {
+	protected := false
+	if err == nil && input.Protect {
+		for _, task := range output.ProtectedTasks {
+			if sameTask(aws.ToString(task.TaskArn), metadata.TaskARN) && task.ProtectionEnabled {
+				protected = true
+			}
+		}
+	}
+
+	c.quota.mu.Lock()
+	// a failed update leaves protection as it was
+	keep := protected || err != nil && (!input.Protect || c.quota.protected[metadata.TaskARN])
+	if protected {
+		if c.quota.protected == nil {
+			c.quota.protected = make(map[string]bool)
+		}
+		c.quota.protected[metadata.TaskARN] = true
+	} else if !keep {
+		delete(c.quota.protected, metadata.TaskARN)
+	}
+	c.quota.mu.Unlock()
+	if keep {
+		return
+	}
+
+	if err := c.quota.store.Release(ctx, metadata.Cluster, metadata.TaskARN); err != nil {
+		c.log().WarnContext(ctx, "unable to release protection quota",
+			slog.String("task_arn", metadata.TaskARN),
+			slog.Any("error", NewErrorDetail("ReleaseQuota", metadata.TaskARN, err)),
+		)
+	}
+}
+
+// MemoryQuotaStore is a QuotaStore that tracks protected tasks in memory. Entries expire with the
+// protection they were acquired for.
+type MemoryQuotaStore struct {
+	mu       sync.Mutex
+	clusters map[string]map[string]time.Time
+}
+
+// NewMemoryQuotaStore returns an empty MemoryQuotaStore.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{
+		clusters: make(map[string]map[string]time.Time),
+	}
+}
+
+// Acquire implements QuotaStore.
+func (s *MemoryQuotaStore) Acquire(_ context.Context, cluster, taskARN string, limit int, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks, ok := s.clusters[cluster]
+	if !ok {
+		tasks = make(map[string]time.Time)
+		s.clusters[cluster] = tasks
+	}
+
+	now := time.Now()
+	for task, expiry := range tasks {
+		if !expiry.After(now) {
+			delete(tasks, task)
+		}
+	}
+
+	if _, held := tasks[taskARN]; !held && len(tasks) >= limit {
+		return false, nil
+	}
+	tasks[taskARN] = expiresAt
+
+	return true, nil
+}
+
+// Release implements QuotaStore.
+func (s *MemoryQuotaStore) Release(_ context.Context, cluster, taskARN string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.clusters[cluster], taskARN)
+
+	return nil
+}
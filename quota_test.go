@@ -0,0 +1,115 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_UpdateTaskProtection_Quota(t *testing.T) {
+	store := NewMemoryQuotaStore()
+	ctx := context.Background()
+	update := func(c *Client, task string, protect bool) error {
+		_, err := c.UpdateTaskProtection(ctx, &UpdateTaskProtectionInput{
+			Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: task},
+			Protect:  protect,
+		})
+		return err
+	}
+
+	c := NewClient(&SuccessfulTestClient{}, WithQuota(store, 2))
+	assert.NoError(t, update(c, "a", true))
+	assert.NoError(t, update(c, "b", true))
+	assert.NoError(t, update(c, "a", true), "renewing a protected task should not count twice")
+
+	var quotaErr *QuotaExceededError
+	if assert.ErrorAs(t, update(c, "c", true), &quotaErr) {
+		assert.Equal(t, "test_cluster", quotaErr.Cluster)
+		assert.Equal(t, 2, quotaErr.Limit)
+	}
+
+	assert.NoError(t, update(c, "a", false))
+	assert.NoError(t, update(c, "c", true), "unprotecting a task should release its quota")
+
+	failing := NewClient(&FailureTestClient{}, WithQuota(store, 3))
+	assert.NoError(t, update(failing, "d", true))
+	ok, err := store.Acquire(ctx, "test_cluster", "e", 3, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, ok, "failed protection should not hold quota")
+}
+
+func TestClient_UpdateTaskProtection_Quota_FailedRenewal(t *testing.T) {
+	store := NewMemoryQuotaStore()
+	ctx := context.Background()
+	ecsClient := &ExpiringTestClient{}
+	c := NewClient(ecsClient, WithQuota(store, 1))
+	update := func(task string) error {
+		_, err := c.UpdateTaskProtection(ctx, &UpdateTaskProtectionInput{
+			Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: task},
+			Protect:  true,
+		})
+		return err
+	}
+
+	assert.NoError(t, update("a"))
+	ecsClient.fail.Store(true)
+	assert.Error(t, update("a"))
+	ok, err := store.Acquire(ctx, "test_cluster", "b", 1, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, ok, "a failed renewal should keep the quota of the still protected task")
+
+	ecsClient.fail.Store(false)
+	_, err = c.UpdateTaskProtection(ctx, &UpdateTaskProtectionInput{
+		Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "a"},
+	})
+	assert.NoError(t, err)
+	ecsClient.fail.Store(true)
+	assert.Error(t, update("c"))
+	ok, err = store.Acquire(ctx, "test_cluster", "b", 1, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, ok, "failing to protect a task that wasn't protected should not hold quota")
+}
+
+func TestMemoryQuotaStore_Acquire(t *testing.T) {
+	tests := []struct {
+		name      string
+		held      map[string]time.Duration
+		limit     int
+		wantAllow bool
+	}{
+		{
+			name:      "should allow tasks below the limit",
+			held:      map[string]time.Duration{"a": time.Minute},
+			limit:     2,
+			wantAllow: true,
+		},
+		{
+			name:      "should reject tasks at the limit",
+			held:      map[string]time.Duration{"a": time.Minute, "b": time.Minute},
+			limit:     2,
+			wantAllow: false,
+		},
+		{
+			name:      "should not count expired protection",
+			held:      map[string]time.Duration{"a": time.Minute, "b": -time.Minute},
+			limit:     2,
+			wantAllow: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryQuotaStore()
+			s.clusters["test_cluster"] = map[string]time.Time{}
+			for task, d := range tt.held {
+				s.clusters["test_cluster"][task] = time.Now().Add(d)
+			}
+
+			got, err := s.Acquire(context.Background(), "test_cluster", "new", tt.limit, time.Now().Add(time.Minute))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantAllow, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package reconcile keeps a Manager's view of the task consistent with ECS by consuming ECS task
+// state change events, delivered by an EventBridge rule to an SQS queue.
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// DetailTypeTaskStateChange is the EventBridge detail type of ECS task state change events.
+const DetailTypeTaskStateChange = "ECS Task State Change"
+
+// stoppingStatuses are the task statuses from which a task can't return to running.
+var stoppingStatuses = map[string]bool{
+	"DEACTIVATING":   true,
+	"STOPPING":       true,
+	"DEPROVISIONING": true,
+	"STOPPED":        true,
+}
+
+// SQSClient is the subset of the SQS client used by Listener.
+type SQSClient interface {
+	ReceiveMessage(
+		ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options),
+	) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(
+		ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options),
+	) (*sqs.DeleteMessageOutput, error)
+}
+
+// TaskStateChange is the detail of an ECS task state change event.
+type TaskStateChange struct {
+	ClusterARN    string `json:"clusterArn"`
+	TaskARN       string `json:"taskArn"`
+	LastStatus    string `json:"lastStatus"`
+	DesiredStatus string `json:"desiredStatus"`
+	StopCode      string `json:"stopCode,omitempty"`
+	StoppedReason string `json:"stoppedReason,omitempty"`
+}
+
+// Stopping reports whether the event shows the task on its way to being stopped.
+func (c TaskStateChange) Stopping() bool {
+	return c.DesiredStatus == "STOPPED" || stoppingStatuses[c.LastStatus]
+}
+
+type event struct {
+	DetailType string          `json:"detail-type"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// Listener consumes ECS task state change events from an SQS queue and marks Manager's task as
+// stopping when ECS reports it stopping, clearing its local protection state.
+//
+// The queue should only receive events for the Manager's task, e.g. from an EventBridge rule
+// matching its task ARN, as every message is deleted once received. Events for other tasks are
+// ignored.
+type Listener struct {
+	Client   SQSClient
+	QueueURL string
+	Manager  *ecstp.Manager
+	// TaskARN is the task whose events are applied. Defaults to the Manager's task.
+	TaskARN string
+	Logger  *slog.Logger
+}
+
+// Run receives events until ctx is done.
+func (l *Listener) Run(ctx context.Context) error {
+	for {
+		output, err := l.Client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(l.QueueURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     20,
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			l.logger().ErrorContext(ctx, "failed to receive task state change events", slog.String("error", err.Error()))
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		for _, msg := range output.Messages {
+			l.handle(ctx, msg)
+		}
+	}
+}
+
+func (l *Listener) handle(ctx context.Context, msg types.Message) {
+	var ev event
+	if err := json.Unmarshal([]byte(aws.ToString(msg.Body)), &ev); err != nil {
+		l.logger().WarnContext(ctx, "discarding malformed event",
+			slog.String("message_id", aws.ToString(msg.MessageId)),
+			slog.String("error", err.Error()),
+		)
+	} else if ev.DetailType == DetailTypeTaskStateChange {
+		var change TaskStateChange
+		if err := json.Unmarshal(ev.Detail, &change); err == nil {
+			l.Apply(change)
+		}
+	}
+
+	if _, err := l.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(l.QueueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	}); err != nil {
+		l.logger().ErrorContext(ctx, "failed to delete message",
+			slog.String("message_id", aws.ToString(msg.MessageId)),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Apply reconciles the Manager with change, returning whether it applied to the Manager's task.
+func (l *Listener) Apply(change TaskStateChange) bool {
+	taskARN := l.TaskARN
+	if taskARN == "" {
+		taskARN = l.Manager.State().TaskARN
+	}
+	if taskARN == "" || change.TaskARN != taskARN {
+		return false
+	}
+
+	if change.Stopping() {
+		reason := change.StoppedReason
+		if reason == "" {
+			reason = change.StopCode
+		}
+		l.logger().Info("task is stopping, clearing protection state",
+			slog.String("task_arn", change.TaskARN),
+			slog.String("last_status", change.LastStatus),
+			slog.String("desired_status", change.DesiredStatus),
+			slog.String("reason", reason),
+		)
+		l.Manager.MarkStopping(reason)
+	}
+
+	return true
+}
+
+func (l *Listener) logger() *slog.Logger {
+	if l.Logger == nil {
+		return slog.Default()
+	}
+
+	return l.Logger
+}
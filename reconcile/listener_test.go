@@ -0,0 +1,158 @@
+package reconcile
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+type testECSClient struct{}
+
+func (c *testECSClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	return &ecs.UpdateTaskProtectionOutput{
+		ProtectedTasks: []ecstypes.ProtectedTask{{
+			TaskArn:           aws.String(params.Tasks[0]),
+			ProtectionEnabled: params.ProtectionEnabled,
+		}},
+	}, nil
+}
+
+// testSQSClient delivers messages once and records deleted receipt handles.
+type testSQSClient struct {
+	mu       sync.Mutex
+	messages []types.Message
+	deleted  []string
+}
+
+func (c *testSQSClient) ReceiveMessage(
+	ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options),
+) (*sqs.ReceiveMessageOutput, error) {
+	c.mu.Lock()
+	messages := c.messages
+	c.messages = nil
+	c.mu.Unlock()
+
+	if len(messages) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (c *testSQSClient) DeleteMessage(
+	ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options),
+) (*sqs.DeleteMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, aws.ToString(params.ReceiptHandle))
+
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (c *testSQSClient) Deleted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.deleted...)
+}
+
+func newProtectedManager(t *testing.T) *ecstp.Manager {
+	t.Helper()
+
+	m := ecstp.NewManager(ecstp.NewClient(&testECSClient{}), &ecstp.MetadataBody{
+		Cluster: "test_cluster",
+		TaskARN: "test_arn",
+	})
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestListener_Run(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStopping bool
+	}{
+		{
+			name: "should clear protection when the task is stopping",
+			body: `{"detail-type":"ECS Task State Change","source":"aws.ecs","detail":{"taskArn":"test_arn",` +
+				`"lastStatus":"RUNNING","desiredStatus":"STOPPED","stoppedReason":"Scaling activity initiated by (deployment ecs-svc/123)"}}`,
+			wantStopping: true,
+		},
+		{
+			name: "should ignore running tasks",
+			body: `{"detail-type":"ECS Task State Change","source":"aws.ecs","detail":{"taskArn":"test_arn",` +
+				`"lastStatus":"RUNNING","desiredStatus":"RUNNING"}}`,
+		},
+		{
+			name: "should ignore other tasks",
+			body: `{"detail-type":"ECS Task State Change","source":"aws.ecs","detail":{"taskArn":"other_arn",` +
+				`"lastStatus":"STOPPED","desiredStatus":"STOPPED"}}`,
+		},
+		{
+			name: "should ignore other events",
+			body: `{"detail-type":"ECS Container Instance State Change","source":"aws.ecs","detail":{"taskArn":"test_arn"}}`,
+		},
+		{
+			name: "should discard malformed events",
+			body: `not json`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newProtectedManager(t)
+			client := &testSQSClient{messages: []types.Message{
+				{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt"), Body: aws.String(tt.body)},
+			}}
+			l := &Listener{Client: client, QueueURL: "queue", Manager: m}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- l.Run(ctx) }()
+
+			require.Eventually(t, func() bool {
+				return len(client.Deleted()) == 1
+			}, time.Second, 5*time.Millisecond)
+			cancel()
+			assert.ErrorIs(t, <-done, context.Canceled)
+
+			state := m.State()
+			assert.Equal(t, tt.wantStopping, state.Stopping)
+			assert.Equal(t, !tt.wantStopping, state.Protected)
+		})
+	}
+}
+
+func TestTaskStateChange_Stopping(t *testing.T) {
+	tests := []struct {
+		change TaskStateChange
+		want   bool
+	}{
+		{change: TaskStateChange{LastStatus: "RUNNING", DesiredStatus: "RUNNING"}, want: false},
+		{change: TaskStateChange{LastStatus: "PENDING", DesiredStatus: "RUNNING"}, want: false},
+		{change: TaskStateChange{LastStatus: "RUNNING", DesiredStatus: "STOPPED"}, want: true},
+		{change: TaskStateChange{LastStatus: "DEACTIVATING", DesiredStatus: "STOPPED"}, want: true},
+		{change: TaskStateChange{LastStatus: "STOPPED"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.change.LastStatus+"/"+tt.change.DesiredStatus, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.change.Stopping())
+		})
+	}
+}
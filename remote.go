@@ -0,0 +1,269 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// TaskLister is implemented by ECS clients that support the ListTasks API.
+type TaskLister interface {
+	ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error)
+}
+
+// TaskSelector identifies tasks in a cluster, either by service or by task ID or ARN, or selects
+// every running task of the cluster if neither is set.
+type TaskSelector struct {
+	Cluster string
+	// Service selects the running tasks of a service.
+	Service string
+	// Tasks selects tasks by ID or ARN.
+	Tasks []string
+}
+
+// ErrNoTasks is returned by Remote.Resolve when a selector matches no tasks.
+var ErrNoTasks = errors.New("no tasks matched")
+
+// Remote operates task protection from outside the task, e.g. from a workstation with local
+// credentials or a shell opened over ECS Exec, where the task metadata endpoint isn't available.
+//
+// Tasks are resolved by service name or task ID with Resolve, and the resolved metadata is passed
+// explicitly to every call, so no metadata lookup is performed.
+type Remote struct {
+	Client *Client
+}
+
+// NewRemote returns a Remote updating protection through client.
+func NewRemote(client *Client) *Remote {
+	return &Remote{Client: client}
+}
+
+// Resolve returns the metadata of the tasks matched by selector. Resolving a service or a cluster
+// requires an ECS client implementing TaskLister, and resolving task IDs one implementing TaskDescriber.
+func (r *Remote) Resolve(ctx context.Context, selector TaskSelector) ([]MetadataBody, error) {
+	if selector.Cluster == "" {
+		return nil, errors.New("a cluster is required to resolve tasks")
+	}
+
+	arns := selector.Tasks
+	if selector.Service != "" || len(selector.Tasks) == 0 {
+		lister, ok := r.Client.ECSClient.(TaskLister)
+		if !ok {
+			return nil, errors.New("ECS client does not support ListTasks")
+		}
+
+		input := &ecs.ListTasksInput{Cluster: aws.String(selector.Cluster)}
+		if selector.Service != "" {
+			input.ServiceName = aws.String(selector.Service)
+		}
+		paginator := ecs.NewListTasksPaginator(lister, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx, r.Client.ecsOptions(nil)...)
+			if err != nil {
+				return nil, err
+			}
+			arns = append(arns, page.TaskArns...)
+		}
+	}
+	if len(arns) == 0 {
+		return nil, fmt.Errorf("%w in cluster %s", ErrNoTasks, selector.Cluster)
+	}
+
+	// task IDs are expanded to ARNs, so they can be matched against ECS responses
+	if needsDescribe(arns) {
+		var err error
+		if arns, err = r.describeTaskARNs(ctx, selector.Cluster, arns); err != nil {
+			return nil, err
+		}
+	}
+
+	tasks := make([]MetadataBody, len(arns))
+	for i, arn := range arns {
+		tasks[i] = MetadataBody{Cluster: selector.Cluster, TaskARN: arn}
+	}
+
+	return tasks, nil
+}
+
+func (r *Remote) describeTaskARNs(ctx context.Context, cluster string, ids []string) ([]string, error) {
+	describer, ok := r.Client.ECSClient.(TaskDescriber)
+	if !ok {
+		return nil, errors.New("ECS client does not support DescribeTasks")
+	}
+
+	// DescribeTasks accepts up to 100 tasks per call
+	var arns []string
+	for start := 0; start < len(ids); start += 100 {
+		batch := ids[start:min(start+100, len(ids))]
+		out, err := describer.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   batch,
+		}, r.Client.ecsOptions(nil)...)
+		if err != nil {
+			return nil, err
+		}
+		if len(out.Failures) > 0 {
+			failure := out.Failures[0]
+			return nil, fmt.Errorf("unable to resolve task %s: %s", aws.ToString(failure.Arn), aws.ToString(failure.Reason))
+		}
+		for _, task := range out.Tasks {
+			arns = append(arns, aws.ToString(task.TaskArn))
+		}
+	}
+
+	return arns, nil
+}
+
+func needsDescribe(tasks []string) bool {
+	for _, task := range tasks {
+		if !isARN(task) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isARN(s string) bool {
+	return len(s) > 4 && s[:4] == "arn:"
+}
+
+// Protect enables protection of tasks, optionally expiring after expiresInMinutes. The state of
+// every task is returned, along with the errors of the tasks that failed joined together.
+//
+// Tasks are updated as by the UpdateTaskProtection method of the Client, but the updates of tasks
+// of the same cluster are sent together, up to 10 per call.
+func (r *Remote) Protect(ctx context.Context, tasks []MetadataBody, expiresInMinutes *int32) ([]State, error) {
+	return r.update(ctx, tasks, true, expiresInMinutes)
+}
+
+// Unprotect disables protection of tasks.
+func (r *Remote) Unprotect(ctx context.Context, tasks []MetadataBody) ([]State, error) {
+	return r.update(ctx, tasks, false, nil)
+}
+
+func (r *Remote) update(ctx context.Context, tasks []MetadataBody, protect bool, expiresInMinutes *int32) ([]State, error) {
+	outputs, updateErrs := r.Client.updateTasks(ctx, tasks, &UpdateTaskProtectionInput{
+		Protect:          protect,
+		ExpiresInMinutes: expiresInMinutes,
+	})
+
+	states := make([]State, len(tasks))
+	var errs []error
+	for i, task := range tasks {
+		states[i] = State{Cluster: task.Cluster, TaskARN: task.TaskARN}
+
+		err := updateErrs[i]
+		if err == nil {
+			err = protectionResult(task.TaskARN, outputs[i], &states[i])
+		}
+		if err != nil {
+			states[i].LastError = NewErrorDetail(OperationUpdateTaskProtection, task.TaskARN, err)
+			errs = append(errs, err)
+			continue
+		}
+		states[i].UpdatedAt = time.Now().UTC()
+	}
+
+	return states, errors.Join(errs...)
+}
+
+// maxGetTasks is the number of tasks GetTaskProtection accepts per call.
+const maxGetTasks = 100
+
+// Status returns the current protection state of tasks, which must be of the same cluster, as
+// reported by ECS. It requires an ECS client implementing TaskProtectionGetter.
+func (r *Remote) Status(ctx context.Context, tasks []MetadataBody) ([]State, error) {
+	getter, ok := r.Client.ECSClient.(TaskProtectionGetter)
+	if !ok {
+		return nil, ErrGetTaskProtectionUnsupported
+	}
+
+	states := make([]State, len(tasks))
+	for i, task := range tasks {
+		if task.Cluster != tasks[0].Cluster {
+			return nil, errors.New("tasks must be in the same cluster")
+		}
+		states[i] = State{Cluster: task.Cluster, TaskARN: task.TaskARN, UpdatedAt: time.Now().UTC()}
+	}
+
+	for start := 0; start < len(tasks); start += maxGetTasks {
+		batch := tasks[start:min(start+maxGetTasks, len(tasks))]
+		arns := make([]string, len(batch))
+		for i, task := range batch {
+			arns[i] = task.TaskARN
+		}
+
+		out, err := getter.GetTaskProtection(ctx, &ecs.GetTaskProtectionInput{
+			Cluster: aws.String(batch[0].Cluster),
+			Tasks:   arns,
+		}, r.Client.ecsOptions(nil)...)
+		if err != nil {
+			return nil, err
+		}
+		result := NewGetResult(out)
+		for i, task := range batch {
+			state := &states[start+i]
+			protection, ok := result.Task(task.TaskARN)
+			switch {
+			case !ok:
+			case protection.Failed:
+				state.LastError = NewErrorDetail(OperationGetTaskProtection, task.TaskARN, result.Failure(task.TaskARN))
+			default:
+				state.Protected = protection.ProtectionEnabled
+				state.ExpiresAt = protection.ExpiresAt
+			}
+		}
+	}
+
+	return states, nil
+}
+
+// Watch polls the status of tasks every interval, calling fn with the states whenever they change,
+// until ctx is done or fn returns an error.
+func (r *Remote) Watch(ctx context.Context, tasks []MetadataBody, interval time.Duration, fn func([]State) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []State
+	for {
+		states, err := r.Status(ctx, tasks)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		if err == nil && !sameProtection(last, states) {
+			if err := fn(states); err != nil {
+				return err
+			}
+			last = states
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sameProtection reports whether a and b describe the same protection, ignoring when they were
+// observed.
+func sameProtection(a, b []State) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		x.UpdatedAt, y.UpdatedAt = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(x, y) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,255 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RemoteTestClient is a fake ECS cluster whose service "web" runs two tasks, and whose only other
+// task is "c". It counts GetTaskProtection calls in gets.
+type RemoteTestClient struct {
+	mu        sync.Mutex
+	protected map[string]bool
+	gets      int
+}
+
+const remoteTestARNPrefix = "arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/"
+
+func (c *RemoteTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.protected == nil {
+		c.protected = map[string]bool{}
+	}
+
+	output := &ecs.UpdateTaskProtectionOutput{}
+	for _, task := range params.Tasks {
+		c.protected[task] = params.ProtectionEnabled
+		output.ProtectedTasks = append(output.ProtectedTasks, types.ProtectedTask{
+			TaskArn:           aws.String(task),
+			ProtectionEnabled: params.ProtectionEnabled,
+		})
+	}
+
+	return output, nil
+}
+
+func (c *RemoteTestClient) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gets++
+	output := &ecs.GetTaskProtectionOutput{}
+	for _, task := range params.Tasks {
+		output.ProtectedTasks = append(output.ProtectedTasks, types.ProtectedTask{
+			TaskArn:           aws.String(task),
+			ProtectionEnabled: c.protected[task],
+		})
+	}
+
+	return output, nil
+}
+
+func (c *RemoteTestClient) ListTasks(
+	ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options),
+) (*ecs.ListTasksOutput, error) {
+	switch aws.ToString(params.ServiceName) {
+	case "":
+		return &ecs.ListTasksOutput{TaskArns: []string{remoteTestARNPrefix + "a", remoteTestARNPrefix + "b", remoteTestARNPrefix + "c"}}, nil
+	case "web":
+	default:
+		return &ecs.ListTasksOutput{}, nil
+	}
+
+	return &ecs.ListTasksOutput{TaskArns: []string{remoteTestARNPrefix + "a", remoteTestARNPrefix + "b"}}, nil
+}
+
+func (c *RemoteTestClient) DescribeTasks(
+	ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options),
+) (*ecs.DescribeTasksOutput, error) {
+	output := &ecs.DescribeTasksOutput{}
+	for _, task := range params.Tasks {
+		if task == "missing" {
+			output.Failures = append(output.Failures, types.Failure{Arn: aws.String(task), Reason: aws.String("MISSING")})
+			continue
+		}
+		if !strings.HasPrefix(task, "arn:") {
+			task = remoteTestARNPrefix + task
+		}
+		output.Tasks = append(output.Tasks, types.Task{TaskArn: aws.String(task)})
+	}
+
+	return output, nil
+}
+
+func TestRemote_Resolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector TaskSelector
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "should resolve the tasks of a service",
+			selector: TaskSelector{Cluster: "test_cluster", Service: "web"},
+			want:     []string{remoteTestARNPrefix + "a", remoteTestARNPrefix + "b"},
+		},
+		{
+			name:     "should resolve every task of a cluster",
+			selector: TaskSelector{Cluster: "test_cluster"},
+			want:     []string{remoteTestARNPrefix + "a", remoteTestARNPrefix + "b", remoteTestARNPrefix + "c"},
+		},
+		{
+			name:     "should expand task IDs to ARNs",
+			selector: TaskSelector{Cluster: "test_cluster", Tasks: []string{"c", remoteTestARNPrefix + "d"}},
+			want:     []string{remoteTestARNPrefix + "c", remoteTestARNPrefix + "d"},
+		},
+		{
+			name:     "should fail for unknown tasks",
+			selector: TaskSelector{Cluster: "test_cluster", Tasks: []string{"missing"}},
+			wantErr:  true,
+		},
+		{
+			name:     "should fail when nothing matches",
+			selector: TaskSelector{Cluster: "test_cluster", Service: "worker"},
+			wantErr:  true,
+		},
+		{
+			name:     "should require a cluster",
+			selector: TaskSelector{Service: "web"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRemote(NewClient(&RemoteTestClient{}))
+
+			got, err := r.Resolve(context.Background(), tt.selector)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			var arns []string
+			for _, task := range got {
+				assert.Equal(t, "test_cluster", task.Cluster)
+				arns = append(arns, task.TaskARN)
+			}
+			assert.Equal(t, tt.want, arns)
+		})
+	}
+}
+
+func TestRemote_ProtectStatus(t *testing.T) {
+	r := NewRemote(NewClient(&RemoteTestClient{}))
+	tasks, err := r.Resolve(context.Background(), TaskSelector{Cluster: "test_cluster", Service: "web"})
+	require.NoError(t, err)
+
+	states, err := r.Protect(context.Background(), tasks, aws.Int32(30))
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	assert.True(t, states[0].Protected)
+
+	states, err = r.Status(context.Background(), tasks)
+	require.NoError(t, err)
+	assert.True(t, states[0].Protected)
+	assert.True(t, states[1].Protected)
+
+	_, err = r.Unprotect(context.Background(), tasks[:1])
+	require.NoError(t, err)
+	states, err = r.Status(context.Background(), tasks)
+	require.NoError(t, err)
+	assert.False(t, states[0].Protected)
+	assert.True(t, states[1].Protected)
+}
+
+func TestRemote_Status(t *testing.T) {
+	tests := []struct {
+		name      string
+		tasks     int
+		cluster   func(i int) string
+		wantGets  int
+		wantError bool
+	}{
+		{
+			name:     "should get the protection of up to 100 tasks per call",
+			tasks:    150,
+			wantGets: 2,
+		},
+		{
+			name:  "should reject tasks of different clusters",
+			tasks: 20,
+			cluster: func(i int) string {
+				if i == 15 {
+					return "other_cluster"
+				}
+				return "test_cluster"
+			},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &RemoteTestClient{}
+			tasks := make([]MetadataBody, tt.tasks)
+			for i := range tasks {
+				tasks[i] = MetadataBody{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + fmt.Sprint(i)}
+				if tt.cluster != nil {
+					tasks[i].Cluster = tt.cluster(i)
+				}
+			}
+
+			states, err := NewRemote(NewClient(client)).Status(context.Background(), tasks)
+
+			if tt.wantError {
+				assert.Error(t, err)
+				assert.Zero(t, client.gets, "no call should be made for invalid input")
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, states, tt.tasks)
+			assert.Equal(t, tt.wantGets, client.gets)
+		})
+	}
+}
+
+func TestRemote_Watch(t *testing.T) {
+	r := NewRemote(NewClient(&RemoteTestClient{}))
+	tasks := []MetadataBody{{Cluster: "test_cluster", TaskARN: remoteTestARNPrefix + "a"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan []State, 10)
+	done := make(chan error)
+	go func() {
+		done <- r.Watch(ctx, tasks, 5*time.Millisecond, func(states []State) error {
+			changes <- states
+			return nil
+		})
+	}()
+
+	assert.False(t, (<-changes)[0].Protected)
+	_, err := r.Protect(context.Background(), tasks, nil)
+	require.NoError(t, err)
+	assert.True(t, (<-changes)[0].Protected)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Empty(t, changes, "unchanged states should not be reported")
+}
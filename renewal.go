@@ -0,0 +1,413 @@
+package ecstp
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRenewalExpiry is the default protection period set by each renewal of a Renewer.
+	DefaultRenewalExpiry = 30 * time.Minute
+	// MaxExpiresInMinutes is the longest protection period ECS accepts.
+	MaxExpiresInMinutes = 2880
+)
+
+// EscalatingExpiry is a RenewalStrategy lengthening the protection period as protection is held,
+// starting with Initial and growing in proportion to the time protection has already been held, up
+// to Max.
+//
+// A job that dies early then blocks scale-in for at most Initial, while a job that has been running
+// for hours is renewed rarely.
+type EscalatingExpiry struct {
+	// Initial is the first protection period. Defaults to 15 minutes.
+	Initial time.Duration
+	// Factor is the protection period relative to the time protection has been held. Defaults
+	// to 1, e.g. a job that has run for an hour is protected for another hour.
+	Factor float64
+	// Max caps the protection period. Defaults to 4 hours.
+	Max time.Duration
+}
+
+// Expiry returns the protection period once protection has been held for held.
+func (e EscalatingExpiry) Expiry(held time.Duration) time.Duration {
+	initial, factor, limit := e.Initial, e.Factor, e.Max
+	if initial <= 0 {
+		initial = 15 * time.Minute
+	}
+	if factor <= 0 {
+		factor = 1
+	}
+	if limit <= 0 {
+		limit = 4 * time.Hour
+	}
+
+	return min(max(initial, time.Duration(float64(held)*factor)), limit)
+}
+
+// RenewalStrategy decides when a Renewer renews protection and for how long.
+type RenewalStrategy interface {
+	// NextRenewal returns when to renew protection, given the state after the last update.
+	NextRenewal(state State) time.Time
+	// NextExpiry returns the protection period to set with the next update, given the current
+	// state.
+	NextExpiry(state State) time.Duration
+}
+
+// FixedInterval renews protection every Interval, setting a protection period of Expiry.
+type FixedInterval struct {
+	// Interval defaults to half of Expiry.
+	Interval time.Duration
+	// Expiry defaults to DefaultRenewalExpiry.
+	Expiry time.Duration
+}
+
+// NextRenewal implements RenewalStrategy.
+func (f FixedInterval) NextRenewal(state State) time.Time {
+	interval := f.Interval
+	if interval <= 0 {
+		interval = defaultExpiry(f.Expiry) / 2
+	}
+
+	return updatedAt(state).Add(interval)
+}
+
+// NextExpiry implements RenewalStrategy.
+func (f FixedInterval) NextExpiry(State) time.Duration {
+	return defaultExpiry(f.Expiry)
+}
+
+// FractionOfTTL renews protection once Fraction of the protection period has passed, setting a
+// protection period of Expiry. It's the default strategy of a Renewer.
+type FractionOfTTL struct {
+	// Expiry defaults to DefaultRenewalExpiry.
+	Expiry time.Duration
+	// Fraction defaults to 0.5.
+	Fraction float64
+}
+
+// NextRenewal implements RenewalStrategy.
+func (f FractionOfTTL) NextRenewal(state State) time.Time {
+	fraction := f.Fraction
+	if fraction <= 0 || fraction >= 1 {
+		fraction = 0.5
+	}
+
+	return updatedAt(state).Add(time.Duration(float64(ttl(state, defaultExpiry(f.Expiry))) * fraction))
+}
+
+// NextExpiry implements RenewalStrategy.
+func (f FractionOfTTL) NextExpiry(State) time.Duration {
+	return defaultExpiry(f.Expiry)
+}
+
+// Adaptive renews protection halfway through the remaining protection period, and retries every
+// RetryInterval after a failed renewal, so renewals are retried several times before protection
+// lapses.
+type Adaptive struct {
+	// Expiry defaults to DefaultRenewalExpiry.
+	Expiry time.Duration
+	// RetryInterval defaults to 10 seconds.
+	RetryInterval time.Duration
+}
+
+// NextRenewal implements RenewalStrategy.
+func (a Adaptive) NextRenewal(state State) time.Time {
+	now := time.Now()
+	if state.LastError != nil {
+		retry := a.RetryInterval
+		if retry <= 0 {
+			retry = 10 * time.Second
+		}
+		if state.ExpiresAt != nil {
+			retry = min(retry, max(state.ExpiresAt.Sub(now)/2, time.Second))
+		}
+		return now.Add(retry)
+	}
+	if state.ExpiresAt != nil {
+		return now.Add(state.ExpiresAt.Sub(now) / 2)
+	}
+
+	return updatedAt(state).Add(defaultExpiry(a.Expiry) / 2)
+}
+
+// NextExpiry implements RenewalStrategy.
+func (a Adaptive) NextExpiry(State) time.Duration {
+	return defaultExpiry(a.Expiry)
+}
+
+// NextRenewal implements RenewalStrategy, renewing halfway through the protection period.
+func (e EscalatingExpiry) NextRenewal(state State) time.Time {
+	return updatedAt(state).Add(ttl(state, e.Expiry(held(state))) / 2)
+}
+
+// NextExpiry implements RenewalStrategy.
+func (e EscalatingExpiry) NextExpiry(state State) time.Duration {
+	return e.Expiry(held(state))
+}
+
+// updatedAt returns when state was last updated, or now if it never was.
+func updatedAt(state State) time.Time {
+	if state.UpdatedAt.IsZero() {
+		return time.Now()
+	}
+
+	return state.UpdatedAt
+}
+
+// ttl returns the protection period set by the last update, or fallback if ECS didn't report an
+// expiry.
+func ttl(state State, fallback time.Duration) time.Duration {
+	if state.ExpiresAt == nil || state.UpdatedAt.IsZero() {
+		return fallback
+	}
+
+	return state.ExpiresAt.Sub(state.UpdatedAt)
+}
+
+// held returns how long protection has been held continuously.
+func held(state State) time.Duration {
+	if !state.Protected || state.ProtectedSince == nil {
+		return 0
+	}
+
+	return time.Since(*state.ProtectedSince)
+}
+
+func defaultExpiry(expiry time.Duration) time.Duration {
+	if expiry <= 0 {
+		return DefaultRenewalExpiry
+	}
+
+	return expiry
+}
+
+// Renewer keeps protection enabled through a Manager, renewing it as decided by Strategy until its
+// context is done.
+//
+// If HeartbeatTimeout is set, protection is only renewed while Heartbeat is called at least every
+// HeartbeatTimeout. Once heartbeats stop, e.g. because the worker is wedged or finished without
+// releasing protection, EventHeartbeatMissed is published and protection is left to lapse at its
+// expiry. A later heartbeat enables protection again.
+//
+// Protection periods longer than MaxExpiresInMinutes are set in chunks of MaxExpiresInMinutes, each
+// renewed at the latest halfway through.
+type Renewer struct {
+	Manager *Manager
+	// Strategy defaults to the Renewal of the Client's Profile, or FractionOfTTL with
+	// DefaultRenewalExpiry.
+	Strategy         RenewalStrategy
+	HeartbeatTimeout time.Duration
+	// Jitter brings every renewal forward by a random fraction, up to Jitter, of the time until
+	// it, e.g. 0.1, so that tasks started together don't renew in lockstep.
+	Jitter float64
+	// OnError is called with every failed renewal, in addition to it being logged.
+	OnError func(err error)
+	Logger  *slog.Logger
+
+	mu       sync.Mutex
+	lastBeat time.Time
+	lapsed   bool
+	resuming bool
+	resumed  chan State
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// Start runs the Renewer in the background until ctx is done or Stop is called. Failing to enable
+// protection in the first place is reported to OnError and logged like a failed renewal. If the
+// Renewer was already started, the previous run is stopped first, waiting for it to return.
+func (r *Renewer) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	r.mu.Lock()
+	prevCancel, prevDone := r.cancel, r.done
+	r.cancel, r.done = cancel, done
+	r.mu.Unlock()
+
+	if prevCancel != nil {
+		prevCancel()
+		<-prevDone
+	}
+	go func() {
+		defer close(done)
+		if err := r.Run(ctx); ctx.Err() == nil {
+			r.renewalFailed(ctx, r.strategy().NextExpiry(r.Manager.State()), err)
+		}
+	}()
+}
+
+// Stop stops a Renewer started with Start, waiting for it to return. Protection is left enabled,
+// to be released with Manager.Unprotect.
+func (r *Renewer) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+// Heartbeat records that the worker is alive, enabling protection again if it was left to lapse
+// after missed heartbeats.
+func (r *Renewer) Heartbeat(ctx context.Context) error {
+	r.mu.Lock()
+	r.lastBeat = time.Now()
+	if !r.lapsed || r.resuming {
+		r.mu.Unlock()
+		return nil
+	}
+	r.resuming = true
+	resumed := r.resumed
+	r.mu.Unlock()
+
+	// protection is enabled without holding r.mu, so that the run loop isn't blocked on the call
+	state, err := r.Manager.Protect(ctx, expiresInMinutes(r.strategy().NextExpiry(r.Manager.State())))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resuming = false
+	if err != nil {
+		return err
+	}
+	r.lapsed = false
+	select {
+	case resumed <- state:
+	default:
+	}
+
+	return nil
+}
+
+// Run enables protection and renews it until ctx is done, returning ctx.Err(). Failed renewals are
+// logged and retried at the next renewal. Protection is left enabled, to be released with
+// Manager.Unprotect once the work is done.
+func (r *Renewer) Run(ctx context.Context) error {
+	strategy := r.strategy()
+	r.mu.Lock()
+	r.lastBeat, r.lapsed = time.Now(), false
+	r.resumed = make(chan State, 1)
+	r.mu.Unlock()
+
+	state, err := r.Manager.Protect(ctx, expiresInMinutes(strategy.NextExpiry(r.Manager.State())))
+	if err != nil {
+		return err
+	}
+
+	var heartbeatCheck <-chan time.Time
+	if r.HeartbeatTimeout > 0 {
+		ticker := time.NewTicker(r.HeartbeatTimeout / 4)
+		defer ticker.Stop()
+		heartbeatCheck = ticker.C
+	}
+
+	timer := time.NewTimer(r.untilRenewal(strategy, state))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-heartbeatCheck:
+			r.checkHeartbeat(ctx)
+			continue
+		case state = <-r.resumed:
+			timer.Reset(r.untilRenewal(strategy, state))
+			continue
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		// renewal stops once heartbeats are missed, until the next heartbeat
+		if r.checkHeartbeat(ctx) {
+			continue
+		}
+		expiry := strategy.NextExpiry(r.Manager.State())
+		if state, err = r.Manager.Protect(ctx, expiresInMinutes(expiry)); err != nil && ctx.Err() == nil {
+			r.renewalFailed(ctx, expiry, err)
+		}
+		timer.Reset(r.untilRenewal(strategy, state))
+	}
+}
+
+// untilRenewal returns the time until the next renewal after state, as decided by strategy, brought
+// forward so that protection periods are renewed in chunks and by Jitter.
+func (r *Renewer) untilRenewal(strategy RenewalStrategy, state State) time.Duration {
+	renewal := strategy.NextRenewal(state)
+	if latest := updatedAt(state).Add(MaxExpiresInMinutes * time.Minute / 2); renewal.After(latest) {
+		renewal = latest
+	}
+
+	until := time.Until(renewal)
+	if r.Jitter > 0 && until > 0 {
+		until -= time.Duration(rand.Float64() * min(r.Jitter, 1) * float64(until))
+	}
+
+	return until
+}
+
+// renewalFailed logs a failed renewal and reports it to OnError.
+func (r *Renewer) renewalFailed(ctx context.Context, expiry time.Duration, err error) {
+	r.logger().ErrorContext(ctx, "unable to renew protection",
+		slog.Duration("expiry", expiry),
+		slog.Any("error", err),
+	)
+	if r.OnError != nil {
+		r.OnError(err)
+	}
+}
+
+// checkHeartbeat reports whether heartbeats have been missed, publishing EventHeartbeatMissed the
+// first time they are.
+func (r *Renewer) checkHeartbeat(ctx context.Context) bool {
+	if r.HeartbeatTimeout <= 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lapsed {
+		return true
+	}
+	since := time.Since(r.lastBeat)
+	if since < r.HeartbeatTimeout {
+		return false
+	}
+
+	r.lapsed = true
+	state := r.Manager.State()
+	r.logger().WarnContext(ctx, "heartbeats missed, leaving protection to lapse",
+		slog.Duration("since_last_heartbeat", since),
+	)
+	r.Manager.publish(EventHeartbeatMissed, state)
+
+	return true
+}
+
+func (r *Renewer) strategy() RenewalStrategy {
+	if r.Strategy == nil {
+		return r.Manager.client.renewalStrategy(FractionOfTTL{})
+	}
+
+	return r.Strategy
+}
+
+func (r *Renewer) logger() *slog.Logger {
+	if r.Logger == nil {
+		return slog.Default()
+	}
+
+	return r.Logger
+}
+
+// expiresInMinutes converts a protection period to the whole minutes accepted by ECS, rounding up
+// and clamping it to the allowed range.
+func expiresInMinutes(d time.Duration) *int32 {
+	minutes := int32(min(max((d+time.Minute-1)/time.Minute, 1), MaxExpiresInMinutes))
+
+	return &minutes
+}
@@ -0,0 +1,386 @@
+package ecstp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalatingExpiry_Expiry(t *testing.T) {
+	tests := []struct {
+		name       string
+		escalation EscalatingExpiry
+		held       time.Duration
+		want       time.Duration
+	}{
+		{
+			name: "should start with the initial expiry",
+			want: 15 * time.Minute,
+		},
+		{
+			name: "should keep the initial expiry for short jobs",
+			held: 10 * time.Minute,
+			want: 15 * time.Minute,
+		},
+		{
+			name: "should lengthen the expiry as the job keeps running",
+			held: time.Hour,
+			want: time.Hour,
+		},
+		{
+			name:       "should apply the factor",
+			escalation: EscalatingExpiry{Initial: 5 * time.Minute, Factor: 0.5},
+			held:       time.Hour,
+			want:       30 * time.Minute,
+		},
+		{
+			name:       "should cap the expiry",
+			escalation: EscalatingExpiry{Max: 2 * time.Hour},
+			held:       10 * time.Hour,
+			want:       2 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.escalation.Expiry(tt.held))
+		})
+	}
+}
+
+func TestRenewalStrategy(t *testing.T) {
+	updated := time.Now().Add(-time.Minute)
+	expires := updated.Add(time.Hour)
+	since := updated.Add(-2 * time.Hour)
+	protected := State{Protected: true, UpdatedAt: updated, ExpiresAt: &expires, ProtectedSince: &since}
+	unknownExpiry := State{Protected: true, UpdatedAt: updated}
+
+	tests := []struct {
+		name        string
+		strategy    RenewalStrategy
+		state       State
+		wantRenewal time.Time
+		wantExpiry  time.Duration
+	}{
+		{
+			name:        "fixed interval should renew every interval",
+			strategy:    FixedInterval{Interval: 5 * time.Minute, Expiry: time.Hour},
+			state:       protected,
+			wantRenewal: updated.Add(5 * time.Minute),
+			wantExpiry:  time.Hour,
+		},
+		{
+			name:        "fixed interval should default to half the expiry",
+			strategy:    FixedInterval{},
+			state:       unknownExpiry,
+			wantRenewal: updated.Add(DefaultRenewalExpiry / 2),
+			wantExpiry:  DefaultRenewalExpiry,
+		},
+		{
+			name:        "fraction of TTL should renew after the fraction of the reported TTL",
+			strategy:    FractionOfTTL{Fraction: 0.75},
+			state:       protected,
+			wantRenewal: updated.Add(45 * time.Minute),
+			wantExpiry:  DefaultRenewalExpiry,
+		},
+		{
+			name:        "fraction of TTL should fall back to the expiry",
+			strategy:    FractionOfTTL{Expiry: 10 * time.Minute},
+			state:       unknownExpiry,
+			wantRenewal: updated.Add(5 * time.Minute),
+			wantExpiry:  10 * time.Minute,
+		},
+		{
+			name:        "escalating should lengthen the expiry with the time held",
+			strategy:    EscalatingExpiry{},
+			state:       protected,
+			wantRenewal: updated.Add(30 * time.Minute),
+			wantExpiry:  2*time.Hour + time.Minute,
+		},
+		{
+			name:       "escalating should start with the initial expiry",
+			strategy:   EscalatingExpiry{Initial: 10 * time.Minute},
+			state:      State{},
+			wantExpiry: 10 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.wantRenewal.IsZero() {
+				assert.WithinDuration(t, tt.wantRenewal, tt.strategy.NextRenewal(tt.state), time.Second)
+			}
+			assert.InDelta(t, tt.wantExpiry, tt.strategy.NextExpiry(tt.state), float64(time.Second))
+		})
+	}
+}
+
+func TestAdaptive_NextRenewal(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	soon := now.Add(4 * time.Second)
+
+	tests := []struct {
+		name  string
+		state State
+		want  time.Duration
+	}{
+		{
+			name:  "should renew halfway through the remaining protection",
+			state: State{Protected: true, UpdatedAt: now, ExpiresAt: &expires},
+			want:  30 * time.Minute,
+		},
+		{
+			name:  "should retry failed renewals",
+			state: State{Protected: true, ExpiresAt: &expires, LastError: &ErrorDetail{}},
+			want:  10 * time.Second,
+		},
+		{
+			name:  "should retry before protection lapses",
+			state: State{Protected: true, ExpiresAt: &soon, LastError: &ErrorDetail{}},
+			want:  2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.WithinDuration(t, now.Add(tt.want), Adaptive{}.NextRenewal(tt.state), 100*time.Millisecond)
+		})
+	}
+}
+
+func Test_expiresInMinutes(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int32
+	}{
+		{d: 0, want: 1},
+		{d: 30 * time.Second, want: 1},
+		{d: 90 * time.Second, want: 2},
+		{d: time.Hour, want: 60},
+		{d: 100 * time.Hour, want: MaxExpiresInMinutes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, *expiresInMinutes(tt.d))
+		})
+	}
+}
+
+// RenewalTestClient records the protection period of every UpdateTaskProtection call.
+type RenewalTestClient struct {
+	SuccessfulTestClient
+
+	mu      sync.Mutex
+	expires []int32
+}
+
+func (c *RenewalTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	c.mu.Lock()
+	c.expires = append(c.expires, *params.ExpiresInMinutes)
+	c.mu.Unlock()
+
+	return c.SuccessfulTestClient.UpdateTaskProtection(ctx, params, optFns...)
+}
+
+func (c *RenewalTestClient) Expires() []int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]int32(nil), c.expires...)
+}
+
+func TestRenewer_Run(t *testing.T) {
+	ecsClient := &RenewalTestClient{}
+	m := NewManager(NewClient(ecsClient), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	// renewals are due every 30ms, half of the protection period
+	r := &Renewer{Manager: m, Strategy: FractionOfTTL{Expiry: 60 * time.Millisecond}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool { return len(ecsClient.Expires()) >= 3 }, time.Second, 5*time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	for _, minutes := range ecsClient.Expires() {
+		assert.Equal(t, int32(1), minutes)
+	}
+	assert.True(t, m.State().Protected, "protection should be left enabled")
+}
+
+func TestRenewer_Run_ProtectError(t *testing.T) {
+	m := NewManager(NewClient(&FailureTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	r := &Renewer{Manager: m, Strategy: EscalatingExpiry{}}
+
+	assert.Error(t, r.Run(context.Background()))
+}
+
+func TestRenewer_Heartbeat(t *testing.T) {
+	ecsClient := &RenewalTestClient{}
+	m := NewManager(NewClient(ecsClient), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	r := &Renewer{
+		Manager:          m,
+		Strategy:         FixedInterval{Interval: 10 * time.Millisecond},
+		HeartbeatTimeout: 40 * time.Millisecond,
+	}
+	events, cancelEvents := m.Subscribe()
+	defer cancelEvents()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	// renewals continue while heartbeats arrive
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		require.NoError(t, r.Heartbeat(ctx))
+	}
+	assert.GreaterOrEqual(t, len(ecsClient.Expires()), 5)
+
+	// renewals stop once heartbeats do
+	var event Event
+	for event = range events {
+		if event.Type == EventHeartbeatMissed {
+			break
+		}
+	}
+	assert.Equal(t, EventHeartbeatMissed, event.Type)
+	calls := len(ecsClient.Expires())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, calls, len(ecsClient.Expires()), "protection should be left to lapse")
+
+	// a later heartbeat resumes protection
+	require.NoError(t, r.Heartbeat(ctx))
+	assert.Eventually(t, func() bool { return len(ecsClient.Expires()) >= calls+3 }, time.Second, 5*time.Millisecond)
+}
+
+func TestRenewer_Heartbeat_Resuming(t *testing.T) {
+	client := &GatedTestClient{gate: make(chan struct{})}
+	m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	r := &Renewer{
+		Manager:          m,
+		Strategy:         FixedInterval{Interval: time.Minute},
+		HeartbeatTimeout: time.Minute,
+		lapsed:           true,
+		resumed:          make(chan State, 1),
+	}
+
+	resumed := make(chan error, 1)
+	go func() { resumed <- r.Heartbeat(context.Background()) }()
+	require.Eventually(t, func() bool { return m.Phase() == PhaseProtecting }, time.Second, time.Millisecond)
+
+	// the run loop and other heartbeats aren't blocked while protection is enabled again
+	checked := make(chan bool, 1)
+	go func() { checked <- r.checkHeartbeat(context.Background()) }()
+	select {
+	case lapsed := <-checked:
+		assert.True(t, lapsed, "protection should count as lapsed until it has been enabled again")
+	case <-time.After(time.Second):
+		t.Fatal("checkHeartbeat blocked on the call of Heartbeat")
+	}
+	require.NoError(t, r.Heartbeat(context.Background()))
+
+	close(client.gate)
+	require.NoError(t, <-resumed)
+	assert.Equal(t, int32(1), client.protects.Load(), "protection should be enabled again once")
+	assert.False(t, r.checkHeartbeat(context.Background()))
+	assert.Len(t, r.resumed, 1)
+}
+
+func TestRenewer_untilRenewal(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		strategy RenewalStrategy
+		jitter   float64
+		wantMin  time.Duration
+		wantMax  time.Duration
+	}{
+		{
+			name:     "should renew as decided by the strategy",
+			strategy: FixedInterval{Interval: time.Hour},
+			wantMin:  time.Hour,
+			wantMax:  time.Hour,
+		},
+		{
+			name:     "should renew periods beyond the ECS maximum in chunks",
+			strategy: FixedInterval{Expiry: 100 * time.Hour},
+			wantMin:  MaxExpiresInMinutes * time.Minute / 2,
+			wantMax:  MaxExpiresInMinutes * time.Minute / 2,
+		},
+		{
+			name:     "should bring renewals forward by the jitter",
+			strategy: FixedInterval{Interval: time.Hour},
+			jitter:   0.1,
+			wantMin:  54 * time.Minute,
+			wantMax:  time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Renewer{Jitter: tt.jitter}
+
+			got := r.untilRenewal(tt.strategy, State{Protected: true, UpdatedAt: now})
+			assert.GreaterOrEqual(t, got, tt.wantMin-time.Second)
+			assert.LessOrEqual(t, got, tt.wantMax)
+		})
+	}
+}
+
+func TestRenewer_StartStop(t *testing.T) {
+	ecsClient := &ExpiringTestClient{}
+	m := NewManager(NewClient(ecsClient), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	renewalErrs := make(chan error, 10)
+	r := &Renewer{
+		Manager:  m,
+		Strategy: FixedInterval{Interval: 10 * time.Millisecond},
+		OnError: func(err error) {
+			select {
+			case renewalErrs <- err:
+			default:
+			}
+		},
+	}
+
+	r.Start(context.Background())
+	assert.Eventually(t, func() bool { return m.State().Protected }, time.Second, 5*time.Millisecond)
+
+	ecsClient.fail.Store(true)
+	select {
+	case err := <-renewalErrs:
+		assert.ErrorContains(t, err, "throttled")
+	case <-time.After(time.Second):
+		t.Fatal("failed renewals should be reported")
+	}
+
+	r.Stop()
+	assert.True(t, m.State().Protected, "protection should be left enabled")
+}
+
+func TestRenewer_Start_Again(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	r := &Renewer{Manager: m, Strategy: FixedInterval{Interval: 10 * time.Millisecond}}
+
+	r.Start(context.Background())
+	r.mu.Lock()
+	first := r.done
+	r.mu.Unlock()
+
+	r.Start(context.Background())
+	select {
+	case <-first:
+	default:
+		t.Fatal("starting again should stop the previous run")
+	}
+	assert.Eventually(t, func() bool { return m.State().Protected }, time.Second, 5*time.Millisecond)
+	r.Stop()
+}
@@ -0,0 +1,220 @@
+package ecstp
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Result holds the per-task outcome of a task protection call, which ECS reports as two parallel
+// lists of protected tasks and failures.
+type Result struct {
+	ProtectedTasks []types.ProtectedTask
+	Failures       []types.Failure
+}
+
+// TaskResult is the outcome of a task protection call for a single task.
+type TaskResult struct {
+	TaskARN           string
+	ProtectionEnabled bool
+	ExpiresAt         *time.Time
+	// Failed is set if ECS reported a failure for the task, described by FailureReason and
+	// FailureDetail.
+	Failed        bool
+	FailureReason string
+	FailureDetail string
+}
+
+// NewUpdateResult returns the Result of an UpdateTaskProtection call.
+func NewUpdateResult(output *ecs.UpdateTaskProtectionOutput) Result {
+	if output == nil {
+		return Result{}
+	}
+
+	return Result{ProtectedTasks: output.ProtectedTasks, Failures: output.Failures}
+}
+
+// NewGetResult returns the Result of a GetTaskProtection call, e.g. one made with the SDK
+// directly.
+func NewGetResult(output *ecs.GetTaskProtectionOutput) Result {
+	if output == nil {
+		return Result{}
+	}
+
+	return Result{ProtectedTasks: output.ProtectedTasks, Failures: output.Failures}
+}
+
+// Expiry returns when protection of the task expires, or false if the task isn't protected or
+// ECS reported no expiry.
+func (r TaskResult) Expiry() (time.Time, bool) {
+	if !r.ProtectionEnabled || r.ExpiresAt == nil {
+		return time.Time{}, false
+	}
+
+	return r.ExpiresAt.UTC(), true
+}
+
+// ProtectedAt reports whether the task is protected at t, i.e. protection is enabled and hasn't
+// expired by t.
+func (r TaskResult) ProtectedAt(t time.Time) bool {
+	expiry, ok := r.Expiry()
+
+	return r.ProtectionEnabled && !r.Failed && (!ok || expiry.After(t))
+}
+
+// ByTask returns the outcome for every task in r keyed by task ARN. A task reported as both
+// protected and failed is considered failed.
+func (r Result) ByTask() map[string]TaskResult {
+	results := make(map[string]TaskResult, len(r.ProtectedTasks)+len(r.Failures))
+	for _, task := range r.ProtectedTasks {
+		arn := aws.ToString(task.TaskArn)
+		results[arn] = TaskResult{
+			TaskARN:           arn,
+			ProtectionEnabled: task.ProtectionEnabled,
+			ExpiresAt:         task.ExpirationDate,
+		}
+	}
+	for _, failure := range r.Failures {
+		arn := aws.ToString(failure.Arn)
+		results[arn] = TaskResult{
+			TaskARN:       arn,
+			Failed:        true,
+			FailureReason: aws.ToString(failure.Reason),
+			FailureDetail: aws.ToString(failure.Detail),
+		}
+	}
+
+	return results
+}
+
+// Single returns the outcome for the only task in r, e.g. of a call made by a Client, which always
+// updates or reads a single task. It returns false if r reports no task or several.
+func (r Result) Single() (TaskResult, bool) {
+	results := r.ByTask()
+	if len(results) != 1 {
+		return TaskResult{}, false
+	}
+	for _, result := range results {
+		return result, true
+	}
+
+	return TaskResult{}, false
+}
+
+// TaskARNMismatchError is returned when ECS reports the outcome of a task protection call for tasks
+// other than those requested, e.g. because of a metadata override copied from another task.
+type TaskARNMismatchError struct {
+	// Requested are the tasks the call was made for.
+	Requested []string
+	// Unexpected are the tasks ECS reported that weren't requested.
+	Unexpected []string
+}
+
+// Error implements error.
+func (e *TaskARNMismatchError) Error() string {
+	return fmt.Sprintf("task protection response reports tasks %v, which weren't requested in %v", e.Unexpected, e.Requested)
+}
+
+// Verify checks that r only reports the outcome of the tasks identified by taskARNs, by ARN or ID,
+// returning a *TaskARNMismatchError otherwise. Requested tasks missing from r aren't reported by
+// Verify; ByTask can be used to find them.
+func (r Result) Verify(taskARNs ...string) error {
+	var unexpected []string
+	for arn := range r.ByTask() {
+		if !containsTask(taskARNs, arn) {
+			unexpected = append(unexpected, arn)
+		}
+	}
+	if len(unexpected) > 0 {
+		slices.Sort(unexpected)
+		return &TaskARNMismatchError{Requested: taskARNs, Unexpected: unexpected}
+	}
+
+	return nil
+}
+
+// ProtectionFailureError is returned by a Client created with WithFailOnFailures when ECS reports a
+// failure for the task, or doesn't report it at all, in an otherwise successful call.
+type ProtectionFailureError struct {
+	TaskARN string
+	// Reason is the reason reported by ECS, e.g. TASK_NOT_VALID, and Detail its optional detail.
+	Reason string
+	Detail string
+}
+
+// Error implements error.
+func (e *ProtectionFailureError) Error() string {
+	msg := fmt.Sprintf("task protection failed for %s: %s", e.TaskARN, e.Reason)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+
+	return msg
+}
+
+// Is reports whether target is the sentinel error of the class of the reason, e.g. ErrTaskNotFound.
+func (e *ProtectionFailureError) Is(target error) bool {
+	class := errorClass(e.Reason, e.Detail)
+
+	return class != nil && class == target
+}
+
+// Failure returns a *ProtectionFailureError if r reports a failure for taskARN, by ARN or ID, or
+// doesn't report it, and nil otherwise.
+func (r Result) Failure(taskARN string) error {
+	result, ok := r.Task(taskARN)
+	switch {
+	case !ok:
+		return &ProtectionFailureError{TaskARN: taskARN, Reason: "task missing from response"}
+	case result.Failed:
+		return &ProtectionFailureError{TaskARN: taskARN, Reason: result.FailureReason, Detail: result.FailureDetail}
+	}
+
+	return nil
+}
+
+// Task returns the outcome for taskARN in r, matched by ARN or ID like Verify does, or false if r
+// doesn't report it.
+func (r Result) Task(taskARN string) (TaskResult, bool) {
+	results := r.ByTask()
+	if result, ok := results[taskARN]; ok {
+		return result, true
+	}
+	for arn, result := range results {
+		if sameTask(arn, taskARN) {
+			return result, true
+		}
+	}
+
+	return TaskResult{}, false
+}
+
+// containsTask reports whether tasks contains task, where either may be a task ARN or ID.
+func containsTask(tasks []string, task string) bool {
+	for _, t := range tasks {
+		if sameTask(t, task) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameTask reports whether a and b identify the same task, each by ARN or ID.
+func sameTask(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if parsed, err := ParseTaskARN(a); err == nil {
+		return parsed.TaskID == b
+	}
+	if parsed, err := ParseTaskARN(b); err == nil {
+		return parsed.TaskID == a
+	}
+
+	return false
+}
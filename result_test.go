@@ -0,0 +1,244 @@
+package ecstp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResult_ByTask(t *testing.T) {
+	expiresAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		output *ecs.UpdateTaskProtectionOutput
+		want   map[string]TaskResult
+	}{
+		{
+			name: "should merge protected tasks and failures",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{
+					{TaskArn: aws.String("task_1"), ProtectionEnabled: true, ExpirationDate: &expiresAt},
+					{TaskArn: aws.String("task_2")},
+				},
+				Failures: []types.Failure{
+					{Arn: aws.String("task_3"), Reason: aws.String("MISSING"), Detail: aws.String("task not found")},
+				},
+			},
+			want: map[string]TaskResult{
+				"task_1": {TaskARN: "task_1", ProtectionEnabled: true, ExpiresAt: &expiresAt},
+				"task_2": {TaskARN: "task_2"},
+				"task_3": {TaskARN: "task_3", Failed: true, FailureReason: "MISSING", FailureDetail: "task not found"},
+			},
+		},
+		{
+			name: "should prefer failures over protected tasks",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{TaskArn: aws.String("task_1"), ProtectionEnabled: true}},
+				Failures:       []types.Failure{{Arn: aws.String("task_1"), Reason: aws.String("failed")}},
+			},
+			want: map[string]TaskResult{
+				"task_1": {TaskARN: "task_1", Failed: true, FailureReason: "failed"},
+			},
+		},
+		{
+			name:   "should handle missing output",
+			output: nil,
+			want:   map[string]TaskResult{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewUpdateResult(tt.output).ByTask())
+		})
+	}
+}
+
+func TestResult_Single(t *testing.T) {
+	expiresAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		output *ecs.UpdateTaskProtectionOutput
+		want   TaskResult
+		wantOK bool
+	}{
+		{
+			name: "should return the outcome of a single task",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{
+					{TaskArn: aws.String("task_1"), ProtectionEnabled: true, ExpirationDate: &expiresAt},
+				},
+			},
+			want:   TaskResult{TaskARN: "task_1", ProtectionEnabled: true, ExpiresAt: &expiresAt},
+			wantOK: true,
+		},
+		{
+			name: "should return the failure of a single task",
+			output: &ecs.UpdateTaskProtectionOutput{
+				Failures: []types.Failure{{Arn: aws.String("task_1"), Reason: aws.String("MISSING")}},
+			},
+			want:   TaskResult{TaskARN: "task_1", Failed: true, FailureReason: "MISSING"},
+			wantOK: true,
+		},
+		{
+			name: "should not pick one of several tasks",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{TaskArn: aws.String("task_1")}, {TaskArn: aws.String("task_2")}},
+			},
+		},
+		{
+			name:   "should handle missing output",
+			output: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NewUpdateResult(tt.output).Single()
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResult_Failure(t *testing.T) {
+	tests := []struct {
+		name   string
+		output *ecs.UpdateTaskProtectionOutput
+		want   error
+	}{
+		{
+			name: "should accept tasks reported as protected",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{TaskArn: aws.String("task_1"), ProtectionEnabled: true}},
+			},
+		},
+		{
+			name: "should return failures of the task",
+			output: &ecs.UpdateTaskProtectionOutput{
+				Failures: []types.Failure{
+					{Arn: aws.String("task_1"), Reason: aws.String("TASK_NOT_VALID"), Detail: aws.String("not running")},
+				},
+			},
+			want: &ProtectionFailureError{TaskARN: "task_1", Reason: "TASK_NOT_VALID", Detail: "not running"},
+		},
+		{
+			name: "should match the task by ID",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{
+					TaskArn:           aws.String("arn:aws:ecs:eu-west-2:123456789012:task/test_cluster/task_1"),
+					ProtectionEnabled: true,
+				}},
+			},
+		},
+		{
+			name: "should return tasks missing from the output as failures",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{TaskArn: aws.String("task_2")}},
+			},
+			want: &ProtectionFailureError{TaskARN: "task_1", Reason: "task missing from response"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewUpdateResult(tt.output).Failure("task_1"))
+		})
+	}
+}
+
+func TestNewGetResult(t *testing.T) {
+	expiresAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	output := &ecs.GetTaskProtectionOutput{
+		ProtectedTasks: []types.ProtectedTask{
+			{TaskArn: aws.String("task_1"), ProtectionEnabled: true, ExpirationDate: &expiresAt},
+			{TaskArn: aws.String("task_2")},
+		},
+		Failures: []types.Failure{{Arn: aws.String("task_3"), Reason: aws.String("MISSING")}},
+	}
+
+	byTask := NewGetResult(output).ByTask()
+	assert.Len(t, byTask, 3)
+
+	expiry, ok := byTask["task_1"].Expiry()
+	assert.True(t, ok)
+	assert.Equal(t, expiresAt, expiry)
+	assert.True(t, byTask["task_1"].ProtectedAt(expiresAt.Add(-time.Minute)))
+	assert.False(t, byTask["task_1"].ProtectedAt(expiresAt), "protection should end at its expiry")
+
+	_, ok = byTask["task_2"].Expiry()
+	assert.False(t, ok)
+	assert.False(t, byTask["task_2"].ProtectedAt(expiresAt))
+	assert.False(t, byTask["task_3"].ProtectedAt(expiresAt))
+}
+
+func TestResult_Verify(t *testing.T) {
+	arn := "arn:aws:ecs:eu-west-1:123456789012:task/cluster/0123456789abcdef"
+
+	tests := []struct {
+		name     string
+		output   *ecs.UpdateTaskProtectionOutput
+		requests []string
+		want     *TaskARNMismatchError
+	}{
+		{
+			name: "should accept matching tasks",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{TaskArn: aws.String(arn)}},
+				Failures:       []types.Failure{{Arn: aws.String("task_2")}},
+			},
+			requests: []string{arn, "task_2"},
+		},
+		{
+			name: "should match task IDs with ARNs",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{TaskArn: aws.String(arn)}},
+			},
+			requests: []string{"0123456789abcdef"},
+		},
+		{
+			name: "should reject unexpected tasks",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{TaskArn: aws.String("task_2")}},
+			},
+			requests: []string{arn},
+			want: &TaskARNMismatchError{
+				Requested:  []string{arn},
+				Unexpected: []string{"task_2"},
+			},
+		},
+		{
+			name:     "should accept missing tasks",
+			output:   &ecs.UpdateTaskProtectionOutput{},
+			requests: []string{"task_1"},
+		},
+		{
+			name: "should not match tasks by partial ID",
+			output: &ecs.UpdateTaskProtectionOutput{
+				ProtectedTasks: []types.ProtectedTask{{TaskArn: aws.String(arn)}},
+			},
+			requests: []string{"abcdef"},
+			want: &TaskARNMismatchError{
+				Requested:  []string{"abcdef"},
+				Unexpected: []string{arn},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewUpdateResult(tt.output).Verify(tt.requests...)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var mismatch *TaskARNMismatchError
+			if assert.ErrorAs(t, err, &mismatch) {
+				assert.Equal(t, tt.want, mismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// RetryPolicy configures the retries of Do. The zero value retries like the AWS SDK's standard
+// retryer: up to 3 attempts with exponential backoff and full jitter, capped at 20 seconds.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of calls, including the first. Defaults to 3.
+	MaxAttempts int
+	// BaseDelay is the maximum delay before the first retry, doubling with every further retry.
+	// Defaults to 1 second.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. Defaults to 20 seconds.
+	MaxDelay time.Duration
+	// Retryable reports whether a failed call should be retried. Defaults to IsRetryable.
+	Retryable func(err error) bool
+}
+
+// IsRetryable reports whether err is worth retrying: throttling, transient AWS errors, connection
+// errors and timeouts are, while cancelation and other errors are not. It's the classification
+// used for ErrorDetail.Retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// Do calls fn until it succeeds, returns an error that isn't retryable, or policy.MaxAttempts
+// calls have been made, waiting with exponential backoff and jitter between calls. It returns the
+// result of the last call. If ctx is done while waiting, the last error is returned joined with
+// ctx.Err(); if the wait would outlast the deadline of ctx, the last error is returned right away.
+//
+// Do lets adjacent AWS calls, e.g. of an SQS consumer holding protection, share the retry behavior
+// of this package.
+func Do[T any](ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) (T, error)) (T, error) {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil || attempt >= maxAttempts || !retryable(err) {
+			return result, err
+		}
+
+		delay := policy.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return result, err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// delay returns a random delay before the retry following attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	base, limit := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = time.Second
+	}
+	if limit <= 0 {
+		limit = 20 * time.Second
+	}
+
+	backoff := limit
+	if shift := attempt - 1; shift < 32 {
+		backoff = min(base<<shift, limit)
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// retryCall calls fn with Do if policy is set, and once otherwise.
+func retryCall[T any](ctx context.Context, policy *RetryPolicy, fn func(ctx context.Context) (T, error)) (T, error) {
+	if policy == nil {
+		return fn(ctx)
+	}
+
+	return Do(ctx, *policy, fn)
+}
+
+// metadataRetry returns the retry policy of metadata requests, which are also retried for server
+// errors of the metadata endpoint: that of WithRetry, or defaultMetadataRetry.
+func (c *Client) metadataRetry() *RetryPolicy {
+	policy := defaultMetadataRetry
+	if c.retry != nil {
+		if c.retry.Retryable != nil {
+			return c.retry
+		}
+		policy = *c.retry
+	}
+
+	policy.Retryable = func(err error) bool {
+		var decodeErr *MetadataDecodeError
+		if errors.As(err, &decodeErr) {
+			return decodeErr.StatusCode >= http.StatusInternalServerError || decodeErr.StatusCode == http.StatusTooManyRequests
+		}
+		// only transient failures to reach the endpoint, not a missing configuration
+		return errors.Is(err, ErrMetadataUnavailable) && IsRetryable(err)
+	}
+
+	return &policy
+}
@@ -0,0 +1,223 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}
+	invalid := &smithy.GenericAPIError{Code: "InvalidParameterException", Message: "invalid task"}
+
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		errs      []error
+		want      int
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "should return the first success",
+			errs:      []error{nil},
+			want:      1,
+			wantCalls: 1,
+		},
+		{
+			name:      "should retry retryable errors",
+			errs:      []error{throttled, throttled, nil},
+			want:      3,
+			wantCalls: 3,
+		},
+		{
+			name:      "should give up after the maximum attempts",
+			policy:    RetryPolicy{MaxAttempts: 2},
+			errs:      []error{throttled, throttled, nil},
+			want:      2,
+			wantCalls: 2,
+			wantErr:   throttled,
+		},
+		{
+			name:      "should not retry errors that aren't retryable",
+			errs:      []error{invalid, nil},
+			want:      1,
+			wantCalls: 1,
+			wantErr:   invalid,
+		},
+		{
+			name: "should use the policy's classification",
+			policy: RetryPolicy{Retryable: func(err error) bool {
+				return errors.Is(err, invalid)
+			}},
+			errs:      []error{invalid, nil},
+			want:      2,
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.BaseDelay = time.Millisecond
+			calls := 0
+
+			got, err := Do(context.Background(), tt.policy, func(context.Context) (int, error) {
+				calls++
+				return calls, tt.errs[calls-1]
+			})
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCalls, calls)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDo_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}
+
+	_, err := Do(ctx, RetryPolicy{BaseDelay: time.Hour, MaxDelay: time.Hour}, func(context.Context) (int, error) {
+		cancel()
+		return 0, throttled
+	})
+	assert.ErrorIs(t, err, throttled)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDo_Deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}
+
+	start := time.Now()
+	var calls int
+	_, err := Do(ctx, RetryPolicy{BaseDelay: 24 * time.Hour, MaxDelay: 24 * time.Hour}, func(context.Context) (int, error) {
+		calls++
+		return 0, throttled
+	})
+	assert.ErrorIs(t, err, throttled)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded, "retries outlasting the deadline shouldn't be waited for")
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestClient_WithRetry(t *testing.T) {
+	var metadataCalls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if metadataCalls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"Cluster": "test_cluster", "TaskARN": "test_arn"}`)
+	}))
+	defer ts.Close()
+
+	ecsClient := &ThrottlingTestClient{throttles: 2}
+	c := NewClient(ecsClient, WithMetadataEndpoint(ts.URL), WithRetry(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+	}))
+
+	_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{Protect: true})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, metadataCalls.Load())
+	assert.EqualValues(t, 3, ecsClient.calls.Load())
+
+	ecsClient = &ThrottlingTestClient{throttles: 3}
+	c = NewClient(ecsClient, WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+	_, err = c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+		Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+		Protect:  true,
+	})
+	assert.ErrorIs(t, err, ErrThrottled)
+	assert.EqualValues(t, 3, ecsClient.calls.Load())
+}
+
+func TestClient_WithRetry_SDKRetryer(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Header().Set("X-Amzn-Errortype", "ThrottlingException")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type":"ThrottlingException","message":"rate exceeded"}`)
+	}))
+	defer ts.Close()
+
+	ecsClient := ecs.New(ecs.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(ts.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("default", "secret", ""),
+	})
+	c := NewClient(ecsClient, WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+
+	_, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+		Metadata: &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+		Protect:  true,
+	})
+	assert.ErrorIs(t, err, ErrThrottled)
+	assert.EqualValues(t, 2, calls.Load(), "the ECS client's retries shouldn't multiply the policy's attempts")
+}
+
+func TestClient_WithRetry_NotRetryable(t *testing.T) {
+	var metadataCalls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metadataCalls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	c := NewClient(&SuccessfulTestClient{}, WithMetadataEndpoint(ts.URL), WithRetry(RetryPolicy{BaseDelay: time.Millisecond}))
+
+	_, err := c.GetTaskArn(context.Background())
+	assert.ErrorIs(t, err, ErrMetadataDecode)
+	assert.EqualValues(t, 1, metadataCalls.Load())
+}
+
+// ThrottlingTestClient is throttled for its first throttles calls.
+type ThrottlingTestClient struct {
+	SuccessfulTestClient
+	throttles int32
+	calls     atomic.Int32
+}
+
+func (c *ThrottlingTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if c.calls.Add(1) <= c.throttles {
+		return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}
+	}
+
+	return c.SuccessfulTestClient.UpdateTaskProtection(ctx, params, optFns...)
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt, limit := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		5:  time.Second,
+		64: time.Second,
+	} {
+		for i := 0; i < 100; i++ {
+			delay := policy.delay(attempt)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, limit, "attempt %d", attempt)
+		}
+	}
+}
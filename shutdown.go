@@ -0,0 +1,214 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ShutdownUnprotectTimeout caps the retries of the final unprotect made by
+// Manager.ShutdownUnprotect.
+const ShutdownUnprotectTimeout = 30 * time.Second
+
+// DefaultMaxDrain is the default time a ShutdownGuard waits for draining, ECS's default stop
+// timeout after which the task's containers are killed.
+const DefaultMaxDrain = 30 * time.Second
+
+// ErrDrainTimeout is returned by a ShutdownGuard when draining didn't complete within MaxDrain.
+var ErrDrainTimeout = errors.New("drain timed out")
+
+// ErrShuttingDown is returned by Manager.Acquire and jobs wrapped with Wrap once a ShutdownGuard of
+// the Manager has stopped holding protection, as it's about to be disabled.
+var ErrShuttingDown = errors.New("task shutting down")
+
+// shutdownUnprotectPolicy retries the final unprotect at shutdown. Every failure is retried, as a
+// lost unprotect leaves the task protected for the rest of its protection period.
+var shutdownUnprotectPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Retryable:   func(err error) bool { return true },
+}
+
+// DrainStep is a step of draining the task at shutdown that must complete before protection is
+// removed, e.g. deregistering from a load balancer or finishing in-flight jobs.
+type DrainStep interface {
+	Drain(ctx context.Context) error
+}
+
+// DrainFunc is an adapter to allow the use of ordinary functions as DrainSteps.
+type DrainFunc func(ctx context.Context) error
+
+// Drain calls f(ctx).
+func (f DrainFunc) Drain(ctx context.Context) error {
+	return f(ctx)
+}
+
+// DrainError is returned by Drain when a step failed. Protection is still removed.
+type DrainError struct {
+	// Step is the index of the failed step.
+	Step int
+	Err  error
+}
+
+func (e *DrainError) Error() string {
+	return fmt.Sprintf("drain step %d failed: %v", e.Step, e.Err)
+}
+
+func (e *DrainError) Unwrap() error {
+	return e.Err
+}
+
+// Drain runs steps in order and then disables protection through m with ShutdownUnprotect.
+//
+// A failing step doesn't stop the sequence, as leaving the task protected after shutdown would
+// block scale-in until protection expires. For the same reason, protection is disabled even if ctx
+// is done by then. The errors of failed steps and of the final unprotect are joined together.
+func Drain(ctx context.Context, m *Manager, steps ...DrainStep) error {
+	errs := drainSteps(ctx, steps)
+	if _, err := m.ShutdownUnprotect(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// drainSteps runs steps in order, returning the errors of those that failed as *DrainErrors.
+func drainSteps(ctx context.Context, steps []DrainStep) []error {
+	var errs []error
+	for i, step := range steps {
+		if err := step.Drain(ctx); err != nil {
+			errs = append(errs, &DrainError{Step: i, Err: err})
+		}
+	}
+
+	return errs
+}
+
+// ShutdownGuard drains the task when it's signaled to stop, keeping it protected while in-flight
+// work drains and removing protection once it has, or MaxDrain has elapsed:
+//
+//	guard := &ecstp.ShutdownGuard{
+//		Manager: manager,
+//		Steps:   []ecstp.DrainStep{ecstp.DrainFunc(pool.Wait)},
+//	}
+//	go guard.Run(ctx)
+type ShutdownGuard struct {
+	Manager *Manager
+	// Steps are run in order once a signal is received, like with Drain.
+	Steps []DrainStep
+	// MaxDrain bounds the time given to Steps. Defaults to DefaultMaxDrain.
+	MaxDrain time.Duration
+	// Signals defaults to SIGTERM and SIGINT.
+	Signals []os.Signal
+}
+
+// Run waits for one of the Signals and then calls Shutdown, returning its error. If ctx is done
+// first, Run returns ctx.Err() without draining.
+func (g *ShutdownGuard) Run(ctx context.Context) error {
+	signals := g.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+
+	select {
+	case sig := <-ch:
+		g.Manager.client.log().InfoContext(ctx, "draining before shutdown", slog.String("signal", sig.String()))
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return g.Shutdown(ctx)
+}
+
+// Shutdown enables protection if it isn't already, runs Steps for at most MaxDrain, and then
+// disables protection with ShutdownUnprotect, even if ctx is done. Protection is renewed while
+// Steps run, along with that of any jobs and holds of the Manager, whose renewal stops once
+// draining ends.
+//
+// Once draining ends, Acquire and jobs wrapped with Wrap fail with ErrShuttingDown instead of
+// protecting the task again.
+//
+// If Steps don't complete within MaxDrain, protection is disabled while they're still running and
+// the returned error wraps ErrDrainTimeout. Otherwise, it joins the errors of failed steps, as
+// *DrainErrors, and of the final unprotect.
+func (g *ShutdownGuard) Shutdown(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+	m := g.Manager
+
+	var errs []error
+	held := true
+	if err := m.hold(ctx); err != nil {
+		held = false
+		errs = append(errs, fmt.Errorf("unable to keep protection while draining: %w", err))
+	}
+
+	maxDrain := g.MaxDrain
+	if maxDrain <= 0 {
+		maxDrain = DefaultMaxDrain
+	}
+	drainCtx, cancel := context.WithTimeout(ctx, maxDrain)
+	defer cancel()
+	drained := make(chan []error, 1)
+	go func() {
+		drained <- drainSteps(drainCtx, g.Steps)
+	}()
+	select {
+	case stepErrs := <-drained:
+		errs = append(errs, stepErrs...)
+	case <-drainCtx.Done():
+		errs = append(errs, fmt.Errorf("%w after %s", ErrDrainTimeout, maxDrain))
+	}
+
+	m.stopHolding()
+	if held {
+		m.releaseHold(ctx, true)
+	}
+	if _, err := m.ShutdownUnprotect(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ShutdownUnprotect disables protection as the last step of shutting down, best-effort: the call
+// is made on a context detached from ctx's cancelation and retried a few times, for at most
+// ShutdownUnprotectTimeout in total, or the ShutdownTimeout of the Client's Profile. Losing it
+// would leave the task protected for the rest of its protection period after it's gone. The
+// outcome is logged with the Client's logger.
+func (m *Manager) ShutdownUnprotect(ctx context.Context) (State, error) {
+	timeout := ShutdownUnprotectTimeout
+	if m.client.profile.ShutdownTimeout > 0 {
+		timeout = m.client.profile.ShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	attempts := 0
+	state, err := Do(ctx, shutdownUnprotectPolicy, func(ctx context.Context) (State, error) {
+		attempts++
+		return m.Unprotect(ctx)
+	})
+	if err != nil {
+		m.client.log().ErrorContext(ctx, "unable to disable protection at shutdown",
+			slog.String("task_arn", state.TaskARN),
+			slog.Int("attempts", attempts),
+			slog.Any("error", state.LastError),
+		)
+		return state, err
+	}
+	m.client.log().InfoContext(ctx, "disabled protection at shutdown",
+		slog.String("task_arn", state.TaskARN),
+		slog.Int("attempts", attempts),
+	)
+
+	return state, nil
+}
@@ -0,0 +1,257 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastShutdownRetries shortens the retries of the final unprotect at shutdown for the test.
+func fastShutdownRetries(t *testing.T) {
+	policy := shutdownUnprotectPolicy
+	shutdownUnprotectPolicy.BaseDelay = time.Millisecond
+	t.Cleanup(func() { shutdownUnprotectPolicy = policy })
+}
+
+func TestDrain(t *testing.T) {
+	fastShutdownRetries(t)
+
+	tests := []struct {
+		name      string
+		ecsClient ECSClient
+		steps     []error
+		wantRan   int
+		wantStep  int
+		wantErr   bool
+	}{
+		{
+			name:      "should run the steps and unprotect",
+			ecsClient: &SuccessfulTestClient{},
+			steps:     []error{nil, nil},
+			wantRan:   2,
+		},
+		{
+			name:      "should unprotect after a failed step",
+			ecsClient: &SuccessfulTestClient{},
+			steps:     []error{errors.New("deregistration failed"), nil},
+			wantRan:   2,
+			wantStep:  0,
+			wantErr:   true,
+		},
+		{
+			name:      "should return a failed unprotect",
+			ecsClient: &FailureTestClient{},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+			_, err := m.Protect(context.Background(), nil)
+			require.NoError(t, err)
+			m.client = NewClient(tt.ecsClient)
+
+			ran := 0
+			var steps []DrainStep
+			for _, stepErr := range tt.steps {
+				stepErr := stepErr
+				steps = append(steps, DrainFunc(func(ctx context.Context) error {
+					ran++
+					assert.True(t, m.State().Protected, "task should stay protected while draining")
+					return stepErr
+				}))
+			}
+
+			err = Drain(context.Background(), m, steps...)
+
+			assert.Equal(t, tt.wantRan, ran)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				assert.False(t, m.State().Protected)
+				return
+			}
+			assert.Error(t, err)
+			var drainErr *DrainError
+			if errors.As(err, &drainErr) {
+				assert.Equal(t, tt.wantStep, drainErr.Step)
+				assert.False(t, m.State().Protected)
+			}
+		})
+	}
+}
+
+// FlakyTestClient fails the first failures UpdateTaskProtection calls.
+type FlakyTestClient struct {
+	SuccessfulTestClient
+	failures int
+	calls    int
+}
+
+func (c *FlakyTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.calls++
+	if c.calls <= c.failures {
+		return nil, errors.New("connection reset")
+	}
+
+	return c.SuccessfulTestClient.UpdateTaskProtection(ctx, params, optFns...)
+}
+
+func TestManager_ShutdownUnprotect(t *testing.T) {
+	fastShutdownRetries(t)
+
+	tests := []struct {
+		name      string
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "should unprotect after the context is canceled",
+			wantCalls: 1,
+		},
+		{
+			name:      "should retry failed calls",
+			failures:  2,
+			wantCalls: 3,
+		},
+		{
+			name:      "should give up after a few attempts",
+			failures:  10,
+			wantCalls: 4,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &FlakyTestClient{}
+			m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+			_, err := m.Protect(context.Background(), nil)
+			require.NoError(t, err)
+			client.calls, client.failures = 0, tt.failures
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			state, err := m.ShutdownUnprotect(ctx)
+			assert.Equal(t, tt.wantCalls, client.calls)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.True(t, state.Protected)
+			} else {
+				assert.NoError(t, err)
+				assert.False(t, state.Protected)
+			}
+		})
+	}
+}
+
+func TestShutdownGuard_Shutdown(t *testing.T) {
+	fastShutdownRetries(t)
+
+	tests := []struct {
+		name     string
+		step     func(ctx context.Context) error
+		wantErr  error
+		wantStep bool
+	}{
+		{
+			name: "should unprotect once drained",
+			step: func(ctx context.Context) error { return nil },
+		},
+		{
+			name:     "should unprotect after a failed step",
+			step:     func(ctx context.Context) error { return errTestJob },
+			wantErr:  errTestJob,
+			wantStep: true,
+		},
+		{
+			name: "should unprotect once the drain times out",
+			step: func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			},
+			wantErr: ErrDrainTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &CountingTestClient{}
+			m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+			guard := &ShutdownGuard{
+				Manager:  m,
+				MaxDrain: 50 * time.Millisecond,
+				Steps: []DrainStep{DrainFunc(func(ctx context.Context) error {
+					assert.True(t, m.State().Protected, "protection should be kept while draining")
+					return tt.step(ctx)
+				})},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := guard.Shutdown(ctx)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			var drainErr *DrainError
+			assert.Equal(t, tt.wantStep, errors.As(err, &drainErr))
+
+			_, err = m.Acquire(context.Background())
+			assert.ErrorIs(t, err, ErrShuttingDown, "protection shouldn't be held again after shutdown")
+			assert.ErrorIs(t, Wrap(m, JobFunc(func(ctx context.Context) error { return nil })).Run(context.Background()),
+				ErrShuttingDown)
+			assert.False(t, m.State().Protected)
+			assert.Equal(t, int32(1), client.protects.Load())
+		})
+	}
+}
+
+func TestShutdownGuard_Run(t *testing.T) {
+	fastShutdownRetries(t)
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	drained := make(chan struct{})
+	guard := &ShutdownGuard{
+		Manager: m,
+		Steps:   []DrainStep{DrainFunc(func(ctx context.Context) error { close(drained); return nil })},
+		Signals: []os.Signal{syscall.SIGUSR1},
+	}
+
+	// keep the signal from terminating the test before the guard listens for it
+	ignored := make(chan os.Signal, 1)
+	signal.Notify(ignored, syscall.SIGUSR1)
+	defer signal.Stop(ignored)
+
+	done := make(chan error)
+	go func() { done <- guard.Run(context.Background()) }()
+
+	// the signal is only delivered to the guard once it's listening
+	require.Eventually(t, func() bool {
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+		select {
+		case <-drained:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, <-done)
+	assert.False(t, m.State().Protected)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, guard.Run(ctx), context.Canceled)
+}
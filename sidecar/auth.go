@@ -0,0 +1,128 @@
+package sidecar
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// TokenAuth authenticates requests using bearer tokens. Multiple tokens can be valid at once, so
+// tokens can be rotated by adding the new token, updating clients and then removing the old one.
+// It's safe for concurrent use.
+type TokenAuth struct {
+	path   string
+	tokens atomic.Pointer[[][]byte]
+}
+
+// NewTokenAuth returns a TokenAuth accepting tokens.
+func NewTokenAuth(tokens ...string) *TokenAuth {
+	a := &TokenAuth{}
+	a.SetTokens(tokens...)
+
+	return a
+}
+
+// LoadTokenAuth returns a TokenAuth accepting the tokens in the file at path, one per line. Blank
+// lines and lines starting with # are ignored. The file is read again by Reload.
+func LoadTokenAuth(path string) (*TokenAuth, error) {
+	a := &TokenAuth{path: path}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Reload replaces the accepted tokens with those in the token file. If the file can't be read or
+// contains no tokens, the current tokens are kept.
+func (a *TokenAuth) Reload() error {
+	if a.path == "" {
+		return errors.New("no token file configured")
+	}
+
+	b, err := os.ReadFile(a.path)
+	if err != nil {
+		return err
+	}
+
+	var tokens []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if len(tokens) == 0 {
+		return errors.New("token file contains no tokens")
+	}
+
+	a.SetTokens(tokens...)
+
+	return nil
+}
+
+// SetTokens replaces the accepted tokens.
+func (a *TokenAuth) SetTokens(tokens ...string) {
+	b := make([][]byte, len(tokens))
+	for i, token := range tokens {
+		b[i] = []byte(token)
+	}
+	a.tokens.Store(&b)
+}
+
+// Valid returns true if token is one of the accepted tokens.
+func (a *TokenAuth) Valid(token string) bool {
+	tokens := a.tokens.Load()
+	if tokens == nil || token == "" {
+		return false
+	}
+
+	valid := 0
+	for _, t := range *tokens {
+		valid |= subtle.ConstantTimeCompare(t, []byte(token))
+	}
+
+	return valid == 1
+}
+
+// authenticate returns true if r carries a valid bearer token, writing a 401 response otherwise.
+func (a *TokenAuth) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && a.Valid(strings.TrimSpace(token)) {
+		return true
+	}
+
+	w.Header().Set("WWW-Authenticate", `Bearer realm="ecstp-sidecar"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+	return false
+}
+
+// handleAuthReload reloads the token file.
+func (s *Server) handleAuthReload(w http.ResponseWriter, r *http.Request) {
+	if s.Auth == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.Auth.Reload(); err != nil {
+		s.logger().Error("unable to reload auth tokens", slog.Any("error", err))
+		http.Error(w, "unable to reload tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
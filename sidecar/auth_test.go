@@ -0,0 +1,80 @@
+package sidecar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAuth_Valid(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []string
+		token  string
+		want   bool
+	}{
+		{name: "should accept a valid token", tokens: []string{"old", "new"}, token: "new", want: true},
+		{name: "should reject an unknown token", tokens: []string{"old", "new"}, token: "other"},
+		{name: "should reject an empty token", tokens: []string{"old"}, token: ""},
+		{name: "should reject everything without tokens", token: "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewTokenAuth(tt.tokens...).Valid(tt.token))
+		})
+	}
+}
+
+func TestTokenAuth_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(path, []byte("# sidecar tokens\nold\n\n"), 0o600))
+
+	a, err := LoadTokenAuth(path)
+	require.NoError(t, err)
+	assert.True(t, a.Valid("old"))
+
+	require.NoError(t, os.WriteFile(path, []byte("old\nnew\n"), 0o600))
+	require.NoError(t, a.Reload())
+	assert.True(t, a.Valid("old"))
+	assert.True(t, a.Valid("new"))
+
+	require.NoError(t, os.WriteFile(path, []byte("# rotated out\n"), 0o600))
+	assert.Error(t, a.Reload())
+	assert.True(t, a.Valid("new"), "tokens should be kept when the file is empty")
+
+	assert.Error(t, NewTokenAuth("a").Reload())
+}
+
+func TestServer_Auth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+	auth, err := LoadTokenAuth(path)
+	require.NoError(t, err)
+
+	s := NewServer(newTestManager(&testECSClient{}))
+	s.Auth = auth
+
+	request := func(method, target, token string) int {
+		r := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/status", ""))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/status", "new"))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/status", "old"))
+
+	require.NoError(t, os.WriteFile(path, []byte("new\n"), 0o600))
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/auth/reload", "old"))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/status", "new"))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/status", "old"))
+}
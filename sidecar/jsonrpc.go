@@ -0,0 +1,158 @@
+// Package sidecar exposes an ecstp.Manager to other processes, so applications that can't use the
+// library directly can control the protection of their task.
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeServerError    = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// ProtectParams are the parameters of the protect method.
+type ProtectParams struct {
+	ExpiresInMinutes *int32 `json:"expiresInMinutes,omitempty"`
+}
+
+// ServeJSONRPC serves JSON-RPC 2.0 requests read from r, writing responses to w, until r is closed
+// or ctx is canceled. Requests and responses are newline-delimited JSON objects and requests are
+// handled in order.
+//
+// The supported methods are protect (with optional ProtectParams), unprotect and status, each of
+// which returns the resulting ecstp.State.
+func ServeJSONRPC(ctx context.Context, m *ecstp.Manager, r io.Reader, w io.Writer) error {
+	enc := json.NewEncoder(w)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		res := handleJSONRPC(ctx, m, line)
+		if res == nil {
+			continue
+		}
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// handleJSONRPC handles a single request, returning nil for notifications.
+func handleJSONRPC(ctx context.Context, m *ecstp.Manager, line []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(json.RawMessage("null"), codeParseError, "parse error", nil)
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(idOrNull(req.ID), codeInvalidRequest, "invalid request", nil)
+	}
+
+	var (
+		state ecstp.State
+		err   error
+	)
+	switch req.Method {
+	case "protect":
+		var params ProtectParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return errorResponse(idOrNull(req.ID), codeInvalidParams, "invalid params", nil)
+			}
+		}
+		state, err = m.Protect(ctx, params.ExpiresInMinutes)
+	case "unprotect":
+		state, err = m.Unprotect(ctx)
+	case "status":
+		state = m.State()
+	default:
+		return errorResponse(idOrNull(req.ID), codeMethodNotFound, "method not found", nil)
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return errorResponse(req.ID, codeServerError, "protection update failed", errorDetail(state, err))
+	}
+
+	return &rpcResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  state,
+	}
+}
+
+func errorResponse(id json.RawMessage, code int, message string, data any) *rpcResponse {
+	return &rpcResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &rpcError{
+			Code:    code,
+			Message: message,
+			Data:    data,
+		},
+	}
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+
+	return id
+}
+
+// errorDetail returns the sanitized detail of err, which is safe to return to clients.
+func errorDetail(state ecstp.State, err error) *ecstp.ErrorDetail {
+	var detail *ecstp.ErrorDetail
+	if errors.As(err, &detail) {
+		return detail
+	}
+	if state.LastError != nil {
+		return state.LastError
+	}
+
+	return ecstp.NewErrorDetail(ecstp.OperationUpdateTaskProtection, state.TaskARN, err)
+}
@@ -0,0 +1,140 @@
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+type testECSClient struct {
+	fail      bool
+	expiresAt *time.Time
+}
+
+func (c *testECSClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if c.fail {
+		return &ecs.UpdateTaskProtectionOutput{
+			Failures: []types.Failure{{Arn: aws.String(params.Tasks[0]), Reason: aws.String("MISSING")}},
+		}, nil
+	}
+
+	return &ecs.UpdateTaskProtectionOutput{
+		ProtectedTasks: []types.ProtectedTask{{
+			TaskArn:           aws.String(params.Tasks[0]),
+			ProtectionEnabled: params.ProtectionEnabled,
+			ExpirationDate:    c.expiresAt,
+		}},
+	}, nil
+}
+
+func newTestManager(ecsClient ecstp.ECSClient) *ecstp.Manager {
+	return ecstp.NewManager(ecstp.NewClient(ecsClient), &ecstp.MetadataBody{
+		Cluster: "test_cluster",
+		TaskARN: "test_arn",
+	})
+}
+
+func TestServeJSONRPC(t *testing.T) {
+	tests := []struct {
+		name      string
+		ecsClient ecstp.ECSClient
+		request   string
+		want      string
+	}{
+		{
+			name:      "should protect the task",
+			ecsClient: &testECSClient{},
+			request:   `{"jsonrpc":"2.0","id":1,"method":"protect","params":{"expiresInMinutes":30}}`,
+			want:      `{"jsonrpc":"2.0","id":1,"result":{"protected":true`,
+		},
+		{
+			name:      "should unprotect the task",
+			ecsClient: &testECSClient{},
+			request:   `{"jsonrpc":"2.0","id":"a","method":"unprotect"}`,
+			want:      `{"jsonrpc":"2.0","id":"a","result":{"protected":false`,
+		},
+		{
+			name:      "should return the status",
+			ecsClient: &testECSClient{},
+			request:   `{"jsonrpc":"2.0","id":2,"method":"status"}`,
+			want:      `{"jsonrpc":"2.0","id":2,"result":{"protected":false`,
+		},
+		{
+			name:      "should return server errors with details",
+			ecsClient: &testECSClient{fail: true},
+			request:   `{"jsonrpc":"2.0","id":3,"method":"protect"}`,
+			want:      `{"jsonrpc":"2.0","id":3,"error":{"code":-32000,"message":"protection update failed","data":{"operation":"UpdateTaskProtection"`,
+		},
+		{
+			name:      "should reject unknown methods",
+			ecsClient: &testECSClient{},
+			request:   `{"jsonrpc":"2.0","id":4,"method":"explode"}`,
+			want:      `{"jsonrpc":"2.0","id":4,"error":{"code":-32601,"message":"method not found"}}`,
+		},
+		{
+			name:      "should reject invalid params",
+			ecsClient: &testECSClient{},
+			request:   `{"jsonrpc":"2.0","id":5,"method":"protect","params":{"expiresInMinutes":"soon"}}`,
+			want:      `{"jsonrpc":"2.0","id":5,"error":{"code":-32602,"message":"invalid params"}}`,
+		},
+		{
+			name:      "should report parse errors",
+			ecsClient: &testECSClient{},
+			request:   `{not json`,
+			want:      `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`,
+		},
+		{
+			name:      "should not respond to notifications",
+			ecsClient: &testECSClient{},
+			request:   `{"jsonrpc":"2.0","method":"protect"}`,
+			want:      ``,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := ServeJSONRPC(context.Background(), newTestManager(tt.ecsClient), strings.NewReader(tt.request+"\n"), &out)
+			if assert.NoError(t, err) {
+				assert.True(t, strings.HasPrefix(out.String(), tt.want), out.String())
+			}
+		})
+	}
+}
+
+func TestServeJSONRPC_Sequence(t *testing.T) {
+	requests := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"protect"}`,
+		``,
+		`{"jsonrpc":"2.0","id":2,"method":"status"}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	err := ServeJSONRPC(context.Background(), newTestManager(&testECSClient{}), strings.NewReader(requests), &out)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	dec := json.NewDecoder(&out)
+	for _, id := range []string{"1", "2"} {
+		var res struct {
+			ID     json.RawMessage `json:"id"`
+			Result ecstp.State     `json:"result"`
+		}
+		if assert.NoError(t, dec.Decode(&res)) {
+			assert.Equal(t, id, string(res.ID))
+			assert.True(t, res.Result.Protected)
+		}
+	}
+}
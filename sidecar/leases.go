@@ -0,0 +1,415 @@
+package sidecar
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// DefaultLeaseTTL is the default time a lease is held without a heartbeat.
+const DefaultLeaseTTL = time.Minute
+
+// maxLeaseRequestBody is the maximum size of a lease request body.
+const maxLeaseRequestBody = 4096
+
+// Lease is a client's claim on protection of the task.
+type Lease = ecstp.Lease
+
+// LeaseRequest is the optional body of a request acquiring a lease.
+type LeaseRequest struct {
+	Name   string            `json:"name,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// TTLSeconds is the time the lease is held without a heartbeat. Defaults to Server.LeaseTTL.
+	TTLSeconds int `json:"ttlSeconds,omitempty"`
+}
+
+var errLeaseNotFound = errors.New("lease not found")
+
+var errLeasesHeld = errors.New("leases are held, the task is unprotected once they're released")
+
+// ErrDraining is returned when a lease is requested after the Server started shutting down.
+var ErrDraining = errors.New("sidecar is shutting down, not accepting new leases")
+
+// leaseTable keeps the task protected while any lease is held.
+type leaseTable struct {
+	manager *ecstp.Manager
+	logger  func() *slog.Logger
+
+	mu     sync.Mutex
+	leases map[string]*heldLease
+	// drained is set once draining starts and closed when the last lease is released.
+	drained chan struct{}
+}
+
+type heldLease struct {
+	Lease
+	ttl   time.Duration
+	timer *time.Timer
+}
+
+func newLeaseTable(m *ecstp.Manager, logger func() *slog.Logger) *leaseTable {
+	return &leaseTable{
+		manager: m,
+		logger:  logger,
+		leases:  make(map[string]*heldLease),
+	}
+}
+
+// acquire creates a lease, enabling protection if it's the first one.
+func (t *leaseTable) acquire(ctx context.Context, name string, labels map[string]string, ttl time.Duration) (Lease, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.drained != nil {
+		return Lease{}, ErrDraining
+	}
+
+	if len(t.leases) == 0 {
+		if _, err := t.manager.Protect(ecstp.ContextWithLabels(ctx, labels), nil); err != nil {
+			return Lease{}, err
+		}
+	}
+
+	id, err := newLeaseID()
+	if err != nil {
+		return Lease{}, err
+	}
+
+	now := time.Now().UTC()
+	lease := &heldLease{
+		Lease: Lease{
+			ID:          id,
+			Name:        name,
+			Labels:      maps.Clone(labels),
+			CreatedAt:   now,
+			HeartbeatAt: now,
+			ExpiresAt:   now.Add(ttl),
+		},
+		ttl: ttl,
+	}
+	lease.timer = time.AfterFunc(ttl, func() {
+		t.expire(id)
+	})
+	t.leases[id] = lease
+
+	return lease.Lease, nil
+}
+
+// heartbeat extends the lease with the given ID by its TTL.
+func (t *leaseTable) heartbeat(id string) (Lease, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lease, ok := t.leases[id]
+	if !ok {
+		return Lease{}, errLeaseNotFound
+	}
+	lease.timer.Reset(lease.ttl)
+	lease.HeartbeatAt = time.Now().UTC()
+	lease.ExpiresAt = lease.HeartbeatAt.Add(lease.ttl)
+
+	return lease.Lease, nil
+}
+
+// expire releases the lease with the given ID unless it was heartbeated after its timer fired.
+func (t *leaseTable) expire(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lease, ok := t.leases[id]
+	if !ok || time.Now().Before(lease.ExpiresAt) {
+		return
+	}
+
+	t.logger().Warn("lease expired without heartbeat",
+		slog.String("lease_id", id),
+		slog.String("name", lease.Name),
+		slog.Any("labels", lease.Labels),
+	)
+	t.releaseLocked(context.Background(), lease)
+}
+
+// release removes the lease with the given ID, disabling protection if it was the last one.
+func (t *leaseTable) release(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lease, ok := t.leases[id]
+	if !ok {
+		return errLeaseNotFound
+	}
+
+	return t.releaseLocked(ctx, lease)
+}
+
+func (t *leaseTable) releaseLocked(ctx context.Context, lease *heldLease) error {
+	lease.timer.Stop()
+	delete(t.leases, lease.ID)
+	if t.drained != nil && len(t.leases) == 0 {
+		close(t.drained)
+	}
+
+	if len(t.leases) == 0 {
+		// the request releasing the lease may have been canceled by then
+		if _, err := t.manager.FinalUnprotect(ctx); err != nil {
+			t.logger().Error("unable to disable protection after last lease was released", slog.Any("error", err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// protect enables protection regardless of leases, unless draining. It's left to the last lease
+// released, if any, or unprotect to disable.
+func (t *leaseTable) protect(ctx context.Context, expiresInMinutes *int32) (ecstp.State, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.drained != nil {
+		return ecstp.State{}, ErrDraining
+	}
+
+	return t.manager.Protect(ctx, expiresInMinutes)
+}
+
+// unprotect disables protection enabled by protect, unless leases are held.
+func (t *leaseTable) unprotect(ctx context.Context) (ecstp.State, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.leases) > 0 {
+		return ecstp.State{}, errLeasesHeld
+	}
+
+	return t.manager.Unprotect(ctx)
+}
+
+// drain stops new leases from being acquired and returns a channel closed once no leases are held.
+func (t *leaseTable) drain() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.drained == nil {
+		t.drained = make(chan struct{})
+		if len(t.leases) == 0 {
+			close(t.drained)
+		}
+	}
+
+	return t.drained
+}
+
+// revoke drops all held leases without unprotecting the task, returning the dropped leases.
+func (t *leaseTable) revoke() []Lease {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var revoked []Lease
+	for id, lease := range t.leases {
+		lease.timer.Stop()
+		delete(t.leases, id)
+		revoked = append(revoked, lease.Lease)
+	}
+	if t.drained != nil && len(revoked) > 0 {
+		close(t.drained)
+	}
+	sort.Slice(revoked, func(i, j int) bool {
+		return revoked[i].CreatedAt.Before(revoked[j].CreatedAt)
+	})
+
+	return revoked
+}
+
+// get returns the lease with the given ID.
+func (t *leaseTable) get(id string) (Lease, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lease, ok := t.leases[id]
+	if !ok {
+		return Lease{}, errLeaseNotFound
+	}
+
+	return lease.Lease, nil
+}
+
+// list returns the held leases, oldest first.
+func (t *leaseTable) list() []Lease {
+	return t.find(nil)
+}
+
+// find returns the held leases having all of the given labels, oldest first.
+func (t *leaseTable) find(labels map[string]string) []Lease {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	leases := make([]Lease, 0, len(t.leases))
+	for _, lease := range t.leases {
+		if hasLabels(lease.Labels, labels) {
+			leases = append(leases, lease.Lease)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].CreatedAt.Before(leases[j].CreatedAt)
+	})
+
+	return leases
+}
+
+// Leases returns the held leases, oldest first.
+func (s *Server) Leases() []Lease {
+	return s.leases.list()
+}
+
+// LeasesWithLabels returns the held leases having all of the given labels, oldest first, e.g. to
+// find which leases a job holds.
+func (s *Server) LeasesWithLabels(labels map[string]string) []Lease {
+	return s.leases.find(labels)
+}
+
+// LeaseNames returns the names of the held leases, oldest first, e.g. as labels for an
+// ecstpddb.Registry.
+func (s *Server) LeaseNames() []string {
+	leases := s.leases.list()
+	names := make([]string, 0, len(leases))
+	for _, lease := range leases {
+		if lease.Name != "" {
+			names = append(names, lease.Name)
+		}
+	}
+
+	return names
+}
+
+// hasLabels reports whether have includes all of want.
+func hasLabels(have, want map[string]string) bool {
+	for k, v := range want {
+		if value, ok := have[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseLabelSelector parses label query parameters of the form key=value.
+func parseLabelSelector(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	labels := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label selector %q, want key=value", v)
+		}
+		labels[key] = value
+	}
+
+	return labels, nil
+}
+
+func newLeaseID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// handleLeases handles requests to /leases and /leases/{id}.
+func (s *Server) handleLeases(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/leases"), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		labels, err := parseLabelSelector(r.URL.Query()["label"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.leases.find(labels))
+	case id != "" && r.Method == http.MethodGet:
+		lease, err := s.leases.get(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, lease)
+	case id == "" && r.Method == http.MethodPost:
+		s.acquireLease(w, r)
+	case id != "" && r.Method == http.MethodPut:
+		lease, err := s.leases.heartbeat(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, lease)
+	case id != "" && r.Method == http.MethodDelete:
+		err := s.leases.release(r.Context(), id)
+		switch {
+		case errors.Is(err, errLeaseNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			writeJSON(w, http.StatusBadGateway, errorDetail(s.manager.State(), err))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) acquireLease(w http.ResponseWriter, r *http.Request) {
+	var req LeaseRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxLeaseRequestBody)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid lease request", http.StatusBadRequest)
+		return
+	}
+	if req.TTLSeconds < 0 {
+		http.Error(w, "invalid lease TTL", http.StatusBadRequest)
+		return
+	}
+
+	ttl := s.leaseTTL()
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	lease, err := s.leases.acquire(r.Context(), req.Name, req.Labels, ttl)
+	if errors.Is(err, ErrDraining) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorDetail(s.manager.State(), err))
+		return
+	}
+
+	w.Header().Set("Location", "/leases/"+lease.ID)
+	writeJSON(w, http.StatusCreated, lease)
+}
+
+func (s *Server) leaseTTL() time.Duration {
+	if s.LeaseTTL <= 0 {
+		return DefaultLeaseTTL
+	}
+
+	return s.LeaseTTL
+}
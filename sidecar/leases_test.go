@@ -0,0 +1,154 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+func doLeaseRequest(t *testing.T, s *Server, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	return rec
+}
+
+func TestServer_Leases(t *testing.T) {
+	m := newTestManager(&testECSClient{})
+	s := NewServer(m)
+
+	rec := doLeaseRequest(t, s, http.MethodPost, "/leases", `{"name":"worker-1","labels":{"job":"42"}}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var first Lease
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&first))
+	assert.Equal(t, "worker-1", first.Name)
+	assert.Equal(t, map[string]string{"job": "42"}, first.Labels)
+	assert.Equal(t, "/leases/"+first.ID, rec.Header().Get("Location"))
+	assert.True(t, m.State().Protected)
+
+	rec = doLeaseRequest(t, s, http.MethodPost, "/leases", "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var second Lease
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&second))
+
+	rec = doLeaseRequest(t, s, http.MethodGet, "/leases", "")
+	var leases []Lease
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&leases))
+	assert.Len(t, leases, 2)
+	assert.Equal(t, leases, s.Leases())
+	assert.Equal(t, []string{"worker-1"}, s.LeaseNames())
+
+	rec = doLeaseRequest(t, s, http.MethodGet, "/status", "")
+	var snapshot ecstp.Snapshot
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&snapshot))
+	assert.Equal(t, leases, snapshot.Leases)
+
+	rec = doLeaseRequest(t, s, http.MethodGet, "/leases?label=job=42", "")
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&leases))
+	assert.Equal(t, []Lease{first}, leases)
+	assert.Empty(t, s.LeasesWithLabels(map[string]string{"job": "43"}))
+
+	rec = doLeaseRequest(t, s, http.MethodGet, "/leases/"+second.ID, "")
+	var got Lease
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, second, got)
+
+	rec = doLeaseRequest(t, s, http.MethodPut, "/leases/"+first.ID, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doLeaseRequest(t, s, http.MethodDelete, "/leases/"+first.ID, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.True(t, m.State().Protected, "task should stay protected while a lease is held")
+
+	rec = doLeaseRequest(t, s, http.MethodDelete, "/leases/"+second.ID, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.False(t, m.State().Protected, "task should be unprotected once all leases are released")
+
+	rec = doLeaseRequest(t, s, http.MethodDelete, "/leases/"+second.ID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = doLeaseRequest(t, s, http.MethodPut, "/leases/unknown", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_Leases_Expire(t *testing.T) {
+	m := newTestManager(&testECSClient{})
+	s := NewServer(m)
+	s.LeaseTTL = 20 * time.Millisecond
+
+	rec := doLeaseRequest(t, s, http.MethodPost, "/leases", "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.True(t, m.State().Protected)
+
+	assert.Eventually(t, func() bool { return !m.State().Protected }, time.Second, 5*time.Millisecond)
+	assert.Empty(t, s.leases.list())
+}
+
+func TestServer_Leases_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       bool
+		method     string
+		target     string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "should report failures to enable protection",
+			fail:       true,
+			method:     http.MethodPost,
+			target:     "/leases",
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "should reject invalid bodies",
+			method:     http.MethodPost,
+			target:     "/leases",
+			body:       `{"ttlSeconds":"long"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject negative TTLs",
+			method:     http.MethodPost,
+			target:     "/leases",
+			body:       `{"ttlSeconds":-1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject invalid label selectors",
+			method:     http.MethodGet,
+			target:     "/leases?label=job",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should report unknown leases",
+			method:     http.MethodGet,
+			target:     "/leases/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "should reject unsupported methods",
+			method:     http.MethodPatch,
+			target:     "/leases/abc",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(newTestManager(&testECSClient{fail: tt.fail}))
+
+			rec := doLeaseRequest(t, s, tt.method, tt.target, tt.body)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Empty(t, s.leases.list())
+		})
+	}
+}
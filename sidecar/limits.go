@@ -0,0 +1,176 @@
+package sidecar
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limits protects the sidecar from misbehaving clients. Zero values disable the limit.
+type Limits struct {
+	// RatePerSecond is the sustained number of requests allowed per client.
+	RatePerSecond float64
+	// Burst is the number of requests a client can make at once.
+	Burst int
+	// MaxBodyBytes is the maximum size of a request body.
+	MaxBodyBytes int64
+	// Timeout bounds the handling of non-streaming requests, including any ECS calls they make.
+	Timeout time.Duration
+}
+
+// DefaultLimits are the Limits used by NewServer.
+var DefaultLimits = Limits{
+	RatePerSecond: 10,
+	Burst:         20,
+	MaxBodyBytes:  64 * 1024,
+	Timeout:       30 * time.Second,
+}
+
+// Metrics counts the requests handled by a Server.
+type Metrics struct {
+	Requests    uint64 `json:"requests"`
+	RateLimited uint64 `json:"rateLimited"`
+	TimedOut    uint64 `json:"timedOut"`
+}
+
+type serverMetrics struct {
+	requests    atomic.Uint64
+	rateLimited atomic.Uint64
+	timedOut    atomic.Uint64
+}
+
+// Metrics returns the request counters of the Server.
+func (s *Server) Metrics() Metrics {
+	return Metrics{
+		Requests:    s.metrics.requests.Load(),
+		RateLimited: s.metrics.rateLimited.Load(),
+		TimedOut:    s.metrics.timedOut.Load(),
+	}
+}
+
+// limit applies the Server's Limits to r, returning false if the request was rejected.
+//
+// Clients are identified by their bearer token if it's valid, or by their remote address
+// otherwise, so that a client can't escape the limit by sending made-up tokens.
+func (s *Server) limit(w http.ResponseWriter, r *http.Request) (*http.Request, context.CancelFunc, bool) {
+	s.metrics.requests.Add(1)
+
+	if s.Limits.RatePerSecond > 0 {
+		if wait, ok := s.limiter.allow(s.clientKey(r), s.Limits.RatePerSecond, s.Limits.Burst); !ok {
+			s.metrics.rateLimited.Add(1)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return r, func() {}, false
+		}
+	}
+
+	if s.Limits.MaxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.Limits.MaxBodyBytes)
+	}
+
+	cancel := func() {}
+	if s.Limits.Timeout > 0 && !isStreaming(r) {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(r.Context(), s.Limits.Timeout)
+		r = r.WithContext(ctx)
+	}
+
+	return r, cancel, true
+}
+
+// recordTimeout counts r if its deadline was exceeded while handling it.
+func (s *Server) recordTimeout(r *http.Request) {
+	if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		s.metrics.timedOut.Add(1)
+	}
+}
+
+// isStreaming returns true for requests that are expected to be held open.
+func isStreaming(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/ws", "/events":
+		return true
+	case "/status":
+		return r.URL.Query().Has("wait")
+	}
+
+	return false
+}
+
+// clientKey returns the key of the rate limit bucket of r. Tokens are hashed, so they aren't kept
+// in memory beyond the TokenAuth.
+func (s *Server) clientKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.Auth != nil {
+		if token = strings.TrimSpace(token); s.Auth.Valid(token) {
+			sum := sha256.Sum256([]byte(token))
+			return "token:" + hex.EncodeToString(sum[:])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "addr:" + host
+}
+
+// idleBucketTTL is how long an unused bucket is kept.
+const idleBucketTTL = 10 * time.Minute
+
+// rateLimiter is a per-key token bucket rate limiter.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket of key, returning false and the time until a token is
+// available if the bucket is empty.
+func (l *rateLimiter) allow(key string, rate float64, burst int) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucket)
+	}
+	if now.Sub(l.lastSweep) > idleBucketTTL {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > idleBucketTTL {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	capacity := float64(max(burst, 1))
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+
+	return 0, true
+}
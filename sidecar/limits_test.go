@@ -0,0 +1,124 @@
+package sidecar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_RateLimit(t *testing.T) {
+	s := NewServer(newTestManager(&testECSClient{}))
+	s.Limits = Limits{RatePerSecond: 1, Burst: 2}
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/status", nil)
+		r.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1000").Code)
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1001").Code)
+
+	rec := request("10.0.0.1:1002")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:1000").Code, "clients should be limited separately")
+
+	assert.Equal(t, Metrics{Requests: 4, RateLimited: 1}, s.Metrics())
+}
+
+func TestServer_RateLimit_Tokens(t *testing.T) {
+	s := NewServer(newTestManager(&testECSClient{}))
+	s.Auth = NewTokenAuth("valid")
+	s.Limits = Limits{RatePerSecond: 1, Burst: 1}
+
+	request := func(token string) int {
+		r := httptest.NewRequest(http.MethodGet, "/status", nil)
+		r.RemoteAddr = "10.0.0.1:1000"
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("forged-1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("forged-2"), "invalid tokens should share the bucket of the address")
+	assert.Equal(t, http.StatusOK, request("valid"), "valid tokens should have their own bucket")
+	assert.Equal(t, http.StatusTooManyRequests, request("valid"))
+
+	for key := range s.limiter.buckets {
+		assert.NotContains(t, key, "valid", "tokens should not be kept as keys")
+	}
+}
+
+func TestServer_MaxBodyBytes(t *testing.T) {
+	s := NewServer(newTestManager(&testECSClient{}))
+	s.Limits = Limits{MaxBodyBytes: 16}
+
+	rec := httptest.NewRecorder()
+	body := `{"name":"` + strings.Repeat("a", 64) + `"}`
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/leases", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, s.leases.list())
+}
+
+func TestServer_Timeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		wantDeadline bool
+	}{
+		{name: "should bound regular requests", target: "/status", wantDeadline: true},
+		{name: "should not bound long polls", target: "/status?wait=1s"},
+		{name: "should not bound event streams", target: "/events"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(newTestManager(&testECSClient{}))
+			s.Limits = Limits{Timeout: time.Minute}
+
+			var ctx context.Context
+			s.mux = http.NewServeMux()
+			s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				ctx = r.Context()
+			})
+			s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			_, ok := ctx.Deadline()
+			assert.Equal(t, tt.wantDeadline, ok)
+		})
+	}
+}
+
+func Test_rateLimiter_allow(t *testing.T) {
+	var l rateLimiter
+
+	_, ok := l.allow("a", 100, 1)
+	assert.True(t, ok)
+	wait, ok := l.allow("a", 100, 1)
+	assert.False(t, ok)
+	assert.LessOrEqual(t, wait, 10*time.Millisecond)
+
+	time.Sleep(15 * time.Millisecond)
+	_, ok = l.allow("a", 100, 1)
+	assert.True(t, ok, "tokens should be refilled over time")
+}
+
+func TestServer_MetricsEndpoint(t *testing.T) {
+	s := NewServer(newTestManager(&testECSClient{}))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"requests":1,"rateLimited":0,"timedOut":0}`, rec.Body.String())
+}
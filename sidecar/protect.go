@@ -0,0 +1,49 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// handleProtect handles requests to /protect, enabling and disabling protection outside of leases
+// for clients that toggle it themselves.
+func (s *Server) handleProtect(w http.ResponseWriter, r *http.Request) {
+	var (
+		state ecstp.State
+		err   error
+	)
+	switch r.Method {
+	case http.MethodPut:
+		var params ProtectParams
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxLeaseRequestBody)).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid protect request", http.StatusBadRequest)
+			return
+		}
+		if params.ExpiresInMinutes != nil && (*params.ExpiresInMinutes < 1 || *params.ExpiresInMinutes > ecstp.MaxExpiresInMinutes) {
+			http.Error(w, "invalid expiresInMinutes", http.StatusBadRequest)
+			return
+		}
+		state, err = s.leases.protect(r.Context(), params.ExpiresInMinutes)
+	case http.MethodDelete:
+		state, err = s.leases.unprotect(r.Context())
+	default:
+		w.Header().Set("Allow", "PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrDraining):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, errLeasesHeld):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		writeJSON(w, http.StatusBadGateway, errorDetail(state, err))
+	default:
+		writeJSON(w, http.StatusOK, state)
+	}
+}
@@ -0,0 +1,119 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+func TestServer_Protect(t *testing.T) {
+	tests := []struct {
+		name          string
+		ecsClient     *testECSClient
+		method        string
+		body          string
+		setup         func(t *testing.T, s *Server)
+		wantStatus    int
+		wantProtected bool
+	}{
+		{
+			name:          "should enable protection",
+			ecsClient:     &testECSClient{},
+			method:        http.MethodPut,
+			wantStatus:    http.StatusOK,
+			wantProtected: true,
+		},
+		{
+			name:          "should enable protection for the requested period",
+			ecsClient:     &testECSClient{},
+			method:        http.MethodPut,
+			body:          `{"expiresInMinutes": 60}`,
+			wantStatus:    http.StatusOK,
+			wantProtected: true,
+		},
+		{
+			name:       "should reject invalid periods",
+			ecsClient:  &testECSClient{},
+			method:     http.MethodPut,
+			body:       `{"expiresInMinutes": 0}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject invalid requests",
+			ecsClient:  &testECSClient{},
+			method:     http.MethodPut,
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should report failed updates",
+			ecsClient:  &testECSClient{fail: true},
+			method:     http.MethodPut,
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:      "should not enable protection while draining",
+			ecsClient: &testECSClient{},
+			method:    http.MethodPut,
+			setup: func(t *testing.T, s *Server) {
+				s.leases.drain()
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:      "should disable protection",
+			ecsClient: &testECSClient{},
+			method:    http.MethodDelete,
+			setup: func(t *testing.T, s *Server) {
+				_, err := s.manager.Protect(context.Background(), nil)
+				require.NoError(t, err)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "should not disable protection while leases are held",
+			ecsClient: &testECSClient{},
+			method:    http.MethodDelete,
+			setup: func(t *testing.T, s *Server) {
+				_, err := s.leases.acquire(context.Background(), "job", nil, time.Minute)
+				require.NoError(t, err)
+			},
+			wantStatus:    http.StatusConflict,
+			wantProtected: true,
+		},
+		{
+			name:       "should reject other methods",
+			ecsClient:  &testECSClient{},
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(newTestManager(tt.ecsClient))
+			if tt.setup != nil {
+				tt.setup(t, s)
+			}
+
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(tt.method, "/protect", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantProtected, s.manager.State().Protected)
+			if tt.wantStatus == http.StatusOK {
+				var got ecstp.State
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+				assert.Equal(t, tt.wantProtected, got.Protected)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// Stream event types sent in addition to the ecstp.Manager event types.
+const (
+	// EventState is sent when a client connects, describing the current state.
+	EventState = "state"
+	// EventCountdown is sent periodically while protection with an expiry is enabled.
+	EventCountdown = "countdown"
+)
+
+// MaxStatusWait is the longest a long-poll status request is held open.
+const MaxStatusWait = 5 * time.Minute
+
+// DefaultCountdownInterval is the default interval between countdown events.
+const DefaultCountdownInterval = 10 * time.Second
+
+// StreamEvent is an event pushed to streaming clients.
+type StreamEvent struct {
+	ecstp.Event
+	// RemainingSeconds is the time left until protection expires, set for countdown events.
+	RemainingSeconds *int64 `json:"remainingSeconds,omitempty"`
+}
+
+// Server is an http.Handler exposing a Manager to other containers in the task.
+//
+// Endpoints:
+//
+//	GET /status   the current ecstp.Snapshot as JSON; with ?wait=30s the response is held until the
+//	              state changes or the wait (capped at MaxStatusWait) elapses
+//	GET /ws       WebSocket pushing StreamEvents as JSON text messages
+//	GET /events   Server-Sent Events stream of StreamEvents, resumable via Last-Event-ID
+//
+//	GET    /leases       list the held Leases, filtered by ?label=key=value
+//	GET    /leases/{id}  get a Lease
+//	POST   /leases       acquire a Lease with an optional LeaseRequest body
+//	PUT    /leases/{id}  heartbeat a Lease, extending it by its TTL
+//	DELETE /leases/{id}  release a Lease
+//
+// The task is protected while any lease is held. Leases that aren't heartbeated within their TTL
+// are released automatically, so a crashed client can't keep the task protected.
+//
+//	PUT    /protect      enable protection with an optional ProtectParams body
+//	DELETE /protect      disable protection, unless leases are held
+//
+// Both return the resulting ecstp.State. Protection enabled with PUT /protect isn't renewed and is
+// left to expire, or to be disabled with DELETE /protect or when the last lease is released.
+//
+//	POST /auth/reload    reload the token file of Auth
+//	GET  /metrics        request Metrics as JSON
+//
+// If Auth is set, every request must carry a valid "Authorization: Bearer <token>" header.
+//
+// Requests are rate limited per client according to Limits, with rejected requests receiving a
+// 429 response, and counted in Metrics.
+type Server struct {
+	// CountdownInterval is the interval between countdown events. Defaults to
+	// DefaultCountdownInterval.
+	CountdownInterval time.Duration
+	// LeaseTTL is the time a lease is held without a heartbeat, unless requested otherwise.
+	// Defaults to DefaultLeaseTTL.
+	LeaseTTL time.Duration
+	// Auth, if set, requires every request to carry a valid bearer token.
+	Auth *TokenAuth
+	// Limits protects the sidecar from misbehaving clients. Defaults to DefaultLimits.
+	Limits Limits
+	// Logger is used to log connection errors. Defaults to slog.Default().
+	Logger *slog.Logger
+
+	manager *ecstp.Manager
+	leases  *leaseTable
+	mux     *http.ServeMux
+	limiter rateLimiter
+	metrics serverMetrics
+}
+
+// NewServer returns a Server for m.
+func NewServer(m *ecstp.Manager) *Server {
+	s := &Server{
+		CountdownInterval: DefaultCountdownInterval,
+		Limits:            DefaultLimits,
+		manager:           m,
+		mux:               http.NewServeMux(),
+	}
+	s.leases = newLeaseTable(m, s.logger)
+	m.SetLeaseSource(s.leases.list)
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/protect", s.handleProtect)
+	s.mux.HandleFunc("/ws", s.handleWebSocket)
+	s.mux.HandleFunc("/events", s.handleEvents)
+	s.mux.HandleFunc("/leases", s.handleLeases)
+	s.mux.HandleFunc("/leases/", s.handleLeases)
+	s.mux.HandleFunc("/auth/reload", s.handleAuthReload)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel, ok := s.limit(w, r)
+	defer cancel()
+	if !ok {
+		return
+	}
+
+	if s.Auth != nil && !s.Auth.authenticate(w, r) {
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+	s.recordTimeout(r)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+		d, err := parseWait(wait)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.waitForEvent(r.Context(), d)
+	}
+
+	writeJSON(w, http.StatusOK, s.manager.Snapshot())
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.Metrics())
+}
+
+// waitForEvent blocks until the Manager publishes an event, d elapses or ctx is done.
+func (s *Server) waitForEvent(ctx context.Context, d time.Duration) {
+	events, cancel := s.manager.Subscribe()
+	defer cancel()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-events:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// parseWait parses the wait parameter of a long-poll request, either as a duration such as "30s" or
+// a number of seconds.
+func parseWait(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid wait %q", value)
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid wait %q", value)
+	}
+
+	return min(d, MaxStatusWait), nil
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	events, cancel := s.manager.Subscribe()
+	defer cancel()
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// the client closing the connection ends the read loop, and with it the stream
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.readLoop()
+	}()
+
+	send := func(event StreamEvent) bool {
+		b, err := json.Marshal(event)
+		if err == nil {
+			err = conn.WriteText(b)
+		}
+		if err != nil {
+			s.logger().Debug("websocket client disconnected", slog.Any("error", err))
+			return false
+		}
+		return true
+	}
+
+	if !send(s.stateEvent(EventState)) {
+		return
+	}
+	s.stream(events, send, closed)
+}
+
+// stream sends events and periodic countdown events using send until it fails or done is closed.
+func (s *Server) stream(events <-chan ecstp.Event, send func(StreamEvent) bool, done <-chan struct{}) {
+	ticker := time.NewTicker(s.countdownInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			if !send(StreamEvent{Event: event}) {
+				return
+			}
+		case <-ticker.C:
+			event := s.stateEvent(EventCountdown)
+			if event.RemainingSeconds != nil && !send(event) {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// stateEvent returns an event of eventType describing the current state, including the remaining
+// protection time if protection expires.
+func (s *Server) stateEvent(eventType string) StreamEvent {
+	now := time.Now().UTC()
+	state := s.manager.State()
+	event := StreamEvent{
+		Event: ecstp.Event{
+			Type:  eventType,
+			Time:  now,
+			State: state,
+		},
+	}
+	if state.Protected && state.ExpiresAt != nil {
+		remaining := int64(max(state.ExpiresAt.Sub(now), 0) / time.Second)
+		event.RemainingSeconds = &remaining
+	}
+
+	return event
+}
+
+func (s *Server) countdownInterval() time.Duration {
+	if s.CountdownInterval <= 0 {
+		return DefaultCountdownInterval
+	}
+
+	return s.CountdownInterval
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+
+	return s.Logger
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,266 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+func TestServer_Status(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		wantStatus int
+	}{
+		{
+			name:       "should return the current state",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should reject other methods",
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(&testECSClient{})
+			_, err := m.Protect(context.Background(), nil)
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			NewServer(m).ServeHTTP(rec, httptest.NewRequest(tt.method, "/status", nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				var got ecstp.State
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+				assert.True(t, got.Protected)
+			}
+		})
+	}
+}
+
+// testWebSocket is a minimal WebSocket client for testing.
+type testWebSocket struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialTestWebSocket(t *testing.T, url string) *testWebSocket {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	_, err = io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get("Sec-WebSocket-Accept"))
+
+	return &testWebSocket{conn: conn, br: br}
+}
+
+func (ws *testWebSocket) readEvent(t *testing.T) StreamEvent {
+	t.Helper()
+
+	ws.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var header [2]byte
+	_, err := io.ReadFull(ws.br, header[:])
+	require.NoError(t, err)
+	require.Equal(t, byte(0x80|opText), header[0])
+
+	length := int(header[1])
+	if length == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(ws.br, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(ws.br, payload)
+	require.NoError(t, err)
+
+	var event StreamEvent
+	require.NoError(t, json.Unmarshal(payload, &event))
+
+	return event
+}
+
+func (ws *testWebSocket) close(t *testing.T) {
+	t.Helper()
+
+	// masked close frame with an empty payload
+	_, err := ws.conn.Write([]byte{0x80 | opClose, 0x80, 1, 2, 3, 4})
+	require.NoError(t, err)
+
+	var header [2]byte
+	_, err = io.ReadFull(ws.br, header[:])
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x80|opClose), header[0])
+}
+
+func TestServer_WebSocket(t *testing.T) {
+	m := newTestManager(&testECSClient{})
+	server := NewServer(m)
+	server.CountdownInterval = 10 * time.Millisecond
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	ws := dialTestWebSocket(t, ts.URL)
+
+	event := ws.readEvent(t)
+	assert.Equal(t, EventState, event.Type)
+	assert.False(t, event.State.Protected)
+
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+
+	event = ws.readEvent(t)
+	assert.Equal(t, ecstp.EventProtected, event.Type)
+	assert.True(t, event.State.Protected)
+
+	ws.close(t)
+}
+
+func TestServer_WebSocket_Countdown(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	m := newTestManager(&testECSClient{expiresAt: &expiresAt})
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+
+	server := NewServer(m)
+	server.CountdownInterval = 10 * time.Millisecond
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	ws := dialTestWebSocket(t, ts.URL)
+	assert.Equal(t, EventState, ws.readEvent(t).Type)
+
+	event := ws.readEvent(t)
+	assert.Equal(t, EventCountdown, event.Type)
+	if assert.NotNil(t, event.RemainingSeconds) {
+		assert.InDelta(t, time.Hour.Seconds(), *event.RemainingSeconds, 5)
+	}
+
+	ws.close(t)
+}
+
+func TestServer_WebSocket_BadHandshake(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer(newTestManager(&testECSClient{})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_Status_Wait(t *testing.T) {
+	tests := []struct {
+		name          string
+		wait          string
+		protect       bool
+		wantStatus    int
+		wantProtected bool
+	}{
+		{
+			name:          "should return once the state changes",
+			wait:          "30s",
+			protect:       true,
+			wantStatus:    http.StatusOK,
+			wantProtected: true,
+		},
+		{
+			name:       "should return the unchanged state when the wait expires",
+			wait:       "10ms",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should accept a number of seconds",
+			wait:       "0",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should reject invalid waits",
+			wait:       "soon",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(&testECSClient{})
+			rec := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				NewServer(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status?wait="+tt.wait, nil))
+			}()
+
+			if tt.protect {
+				// keep changing state until the request, which may not have subscribed yet, returns
+				ticker := time.NewTicker(10 * time.Millisecond)
+				defer ticker.Stop()
+			loop:
+				for {
+					select {
+					case <-done:
+						break loop
+					case <-ticker.C:
+						m.Protect(context.Background(), nil)
+					}
+				}
+			}
+			<-done
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				var got ecstp.State
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+				assert.Equal(t, tt.wantProtected, got.Protected)
+			}
+		})
+	}
+}
+
+func Test_parseWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "should parse durations", value: "30s", want: 30 * time.Second},
+		{name: "should parse seconds", value: "15", want: 15 * time.Second},
+		{name: "should cap long waits", value: "1h", want: MaxStatusWait},
+		{name: "should reject negative waits", value: "-1s", wantErr: true},
+		{name: "should reject garbage", value: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWait(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
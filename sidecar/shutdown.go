@@ -0,0 +1,82 @@
+package sidecar
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+// ShutdownReport describes the outcome of Server.Shutdown.
+type ShutdownReport struct {
+	// LeasesHeld is the number of leases held when the shutdown started.
+	LeasesHeld int `json:"leasesHeld"`
+	// Drained is true if every lease was released before the drain deadline.
+	Drained bool `json:"drained"`
+	// Revoked lists the leases still held at the deadline, which were dropped.
+	Revoked []Lease `json:"revoked,omitempty"`
+	// Unprotected is true if the final unprotect succeeded.
+	Unprotected bool `json:"unprotected"`
+	// Error describes why the final unprotect failed.
+	Error *ecstp.ErrorDetail `json:"error,omitempty"`
+	// Duration is the time the shutdown took.
+	Duration time.Duration `json:"duration"`
+}
+
+// Shutdown runs the sidecar's shutdown sequence, e.g. when the task receives SIGTERM:
+//
+//  1. new leases are rejected with 503 Service Unavailable, while existing leases can still be
+//     heartbeated and released;
+//  2. Shutdown waits up to drainTimeout for the held leases to be released;
+//  3. any leases still held are revoked;
+//  4. protection is disabled, whether or not the task was protected.
+//
+// The outcome is logged and returned. The final unprotect is made with Manager.ShutdownUnprotect,
+// so it's retried and made even if ctx is done by then. The HTTP server should keep serving until
+// Shutdown returns so clients can release their leases.
+func (s *Server) Shutdown(ctx context.Context, drainTimeout time.Duration) ShutdownReport {
+	start := time.Now()
+	report := ShutdownReport{LeasesHeld: len(s.leases.list())}
+
+	drained := s.leases.drain()
+	s.logger().InfoContext(ctx, "sidecar shutting down, waiting for leases to drain",
+		slog.Int("leases", report.LeasesHeld),
+		slog.Duration("drain_timeout", drainTimeout),
+	)
+
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		report.Drained = true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	report.Revoked = s.leases.revoke()
+	if _, err := s.manager.ShutdownUnprotect(ctx); err != nil {
+		report.Error = errorDetail(s.manager.State(), err)
+	} else {
+		report.Unprotected = true
+	}
+	report.Duration = time.Since(start)
+
+	attrs := []any{
+		slog.Int("leases", report.LeasesHeld),
+		slog.Bool("drained", report.Drained),
+		slog.Int("revoked", len(report.Revoked)),
+		slog.Bool("unprotected", report.Unprotected),
+		slog.Duration("duration", report.Duration),
+	}
+	for _, lease := range report.Revoked {
+		s.logger().WarnContext(ctx, "revoked lease at shutdown", slog.String("lease_id", lease.ID), slog.String("name", lease.Name))
+	}
+	if report.Error != nil {
+		s.logger().ErrorContext(ctx, "sidecar shutdown failed to unprotect task", append(attrs, slog.Any("error", report.Error))...)
+	} else {
+		s.logger().InfoContext(ctx, "sidecar shutdown complete", attrs...)
+	}
+
+	return report
+}
@@ -0,0 +1,112 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Shutdown(t *testing.T) {
+	tests := []struct {
+		name          string
+		ecsClient     *testECSClient
+		leases        int
+		release       bool
+		wantDrained   bool
+		wantRevoked   int
+		wantUnprotect bool
+	}{
+		{
+			name:          "should unprotect immediately without leases",
+			ecsClient:     &testECSClient{},
+			wantDrained:   true,
+			wantUnprotect: true,
+		},
+		{
+			name:          "should wait for leases to be released",
+			ecsClient:     &testECSClient{},
+			leases:        2,
+			release:       true,
+			wantDrained:   true,
+			wantUnprotect: true,
+		},
+		{
+			name:          "should revoke leases held at the deadline",
+			ecsClient:     &testECSClient{},
+			leases:        2,
+			wantRevoked:   2,
+			wantUnprotect: true,
+		},
+		{
+			name:        "should report a failed final unprotect",
+			ecsClient:   &testECSClient{fail: true},
+			wantDrained: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(tt.ecsClient)
+			s := NewServer(m)
+
+			var ids []string
+			for i := 0; i < tt.leases; i++ {
+				rec := doLeaseRequest(t, s, http.MethodPost, "/leases", "")
+				require.Equal(t, http.StatusCreated, rec.Code)
+				var lease Lease
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&lease))
+				ids = append(ids, lease.ID)
+			}
+
+			if tt.release {
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					for _, id := range ids {
+						doLeaseRequest(t, s, http.MethodDelete, "/leases/"+id, "")
+					}
+				}()
+			}
+
+			report := s.Shutdown(context.Background(), 200*time.Millisecond)
+
+			assert.Equal(t, tt.leases, report.LeasesHeld)
+			assert.Equal(t, tt.wantDrained, report.Drained)
+			assert.Len(t, report.Revoked, tt.wantRevoked)
+			assert.Equal(t, tt.wantUnprotect, report.Unprotected)
+			assert.Equal(t, !tt.wantUnprotect, report.Error != nil)
+			assert.False(t, m.State().Protected)
+			assert.Empty(t, s.leases.list())
+		})
+	}
+}
+
+func TestServer_Shutdown_RejectsNewLeases(t *testing.T) {
+	s := NewServer(newTestManager(&testECSClient{}))
+
+	rec := doLeaseRequest(t, s, http.MethodPost, "/leases", "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var lease Lease
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&lease))
+
+	done := make(chan ShutdownReport)
+	go func() { done <- s.Shutdown(context.Background(), time.Second) }()
+
+	require.Eventually(t, func() bool {
+		s.leases.mu.Lock()
+		defer s.leases.mu.Unlock()
+		return s.leases.drained != nil
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, doLeaseRequest(t, s, http.MethodPost, "/leases", "").Code)
+
+	// existing leases can still be heartbeated and released while draining
+	assert.Equal(t, http.StatusOK, doLeaseRequest(t, s, http.MethodPut, "/leases/"+lease.ID, "").Code)
+	assert.Equal(t, http.StatusNoContent, doLeaseRequest(t, s, http.MethodDelete, "/leases/"+lease.ID, "").Code)
+
+	report := <-done
+	assert.True(t, report.Drained)
+	assert.True(t, report.Unprotected)
+}
@@ -0,0 +1,100 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// sseRetryMillis is the reconnection delay suggested to SSE clients.
+const sseRetryMillis = 3000
+
+// handleEvents streams events using Server-Sent Events.
+//
+// Manager events carry their ID, so a reconnecting client sending Last-Event-ID receives the
+// retained events it missed instead of the current state. Countdown and state events don't have an
+// ID and therefore don't move the client's position in the stream.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID, resume := parseLastEventID(r)
+	if !resume {
+		lastID = ^uint64(0)
+	}
+	missed, events, cancel := s.manager.SubscribeSince(lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event StreamEvent) bool {
+		if err := writeSSE(w, event); err != nil {
+			s.logger().Debug("event stream client disconnected", slog.Any("error", err))
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis); err != nil {
+		return
+	}
+
+	if resume {
+		for _, event := range missed {
+			if !send(StreamEvent{Event: event}) {
+				return
+			}
+		}
+	} else if !send(s.stateEvent(EventState)) {
+		return
+	}
+
+	s.stream(events, send, r.Context().Done())
+}
+
+// parseLastEventID returns the ID sent by a reconnecting client, either in the Last-Event-ID header
+// or the lastEventId query parameter for clients that can't set headers.
+func parseLastEventID(r *http.Request) (uint64, bool) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return id, true
+}
+
+func writeSSE(w http.ResponseWriter, event StreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if event.ID != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+
+	return err
+}
@@ -0,0 +1,141 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ecstp "github.com/Thumbscrew/ecs-task-protection"
+)
+
+type sseEvent struct {
+	id    string
+	event StreamEvent
+}
+
+func readSSEEvent(t *testing.T, br *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var got sseEvent
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && got.event.Type != "":
+			return got
+		case strings.HasPrefix(line, "id: "):
+			got.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &got.event))
+		}
+	}
+}
+
+func openEventStream(t *testing.T, url string, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/events", nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	return bufio.NewReader(res.Body)
+}
+
+func TestServer_Events(t *testing.T) {
+	m := newTestManager(&testECSClient{})
+	ts := httptest.NewServer(NewServer(m))
+	t.Cleanup(ts.Close)
+
+	br := openEventStream(t, ts.URL, "")
+
+	got := readSSEEvent(t, br)
+	assert.Equal(t, EventState, got.event.Type)
+	assert.Empty(t, got.id)
+
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+
+	got = readSSEEvent(t, br)
+	assert.Equal(t, ecstp.EventProtected, got.event.Type)
+	assert.Equal(t, "1", got.id)
+}
+
+func TestServer_Events_Resume(t *testing.T) {
+	m := newTestManager(&testECSClient{})
+	for i := 0; i < 3; i++ {
+		_, err := m.Protect(context.Background(), nil)
+		require.NoError(t, err)
+	}
+
+	ts := httptest.NewServer(NewServer(m))
+	t.Cleanup(ts.Close)
+
+	br := openEventStream(t, ts.URL, "1")
+	for _, want := range []string{"2", "3"} {
+		got := readSSEEvent(t, br)
+		assert.Equal(t, want, got.id)
+		assert.Equal(t, ecstp.EventProtected, got.event.Type)
+	}
+}
+
+func Test_parseLastEventID(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		target     string
+		wantID     uint64
+		wantResume bool
+	}{
+		{
+			name:       "should parse the header",
+			header:     "42",
+			target:     "/events",
+			wantID:     42,
+			wantResume: true,
+		},
+		{
+			name:       "should fall back to the query parameter",
+			target:     "/events?lastEventId=7",
+			wantID:     7,
+			wantResume: true,
+		},
+		{
+			name:   "should ignore invalid IDs",
+			header: "abc",
+			target: "/events",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Last-Event-ID", tt.header)
+			}
+
+			id, resume := parseLastEventID(r)
+			assert.Equal(t, tt.wantID, id)
+			assert.Equal(t, tt.wantResume, resume)
+		})
+	}
+}
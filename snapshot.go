@@ -0,0 +1,77 @@
+package ecstp
+
+import "time"
+
+// snapshotFailures is the maximum number of recent failures included in a Snapshot.
+const snapshotFailures = 10
+
+// Lease is a claim on protection of the task held by a unit of work, e.g. a lease acquired from
+// ecstp-sidecar.
+type Lease struct {
+	ID   string `json:"id" yaml:"id"`
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Labels identify the work holding the lease, e.g. a job or request ID.
+	Labels    map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	CreatedAt time.Time         `json:"createdAt" yaml:"createdAt"`
+	// HeartbeatAt is when the lease was last heartbeated, or acquired if it never was.
+	HeartbeatAt time.Time `json:"heartbeatAt" yaml:"heartbeatAt"`
+	ExpiresAt   time.Time `json:"expiresAt" yaml:"expiresAt"`
+}
+
+// Failure is a failed or refused protection update.
+type Failure struct {
+	Time time.Time `json:"time" yaml:"time"`
+	// Type is the type of the event published for the failure, EventUpdateFailed or
+	// EventProtectionRefused.
+	Type  string       `json:"type" yaml:"type"`
+	Error *ErrorDetail `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Snapshot is the protection state of a Manager along with the leases held on it and its recent
+// failures, for admin UIs and status endpoints. Its JSON and YAML encodings are stable, with the
+// fields of State inlined.
+type Snapshot struct {
+	State `yaml:",inline"`
+	// Leases are the held leases, oldest first, if a lease source was set with SetLeaseSource.
+	Leases []Lease `json:"leases,omitempty" yaml:"leases,omitempty"`
+	// RecentFailures are the most recent failed or refused updates, oldest first.
+	RecentFailures []Failure `json:"recentFailures,omitempty" yaml:"recentFailures,omitempty"`
+}
+
+// SetLeaseSource sets the function listing the leases held on the Manager's protection, to be
+// included in Snapshots. It's set by ecstp-sidecar's Server.
+func (m *Manager) SetLeaseSource(source func() []Lease) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.leaseSource = source
+}
+
+// Snapshot returns a Snapshot of the Manager.
+func (m *Manager) Snapshot() Snapshot {
+	m.mu.Lock()
+	snapshot := Snapshot{State: m.state}
+	source := m.leaseSource
+	m.mu.Unlock()
+
+	if source != nil {
+		snapshot.Leases = source()
+	}
+
+	m.subMu.Lock()
+	for _, event := range m.history {
+		if event.Type == EventUpdateFailed || event.Type == EventProtectionRefused {
+			snapshot.RecentFailures = append(snapshot.RecentFailures, Failure{
+				Time:  event.Time,
+				Type:  event.Type,
+				Error: event.State.LastError,
+			})
+		}
+	}
+	m.subMu.Unlock()
+	if len(snapshot.RecentFailures) > snapshotFailures {
+		snapshot.RecentFailures = snapshot.RecentFailures[len(snapshot.RecentFailures)-snapshotFailures:]
+	}
+
+	return snapshot
+}
@@ -0,0 +1,100 @@
+package ecstp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestManager_Snapshot(t *testing.T) {
+	m := NewManager(NewClient(&FailureTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	for i := 0; i < snapshotFailures+2; i++ {
+		_, err := m.Protect(context.Background(), nil)
+		require.Error(t, err)
+	}
+
+	snapshot := m.Snapshot()
+	assert.Equal(t, m.State(), snapshot.State)
+	assert.Empty(t, snapshot.Leases)
+	require.Len(t, snapshot.RecentFailures, snapshotFailures)
+	assert.Equal(t, EventUpdateFailed, snapshot.RecentFailures[0].Type)
+	assert.Equal(t, OperationUpdateTaskProtection, snapshot.RecentFailures[0].Error.Operation)
+
+	lease := Lease{ID: "abc", Name: "worker-1"}
+	m.SetLeaseSource(func() []Lease { return []Lease{lease} })
+	assert.Equal(t, []Lease{lease}, m.Snapshot().Leases)
+}
+
+func TestSnapshot_Marshal(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := Snapshot{
+		State: State{
+			Protected: true,
+			ExpiresAt: &at,
+			TaskARN:   "test_arn",
+			UpdatedAt: at,
+		},
+		Leases: []Lease{{
+			ID:          "abc",
+			Labels:      map[string]string{"job": "42"},
+			CreatedAt:   at,
+			HeartbeatAt: at,
+			ExpiresAt:   at,
+		}},
+		RecentFailures: []Failure{{
+			Time:  at,
+			Type:  EventUpdateFailed,
+			Error: &ErrorDetail{Operation: OperationUpdateTaskProtection, Code: "ThrottlingException", Retryable: true},
+		}},
+	}
+
+	b, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"protected": true,
+		"expiresAt": "2024-05-01T12:00:00Z",
+		"taskArn": "test_arn",
+		"updatedAt": "2024-05-01T12:00:00Z",
+		"leases": [{
+			"id": "abc",
+			"labels": {"job": "42"},
+			"createdAt": "2024-05-01T12:00:00Z",
+			"heartbeatAt": "2024-05-01T12:00:00Z",
+			"expiresAt": "2024-05-01T12:00:00Z"
+		}],
+		"recentFailures": [{
+			"time": "2024-05-01T12:00:00Z",
+			"type": "update_failed",
+			"error": {"operation": "UpdateTaskProtection", "code": "ThrottlingException", "retryable": true}
+		}]
+	}`, string(b))
+
+	// a Snapshot decodes as a State
+	var state State
+	require.NoError(t, json.Unmarshal(b, &state))
+	assert.Equal(t, snapshot.State.TaskARN, state.TaskARN)
+
+	b, err = yaml.Marshal(snapshot)
+	require.NoError(t, err)
+	assert.YAMLEq(t, `
+protected: true
+expiresAt: 2024-05-01T12:00:00Z
+taskArn: test_arn
+updatedAt: 2024-05-01T12:00:00Z
+leases:
+  - id: abc
+    labels: {job: "42"}
+    createdAt: 2024-05-01T12:00:00Z
+    heartbeatAt: 2024-05-01T12:00:00Z
+    expiresAt: 2024-05-01T12:00:00Z
+recentFailures:
+  - time: 2024-05-01T12:00:00Z
+    type: update_failed
+    error: {operation: UpdateTaskProtection, code: ThrottlingException, retryable: true}
+`, string(b))
+}
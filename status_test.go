@@ -0,0 +1,59 @@
+package ecstp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusHandler(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+	handler := StatusHandler(m)
+
+	tests := []struct {
+		name       string
+		method     string
+		wantStatus int
+		wantBody   bool
+	}{
+		{
+			name:       "should serve the snapshot",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantBody:   true,
+		},
+		{
+			name:       "should serve headers only for HEAD requests",
+			method:     http.MethodHead,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should reject other methods",
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, "/status", nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if !tt.wantBody {
+				return
+			}
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			var snapshot Snapshot
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&snapshot))
+			assert.True(t, snapshot.Protected)
+			assert.Equal(t, "test_arn", snapshot.TaskARN)
+		})
+	}
+}
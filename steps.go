@@ -0,0 +1,130 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrPipelineStopped is returned by StepGuard.Run when the task starts stopping while protection
+// has lapsed at a step boundary.
+var ErrPipelineStopped = errors.New("pipeline stopped at step boundary")
+
+// Step is a stage of a multi-stage pipeline, e.g. the extract, transform or load of an ETL job.
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// StepError is returned by StepGuard.Run when a step fails or panics.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %s: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// StepGuard runs the steps of a pipeline under protection, like Wrap, with a checkpoint between
+// steps. The context of each step carries its name as the "step" correlation label.
+//
+// If Lapse allows it at a step boundary, protection is released once the step is checkpointed and
+// enabled again for the next step after LapseFor, so the task can be scaled in at a safe point
+// rather than never. If the task starts stopping or ctx is done meanwhile, the remaining steps
+// aren't run.
+type StepGuard struct {
+	Manager *Manager
+	// Checkpoint, if set, is called once a step succeeds, while still protected, e.g. to persist
+	// the progress of the pipeline. If it fails, the pipeline stops.
+	Checkpoint func(ctx context.Context, step string) error
+	// Lapse, if set, reports whether protection may lapse between the completed step and the next.
+	Lapse func(step, next string) bool
+	// LapseFor is how long protection is left lapsed at a step boundary before the next step.
+	LapseFor time.Duration
+}
+
+// Run runs steps in order, stopping at the first that fails. The returned error is a *StepError,
+// ErrPipelineStopped or the error of ctx, joined with any error disabling protection.
+func (g *StepGuard) Run(ctx context.Context, steps ...Step) error {
+	held := false
+	release := func() error {
+		held = false
+		return g.Manager.releaseHold(ctx, true)
+	}
+
+	for i, step := range steps {
+		if !held {
+			if err := g.Manager.hold(ctx); err != nil {
+				return err
+			}
+			held = true
+		}
+
+		err := g.runStep(ctx, step)
+		if err == nil && g.Checkpoint != nil {
+			if err = g.Checkpoint(ctx, step.Name); err != nil {
+				err = &StepError{Step: step.Name, Err: fmt.Errorf("checkpoint: %w", err)}
+			}
+		}
+		if err != nil {
+			return errors.Join(err, release())
+		}
+
+		if i == len(steps)-1 || g.Lapse == nil || !g.Lapse(step.Name, steps[i+1].Name) {
+			continue
+		}
+		if err := release(); err != nil {
+			g.Manager.client.log().WarnContext(ctx, "unable to release protection at step boundary",
+				slog.String("step", step.Name),
+				slog.Any("error", err),
+			)
+		}
+		if err := g.lapse(ctx); err != nil {
+			return err
+		}
+	}
+	if held {
+		return release()
+	}
+
+	return nil
+}
+
+// runStep runs step with its name as a correlation label, recovering a panic.
+func (g *StepGuard) runStep(ctx context.Context, step Step) error {
+	ctx = ContextWithLabels(ctx, map[string]string{"step": step.Name})
+	if err := runJob(ctx, JobFunc(step.Run)); err != nil {
+		return &StepError{Step: step.Name, Err: err}
+	}
+
+	return nil
+}
+
+// lapse waits LapseFor with protection lapsed, returning ErrPipelineStopped if the task starts
+// stopping meanwhile.
+func (g *StepGuard) lapse(ctx context.Context) error {
+	if g.LapseFor > 0 {
+		timer := time.NewTimer(g.LapseFor)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if g.Manager.State().Stopping {
+		return ErrPipelineStopped
+	}
+
+	return nil
+}
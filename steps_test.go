@@ -0,0 +1,136 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepGuard_Run(t *testing.T) {
+	steps := func(log *[]string, m *Manager, failStep string) []Step {
+		var steps []Step
+		for _, name := range []string{"extract", "transform", "load"} {
+			name := name
+			steps = append(steps, Step{Name: name, Run: func(ctx context.Context) error {
+				*log = append(*log, name)
+				if !m.State().Protected {
+					*log = append(*log, "(unprotected)")
+				}
+				if LabelsFromContext(ctx)["step"] != name {
+					*log = append(*log, "(unlabelled)")
+				}
+				if name == failStep {
+					return errors.New("boom")
+				}
+				return nil
+			}})
+		}
+		return steps
+	}
+
+	tests := []struct {
+		name           string
+		failStep       string
+		checkpointErr  error
+		lapse          func(step, next string) bool
+		stop           bool
+		wantLog        []string
+		wantUnprotects int32
+		wantStepErr    string
+		wantErr        error
+	}{
+		{
+			name:           "should run every step and checkpoint in between",
+			wantLog:        []string{"extract", "checkpoint extract", "transform", "checkpoint transform", "load", "checkpoint load"},
+			wantUnprotects: 1,
+		},
+		{
+			name:           "should stop at the first failed step",
+			failStep:       "transform",
+			wantLog:        []string{"extract", "checkpoint extract", "transform"},
+			wantUnprotects: 1,
+			wantStepErr:    "transform",
+		},
+		{
+			name:           "should stop if a checkpoint fails",
+			checkpointErr:  errors.New("disk full"),
+			wantLog:        []string{"extract", "checkpoint extract"},
+			wantUnprotects: 1,
+			wantStepErr:    "extract",
+		},
+		{
+			name:           "should let protection lapse at allowed boundaries",
+			lapse:          func(step, next string) bool { return step == "extract" },
+			wantLog:        []string{"extract", "checkpoint extract", "transform", "checkpoint transform", "load", "checkpoint load"},
+			wantUnprotects: 2,
+		},
+		{
+			name:           "should stop at a boundary if the task is stopping",
+			lapse:          func(step, next string) bool { return true },
+			stop:           true,
+			wantLog:        []string{"extract", "checkpoint extract"},
+			wantUnprotects: 1,
+			wantErr:        ErrPipelineStopped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &CountingTestClient{}
+			m := NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+			var log []string
+			g := &StepGuard{
+				Manager: m,
+				Checkpoint: func(ctx context.Context, step string) error {
+					log = append(log, "checkpoint "+step)
+					if tt.stop {
+						m.MarkStopping("Scaling activity initiated")
+					}
+					return tt.checkpointErr
+				},
+				Lapse:    tt.lapse,
+				LapseFor: time.Millisecond,
+			}
+
+			err := g.Run(context.Background(), steps(&log, m, tt.failStep)...)
+
+			assert.Equal(t, tt.wantLog, log)
+			switch {
+			case tt.wantStepErr != "":
+				var stepErr *StepError
+				if assert.ErrorAs(t, err, &stepErr) {
+					assert.Equal(t, tt.wantStepErr, stepErr.Step)
+				}
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			default:
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantUnprotects, client.unprotects.Load())
+			assert.False(t, m.State().Protected)
+		})
+	}
+}
+
+// CountingTestClient counts UpdateTaskProtection calls enabling and disabling protection.
+type CountingTestClient struct {
+	SuccessfulTestClient
+	protects   atomic.Int32
+	unprotects atomic.Int32
+}
+
+func (c *CountingTestClient) UpdateTaskProtection(
+	ctx context.Context, params *ecs.UpdateTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.UpdateTaskProtectionOutput, error) {
+	if params.ProtectionEnabled {
+		c.protects.Add(1)
+	} else {
+		c.unprotects.Add(1)
+	}
+
+	return c.SuccessfulTestClient.UpdateTaskProtection(ctx, params, optFns...)
+}
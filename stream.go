@@ -0,0 +1,222 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStreamExpiry is the default protection period set by a StreamGuard.
+	DefaultStreamExpiry = 5 * time.Minute
+	// DefaultStreamStallTimeout is the default time a stream may go without emitting a chunk.
+	DefaultStreamStallTimeout = time.Minute
+	// streamRenewalRetry is the time after which a failed renewal is retried by the next chunk.
+	streamRenewalRetry = 10 * time.Second
+)
+
+// ErrStreamEnded is returned when a Stream is used after it ended.
+var ErrStreamEnded = errors.New("stream already ended")
+
+// StreamGuard protects the task while streams are in progress, e.g. token-by-token generations of
+// an LLM inference server, so scale-in never cuts a generation short.
+//
+// Protection is enabled for Expiry when the first stream begins. Rather than on a schedule, it's
+// renewed as streams emit chunks, once half of Expiry has passed, so protection lapses at its
+// expiry if every stream hangs. A stream emitting no chunk for StallTimeout is considered stalled
+// and stops counting until it emits again. Protection is disabled once the last stream ends or
+// stalls. It's safe for concurrent use.
+type StreamGuard struct {
+	Manager *Manager
+	// Expiry is the protection period. Defaults to DefaultStreamExpiry.
+	Expiry time.Duration
+	// StallTimeout is the time a stream may go without emitting a chunk. Defaults to
+	// DefaultStreamStallTimeout.
+	StallTimeout time.Duration
+	Logger       *slog.Logger
+
+	mu       sync.Mutex
+	streams  int
+	renewAt  time.Time
+	renewing bool
+}
+
+// Stream is a stream in progress, begun with StreamGuard.Begin.
+type Stream struct {
+	guard *StreamGuard
+
+	mu      sync.Mutex
+	stall   *time.Timer
+	counted bool
+	ended   bool
+}
+
+// Begin records a stream in progress, enabling protection if it's the first. If protection can't
+// be enabled, the stream isn't recorded.
+func (g *StreamGuard) Begin(ctx context.Context) (*Stream, error) {
+	if err := g.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	s := &Stream{guard: g, counted: true}
+	s.stall = time.AfterFunc(g.stallTimeout(), s.stalled)
+
+	return s, nil
+}
+
+// Streams returns the number of streams in progress that haven't stalled.
+func (g *StreamGuard) Streams() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.streams
+}
+
+// Chunk records that the stream emitted a chunk, e.g. a token, renewing protection in the
+// background if it's due. A stalled stream is counted again, enabling protection if needed.
+func (s *Stream) Chunk(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return ErrStreamEnded
+	}
+	if !s.counted {
+		if err := s.guard.acquire(ctx); err != nil {
+			return err
+		}
+		s.counted = true
+	}
+	s.stall.Reset(s.guard.stallTimeout())
+	s.guard.renewIfDue()
+
+	return nil
+}
+
+// End records that the stream ended, disabling protection if it was the last in progress.
+func (s *Stream) End(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return ErrStreamEnded
+	}
+	s.ended = true
+	s.stall.Stop()
+	if !s.counted {
+		return nil
+	}
+	s.counted = false
+
+	return s.guard.release(ctx)
+}
+
+// stalled stops counting the stream once it emitted no chunk for StallTimeout.
+func (s *Stream) stalled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended || !s.counted {
+		return
+	}
+	s.counted = false
+
+	ctx := context.Background()
+	s.guard.logger().WarnContext(ctx, "stream stalled, releasing its protection",
+		slog.Duration("stall_timeout", s.guard.stallTimeout()),
+	)
+	if err := s.guard.release(ctx); err != nil {
+		s.guard.logger().ErrorContext(ctx, "unable to disable protection after stalled stream",
+			slog.Any("error", err),
+		)
+	}
+}
+
+// acquire counts a stream, enabling protection if it's the first.
+func (g *StreamGuard) acquire(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.streams == 0 {
+		if _, err := g.Manager.Protect(ctx, expiresInMinutes(g.expiry())); err != nil {
+			return err
+		}
+		g.renewAt = time.Now().Add(g.expiry() / 2)
+	}
+	g.streams++
+
+	return nil
+}
+
+// release stops counting a stream, disabling protection if it was the last.
+func (g *StreamGuard) release(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.streams--
+	if g.streams > 0 {
+		return nil
+	}
+
+	_, err := g.Manager.FinalUnprotect(ctx)
+
+	return err
+}
+
+// renewIfDue renews protection in the background once half of Expiry has passed since the last
+// update, so emitting a chunk never waits for ECS.
+func (g *StreamGuard) renewIfDue() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.renewing || g.streams == 0 || time.Now().Before(g.renewAt) {
+		return
+	}
+	g.renewing = true
+
+	go func() {
+		ctx := context.Background()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.renewing = false
+		if g.streams == 0 {
+			return
+		}
+
+		if _, err := g.Manager.Protect(ctx, expiresInMinutes(g.expiry())); err != nil {
+			g.logger().ErrorContext(ctx, "unable to renew protection for streams in progress",
+				slog.Int("streams", g.streams),
+				slog.Any("error", err),
+			)
+			g.renewAt = time.Now().Add(streamRenewalRetry)
+			return
+		}
+		g.renewAt = time.Now().Add(g.expiry() / 2)
+	}()
+}
+
+func (g *StreamGuard) expiry() time.Duration {
+	if g.Expiry <= 0 {
+		return DefaultStreamExpiry
+	}
+
+	return g.Expiry
+}
+
+func (g *StreamGuard) stallTimeout() time.Duration {
+	if g.StallTimeout <= 0 {
+		return DefaultStreamStallTimeout
+	}
+
+	return g.StallTimeout
+}
+
+func (g *StreamGuard) logger() *slog.Logger {
+	if g.Logger == nil {
+		return slog.Default()
+	}
+
+	return g.Logger
+}
@@ -0,0 +1,81 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamGuard(t *testing.T) {
+	ctx := context.Background()
+	client := &CountingTestClient{}
+	g := &StreamGuard{
+		Manager: NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"}),
+		Expiry:  time.Minute,
+	}
+
+	s1, err := g.Begin(ctx)
+	require.NoError(t, err)
+	s2, err := g.Begin(ctx)
+	require.NoError(t, err)
+	assert.True(t, g.Manager.State().Protected)
+	assert.Equal(t, 2, g.Streams())
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s1.Chunk(ctx))
+	}
+	assert.Equal(t, int32(1), client.protects.Load(), "chunks shouldn't renew protection before it's due")
+
+	require.NoError(t, s1.End(ctx))
+	assert.True(t, g.Manager.State().Protected, "task should stay protected while a stream is in progress")
+	assert.ErrorIs(t, s1.End(ctx), ErrStreamEnded)
+	assert.ErrorIs(t, s1.Chunk(ctx), ErrStreamEnded)
+
+	require.NoError(t, s2.End(ctx))
+	assert.False(t, g.Manager.State().Protected)
+	assert.Equal(t, 0, g.Streams())
+}
+
+func TestStreamGuard_Renew(t *testing.T) {
+	ctx := context.Background()
+	client := &CountingTestClient{}
+	g := &StreamGuard{
+		Manager: NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"}),
+		Expiry:  20 * time.Millisecond,
+	}
+
+	s, err := g.Begin(ctx)
+	require.NoError(t, err)
+	time.Sleep(15 * time.Millisecond)
+	require.NoError(t, s.Chunk(ctx))
+	assert.Eventually(t, func() bool { return client.protects.Load() == 2 }, time.Second, time.Millisecond,
+		"a chunk should renew protection once it's due")
+
+	require.NoError(t, s.End(ctx))
+}
+
+func TestStreamGuard_Stall(t *testing.T) {
+	ctx := context.Background()
+	client := &CountingTestClient{}
+	g := &StreamGuard{
+		Manager:      NewManager(NewClient(client), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"}),
+		StallTimeout: 20 * time.Millisecond,
+	}
+
+	s, err := g.Begin(ctx)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return g.Streams() == 0 }, time.Second, time.Millisecond,
+		"a stalled stream should stop counting")
+	assert.False(t, g.Manager.State().Protected)
+
+	require.NoError(t, s.Chunk(ctx))
+	assert.Equal(t, 1, g.Streams(), "a stalled stream should count again once it emits")
+	assert.True(t, g.Manager.State().Protected)
+
+	require.NoError(t, s.End(ctx))
+	assert.False(t, g.Manager.State().Protected)
+	assert.Equal(t, int32(2), client.unprotects.Load())
+}
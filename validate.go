@@ -0,0 +1,70 @@
+package ecstp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidInput is matched by a *ValidationError with errors.Is.
+var ErrInvalidInput = errors.New("invalid task protection input")
+
+// ValidationError is returned by UpdateTaskProtection and GetTaskProtection when their input is
+// rejected before calling ECS, e.g. because ExpiresInMinutes is out of range or the metadata doesn't
+// identify the task.
+type ValidationError struct {
+	// Field is the invalid field of the input, e.g. "ExpiresInMinutes" or "Metadata.Cluster".
+	Field  string
+	Reason string
+	// Err is the sentinel error classifying the failure, e.g. ErrExpirationOutOfRange, if any.
+	Err error
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidInput, e.Field, e.Reason)
+}
+
+// Is reports whether target is ErrInvalidInput.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// Unwrap returns Err.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validateMetadata returns a *ValidationError if metadata doesn't identify a task.
+func validateMetadata(metadata *MetadataBody) error {
+	switch {
+	case metadata.Cluster == "":
+		return &ValidationError{Field: "Metadata.Cluster", Reason: "is required"}
+	case metadata.TaskARN == "":
+		return &ValidationError{Field: "Metadata.TaskARN", Reason: "is required"}
+	}
+
+	return nil
+}
+
+// validateInput returns a *ValidationError if input, for the task identified by metadata, would be
+// rejected by ECS.
+func validateInput(metadata *MetadataBody, input *UpdateTaskProtectionInput) error {
+	if err := validateMetadata(metadata); err != nil {
+		return err
+	}
+
+	switch {
+	case input.ExpiresInMinutes == nil:
+		return nil
+	case !input.Protect:
+		return &ValidationError{Field: "ExpiresInMinutes", Reason: "must not be set when disabling protection"}
+	case *input.ExpiresInMinutes < 1 || *input.ExpiresInMinutes > MaxExpiresInMinutes:
+		return &ValidationError{
+			Field:  "ExpiresInMinutes",
+			Reason: fmt.Sprintf("must be between 1 and %d, got %d", MaxExpiresInMinutes, *input.ExpiresInMinutes),
+			Err:    ErrExpirationOutOfRange,
+		}
+	}
+
+	return nil
+}
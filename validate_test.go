@@ -0,0 +1,99 @@
+package ecstp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_UpdateTaskProtection_Validation(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     *UpdateTaskProtectionInput
+		wantField string
+		wantErr   error
+	}{
+		{
+			name: "should require the cluster",
+			input: &UpdateTaskProtectionInput{
+				Metadata: &MetadataBody{TaskARN: "test_arn"},
+				Protect:  true,
+			},
+			wantField: "Metadata.Cluster",
+		},
+		{
+			name: "should require the task ARN",
+			input: &UpdateTaskProtectionInput{
+				Metadata: &MetadataBody{Cluster: "test_cluster"},
+				Protect:  true,
+			},
+			wantField: "Metadata.TaskARN",
+		},
+		{
+			name: "should reject expirations below a minute",
+			input: &UpdateTaskProtectionInput{
+				Metadata:         &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:          true,
+				ExpiresInMinutes: aws.Int32(0),
+			},
+			wantField: "ExpiresInMinutes",
+			wantErr:   ErrExpirationOutOfRange,
+		},
+		{
+			name: "should reject expirations beyond the maximum",
+			input: &UpdateTaskProtectionInput{
+				Metadata:         &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:          true,
+				ExpiresInMinutes: aws.Int32(MaxExpiresInMinutes + 1),
+			},
+			wantField: "ExpiresInMinutes",
+			wantErr:   ErrExpirationOutOfRange,
+		},
+		{
+			name: "should reject expirations when disabling protection",
+			input: &UpdateTaskProtectionInput{
+				Metadata:         &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				ExpiresInMinutes: aws.Int32(60),
+			},
+			wantField: "ExpiresInMinutes",
+		},
+		{
+			name: "should reject ExpiresIn along with ExpiresInMinutes",
+			input: &UpdateTaskProtectionInput{
+				Metadata:         &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:          true,
+				ExpiresInMinutes: aws.Int32(60),
+				ExpiresIn:        time.Hour,
+			},
+			wantField: "ExpiresIn",
+		},
+		{
+			name: "should reject ExpiresIn beyond the maximum",
+			input: &UpdateTaskProtectionInput{
+				Metadata:  &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"},
+				Protect:   true,
+				ExpiresIn: 49 * time.Hour,
+			},
+			wantField: "ExpiresIn",
+			wantErr:   ErrExpirationOutOfRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(&UnreachableTestClient{t: t})
+
+			_, err := c.UpdateTaskProtection(context.Background(), tt.input)
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, tt.wantField, validationErr.Field)
+			}
+			assert.ErrorIs(t, err, ErrInvalidInput)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
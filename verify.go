@@ -0,0 +1,56 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// ErrNotConverged is returned by a Client created with WithVerification when GetTaskProtection
+// still doesn't report the requested protection once its verification retries are exhausted.
+var ErrNotConverged = errors.New("task protection has not converged")
+
+// verifyProtection re-reads the protection of the task until it reports the state requested by
+// input, retrying with the Client's verification policy.
+func (c *Client) verifyProtection(ctx context.Context, metadata *MetadataBody, input *UpdateTaskProtectionInput) error {
+	getter, ok := c.protectionClient(input.Credentials).(TaskProtectionGetter)
+	if !ok {
+		return fmt.Errorf("unable to verify protection: %w", ErrGetTaskProtectionUnsupported)
+	}
+
+	policy := *c.verification
+	if policy.Retryable == nil {
+		policy.Retryable = func(err error) bool {
+			return errors.Is(err, ErrNotConverged) || IsRetryable(err)
+		}
+	}
+
+	_, err := Do(ctx, policy, func(ctx context.Context) (struct{}, error) {
+		output, err := getter.GetTaskProtection(ctx, &ecs.GetTaskProtectionInput{
+			Cluster: aws.String(metadata.Cluster),
+			Tasks:   []string{metadata.TaskARN},
+		}, c.retriedECSOptions(&policy, input.Credentials)...)
+		if err != nil {
+			return struct{}{}, err
+		}
+
+		result, _ := NewGetResult(output).Task(metadata.TaskARN)
+		if result.Failed {
+			return struct{}{}, fmt.Errorf("%w: task %s: %s", ErrNotConverged, metadata.TaskARN, result.FailureReason)
+		}
+		if result.ProtectionEnabled != input.Protect {
+			return struct{}{}, fmt.Errorf("%w: task %s reports protection enabled: %t", ErrNotConverged,
+				metadata.TaskARN, result.ProtectionEnabled)
+		}
+
+		return struct{}{}, nil
+	})
+	if err != nil && !errors.Is(err, ErrNotConverged) {
+		return fmt.Errorf("unable to verify protection of task %s: %w", metadata.TaskARN, err)
+	}
+
+	return err
+}
@@ -0,0 +1,100 @@
+package ecstp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// EventuallyConsistentTestClient reports the protection of the last update from GetTaskProtection
+// only after stale reads reporting the previous state.
+type EventuallyConsistentTestClient struct {
+	SuccessfulTestClient
+	stale  int32
+	getErr error
+
+	reads atomic.Int32
+}
+
+func (c *EventuallyConsistentTestClient) GetTaskProtection(
+	ctx context.Context, params *ecs.GetTaskProtectionInput, optFns ...func(*ecs.Options),
+) (*ecs.GetTaskProtectionOutput, error) {
+	if c.getErr != nil {
+		return nil, c.getErr
+	}
+
+	return &ecs.GetTaskProtectionOutput{
+		ProtectedTasks: []types.ProtectedTask{{
+			TaskArn:           aws.String(params.Tasks[0]),
+			ProtectionEnabled: c.reads.Add(1) > c.stale,
+		}},
+	}, nil
+}
+
+func TestClient_UpdateTaskProtection_Verification(t *testing.T) {
+	tests := []struct {
+		name      string
+		ecsClient ECSClient
+		wantReads int32
+		wantErr   func(error) bool
+	}{
+		{
+			name:      "should verify converged protection",
+			ecsClient: &EventuallyConsistentTestClient{},
+			wantReads: 1,
+		},
+		{
+			name:      "should retry until protection converges",
+			ecsClient: &EventuallyConsistentTestClient{stale: 2},
+			wantReads: 3,
+		},
+		{
+			name:      "should fail if protection doesn't converge",
+			ecsClient: &EventuallyConsistentTestClient{stale: 5},
+			wantReads: 3,
+			wantErr: func(err error) bool {
+				return errors.Is(err, ErrNotConverged)
+			},
+		},
+		{
+			name:      "should return errors reading protection",
+			ecsClient: &EventuallyConsistentTestClient{getErr: errors.New("access denied")},
+			wantErr: func(err error) bool {
+				return err != nil && !errors.Is(err, ErrNotConverged)
+			},
+		},
+		{
+			name:      "should fail without GetTaskProtection",
+			ecsClient: &SuccessfulTestClient{},
+			wantErr: func(err error) bool {
+				return err != nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(tt.ecsClient, WithVerification(RetryPolicy{BaseDelay: time.Millisecond}))
+
+			output, err := c.UpdateTaskProtection(context.Background(), &UpdateTaskProtectionInput{
+				Metadata: &MetadataBody{Cluster: "test", TaskARN: "test_arn"},
+				Protect:  true,
+			})
+			assert.NotNil(t, output)
+			if tt.wantErr != nil {
+				assert.True(t, tt.wantErr(err), "unexpected error: %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if client, ok := tt.ecsClient.(*EventuallyConsistentTestClient); ok && tt.wantReads > 0 {
+				assert.Equal(t, tt.wantReads, client.reads.Load())
+			}
+		})
+	}
+}
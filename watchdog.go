@@ -0,0 +1,98 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Watchdog disables protection when work stalls, so a hung worker doesn't block scale-in until
+// its protection expires.
+//
+// Workers report progress with Progress. Once no progress has been reported for Threshold while
+// the task is protected, the Watchdog publishes EventWorkStalled, describing the last progress,
+// and disables protection. It fires again only after further progress.
+type Watchdog struct {
+	Manager   *Manager
+	Threshold time.Duration
+	// Interval is the time between checks. Defaults to a quarter of Threshold.
+	Interval time.Duration
+	Logger   *slog.Logger
+
+	mu           sync.Mutex
+	lastProgress time.Time
+	note         string
+	fired        bool
+}
+
+// Progress records that the worker made progress, described by note, e.g. the job or batch it
+// completed.
+func (w *Watchdog) Progress(note string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastProgress, w.note, w.fired = time.Now(), note, false
+}
+
+// Run checks for stalled work every Interval until ctx is done, returning ctx.Err().
+func (w *Watchdog) Run(ctx context.Context) error {
+	w.mu.Lock()
+	if w.lastProgress.IsZero() {
+		w.lastProgress = time.Now()
+	}
+	w.mu.Unlock()
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = w.Threshold / 4
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if err := w.check(ctx); err != nil && ctx.Err() == nil {
+			w.logger().ErrorContext(ctx, "unable to disable protection of stalled work", slog.Any("error", err))
+		}
+	}
+}
+
+// check disables protection if no progress was reported within Threshold.
+func (w *Watchdog) check(ctx context.Context) error {
+	w.mu.Lock()
+	since := time.Since(w.lastProgress)
+	if w.fired || since < w.Threshold || !w.Manager.State().Protected {
+		w.mu.Unlock()
+		return nil
+	}
+	w.fired = true
+	detail := fmt.Sprintf("no progress for %s", since.Round(time.Second))
+	if w.note != "" {
+		detail += fmt.Sprintf(", last progress: %s", w.note)
+	}
+	w.mu.Unlock()
+
+	w.logger().WarnContext(ctx, "work stalled, disabling protection", slog.String("detail", detail))
+	w.Manager.publishDetail(EventWorkStalled, w.Manager.State(), detail)
+	_, err := w.Manager.update(ctx, &UpdateTaskProtectionInput{
+		Protect: false,
+		Reason:  "work stalled: " + detail,
+	})
+
+	return err
+}
+
+func (w *Watchdog) logger() *slog.Logger {
+	if w.Logger == nil {
+		return slog.Default()
+	}
+
+	return w.Logger
+}
@@ -0,0 +1,54 @@
+package ecstp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdog(t *testing.T) {
+	m := NewManager(NewClient(&SuccessfulTestClient{}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	w := &Watchdog{Manager: m, Threshold: 50 * time.Millisecond, Interval: 5 * time.Millisecond}
+	events, cancelEvents := m.Subscribe()
+	defer cancelEvents()
+
+	_, err := m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, EventProtected, (<-events).Type)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// progress keeps the task protected
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		w.Progress(fmt.Sprintf("batch %d", i))
+	}
+	assert.True(t, m.State().Protected)
+
+	event := <-events
+	assert.Equal(t, EventWorkStalled, event.Type)
+	assert.Contains(t, event.Detail, "last progress: batch 4")
+	assert.Equal(t, EventUnprotected, (<-events).Type)
+	assert.False(t, m.State().Protected)
+
+	// the watchdog doesn't fire again without further progress
+	_, err = m.Protect(context.Background(), nil)
+	require.NoError(t, err)
+	time.Sleep(70 * time.Millisecond)
+	assert.True(t, m.State().Protected)
+}
+
+func TestWatchdog_Unprotected(t *testing.T) {
+	m := NewManager(NewClient(&UnreachableTestClient{t: t}), &MetadataBody{Cluster: "test_cluster", TaskARN: "test_arn"})
+	w := &Watchdog{Manager: m, Threshold: time.Millisecond}
+	w.Progress("")
+	time.Sleep(5 * time.Millisecond)
+
+	assert.NoError(t, w.check(context.Background()), "unprotected tasks should be left alone")
+}